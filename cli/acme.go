@@ -0,0 +1,537 @@
+// ACME (RFC 8555) certificate provisioning: tls acme --domain example.com
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	acmeProduction = "https://acme-v02.api.letsencrypt.org/directory"
+	acmeStaging    = "https://acme-staging-v02.api.letsencrypt.org/directory"
+	acmePollLimit  = 2 * time.Minute
+)
+
+type acmeOpts struct {
+	domains   []string
+	email     string
+	directory string
+	certOut   string
+	keyOut    string
+	httpPort  string
+	agreeTOS  bool
+}
+
+// acmeClient holds the account key and protocol state for one run.
+type acmeClient struct {
+	http  *http.Client
+	key   *ecdsa.PrivateKey
+	dir   map[string]interface{}
+	nonce string
+	kid   string
+}
+
+func parseACMEArgs(args []string) (acmeOpts, error) {
+	o := acmeOpts{directory: acmeProduction, httpPort: "80"}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--staging" {
+			o.directory = acmeStaging
+			continue
+		}
+		if args[i] == "--agree-tos" {
+			o.agreeTOS = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return o, fmt.Errorf("missing value for %s", args[i])
+		}
+		v := args[i+1]
+		switch args[i] {
+		case "--domain", "-d":
+			for _, d := range strings.Split(v, ",") {
+				if d = strings.TrimSpace(d); d != "" {
+					o.domains = append(o.domains, d)
+				}
+			}
+		case "--email":
+			o.email = v
+		case "--directory":
+			o.directory = v
+		case "--cert-out":
+			o.certOut = v
+		case "--key-out":
+			o.keyOut = v
+		case "--http-port":
+			o.httpPort = v
+		default:
+			return o, fmt.Errorf("unknown option: %s", args[i])
+		}
+		i++
+	}
+	if len(o.domains) == 0 {
+		return o, fmt.Errorf("at least one --domain is required")
+	}
+	if o.certOut == "" {
+		o.certOut = filepath.Join("certs", o.domains[0]+".crt")
+	}
+	if o.keyOut == "" {
+		o.keyOut = filepath.Join("certs", o.domains[0]+".key")
+	}
+	return o, nil
+}
+
+// doTLSACME obtains a certificate via the HTTP-01 challenge, writes the
+// chain and key, and points server.tls_cert/tls_key at them. The challenge
+// is answered by a temporary listener, so the port (80 by default) must be
+// free and reachable from the internet for each domain. Registering means
+// agreeing to the CA's terms of service, so it refuses without --agree-tos
+// and shows where the terms are.
+func doTLSACME(args []string) {
+	o, err := parseACMEArgs(args)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		fmt.Printf("  %sUsage: tls acme --domain example.com --agree-tos [--email you@example.com] [--staging] [--http-port 80] [--cert-out path] [--key-out path]%s\n", dim, reset)
+		exitCode = 2
+		return
+	}
+	root := projectRoot()
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(root, p)
+	}
+
+	c := &acmeClient{http: &http.Client{Timeout: 30 * time.Second}}
+	if err := c.getJSON(o.directory, &c.dir); err != nil {
+		fmt.Printf("  %s✗ ACME directory: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	terms := c.termsOfService()
+	if !o.agreeTOS {
+		fmt.Printf("  %s✗ Issuing a certificate means agreeing to the CA's terms of service%s\n", red, reset)
+		if terms != "" {
+			fmt.Printf("  %sRead them at %s, then re-run with --agree-tos%s\n", dim, terms, reset)
+		} else {
+			fmt.Printf("  %sThe CA publishes no terms URL; re-run with --agree-tos to accept its terms%s\n", dim, reset)
+		}
+		exitCode = 2
+		return
+	}
+	if terms != "" {
+		fmt.Printf("  %sAgreeing to the terms of service at %s%s\n", dim, terms, reset)
+	}
+
+	accountKey, err := loadOrCreateECKey(filepath.Join(root, ".proxycache-acme.key"))
+	if err != nil {
+		fmt.Printf("  %s✗ Account key: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	c.key = accountKey
+
+	tokens := &sync.Map{}
+	ln, err := net.Listen("tcp", ":"+o.httpPort)
+	if err != nil {
+		fmt.Printf("  %s✗ Can't listen on :%s for the HTTP-01 challenge: %s%s\n", red, o.httpPort, err, reset)
+		fmt.Printf("  %sStop whatever holds the port (e.g. the proxy) or forward port 80 and use --http-port%s\n", dim, reset)
+		exitCode = 1
+		return
+	}
+	srv := &http.Server{Handler: acmeChallengeHandler(tokens)}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	fmt.Printf("  %sRequesting certificate for %s from %s%s\n", dim, strings.Join(o.domains, ", "), o.directory, reset)
+	chain, certKey, err := c.obtain(o, tokens)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+
+	certPath, keyPath := resolve(o.certOut), resolve(o.keyOut)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(certKey)
+	if err == nil {
+		err = writeFileMkdir(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+	}
+	if err == nil {
+		err = writeFileMkdir(certPath, chain, 0644)
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ Writing cert/key: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s✓ Certificate issued%s\n", green, reset)
+	printStatusField("Cert", certPath)
+	printStatusField("Key", keyPath)
+
+	if err := setTLSPaths(o.certOut, o.keyOut); err != nil {
+		fmt.Printf("  %s✗ Can't update config: %s%s\n", red, err, reset)
+		fmt.Printf("  %sSet tls_cert and tls_key in [server] to the paths above%s\n", dim, reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s✓ Updated tls_cert/tls_key in config.toml%s\n", green, reset)
+	fmt.Printf("  %sRun 'reload' to serve the new certificate; renew with the same command before it expires ('tls check')%s\n", dim, reset)
+}
+
+// setTLSPaths points server.tls_cert/tls_key at cert and key, with the same
+// version check as other edits so a concurrent change isn't overwritten.
+func setTLSPaths(cert, key string) error {
+	version := configVersion()
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		return err
+	}
+	srvCfg, _ := cfg["server"].(map[string]interface{})
+	if srvCfg == nil {
+		srvCfg = map[string]interface{}{}
+		cfg["server"] = srvCfg
+	}
+	srvCfg["tls_cert"] = filepath.ToSlash(cert)
+	srvCfg["tls_key"] = filepath.ToSlash(key)
+	return saveConfigTOMLAt(cfg, version)
+}
+
+func acmeChallengeHandler(tokens *sync.Map) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+		if v, ok := tokens.Load(token); ok && token != r.URL.Path {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, v.(string))
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+// obtain runs account → order → authorizations → finalize → download,
+// with c.dir already fetched.
+func (c *acmeClient) obtain(o acmeOpts, tokens *sync.Map) ([]byte, *ecdsa.PrivateKey, error) {
+	account := map[string]interface{}{"termsOfServiceAgreed": o.agreeTOS}
+	if o.email != "" {
+		account["contact"] = []string{"mailto:" + o.email}
+	}
+	resp, _, err := c.post(c.url("newAccount"), account)
+	if err != nil {
+		return nil, nil, fmt.Errorf("account: %w", err)
+	}
+	c.kid = resp.Header.Get("Location")
+
+	ids := make([]map[string]string, len(o.domains))
+	for i, d := range o.domains {
+		ids[i] = map[string]string{"type": "dns", "value": d}
+	}
+	resp, body, err := c.post(c.url("newOrder"), map[string]interface{}{"identifiers": ids})
+	if err != nil {
+		return nil, nil, fmt.Errorf("order: %w", err)
+	}
+	orderURL := resp.Header.Get("Location")
+	var order struct {
+		Status         string   `json:"status"`
+		Authorizations []string `json:"authorizations"`
+		Finalize       string   `json:"finalize"`
+		Certificate    string   `json:"certificate"`
+	}
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, nil, fmt.Errorf("order: %w", err)
+	}
+
+	for _, authzURL := range order.Authorizations {
+		if err := c.authorize(authzURL, tokens); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: o.domains[0]},
+		DNSNames: o.domains,
+	}, certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, body, err = c.post(order.Finalize, map[string]string{"csr": b64(csr)}); err != nil {
+		return nil, nil, fmt.Errorf("finalize: %w", err)
+	}
+	json.Unmarshal(body, &order)
+	for deadline := time.Now().Add(acmePollLimit); order.Status != "valid"; {
+		if order.Status == "invalid" || time.Now().After(deadline) {
+			return nil, nil, fmt.Errorf("order ended as %q", order.Status)
+		}
+		time.Sleep(2 * time.Second)
+		if _, body, err = c.post(orderURL, nil); err != nil {
+			return nil, nil, fmt.Errorf("order: %w", err)
+		}
+		json.Unmarshal(body, &order)
+	}
+	if _, body, err = c.post(order.Certificate, nil); err != nil {
+		return nil, nil, fmt.Errorf("download: %w", err)
+	}
+	return body, certKey, nil
+}
+
+// authorize answers the http-01 challenge of one authorization and waits
+// for the CA to validate it.
+func (c *acmeClient) authorize(authzURL string, tokens *sync.Map) error {
+	type challenge struct {
+		Type   string `json:"type"`
+		URL    string `json:"url"`
+		Token  string `json:"token"`
+		Status string `json:"status"`
+	}
+	var authz struct {
+		Status     string            `json:"status"`
+		Identifier map[string]string `json:"identifier"`
+		Challenges []challenge       `json:"challenges"`
+	}
+	_, body, err := c.post(authzURL, nil)
+	if err != nil {
+		return fmt.Errorf("authorization: %w", err)
+	}
+	json.Unmarshal(body, &authz)
+	domain := authz.Identifier["value"]
+	if authz.Status == "valid" {
+		return nil
+	}
+	var ch *challenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == "http-01" {
+			ch = &authz.Challenges[i]
+		}
+	}
+	if ch == nil {
+		return fmt.Errorf("%s: CA offered no http-01 challenge", domain)
+	}
+	tokens.Store(ch.Token, ch.Token+"."+jwkThumbprint(&c.key.PublicKey))
+	defer tokens.Delete(ch.Token)
+
+	if _, _, err := c.post(ch.URL, map[string]interface{}{}); err != nil {
+		return fmt.Errorf("%s: challenge: %w", domain, err)
+	}
+	fmt.Printf("  %sValidating %s…%s\n", dim, domain, reset)
+	for deadline := time.Now().Add(acmePollLimit); ; {
+		time.Sleep(2 * time.Second)
+		if _, body, err = c.post(authzURL, nil); err != nil {
+			return fmt.Errorf("%s: %w", domain, err)
+		}
+		json.Unmarshal(body, &authz)
+		switch authz.Status {
+		case "valid":
+			fmt.Printf("  %s✓ %s validated%s\n", green, domain, reset)
+			return nil
+		case "invalid":
+			return fmt.Errorf("%s: validation failed: %s", domain, challengeError(body))
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s: validation timed out", domain)
+		}
+	}
+}
+
+func challengeError(body []byte) string {
+	var a struct {
+		Challenges []struct {
+			Error struct {
+				Detail string `json:"detail"`
+			} `json:"error"`
+		} `json:"challenges"`
+	}
+	json.Unmarshal(body, &a)
+	for _, ch := range a.Challenges {
+		if ch.Error.Detail != "" {
+			return ch.Error.Detail
+		}
+	}
+	return "no detail from CA"
+}
+
+// termsOfService is the directory's meta.termsOfService URL, if any.
+func (c *acmeClient) termsOfService() string {
+	meta, _ := c.dir["meta"].(map[string]interface{})
+	s, _ := meta["termsOfService"].(string)
+	return s
+}
+
+func (c *acmeClient) url(name string) string {
+	s, _ := c.dir[name].(string)
+	return s
+}
+
+func (c *acmeClient) getJSON(url string, v interface{}) error {
+	resp, err := c.http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *acmeClient) fetchNonce() error {
+	resp, err := c.http.Head(c.url("newNonce"))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	c.nonce = resp.Header.Get("Replay-Nonce")
+	if c.nonce == "" {
+		return errors.New("CA returned no nonce")
+	}
+	return nil
+}
+
+// post sends a JWS-signed request. A nil payload is a POST-as-GET. One
+// badNonce rejection is retried with the fresh nonce, as RFC 8555 allows.
+func (c *acmeClient) post(url string, payload interface{}) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		if c.nonce == "" {
+			if err := c.fetchNonce(); err != nil {
+				return nil, nil, fmt.Errorf("nonce: %w", err)
+			}
+		}
+		jws, err := c.sign(url, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := c.http.Post(url, "application/jose+json", bytes.NewReader(jws))
+		if err != nil {
+			return nil, nil, err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.nonce = resp.Header.Get("Replay-Nonce")
+		if resp.StatusCode < 300 {
+			return resp, body, nil
+		}
+		var problem struct {
+			Type   string `json:"type"`
+			Detail string `json:"detail"`
+		}
+		json.Unmarshal(body, &problem)
+		if strings.HasSuffix(problem.Type, ":badNonce") && attempt == 0 {
+			continue
+		}
+		if problem.Detail != "" {
+			return nil, nil, fmt.Errorf("%s", problem.Detail)
+		}
+		return nil, nil, fmt.Errorf("%s", resp.Status)
+	}
+}
+
+// sign builds a flattened JWS (ES256). Before the account exists the
+// public key is embedded; afterwards the account URL (kid) is used.
+func (c *acmeClient) sign(url string, payload interface{}) ([]byte, error) {
+	protected := map[string]interface{}{"alg": "ES256", "nonce": c.nonce, "url": url}
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = jwk(&c.key.PublicKey)
+	}
+	ph, _ := json.Marshal(protected)
+	pl := ""
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		pl = b64(raw)
+	}
+	signingInput := b64(ph) + "." + pl
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	c.nonce = ""
+	return json.Marshal(map[string]string{"protected": b64(ph), "payload": pl, "signature": b64(sig)})
+}
+
+func jwk(pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"crv": "P-256", "kty": "EC", "x": b64(pad32(pub.X)), "y": b64(pad32(pub.Y))}
+}
+
+// jwkThumbprint is the RFC 7638 thumbprint used in key authorizations; the
+// members must be in lexicographic order with no whitespace.
+func jwkThumbprint(pub *ecdsa.PublicKey) string {
+	k := jwk(pub)
+	canon := fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, k["crv"], k["kty"], k["x"], k["y"])
+	sum := sha256.Sum256([]byte(canon))
+	return b64(sum[:])
+}
+
+func pad32(n *big.Int) []byte {
+	b := make([]byte, 32)
+	n.FillBytes(b)
+	return b
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// loadOrCreateECKey keeps the ACME account key across runs so renewals
+// reuse the same account.
+func loadOrCreateECKey(path string) (*ecdsa.PrivateKey, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s is not PEM", path)
+		}
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		ec, ok := k.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an EC key", path)
+		}
+		return ec, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, writeFileMkdir(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+}
+
+func writeFileMkdir(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
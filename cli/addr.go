@@ -0,0 +1,108 @@
+// host:port parsing for listen, backend and admin addresses
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// splitAddr is net.SplitHostPort with an actionable error for the common
+// mistake of writing an IPv6 address without brackets.
+func splitAddr(s string) (host, port string, err error) {
+	host, port, err = net.SplitHostPort(strings.TrimSpace(s))
+	if err != nil {
+		if strings.Count(s, ":") > 1 && !strings.HasPrefix(s, "[") {
+			return "", "", fmt.Errorf("invalid address '%s': wrap IPv6 addresses in brackets, e.g. [::1]:9090", s)
+		}
+		return "", "", fmt.Errorf("invalid address '%s': expected host:port", s)
+	}
+	return host, port, nil
+}
+
+// canonicalAddr renders an address the same way however it was written:
+// IPs in their shortest form (IPv6 bracketed, zeros compressed) and host
+// names lowercased. Anything that doesn't parse is returned as is.
+func canonicalAddr(s string) string {
+	host, port, err := splitAddr(s)
+	if err != nil {
+		return s
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	} else {
+		host = strings.ToLower(host)
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// dialableAddr maps a wildcard bind address to loopback so it can be dialed.
+func dialableAddr(listen string) (string, error) {
+	host, port, err := splitAddr(listen)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	} else if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		if ip.To4() != nil {
+			host = "127.0.0.1"
+		} else {
+			host = "::1"
+		}
+	}
+	return canonicalAddr(net.JoinHostPort(host, port)), nil
+}
+
+// addrField canonicalizes an address from a JSON response for display,
+// passing non-strings through to printStatusField untouched.
+func addrField(v interface{}) interface{} {
+	if s, ok := v.(string); ok && s != "" {
+		return canonicalAddr(s)
+	}
+	return v
+}
+
+// addrKeys are the config keys holding a host:port, as "section.key". The
+// value says whether the proxy needs an IP there: the server addresses are
+// parsed as socket addresses, so a host name fails startup.
+var addrKeys = map[string]bool{
+	"server.listen_addr":    true,
+	"server.backend_addr":   true,
+	"admin_api.listen_addr": false,
+	"raw_tcp.backend_addr":  false,
+}
+
+func isAddrKey(section, key string) bool {
+	_, ok := addrKeys[section+"."+key]
+	return ok
+}
+
+// normalizeAddr checks an address key's value the way the proxy will read
+// it and returns it in canonical form. ":8080" binds every interface for a
+// listen address and means this machine for a backend; localhost becomes
+// 127.0.0.1 where the proxy needs an IP.
+func normalizeAddr(section, key, s string) (string, error) {
+	s = strings.TrimSpace(s)
+	host, port, err := splitAddr(s)
+	if err != nil {
+		return "", err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid address '%s': port must be 1-65535", s)
+	}
+	if host == "" {
+		host = "0.0.0.0"
+		if !strings.HasSuffix(key, "listen_addr") {
+			host = "127.0.0.1"
+		}
+	}
+	if addrKeys[section+"."+key] && net.ParseIP(host) == nil {
+		if !strings.EqualFold(host, "localhost") {
+			return "", fmt.Errorf("invalid address '%s': %s.%s needs an IP address, the proxy doesn't resolve host names there", s, section, key)
+		}
+		host = "127.0.0.1"
+	}
+	return canonicalAddr(net.JoinHostPort(host, port)), nil
+}
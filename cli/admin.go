@@ -0,0 +1,150 @@
+// Admin API client
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AdminClient is how commands reach the proxy's admin API. Tests set
+// adminClient to one pointed at an httptest.Server.
+type AdminClient interface {
+	Get(path string) (*http.Response, error)
+	Post(path string) (*http.Response, error)
+	Do(method, path string) (*http.Response, error)
+	// Target identifies the admin endpoint, e.g. "127.0.0.1:9090/admin"
+	Target() string
+}
+
+// Ways of sending the admin key. The proxy accepts both; auth middleware
+// in front of it usually only knows bearer tokens.
+const (
+	authAPIKey = "apikey" // X-API-Key: <key>
+	authBearer = "bearer" // Authorization: Bearer <key>
+)
+
+func parseAuthScheme(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case authAPIKey, "api-key", "x-api-key":
+		return authAPIKey, nil
+	case authBearer:
+		return authBearer, nil
+	}
+	return "", fmt.Errorf("unknown auth scheme '%s' (use bearer or apikey)", s)
+}
+
+// httpAdmin talks to an admin API over HTTP. Paths are relative to Prefix.
+// Key goes in the header Scheme names; empty means X-API-Key.
+type httpAdmin struct {
+	Addr   string
+	Key    string
+	Scheme string
+	Prefix string
+	HTTP   *http.Client
+}
+
+func (a httpAdmin) Get(path string) (*http.Response, error)  { return a.Do("GET", path) }
+func (a httpAdmin) Post(path string) (*http.Response, error) { return a.Do("POST", path) }
+func (a httpAdmin) Target() string                           { return a.Addr + a.Prefix }
+
+func (a httpAdmin) Do(method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s%s", a.Addr, a.Prefix, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	if a.Key != "" && a.Scheme == authBearer {
+		req.Header.Set("Authorization", "Bearer "+a.Key)
+	} else if a.Key != "" {
+		req.Header.Set("X-API-Key", a.Key)
+	}
+	release := acquireSlot(a.Addr)
+	defer release()
+	resp, err := a.HTTP.Do(req)
+	if err != nil {
+		return nil, adminCallError(method, path, a.Target(), err)
+	}
+	return resp, nil
+}
+
+// adminCallError wraps a failed admin call with its request and target,
+// so output covering several targets or commands says which one failed.
+// The URL the HTTP client repeats is dropped, and so is the dial wrapper,
+// which only restates the target.
+func adminCallError(method, path, target string, err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+	var op *net.OpError
+	if errors.As(err, &op) && op.Op == "dial" {
+		err = op.Err
+	}
+	return fmt.Errorf("%s %s on %s failed: %w", method, path, target, err)
+}
+
+// adminClient overrides the default client built from the global flags.
+var adminClient AdminClient
+
+func admin() AdminClient {
+	if adminClient != nil {
+		return adminClient
+	}
+	return httpAdmin{Addr: addr, Key: apiKey, Scheme: authScheme, Prefix: apiPrefix, HTTP: client}
+}
+
+func adminRequest(method, path string) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	switch method {
+	case "POST":
+		resp, err = admin().Post(path)
+	case "GET":
+		resp, err = admin().Get(path)
+	default:
+		resp, err = admin().Do(method, path)
+	}
+	if err == nil {
+		noteEndpointStatus(path, resp.StatusCode)
+	}
+	return resp, err
+}
+
+// adminRequestTo calls a profile's admin API. A profile without its own
+// auth_scheme uses the global one.
+func adminRequestTo(p profile, method, path string) (*http.Response, error) {
+	scheme := p.AuthScheme
+	if scheme == "" {
+		scheme = authScheme
+	}
+	return httpAdmin{Addr: p.Addr, Key: p.Key, Scheme: scheme, Prefix: p.Prefix, HTTP: client}.Do(method, path)
+}
+
+// adminJSON performs an admin call and decodes a JSON object body. The HTTP
+// status is returned alongside so callers can tell 404 (unsupported
+// endpoint) apart from other failures.
+func adminJSON(method, path string) (map[string]interface{}, int, error) {
+	resp, err := adminRequest(method, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, resp.StatusCode, adminCallError(method, path, admin().Target(), fmt.Errorf("invalid response: %w", err))
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := data["error"].(string)
+		if msg == "" {
+			msg = resp.Status
+		}
+		return data, resp.StatusCode, adminCallError(method, path, admin().Target(), errors.New(msg))
+	}
+	return data, resp.StatusCode, nil
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary stand in for sqlite3: with
+// PROXYCACHE_FAKE_SQLITE set it reads statements from stdin and fails on the
+// first INSERT, like sqlite3 -bail on a table missing a column. With
+// PROXYCACHE_FAKE_PROXY it plays the proxy for a reload trial start.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("PROXYCACHE_FAKE_PROXY"); mode != "" {
+		fakeProxy(mode)
+	}
+	if os.Getenv("PROXYCACHE_FAKE_SQLITE") != "" {
+		sql, _ := io.ReadAll(os.Stdin)
+		if strings.Contains(string(sql), "INSERT") {
+			fmt.Fprintln(os.Stderr, "Parse error near line 1: table metrics has no column named requests_total")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeProxy serves /ping on the admin listen_addr of the --config file, or
+// with mode "crash" dies at startup like a broken build.
+func fakeProxy(mode string) {
+	if mode == "crash" {
+		fmt.Fprintln(os.Stderr, "thread 'main' panicked at src/server.rs: boom")
+		os.Exit(101)
+	}
+	cfg, err := parseConfigFile(os.Args[len(os.Args)-1])
+	if err != nil {
+		os.Exit(1)
+	}
+	addr := getModules(cfg)["admin_api"].(map[string]interface{})["listen_addr"].(string)
+	http.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"pong":true}`)) })
+	http.ListenAndServe(addr, nil)
+	os.Exit(1)
+}
+
+// stubAdmin serves routes (path -> JSON body) as a fake admin API and makes
+// it the active admin client. Unknown paths get the proxy's 404 body.
+func stubAdmin(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(404)
+			body = `{"error":"not found"}`
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	adminClient = httpAdmin{Addr: strings.TrimPrefix(srv.URL, "http://"), HTTP: srv.Client()}
+	t.Cleanup(func() { adminClient = nil })
+	return srv
+}
+
+// captureOutput runs fn and returns what it printed, without colors.
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out.txt")
+	if err := withOutput(path, fn); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestHTTPAdminSendsKeyAndPrefix(t *testing.T) {
+	var gotPath, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotKey = r.URL.Path, r.Header.Get("X-API-Key")
+	}))
+	defer srv.Close()
+	a := httpAdmin{Addr: strings.TrimPrefix(srv.URL, "http://"), Key: "s3cret", Prefix: "/admin", HTTP: srv.Client()}
+	resp, err := a.Get("/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if gotPath != "/admin/status" || gotKey != "s3cret" {
+		t.Errorf("got path %q key %q", gotPath, gotKey)
+	}
+
+	var gotAuth string
+	bearer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey, gotAuth = r.Header.Get("X-API-Key"), r.Header.Get("Authorization")
+	}))
+	defer bearer.Close()
+	scheme, err := parseAuthScheme(" Bearer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a = httpAdmin{Addr: strings.TrimPrefix(bearer.URL, "http://"), Key: "s3cret", Scheme: scheme, HTTP: bearer.Client()}
+	if resp, err = a.Get("/status"); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if gotAuth != "Bearer s3cret" || gotKey != "" {
+		t.Errorf("bearer scheme sent Authorization %q, X-API-Key %q", gotAuth, gotKey)
+	}
+	if _, err := parseAuthScheme("basic"); err == nil {
+		t.Error("basic accepted as an auth scheme")
+	}
+}
+
+func TestDoMetrics(t *testing.T) {
+	stubAdmin(t, map[string]string{
+		"/metrics": `{"requests_total":42,"requests_ok":40,"requests_err":2,"bytes_in":2048}`,
+	})
+	out := captureOutput(t, doMetrics)
+	for _, want := range []string{"Requests", "42", "2.0 KB"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDoConnectionsUnavailable(t *testing.T) {
+	stubAdmin(t, map[string]string{
+		"/":        `{"endpoints":["/ping","/metrics"]}`,
+		"/metrics": `{}`,
+	})
+	out := captureOutput(t, doConnections)
+	if !strings.Contains(out, "endpoint unavailable on this proxy version") {
+		t.Errorf("expected unavailable note, got:\n%s", out)
+	}
+}
+
+func TestDoStatusJSON(t *testing.T) {
+	useProject(t, map[string]string{})
+	stubAdmin(t, map[string]string{
+		"/status": `{"listen":"127.0.0.1:3000","requests_total":7}`,
+	})
+	jsonOut = true
+	defer func() { jsonOut = false }()
+	out := captureOutput(t, doStatus)
+	if !strings.Contains(out, `"api": true`) || !strings.Contains(out, `"requests_total": 7`) {
+		t.Errorf("unexpected status JSON:\n%s", out)
+	}
+}
+
+func TestModsTiming(t *testing.T) {
+	stubAdmin(t, map[string]string{
+		"/modules/timing": `{"modules":[{"name":"cache","requests":10,"total_us":500,"avg_us":50.0},{"name":"proxy_core","requests":4,"total_us":8000,"avg_us":2000.0},{"name":"compression","requests":0,"total_us":0,"avg_us":0.0}]}`,
+	})
+	out := captureOutput(t, doModsTiming)
+	core, cache := strings.Index(out, "proxy_core"), strings.Index(out, "cache")
+	if core < 0 || cache < 0 || core > cache {
+		t.Fatalf("expected proxy_core listed before cache:\n%s", out)
+	}
+	if !strings.Contains(out, "94.1%") {
+		t.Errorf("missing proxy_core share:\n%s", out)
+	}
+}
+
+func TestFleetDiff(t *testing.T) {
+	newInstance := func(body string) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(srv.Close)
+		return strings.TrimPrefix(srv.URL, "http://")
+	}
+	a := newInstance(`{"server":{"listen_addr":"0.0.0.0:8080","max_connections":1000},"modules":["proxy_core","cache"],"module_config":{"cache":{"enabled":true,"ttl":60},"admin_api":{"enabled":true}}}`)
+	b := newInstance(`{"server":{"listen_addr":"0.0.0.0:8080","max_connections":500},"modules":["proxy_core","cache"],"module_config":{"cache":{"enabled":true,"ttl":60},"admin_api":{"enabled":true,"tls_key":"k.pem"}}}`)
+	useProject(t, map[string]string{
+		".proxycache-cli.toml": "[profiles.a]\naddr = \"" + a + "\"\n[profiles.b]\naddr = \"" + b + "\"\n",
+	})
+
+	out := captureOutput(t, func() { doFleetDiff(nil) })
+	for _, want := range []string{"server.max_connections", "1000", "500", "modules.admin_api.tls_key", "****", "2 key(s) differ across 2 instances"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "listen_addr") || strings.Contains(out, "ttl") {
+		t.Errorf("keys that match shouldn't be listed:\n%s", out)
+	}
+}
+
+func TestConfigStatus(t *testing.T) {
+	if got := configHash(nil); got != "cbf29ce484222325" {
+		t.Errorf("configHash(empty) = %s, want the proxy's FNV-1a 64", got)
+	}
+	const cfg = "[server]\nlisten_addr = \"127.0.0.1:3000\"\n"
+	useProject(t, map[string]string{"config.toml": cfg})
+
+	stubAdmin(t, map[string]string{"/config/hash": `{"hash":"` + configHash([]byte(cfg)) + `"}`})
+	if out := captureOutput(t, doConfigStatus); !strings.Contains(out, "config: in sync") {
+		t.Errorf("matching hash not reported in sync:\n%s", out)
+	}
+
+	stubAdmin(t, map[string]string{"/config/hash": `{"hash":"0000000000000000"}`})
+	exitCode = 0
+	if out := captureOutput(t, doConfigStatus); !strings.Contains(out, "modified since load — reload pending") || exitCode != 1 {
+		t.Errorf("stale hash not reported (exit %d):\n%s", exitCode, out)
+	}
+	exitCode = 0
+
+	const withInclude = "include = [\"modules.d/*.toml\"]\n" + cfg
+	const included = "[modules.cache]\nenabled = true\n"
+	useProject(t, map[string]string{"config.toml": withInclude, "modules.d/cache.toml": included})
+	stubAdmin(t, map[string]string{"/config/hash": `{"hash":"` + configHash([]byte(withInclude+included)) + `"}`})
+	if out := captureOutput(t, doConfigStatus); !strings.Contains(out, "config: in sync") {
+		t.Errorf("include not hashed after config.toml:\n%s", out)
+	}
+}
+
+func TestMetricsHistoryRoundTrip(t *testing.T) {
+	if _, err := findSQLite(); err != nil {
+		t.Skip(err)
+	}
+	useProject(t, map[string]string{})
+	db := filepath.Join(t.TempDir(), "history.db")
+	now := time.Now()
+	sql := historySchema() +
+		historyInsert(now.Add(-3*time.Hour), map[string]float64{"requests_total": 1}) +
+		historyInsert(now.Add(-30*time.Minute), map[string]float64{"requests_total": 40, "latency_avg_ms": 2.5})
+	cmd := exec.Command(sqliteBin, db)
+	cmd.Stdin = strings.NewReader(sql)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sqlite3: %v %s", err, out)
+	}
+	out := captureOutput(t, func() {
+		doMetricsQuery([]string{"--db", db, "--since", "2h", "--columns", "requests_total,latency_avg_ms"})
+	})
+	if !strings.Contains(out, "40") || !strings.Contains(out, "2.5") || !strings.Contains(out, "1 sample(s)") {
+		t.Errorf("expected only the recent sample:\n%s", out)
+	}
+	out = captureOutput(t, func() { doMetricsQuery([]string{"--db", db, "--columns", "nope"}) })
+	if exitCode != 2 || !strings.Contains(out, "unknown column 'nope'") {
+		t.Errorf("expected column error, exit %d:\n%s", exitCode, out)
+	}
+	exitCode = 0
+}
+
+func TestMetricsRecordStopsOnFailedInsert(t *testing.T) {
+	useProject(t, map[string]string{})
+	stubAdmin(t, map[string]string{"/metrics": `{"requests_total":5}`})
+	t.Setenv("PROXYCACHE_FAKE_SQLITE", "1")
+	orig := sqliteBin
+	sqliteBin = os.Args[0]
+	t.Cleanup(func() { sqliteBin = orig })
+
+	exitCode = 0
+	done := make(chan string)
+	go func() {
+		done <- captureOutput(t, func() { doMetricsRecord([]string{"--interval", "10ms"}) })
+	}()
+	select {
+	case out := <-done:
+		if exitCode != 1 || !strings.Contains(out, "has no column named requests_total") || !strings.Contains(out, "0 sample(s) recorded before the error") {
+			t.Errorf("exit %d, output:\n%s", exitCode, out)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("record kept going after a failed INSERT")
+	}
+	exitCode = 0
+}
+
+func TestParseTimeArg(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	for in, want := range map[string]time.Time{
+		"90m":                  now.Add(-90 * time.Minute),
+		"2024-04-30":           time.Date(2024, 4, 30, 0, 0, 0, 0, time.Local),
+		"2024-04-30 08:15":     time.Date(2024, 4, 30, 8, 15, 0, 0, time.Local),
+		"2024-04-30T08:15:00Z": time.Date(2024, 4, 30, 8, 15, 0, 0, time.UTC),
+	} {
+		got, err := parseTimeArg(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseTimeArg(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseTimeArg("yesterday", now); err == nil {
+		t.Error("expected an error for 'yesterday'")
+	}
+}
+
+func TestAdminErrorsNameCallAndTarget(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := ln.Addr().String()
+	ln.Close()
+	adminClient = httpAdmin{Addr: target, HTTP: &http.Client{Timeout: 2 * time.Second}}
+	defer func() { adminClient = nil }()
+
+	_, _, err = adminJSON("GET", "/metrics")
+	if err == nil {
+		t.Fatal("expected an error from a closed port")
+	}
+	if want := "GET /metrics on " + target + " failed: "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %q, want prefix %q", err, want)
+	}
+	var sys *os.SyscallError
+	if !errors.As(err, &sys) {
+		t.Errorf("cause not reachable through the wrap: %#v", errors.Unwrap(err))
+	}
+	if !isConnRefused(err) || !strings.Contains(connErr(err), "Start it with 'run'") {
+		t.Errorf("connErr = %q", connErr(err))
+	}
+
+	stubAdmin(t, map[string]string{"/reload": `{"error":"compile failed"}`})
+	_, _, err = adminJSON("POST", "/nope")
+	if err == nil || !strings.Contains(err.Error(), "POST /nope on ") || !strings.HasSuffix(err.Error(), "failed: not found") {
+		t.Errorf("status error = %v", err)
+	}
+}
+
+func TestMetricsGroupedByModule(t *testing.T) {
+	stubAdmin(t, map[string]string{
+		"/metrics": `{"requests_total":1234567,"requests_ok":1234000,"bytes_in":2048,"latency_avg_ms":12,"uptime_seconds":90,` +
+			`"circuit_breaker_trips":3,"circuit_breaker_rejects":40,"cache_hits":500,"cache_fill_ms":7,"new_gauge_seconds":5,` +
+			`"sources":{"circuit_breaker_trips":"circuit_breaker","circuit_breaker_rejects":"circuit_breaker","cache_hits":"cache","cache_fill_ms":"cache"}}`,
+	})
+	out := captureOutput(t, doMetrics)
+	for _, want := range []string{
+		"Global (proxy-wide)",
+		"Requests         1,234,567",
+		"Bytes In         2.0 KB",
+		"Uptime           1m 30s",
+		"New Gauge        5.0s",
+		"cache (module, 500 events)",
+		"Hits             500",
+		"Fill             7ms",
+		"circuit_breaker (module, 43 events)",
+		"Rejects          40",
+		"Trips            3",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "cache (module") > strings.Index(out, "circuit_breaker (module") {
+		t.Errorf("module groups not sorted:\n%s", out)
+	}
+
+	// Without "sources" the known circuit breaker fields still get grouped.
+	global, modules := groupMetrics(map[string]interface{}{"requests_total": 1.0, "circuit_breaker_trips": 2.0})
+	if !reflect.DeepEqual(global, []string{"requests_total"}) || len(modules) != 1 || modules[0].Module != "circuit_breaker" || modules[0].Events != 2 {
+		t.Errorf("fallback grouping: global %v, modules %+v", global, modules)
+	}
+	for v, want := range map[float64]string{0: "0", 999: "999", 1000: "1,000", -45678: "-45,678", 1.5: "1.50"} {
+		if got := formatCount(v); got != want {
+			t.Errorf("formatCount(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestModsInfo(t *testing.T) {
+	useProject(t, map[string]string{
+		"config.toml":          "[server]\nlisten_addr = \"0.0.0.0:3000\"\n\n[modules.greeter]\nenabled = true\ngreeting = \"hi\"\nstale = 1\n\n[modules.cache]\nenabled = false\nttl_seconds = 300\nmax_size = 50\n",
+		"mods/greeter.pcmod":   "# Says hello on /hello\nmod greeter\nversion 2.1\nauthor \"Ada\"\npriority 60\nconfig {\n  greeting str \"hello\"\n}\non_request {\n  respond 200 text \"hi\"\n}\non_response {\n}\n",
+		"src/modules/cache.rs": "// In-memory HTTP response cache\nimpl Module for Cache {\n    fn on_response(&self) {}\n}\n",
+	})
+	stubAdmin(t, map[string]string{
+		"/modules/timing": `{"modules":[{"name":"greeter","requests":2000,"total_us":5000,"avg_us":2.5}]}`,
+		"/modules/paused": `{"paused":["greeter"]}`,
+	})
+	out := captureOutput(t, func() { doModsInfo("greeter") })
+	for _, want := range []string{
+		"greeter (script)",
+		"Description      Says hello on /hello",
+		"Version          2.1",
+		"Author           Ada",
+		"Hooks            on_request, on_response",
+		"Priority         60",
+		"Enabled          yes",
+		"Loaded           yes, paused",
+		"over 2,000 requests",
+		"greeting hi",
+		"stale    1  (not read by this module)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	out = captureOutput(t, func() { doModsInfo("cache") })
+	for _, want := range []string{"cache (built-in)", "In-memory HTTP response cache", "Version          ?", "Build            not compiled", "Enabled          no", "no, not in the running pipeline", "ttl_seconds 300s (5m 0s) (default)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	exitCode = 0
+	out = captureOutput(t, func() { doModsInfo("greter") })
+	if exitCode != 1 || !strings.Contains(out, "Did you mean 'greeter'?") {
+		t.Errorf("exit %d, output:\n%s", exitCode, out)
+	}
+	exitCode = 0
+}
+
+func TestReloadIfChanged(t *testing.T) {
+	const cfg = "[server]\nlisten_addr = \"127.0.0.1:3000\"\n"
+	dir := useProject(t, map[string]string{
+		"config.toml": cfg,
+		"src/main.rs": "fn main() {}\n",
+	})
+	bin := filepath.Join(dir, binaryPath())
+	os.MkdirAll(filepath.Dir(bin), 0755)
+	os.WriteFile(bin, nil, 0755)
+	long := time.Now().Add(-48 * time.Hour)
+	for _, p := range []string{"Cargo.toml", "src/main.rs"} {
+		os.Chtimes(filepath.Join(dir, p), long, long)
+	}
+	os.Chtimes(bin, long.Add(time.Hour), long.Add(time.Hour))
+	pid := os.Getpid()
+
+	stubAdmin(t, map[string]string{"/config/hash": `{"hash":"` + configHash([]byte(cfg)) + `"}`})
+	if why := reloadReasons(pid, false); len(why) != 0 {
+		t.Errorf("unchanged tree gave reasons %v", why)
+	}
+
+	os.Chtimes(filepath.Join(dir, "src/main.rs"), long.Add(2*time.Hour), long.Add(2*time.Hour))
+	stubAdmin(t, map[string]string{"/config/hash": `{"hash":"0000000000000000"}`})
+	want := []string{filepath.Join("src", "main.rs") + " is newer than the binary", "config.toml or its includes changed since it was loaded"}
+	if why := reloadReasons(pid, false); !reflect.DeepEqual(why, want) {
+		t.Errorf("reasons = %v, want %v", why, want)
+	}
+	if why := reloadReasons(pid, true); !reflect.DeepEqual(why, want[1:]) {
+		t.Errorf("config-only reasons = %v, want %v", why, want[1:])
+	}
+
+	// A config-only reload settles --config-only but not a full reload
+	os.Chtimes(filepath.Join(dir, "src/main.rs"), long, long)
+	stubAdmin(t, map[string]string{"/config/hash": `{"hash":"0000000000000000","applied":"` + configHash([]byte(cfg)) + `"}`})
+	if why := reloadReasons(pid, true); len(why) != 0 {
+		t.Errorf("applied config still gave config-only reasons %v", why)
+	}
+	if why := reloadReasons(pid, false); !reflect.DeepEqual(why, want[1:]) {
+		t.Errorf("full reload reasons = %v, want %v", why, want[1:])
+	}
+
+	// Scripts and imports are read at startup or compiled in
+	stubAdmin(t, map[string]string{"/config/hash": `{"hash":"` + configHash([]byte(cfg)) + `"}`})
+	os.MkdirAll(filepath.Join(dir, "mods"), 0755)
+	os.WriteFile(filepath.Join(dir, "mods", "greeter.pcmod"), []byte("mod greeter\n"), 0644)
+	os.Chtimes(filepath.Join(dir, "mods"), long, long)
+	if why := reloadReasons(pid, false); len(why) != 1 || why[0] != filepath.Join("mods", "greeter.pcmod")+" changed since the proxy started" {
+		t.Errorf("edited script gave %v", why)
+	}
+	if why := reloadReasons(pid, true); len(why) != 0 {
+		t.Errorf("config-only reload doesn't load scripts, got %v", why)
+	}
+	os.Chtimes(filepath.Join(dir, "mods", "greeter.pcmod"), long, long)
+	os.MkdirAll(filepath.Join(dir, "imports"), 0755)
+	os.WriteFile(filepath.Join(dir, "imports", "custom.rs"), nil, 0644)
+	os.Chtimes(filepath.Join(dir, "imports", "custom.rs"), long, long)
+	if why := reloadReasons(pid, false); len(why) != 1 || !strings.HasPrefix(why[0], "imports") {
+		t.Errorf("new import gave %v", why)
+	}
+	os.RemoveAll(filepath.Join(dir, "imports"))
+
+	stubAdmin(t, map[string]string{})
+	if why := reloadReasons(pid, true); len(why) != 1 || !strings.Contains(why[0], "can't report") {
+		t.Errorf("proxy without /config/hash should still reload, got %v", why)
+	}
+}
+
+func TestPauseModule(t *testing.T) {
+	useProject(t, map[string]string{
+		"config.toml": "[modules.greeter]\nenabled = true\n\n[modules.cache]\nenabled = true\n\n[modules.proxy_core]\nenabled = true\n",
+	})
+	stubAdmin(t, map[string]string{
+		"/modules/paused":        `{"paused":[]}`,
+		"/modules/greeter/pause": `{"module":"greeter","paused":true}`,
+	})
+	if out := captureOutput(t, func() { doPauseModule("greeter", true) }); !strings.Contains(out, "greeter paused") {
+		t.Errorf("pause output:\n%s", out)
+	}
+	if out := captureOutput(t, func() { doPauseModule("cache", true) }); !strings.Contains(out, "cache isn't loaded") {
+		t.Errorf("unloaded module output:\n%s", out)
+	}
+	exitCode = 0
+	if out := captureOutput(t, func() { doPauseModule("proxy_core", true) }); exitCode != 1 || !strings.Contains(out, "can't be paused") {
+		t.Errorf("exit %d, output:\n%s", exitCode, out)
+	}
+	exitCode = 0
+}
+
+func TestModuleMetricsFromSources(t *testing.T) {
+	stubAdmin(t, map[string]string{
+		"/metrics": `{"requests_total":10,"circuit_breaker_trips":3,"circuit_breaker_rejects":1200,"sources":{"circuit_breaker_trips":"circuit_breaker","circuit_breaker_rejects":"circuit_breaker"}}`,
+	})
+	out := captureOutput(t, func() { doModuleMetrics("circuit_breaker") })
+	for _, want := range []string{"circuit_breaker Metrics", "Rejects          1,200", "Trips            3"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	exitCode = 0
+	out = captureOutput(t, func() { doModuleMetrics("cache") })
+	if exitCode != 1 || !strings.Contains(out, "Modules with metrics: circuit_breaker") {
+		t.Errorf("exit %d, output:\n%s", exitCode, out)
+	}
+	exitCode = 0
+
+	// Without "sources" the built-in map still places the cache and rate_limiter counters
+	stubAdmin(t, map[string]string{
+		"/metrics": `{"requests_total":10,"rate_limiter_rejects":7,"cache_hits":90,"cache_misses":10}`,
+	})
+	out = captureOutput(t, func() { doModuleMetrics("cache") })
+	if exitCode != 0 || !strings.Contains(out, "Hits") || !strings.Contains(out, "Misses") {
+		t.Errorf("cache: exit %d, output:\n%s", exitCode, out)
+	}
+	out = captureOutput(t, func() { doModuleMetrics("rate_limiter") })
+	if exitCode != 0 || !strings.Contains(out, "Rejects") || !strings.Contains(out, "7") {
+		t.Errorf("rate_limiter: exit %d, output:\n%s", exitCode, out)
+	}
+	exitCode = 0
+}
+
+func TestConfigWarnings(t *testing.T) {
+	stubAdmin(t, map[string]string{"/config/warnings": `{"unknown_keys":["modules.cache.ttl_second","typo"]}`})
+	exitCode = 0
+	out := captureOutput(t, doConfigWarnings)
+	if exitCode != 1 || !strings.Contains(out, "ignored 2 key(s)") || !strings.Contains(out, "• modules.cache.ttl_second") || !strings.Contains(out, "• typo") {
+		t.Errorf("exit %d, output:\n%s", exitCode, out)
+	}
+
+	stubAdmin(t, map[string]string{"/config/warnings": `{"unknown_keys":[]}`})
+	exitCode = 0
+	if out := captureOutput(t, doConfigWarnings); exitCode != 0 || !strings.Contains(out, "recognized every key") {
+		t.Errorf("clean config: exit %d, output:\n%s", exitCode, out)
+	}
+
+	stubAdmin(t, map[string]string{})
+	if out := captureOutput(t, doConfigWarnings); exitCode != 0 || strings.Contains(out, "✗") {
+		t.Errorf("older proxy should be reported as unavailable, exit %d:\n%s", exitCode, out)
+	}
+	exitCode = 0
+}
+
+func TestSnapshotRedactsLogTail(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"config.toml": "[server]\nlisten_addr = \"127.0.0.1:3000\"\n\n[modules.admin_api]\nenabled = true\napi_key_file = \"admin.key\"\n\n[modules.auth]\nclient_secret = \"hunter22\"\n",
+		"admin.key":   "k3y-from-file\n",
+		".proxycache.log": "[INFO] started with key k3y-from-file\n" +
+			"[WARN] login password=letmein from 10.0.0.1\n" +
+			"[DEBUG] headers {\"Authorization\": \"Bearer abc.def\", \"X-API-Key\": \"xyz\"}\n" +
+			"[INFO] auth upstream rejected hunter22\n",
+	})
+	stubAdmin(t, map[string]string{"/status": `{"uptime_secs":1}`})
+	file := filepath.Join(dir, "snap.json")
+	captureOutput(t, func() { doSnapshot([]string{file}) })
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"k3y-from-file", "letmein", "abc.def", "xyz", "hunter22"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("snapshot leaks %q:\n%s", leaked, data)
+		}
+	}
+	if !strings.Contains(string(data), "from 10.0.0.1") {
+		t.Errorf("log tail over-redacted:\n%s", data)
+	}
+}
+
+func TestReloadTrialStart(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"config.toml": "[server]\nlisten_addr = \"0.0.0.0:80\"\n\n[modules.admin_api]\nenabled = false\nallow_ips = [\"10.0.0.0/8\"]\n",
+	})
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PROXYCACHE_FAKE_PROXY", "ok")
+	if err := trialStart(exe, 5*time.Second); err != nil {
+		t.Fatalf("healthy build rejected: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".proxycache-trial.toml")); !os.IsNotExist(err) {
+		t.Error("trial config left behind")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.toml")); !strings.Contains(string(data), "0.0.0.0:80") {
+		t.Errorf("config.toml changed:\n%s", data)
+	}
+
+	t.Setenv("PROXYCACHE_FAKE_PROXY", "crash")
+	err = trialStart(exe, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "exited at startup") || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("crashing build: %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+// admin: who can reach the admin API — its bind address, key and IP allowlist
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// adminSection returns modules.admin_api from cfg, creating it if asked.
+func adminSection(cfg map[string]interface{}, create bool) map[string]interface{} {
+	mods := getModules(cfg)
+	if mods == nil {
+		if !create {
+			return nil
+		}
+		mods = map[string]interface{}{}
+		cfg["modules"] = mods
+	}
+	section, _ := mods["admin_api"].(map[string]interface{})
+	if section == nil && create {
+		section = map[string]interface{}{}
+		mods["admin_api"] = section
+	}
+	return section
+}
+
+func adminListenAddr(section map[string]interface{}) string {
+	if a, ok := section["listen_addr"].(string); ok {
+		return a
+	}
+	return moduleSchema["admin_api"]["listen_addr"].Default.(string)
+}
+
+func adminAllowList(section map[string]interface{}) []string {
+	list, _ := section["allow_ips"].([]interface{})
+	var out []string
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parseAllowEntry checks an allow_ips entry the way the proxy does: an IP
+// or a CIDR network. It returns the entry in canonical form.
+func parseAllowEntry(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n.String(), nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("'%s' is not an IP address or CIDR network (e.g. 10.0.0.0/8)", s)
+}
+
+// isLoopbackBind reports whether a bind address only accepts local
+// connections. Host names other than localhost count as reachable.
+func isLoopbackBind(addr string) bool {
+	host, _, err := splitAddr(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// adminExposure describes how the admin API is exposed when it's reachable
+// from other machines with neither a key nor an allowlist, or "" if it's
+// locked down.
+func adminExposure(cfg map[string]interface{}) string {
+	if !moduleEnabled(getModules(cfg), "admin_api", true) {
+		return ""
+	}
+	section := adminSection(cfg, false)
+	addr := adminListenAddr(section)
+	if isLoopbackBind(addr) || len(adminAllowList(section)) > 0 {
+		return ""
+	}
+	key, err := resolveAPIKey(section)
+	if err != nil {
+		return fmt.Sprintf("modules.admin_api listens on %s but %s: the proxy won't start the admin API until it's fixed", addr, err)
+	}
+	if key != "" {
+		return ""
+	}
+	return fmt.Sprintf("modules.admin_api listens on %s with no api_key or allow_ips: anyone who can reach it can stop or reconfigure the proxy", addr)
+}
+
+func doAdmin(args []string) {
+	switch {
+	case len(args) == 0:
+		doAdminShow()
+	case args[0] == "bind" && len(args) == 2:
+		doAdminBind(args[1])
+	case args[0] == "allow" && len(args) >= 2:
+		remove := hasFlag(args[1:], "--remove")
+		entries := stripFlag(args[1:], "--remove")
+		if len(entries) != 1 {
+			fmt.Printf("  %sUsage: admin allow [--remove] <ip|cidr>%s\n", yellow, reset)
+			exitCode = 2
+			return
+		}
+		doAdminAllow(entries[0], remove)
+	default:
+		fmt.Printf("  %sUsage: admin [bind <host:port> | allow [--remove] <ip|cidr>]%s\n", yellow, reset)
+		exitCode = 2
+	}
+}
+
+func doAdminShow() {
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	section := adminSection(cfg, false)
+	allow := adminAllowList(section)
+	key, keyErr := resolveAPIKey(section)
+	file, _ := section["api_key_file"].(string)
+	if jsonOut {
+		printJSONValue(map[string]interface{}{
+			"enabled":     moduleEnabled(getModules(cfg), "admin_api", true),
+			"listen_addr": adminListenAddr(section),
+			"loopback":    isLoopbackBind(adminListenAddr(section)),
+			"api_key":     key != "",
+			"allow_ips":   allow,
+		})
+		return
+	}
+	fmt.Printf("  %s%sAdmin API Access%s %s(modules.admin_api)%s\n", bold, cyan, reset, dim, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if !moduleEnabled(getModules(cfg), "admin_api", true) {
+		printStatusField("Enabled", red+"no"+reset)
+	}
+	bind := adminListenAddr(section)
+	if isLoopbackBind(bind) {
+		printStatusField("Bind", bind+dim+" (local only)"+reset)
+	} else {
+		printStatusField("Bind", bind+yellow+" (reachable from the network)"+reset)
+	}
+	switch {
+	case keyErr != nil:
+		printStatusField("API Key", red+keyErr.Error()+reset)
+	case file != "":
+		printStatusField("API Key", green+"from "+file+reset)
+	case key != "":
+		printStatusField("API Key", green+"set"+reset)
+	default:
+		printStatusField("API Key", dim+"none"+reset)
+	}
+	if len(allow) == 0 {
+		printStatusField("Allow IPs", dim+"any"+reset)
+	} else {
+		printStatusField("Allow IPs", strings.Join(allow, ", "))
+	}
+	if w := adminExposure(cfg); w != "" {
+		fmt.Printf("\n  %s⚠ %s%s\n", yellow, w, reset)
+		fmt.Printf("  %sSet a key with 'edit admin_api' or restrict clients with 'admin allow 10.0.0.0/8'%s\n", dim, reset)
+	}
+}
+
+func doAdminBind(addr string) {
+	addr, err := normalizeAddr("admin_api", "listen_addr", addr)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 2
+		return
+	}
+	version := configVersion()
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	section := adminSection(cfg, true)
+	old := adminListenAddr(section)
+	if old == addr {
+		fmt.Printf("  %sAdmin API already binds %s%s\n", dim, addr, reset)
+		return
+	}
+	section["listen_addr"] = addr
+	if err := saveConfigTOMLAt(cfg, version); err != nil {
+		fmt.Printf("  %s✗ Not saved: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s✓ admin_api.listen_addr: %s → %s%s\n", green, old, addr, reset)
+	if w := adminExposure(cfg); w != "" {
+		fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+	}
+	printApplyHints("admin_api", []string{"listen_addr"})
+}
+
+func doAdminAllow(entry string, remove bool) {
+	entry, err := parseAllowEntry(entry)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 2
+		return
+	}
+	version := configVersion()
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	section := adminSection(cfg, true)
+	var kept []interface{}
+	found := false
+	for _, e := range adminAllowList(section) {
+		if c, err := parseAllowEntry(e); err == nil && c == entry {
+			found = true
+			continue
+		}
+		kept = append(kept, e)
+	}
+	switch {
+	case remove && !found:
+		fmt.Printf("  %s%s is not in allow_ips%s\n", dim, entry, reset)
+		return
+	case !remove && found:
+		fmt.Printf("  %s%s is already in allow_ips%s\n", dim, entry, reset)
+		return
+	case !remove:
+		kept = append(kept, entry)
+	}
+	if kept == nil {
+		kept = []interface{}{}
+	}
+	section["allow_ips"] = kept
+	if err := saveConfigTOMLAt(cfg, version); err != nil {
+		fmt.Printf("  %s✗ Not saved: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	if remove {
+		fmt.Printf("  %s✓ admin_api.allow_ips -= %s%s\n", green, entry, reset)
+		if len(kept) == 0 {
+			fmt.Printf("  %sallow_ips is empty: any client can connect again%s\n", yellow, reset)
+		}
+	} else {
+		fmt.Printf("  %s✓ admin_api.allow_ips += %s%s\n", green, entry, reset)
+		if local, err := dialableAddr(adminListenAddr(section)); err == nil {
+			host, _, _ := splitAddr(local)
+			if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() && !allowListHas(kept, ip) {
+				fmt.Printf("  %s⚠ %s isn't allowed, so this CLI won't reach the admin API from here%s\n", yellow, host, reset)
+			}
+		}
+	}
+	printApplyHints("admin_api", []string{"allow_ips"})
+}
+
+// allowListHas reports whether ip falls in any of the allow_ips entries.
+func allowListHas(list []interface{}, ip net.IP) bool {
+	for _, v := range list {
+		s, _ := v.(string)
+		if _, n, err := net.ParseCIDR(s); err == nil && n.Contains(ip) {
+			return true
+		}
+		if e := net.ParseIP(s); e != nil && e.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// User-defined command aliases from [aliases] in .proxycache-cli.toml
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// userAliases is loaded once; nil means not loaded yet.
+var userAliases map[string]string
+
+// loadAliases reads [aliases], e.g. s = "status", mc = "metrics cache".
+// Built-in commands always win over an alias with the same name.
+func loadAliases() map[string]string {
+	if userAliases != nil {
+		return userAliases
+	}
+	userAliases = map[string]string{}
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return userAliases
+	}
+	section, _ := cfg["aliases"].(map[string]interface{})
+	for name, v := range section {
+		if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+			userAliases[name] = s
+		}
+	}
+	return userAliases
+}
+
+// expandingAlias guards against aliases that refer to other aliases.
+var expandingAlias = false
+
+// runAlias runs the line an alias stands for, with any extra arguments
+// appended. The line may itself be a chain ("stop; compile; run").
+func runAlias(cmd string, args []string) bool {
+	target, ok := loadAliases()[cmd]
+	if !ok {
+		return false
+	}
+	if expandingAlias {
+		fmt.Printf("  %s✗ Alias '%s' is used inside another alias; aliases can't be nested%s\n", red, cmd, reset)
+		exitCode = 2
+		return true
+	}
+	expandingAlias = true
+	defer func() { expandingAlias = false }()
+	line := target
+	for _, a := range args {
+		line += " " + quoteArg(a)
+	}
+	runLine(line)
+	return true
+}
+
+func printUserAliases() {
+	aliases := loadAliases()
+	if len(aliases) == 0 {
+		return
+	}
+	fmt.Printf("\n  %s%sUser Aliases%s %s(.proxycache-cli.toml, built-in commands take precedence)%s\n", bold, cyan, reset, dim, reset)
+	for _, name := range sortedStringKeys(aliases) {
+		fmt.Printf("    %s%-11s%s → %s\n", cyan, name, reset, aliases[name])
+	}
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,138 @@
+// Which config changes need a restart and which can be applied in place
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// hotKeys are the only keys POST /config/reload applies in place.
+// Everything else needs a restart: server keys bind sockets, load TLS
+// material or size thread pools, and modules read their settings once when
+// they register.
+var hotKeys = map[string]bool{
+	"server.logging":    true,
+	"server.log_level":  true,
+	"server.log_format": true,
+}
+
+// needsRestart reports whether changing key in section ("server" or a
+// module name) only takes effect after a restart.
+func needsRestart(section, key string) bool {
+	return !hotKeys[section+"."+key]
+}
+
+// printApplyHints tells the user, per changed key, whether a restart is
+// needed, then suggests the matching action.
+func printApplyHints(section string, keys []string) {
+	restart := false
+	for _, k := range keys {
+		label := k
+		if section != "server" {
+			label = section + "." + k
+		}
+		if needsRestart(section, k) {
+			restart = true
+			fmt.Printf("  %s● changed %s — requires restart%s\n", yellow, label, reset)
+		} else {
+			fmt.Printf("  %s● changed %s — can hot-apply%s\n", dim, label, reset)
+		}
+	}
+	if restart {
+		fmt.Printf("  %sRun 'reload' to restart with the new config%s\n", dim, reset)
+	} else {
+		fmt.Printf("  %sRun 'reload --config-only' to apply (no restart-only keys changed)%s\n", dim, reset)
+	}
+}
+
+// doConfigReload asks the running proxy to re-read config.toml in place via
+// POST /config/reload. There is deliberately no fallback to a restart: a
+// proxy without hot reload is left untouched.
+func doConfigReload() bool {
+	resp, err := adminOptional("POST", "/config/reload")
+	if errors.Is(err, errUnavailable) {
+		fmt.Printf("  %s✗ This proxy doesn't support hot config reload%s\n", red, reset)
+		fmt.Printf("  %sNothing was changed. Use 'reload' for a full restart.%s\n", dim, reset)
+		return false
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		return false
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var data struct {
+		Applied []string `json:"applied"`
+		Error   string   `json:"error"`
+	}
+	json.Unmarshal(body, &data)
+	if resp.StatusCode != 200 {
+		msg := data.Error
+		if msg == "" {
+			msg = resp.Status
+		}
+		fmt.Printf("  %s✗ Config rejected: %s%s\n", red, msg, reset)
+		fmt.Printf("  %sThe proxy keeps running with its current config%s\n", dim, reset)
+		return false
+	}
+	fmt.Printf("  %s✓ Config reloaded%s\n", green, reset)
+	for _, k := range data.Applied {
+		fmt.Printf("  %s● applied %s%s\n", dim, k, reset)
+	}
+	fmt.Printf("  %sKeys that require a restart take effect on the next 'reload'%s\n", dim, reset)
+	printConfigWarnings()
+	return true
+}
+
+// fetchConfigWarnings returns the keys the running proxy didn't recognize
+// when it last loaded config.toml.
+func fetchConfigWarnings() ([]string, error) {
+	resp, err := adminOptional("GET", "/config/warnings")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var data struct {
+		UnknownKeys []string `json:"unknown_keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid response from /config/warnings")
+	}
+	return data.UnknownKeys, nil
+}
+
+func doConfigWarnings() {
+	keys, err := fetchConfigWarnings()
+	if errors.Is(err, errUnavailable) {
+		printUnavailable("Config Warnings")
+		return
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		exitCode = 1
+		return
+	}
+	if len(keys) == 0 {
+		fmt.Printf("  %s✓ The proxy recognized every key in its config%s\n", green, reset)
+		return
+	}
+	fmt.Printf("  %s⚠ The proxy ignored %d key(s) on its last load:%s\n", yellow, len(keys), reset)
+	for _, k := range keys {
+		fmt.Printf("    %s• %s%s\n", yellow, k, reset)
+	}
+	fmt.Printf("  %sCheck for typos with 'config schema', or remove keys that no longer exist%s\n", dim, reset)
+	exitCode = 1
+}
+
+// printConfigWarnings is the short form shown after a reload: silent when
+// nothing was ignored or the proxy can't tell.
+func printConfigWarnings() {
+	keys, err := fetchConfigWarnings()
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	fmt.Printf("  %s⚠ Ignored unknown config key(s): %s%s\n", yellow, strings.Join(keys, ", "), reset)
+}
@@ -0,0 +1,62 @@
+// Client-side protection for a struggling proxy: polling backoff and a cap
+// on concurrent admin requests per host
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxInFlight is how many admin requests may wait on one host at a time.
+// Matches the transport's idle pool so a busy loop reuses warm connections
+// instead of opening more.
+const maxInFlight = 4
+
+// backoffFactor is how far a poll interval can widen: 16x the base, so a
+// 2s watch slows to 32s while the proxy keeps failing.
+const backoffFactor = 16
+
+// pollBackoff paces a polling loop. Each failure doubles the interval up to
+// backoffFactor times the base; each success halves it back toward the base.
+type pollBackoff struct {
+	base, cur time.Duration
+}
+
+func newPollBackoff(base time.Duration) *pollBackoff {
+	return &pollBackoff{base: base, cur: base}
+}
+
+// next records the outcome of a poll and returns the wait before the next.
+func (b *pollBackoff) next(ok bool) time.Duration {
+	if ok {
+		b.cur /= 2
+		if b.cur < b.base {
+			b.cur = b.base
+		}
+	} else {
+		b.cur *= 2
+		if max := b.base * backoffFactor; b.cur > max {
+			b.cur = max
+		}
+	}
+	return b.cur
+}
+
+var (
+	inFlightMu sync.Mutex
+	inFlight   = map[string]chan struct{}{}
+)
+
+// acquireSlot blocks until fewer than maxInFlight admin requests to host are
+// waiting for a response, and returns the release func.
+func acquireSlot(host string) func() {
+	inFlightMu.Lock()
+	slots, ok := inFlight[host]
+	if !ok {
+		slots = make(chan struct{}, maxInFlight)
+		inFlight[host] = slots
+	}
+	inFlightMu.Unlock()
+	slots <- struct{}{}
+	return func() { <-slots }
+}
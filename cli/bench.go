@@ -0,0 +1,410 @@
+// Load-test helper that drives traffic through the proxy listener
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+type benchResult struct {
+	URL         string             `json:"url"`
+	Protocol    string             `json:"protocol"`
+	Requests    int                `json:"requests"`
+	Concurrency int                `json:"concurrency"`
+	Errors      int                `json:"errors"`
+	DurationMs  float64            `json:"duration_ms"`
+	RPS         float64            `json:"requests_per_sec"`
+	LatencyMs   map[string]float64 `json:"latency_ms"`
+	Statuses    map[string]int     `json:"status_codes"`
+	// Mismatched counts responses that came back over another protocol
+	// than the one --proto asked for.
+	Mismatched int `json:"protocol_mismatch,omitempty"`
+}
+
+// benchProtos are the protocols --proto accepts, in --compare order.
+var benchProtos = []string{"http1", "http2", "http3"}
+
+// benchTransport builds a transport that only speaks proto. "" keeps the
+// default: HTTP/2 when TLS negotiates it, HTTP/1.1 otherwise. HTTP/3 goes
+// over QUIC, so its url must already point at the proxy's UDP port.
+func benchTransport(proto, scheme string, c int) (http.RoundTripper, error) {
+	if proto == "http3" {
+		if scheme != "https" {
+			return nil, errors.New("HTTP/3 needs TLS: QUIC always runs over TLS 1.3")
+		}
+		return &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http3.NextProtoH3}},
+		}, nil
+	}
+	tr := &http.Transport{
+		MaxIdleConnsPerHost: c,
+		ForceAttemptHTTP2:   true,
+		// Local proxies commonly run with self-signed certs
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	switch proto {
+	case "":
+	case "http1":
+		// a non-nil empty map turns off HTTP/2
+		tr.ForceAttemptHTTP2 = false
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		tr.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	case "http2":
+		if scheme != "https" {
+			return nil, errors.New("HTTP/2 needs TLS: the proxy only offers h2 via ALPN")
+		}
+		tr.TLSClientConfig.NextProtos = []string{"h2"}
+	}
+	return tr, nil
+}
+
+// parseBenchProto normalizes a --proto value; "?" means not recognized.
+func parseBenchProto(s string) string {
+	switch strings.ToLower(s) {
+	case "http1", "http1.1", "h1", "1.1":
+		return "http1"
+	case "http2", "h2", "2":
+		return "http2"
+	case "http3", "h3", "3":
+		return "http3"
+	}
+	return "?"
+}
+
+// benchProtoMajor is the HTTP major version a response must have for proto.
+func benchProtoMajor(proto string) int {
+	switch proto {
+	case "http1":
+		return 1
+	case "http2":
+		return 2
+	case "http3":
+		return 3
+	}
+	return 0
+}
+
+func doBench(args []string) {
+	path, n, c, proto, compare := "/", 200, 10, "", false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-n" && i+1 < len(args):
+			n, _ = strconv.Atoi(args[i+1])
+			i++
+		case args[i] == "-c" && i+1 < len(args):
+			c, _ = strconv.Atoi(args[i+1])
+			i++
+		case args[i] == "--proto" && i+1 < len(args):
+			proto = parseBenchProto(args[i+1])
+			i++
+		case args[i] == "--compare":
+			compare = true
+		case strings.HasPrefix(args[i], "/"):
+			path = args[i]
+		}
+	}
+	if n <= 0 || c <= 0 || proto == "?" || (compare && proto != "") {
+		fmt.Printf("  %sUsage: bench <path> [-n requests] [-c concurrency] [--proto http1|http2|http3 | --compare]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+	if c > n {
+		c = n
+	}
+
+	base, err := proxyBaseURL()
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		return
+	}
+	if compare {
+		doBenchCompare(base, path, n, c)
+		return
+	}
+	res, err := runBench(benchURL(base, proto)+path, n, c, proto)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+
+	if jsonOut {
+		out, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	printBenchResult(res)
+}
+
+// doBenchCompare runs the same load once per protocol, one after another so
+// runs don't compete, and prints them side by side.
+func doBenchCompare(base, path string, n, c int) {
+	type row struct {
+		Proto  string       `json:"protocol"`
+		Result *benchResult `json:"result,omitempty"`
+		Error  string       `json:"error,omitempty"`
+	}
+	var rows []row
+	for _, p := range benchProtos {
+		if !jsonOut {
+			fmt.Printf("  %sRunning %s…%s\n", dim, benchProtoLabel(p), reset)
+		}
+		res, err := runBench(benchURL(base, p)+path, n, c, p)
+		if err != nil {
+			rows = append(rows, row{Proto: p, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, row{Proto: p, Result: &res})
+	}
+	if jsonOut {
+		printJSONValue(rows)
+		return
+	}
+	fmt.Printf("\n  %s%sProtocol Comparison%s %s%s  (%d requests, %d concurrent)%s\n", bold, cyan, reset, dim, base+path, n, c, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	t := newTable("PROTOCOL", "REQ/S", "AVG", "P50", "P99", "ERRORS").alignRight(1, 2, 3, 4, 5)
+	for _, r := range rows {
+		if r.Error != "" {
+			t.row(dim+benchProtoLabel(r.Proto)+reset, dim+"—"+reset, "", "", "", "")
+			continue
+		}
+		res := r.Result
+		errs := strconv.Itoa(res.Errors + res.Mismatched)
+		if res.Errors+res.Mismatched > 0 {
+			errs = red + errs + reset
+		}
+		t.row(benchProtoLabel(r.Proto), fmt.Sprintf("%.1f", res.RPS), fmt.Sprintf("%.2fms", res.LatencyMs["avg"]),
+			fmt.Sprintf("%.2fms", res.LatencyMs["p50"]), fmt.Sprintf("%.2fms", res.LatencyMs["p99"]), errs)
+	}
+	t.print()
+	for _, r := range rows {
+		if r.Error != "" {
+			fmt.Printf("  %s%s: %s%s\n", dim, benchProtoLabel(r.Proto), r.Error, reset)
+		} else if r.Result.Mismatched > 0 {
+			fmt.Printf("  %s! %s: %d response(s) came back over another protocol%s\n", yellow, benchProtoLabel(r.Proto), r.Result.Mismatched, reset)
+		}
+	}
+}
+
+func benchProtoLabel(proto string) string {
+	return map[string]string{"http1": "HTTP/1.1", "http2": "HTTP/2", "http3": "HTTP/3"}[proto]
+}
+
+// benchURL points base at the port that serves proto: HTTP/3 listens on
+// its own UDP port when server.h3_port is set.
+func benchURL(base, proto string) string {
+	if proto != "http3" {
+		return base
+	}
+	port := 0
+	if data, _, err := adminJSON("GET", "/protocols"); err == nil {
+		if h3, ok := data["http3"].(map[string]interface{}); ok {
+			if p, ok := h3["port"].(float64); ok {
+				port = int(p)
+			}
+		}
+	} else if cfg, err := loadConfigTOML(); err == nil {
+		srv, _ := cfg["server"].(map[string]interface{})
+		if p, ok := srv["h3_port"].(int64); ok {
+			port = int(p)
+		}
+	}
+	scheme, hostport, _ := strings.Cut(base, "://")
+	host, _, err := net.SplitHostPort(hostport)
+	if port <= 0 || err != nil {
+		return base
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// proxyBaseURL resolves the proxy's client-facing URL, preferring what the
+// running proxy reports and falling back to config.toml.
+func proxyBaseURL() (string, error) {
+	scheme, listen := "", ""
+	if resp, err := adminRequest("GET", "/status"); err == nil {
+		defer resp.Body.Close()
+		var data map[string]interface{}
+		if json.NewDecoder(resp.Body).Decode(&data) == nil {
+			scheme, _ = data["scheme"].(string)
+			listen, _ = data["listen"].(string)
+		}
+	}
+	if listen == "" {
+		cfg, err := loadConfigTOML()
+		if err != nil {
+			return "", fmt.Errorf("can't determine listen address: %w", err)
+		}
+		srv, _ := cfg["server"].(map[string]interface{})
+		listen, _ = srv["listen_addr"].(string)
+		cert, _ := srv["tls_cert"].(string)
+		key, _ := srv["tls_key"].(string)
+		scheme = "http"
+		if cert != "" && key != "" {
+			scheme = "https"
+		}
+	}
+	if scheme == "" {
+		scheme = "http"
+	}
+	target, err := dialableAddr(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address '%s'", listen)
+	}
+	return scheme + "://" + target, nil
+}
+
+// runBench sends n GETs to url over c workers. With proto set, responses
+// over any other protocol count as mismatched rather than as latencies.
+func runBench(url string, n, c int, proto string) (benchResult, error) {
+	tr, err := benchTransport(proto, strings.SplitN(url, ":", 2)[0], c)
+	if err != nil {
+		return benchResult{}, err
+	}
+	bc := &http.Client{Transport: tr, Timeout: 30 * time.Second}
+	defer bc.CloseIdleConnections()
+	if cl, ok := tr.(io.Closer); ok {
+		defer cl.Close()
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, n)
+		statuses  = map[string]int{}
+		failed    = 0
+		mismatch  = 0
+		gotProto  = ""
+	)
+	wantMajor := benchProtoMajor(proto)
+	jobs := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < c; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				t := time.Now()
+				resp, err := bc.Get(url)
+				if err != nil {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					continue
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				d := time.Since(t)
+				mu.Lock()
+				gotProto = resp.Proto
+				if wantMajor != 0 && resp.ProtoMajor != wantMajor {
+					mismatch++
+				} else {
+					latencies = append(latencies, d)
+					statuses[strconv.Itoa(resp.StatusCode)]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	total := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	res := benchResult{
+		URL:         url,
+		Protocol:    gotProto,
+		Requests:    n,
+		Concurrency: c,
+		Errors:      failed,
+		DurationMs:  ms(total),
+		RPS:         float64(n) / total.Seconds(),
+		LatencyMs:   map[string]float64{},
+		Statuses:    statuses,
+		Mismatched:  mismatch,
+	}
+	if len(latencies) > 0 {
+		var sum time.Duration
+		for _, l := range latencies {
+			sum += l
+		}
+		res.LatencyMs["min"] = ms(latencies[0])
+		res.LatencyMs["avg"] = ms(sum / time.Duration(len(latencies)))
+		res.LatencyMs["p50"] = ms(percentile(latencies, 50))
+		res.LatencyMs["p90"] = ms(percentile(latencies, 90))
+		res.LatencyMs["p99"] = ms(percentile(latencies, 99))
+		res.LatencyMs["max"] = ms(latencies[len(latencies)-1])
+	}
+	return res, nil
+}
+
+// percentile expects sorted input.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	idx := (len(sorted)*p + 99) / 100
+	if idx < 1 {
+		idx = 1
+	}
+	return sorted[idx-1]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func printBenchResult(r benchResult) {
+	fmt.Printf("  %s%sBenchmark%s %s%s%s\n", bold, cyan, reset, dim, r.URL, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	printStatusField("Protocol", r.Protocol)
+	printStatusField("Requests", fmt.Sprintf("%d (%d concurrent)", r.Requests, r.Concurrency))
+	printStatusField("Duration", fmt.Sprintf("%.0fms", r.DurationMs))
+	printStatusField("Throughput", fmt.Sprintf("%.1f req/s", r.RPS))
+	if r.Errors > 0 {
+		fmt.Printf("  %s%-16s%s %s%d%s\n", cyan, "Errors", reset, red, r.Errors, reset)
+	} else {
+		printStatusField("Errors", 0)
+	}
+	if r.Mismatched > 0 {
+		fmt.Printf("  %s%-16s%s %s%d (answered over %s)%s\n", cyan, "Wrong protocol", reset, red, r.Mismatched, r.Protocol, reset)
+	}
+	if len(r.LatencyMs) > 0 {
+		fmt.Printf("\n  %s%sLatency (ms)%s\n", bold, cyan, reset)
+		fmt.Printf("  %s%s%s\n", dim, sep, reset)
+		for _, k := range []string{"min", "avg", "p50", "p90", "p99", "max"} {
+			printStatusField(k, fmt.Sprintf("%.2f", r.LatencyMs[k]))
+		}
+	}
+	if len(r.Statuses) > 0 {
+		fmt.Printf("\n  %s%sStatus Codes%s\n", bold, cyan, reset)
+		fmt.Printf("  %s%s%s\n", dim, sep, reset)
+		codes := make([]string, 0, len(r.Statuses))
+		for code := range r.Statuses {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			color := green
+			if code[0] >= '4' {
+				color = red
+			} else if code[0] == '3' {
+				color = yellow
+			}
+			fmt.Printf("  %s%-16s%s %d\n", color, code, reset, r.Statuses[code])
+		}
+	}
+}
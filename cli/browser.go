@@ -0,0 +1,64 @@
+// open / web --open: show the dashboard in the default browser
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+func webURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%s", webPort)
+}
+
+// browserCommand opens url with the platform's handler for links.
+func browserCommand(url string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		return exec.Command("open", url)
+	}
+	return exec.Command("xdg-open", url)
+}
+
+// headlessReason says why a browser opened from here wouldn't be seen, or
+// "" if it would. Over SSH it would open on the remote machine, if at all.
+func headlessReason(getenv func(string) string) string {
+	if getenv("SSH_CONNECTION") != "" || getenv("SSH_TTY") != "" {
+		return "SSH session"
+	}
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" && getenv("DISPLAY") == "" && getenv("WAYLAND_DISPLAY") == "" {
+		return "no display"
+	}
+	return ""
+}
+
+// openBrowser launches url, or prints it when there's no browser to show
+// it in.
+func openBrowser(url string) {
+	if why := headlessReason(os.Getenv); why != "" {
+		fmt.Printf("  %sNo browser to open (%s), visit%s %s%s%s\n", dim, why, reset, cyan, url, reset)
+		return
+	}
+	cmd := browserCommand(url)
+	if err := cmd.Start(); err != nil {
+		fmt.Printf("  %s! Can't launch a browser: %s%s\n", yellow, err, reset)
+		fmt.Printf("  %sVisit %s%s\n", dim, url, reset)
+		return
+	}
+	go cmd.Wait()
+	fmt.Printf("  %sOpening %s in the browser%s\n", dim, url, reset)
+}
+
+// doOpen starts the dashboard if it isn't running and opens it.
+func doOpen() {
+	if !webRunning {
+		doWeb()
+		if !webRunning {
+			return
+		}
+	}
+	openBrowser(webURL())
+}
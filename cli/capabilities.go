@@ -0,0 +1,107 @@
+// Admin endpoint capability probe, cached per target
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// errUnavailable is returned for endpoints the target proxy doesn't serve,
+// typically because it predates them.
+var errUnavailable = errors.New("endpoint unavailable on this proxy version")
+
+var (
+	capMu sync.Mutex
+	// capCache maps target (addr + prefix) -> endpoint path -> available.
+	// Paths absent from the map are unknown and get tried.
+	capCache = map[string]map[string]bool{}
+	// capProbed records targets whose "/" index has been read.
+	capProbed = map[string]bool{}
+)
+
+func capTarget() string {
+	return admin().Target()
+}
+
+// probeEndpoints reads the admin index ("GET /" → {"endpoints":[...]}) once
+// per target. A proxy that's down is retried next time; one without an
+// index is remembered so the probe isn't repeated.
+func probeEndpoints() {
+	target := capTarget()
+	capMu.Lock()
+	done := capProbed[target]
+	capMu.Unlock()
+	if done {
+		return
+	}
+	data, code, err := adminJSON("GET", "/")
+	if err != nil && code == 0 {
+		return
+	}
+	capMu.Lock()
+	defer capMu.Unlock()
+	capProbed[target] = true
+	list, _ := data["endpoints"].([]interface{})
+	for _, v := range list {
+		if p, ok := v.(string); ok {
+			capSet(target, p, true)
+		}
+	}
+}
+
+// capSet records availability; callers hold capMu.
+func capSet(target, path string, ok bool) {
+	if capCache[target] == nil {
+		capCache[target] = map[string]bool{}
+	}
+	capCache[target][path] = ok
+}
+
+// noteEndpointStatus updates the cache from an observed response.
+func noteEndpointStatus(path string, code int) {
+	if path == "/" || code == 0 {
+		return
+	}
+	capMu.Lock()
+	defer capMu.Unlock()
+	if code == http.StatusNotFound {
+		capSet(capTarget(), path, false)
+	} else if code < 300 {
+		capSet(capTarget(), path, true)
+	}
+}
+
+// endpointAvailable reports whether path is worth calling on the current
+// target. Unknown endpoints are assumed available.
+func endpointAvailable(path string) bool {
+	probeEndpoints()
+	capMu.Lock()
+	defer capMu.Unlock()
+	ok, known := capCache[capTarget()][path]
+	return !known || ok
+}
+
+// adminOptional is adminRequest for endpoints that may be missing on older
+// proxies: known-missing endpoints aren't called, and a 404 is reported as
+// errUnavailable.
+func adminOptional(method, path string) (*http.Response, error) {
+	if !endpointAvailable(path) {
+		return nil, errUnavailable
+	}
+	resp, err := adminRequest(method, path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		drainClose(resp)
+		return nil, errUnavailable
+	}
+	return resp, nil
+}
+
+// printUnavailable stubs out a section whose endpoint is missing.
+func printUnavailable(title string) {
+	fmt.Printf("  %s%s%s%s %s(%s)%s\n", bold, cyan, title, reset, dim, errUnavailable, reset)
+}
@@ -0,0 +1,152 @@
+// Command chaining: "stop; compile; run", "verify && reload", "ping || run"
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// chainStep is one command of a chained line; op is the operator that
+// joined it to the previous step ("", ";", "&&" or "||").
+type chainStep struct {
+	op   string
+	args []string
+}
+
+// chainPending is true while later steps of a chain are still to run, so
+// commands that would replace the CLI process (compile) can hold off.
+var chainPending = false
+
+// parseChain splits a line into steps on ;, && and || outside quotes.
+// Quoted runs stay together and lose their quotes.
+func parseChain(input string) ([]chainStep, error) {
+	var steps []chainStep
+	cur := chainStep{}
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	endWord := func() {
+		if inWord {
+			cur.args = append(cur.args, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endStep := func(op string) error {
+		endWord()
+		if len(cur.args) == 0 {
+			if op == ";" && cur.op != "&&" && cur.op != "||" {
+				// stray or trailing ';' is harmless
+				cur.op = op
+				return nil
+			}
+			return fmt.Errorf("missing command before '%s'", op)
+		}
+		steps = append(steps, cur)
+		cur = chainStep{op: op}
+		return nil
+	}
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ';':
+			if err := endStep(";"); err != nil {
+				return nil, err
+			}
+		case (r == '&' || r == '|') && i+1 < len(runes) && runes[i+1] == r:
+			if err := endStep(string([]rune{r, r})); err != nil {
+				return nil, err
+			}
+			i++
+		case r == ' ' || r == '\t':
+			endWord()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	endWord()
+	if len(cur.args) > 0 {
+		steps = append(steps, cur)
+	} else if cur.op == "&&" || cur.op == "||" {
+		return nil, fmt.Errorf("missing command after '%s'", cur.op)
+	}
+	if len(steps) > 0 {
+		steps[0].op = ""
+	}
+	return steps, nil
+}
+
+// runLine runs a possibly chained command line. "a && b" runs b only if a
+// succeeded (exit code 0), "a || b" only if it failed; "a; b" runs b after
+// a success and stops the chain after a failure.
+func runLine(input string) {
+	steps, err := parseChain(input)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 2
+		return
+	}
+	outerPending := chainPending
+	defer func() { chainPending = outerPending }()
+	failed := false
+	for i, st := range steps {
+		switch {
+		case i == 0:
+		case st.op == "&&" && failed, st.op == "||" && !failed:
+			continue
+		case st.op == ";" && failed:
+			fmt.Printf("  %s✗ Stopped after a failed command%s\n", red, reset)
+			return
+		}
+		exitCode = 0
+		chainPending = outerPending || i < len(steps)-1
+		runArgs(st.args)
+		failed = exitCode != 0
+	}
+}
+
+// argsLine rebuilds a command line from os.Args for runLine. A single
+// argument is taken as a whole line (proxycache-cli "stop; compile; run");
+// otherwise words are re-quoted so shell quoting survives, while a bare
+// ";", "&&", "||" or a trailing ";" (stop\; run) still chains.
+func argsLine(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	parts := make([]string, 0, len(args))
+	for _, a := range args {
+		switch {
+		case a == ";" || a == "&&" || a == "||":
+			parts = append(parts, a)
+		case strings.HasSuffix(a, ";") && !strings.ContainsAny(a, " \t"):
+			parts = append(parts, quoteArg(strings.TrimSuffix(a, ";")), ";")
+		default:
+			parts = append(parts, quoteArg(a))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteArg(a string) string {
+	if a != "" && !strings.ContainsAny(a, " \t;&|'\"") {
+		return a
+	}
+	if strings.Contains(a, "'") {
+		return "\"" + a + "\""
+	}
+	return "'" + a + "'"
+}
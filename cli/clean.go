@@ -0,0 +1,154 @@
+// clean: find and remove generated files left in the project root
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cleanPatterns match the files the CLI and proxy generate in the project
+// root, for every instance. .proxycache-cli.toml and the ACME key are
+// settings, not leftovers, and never match.
+var cleanPatterns = []string{
+	"*.toml.bak",
+	".proxycache*.log",
+	".proxycache*.err",
+	".proxycache*.pid",
+	".proxycache-web*.toml",
+}
+
+// cleanFile is one generated file. Active ones are still in use and are
+// listed but never removed.
+type cleanFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Active  string // why it's kept, "" if it can go
+}
+
+// pidAlive reports whether the PID file at path names a running process.
+func pidAlive(path string) bool {
+	pid, err := readPID(path)
+	return err == nil && isProcessRunning(pid)
+}
+
+// activeReason decides whether a generated file is in use: a PID file whose
+// process is alive, the log and .err of such a process, or the web config
+// while this CLI serves the dashboard.
+func activeReason(path string) string {
+	name := filepath.Base(path)
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	switch {
+	case strings.HasPrefix(name, ".proxycache-web"):
+		if webRunning && path == webConfigPath() {
+			return "dashboard running"
+		}
+	case strings.HasSuffix(name, ".pid"):
+		if pidAlive(path) {
+			return "proxy running"
+		}
+	case strings.HasSuffix(name, ".log"), strings.HasSuffix(name, ".err"):
+		if pidAlive(stem+".pid") || ((path == logPath() || path == errLogPath()) && pidAlive(pidPath())) {
+			return "proxy running"
+		}
+	}
+	return ""
+}
+
+// findCleanFiles lists generated files in root, plus the log, .err and PID
+// files when --log/--pid-file moved them elsewhere.
+func findCleanFiles(root string) []cleanFile {
+	seen := map[string]bool{}
+	var paths []string
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	for _, pat := range cleanPatterns {
+		matches, _ := fsys.Glob(filepath.Join(root, pat))
+		for _, m := range matches {
+			add(m)
+		}
+	}
+	for _, p := range []string{logPath(), errLogPath(), pidPath()} {
+		add(p)
+	}
+	sort.Strings(paths)
+
+	var out []cleanFile
+	for _, p := range paths {
+		info, err := fsys.Stat(p)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		out = append(out, cleanFile{Path: p, Size: info.Size(), ModTime: info.ModTime(), Active: activeReason(p)})
+	}
+	return out
+}
+
+func doClean(args []string) {
+	dryRun, yes := false, false
+	for _, a := range args {
+		switch a {
+		case "--dry-run":
+			dryRun = true
+		case "--yes", "-y":
+			yes = true
+		default:
+			fmt.Printf("  %sUsage: clean [--dry-run] [--yes]%s\n", yellow, reset)
+			exitCode = 2
+			return
+		}
+	}
+
+	files := findCleanFiles(projectRoot())
+	fmt.Printf("  %s%sGenerated Files%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if len(files) == 0 {
+		fmt.Printf("  %sNothing to clean%s\n", dim, reset)
+		return
+	}
+	var removable []cleanFile
+	var total int64
+	for _, f := range files {
+		age := "just now"
+		if d := time.Since(f.ModTime).Truncate(time.Second); d > 0 {
+			age = formatDuration(d) + " ago"
+		}
+		if f.Active != "" {
+			fmt.Printf("    %s%-28s %9s  %s  (kept: %s)%s\n", dim, displayPath(f.Path), formatBytes(f.Size), age, f.Active, reset)
+			continue
+		}
+		fmt.Printf("    %-28s %9s  %s%s%s\n", displayPath(f.Path), formatBytes(f.Size), dim, age, reset)
+		removable = append(removable, f)
+		total += f.Size
+	}
+	if len(removable) == 0 {
+		fmt.Printf("\n  %s✓ Everything listed is in use, nothing to remove%s\n", green, reset)
+		return
+	}
+	fmt.Println()
+	if dryRun {
+		fmt.Printf("  %sDry run: %d file(s), %s would be removed%s\n", yellow, len(removable), formatBytes(total), reset)
+		return
+	}
+	if !yes && !confirm(fmt.Sprintf("Remove %d file(s), %s?", len(removable), formatBytes(total))) {
+		fmt.Printf("  %sCancelled, nothing was removed%s\n", dim, reset)
+		return
+	}
+	removed := 0
+	for _, f := range removable {
+		if err := fsys.Remove(f.Path); err != nil {
+			fmt.Printf("  %s✗ %s: %s%s\n", red, displayPath(f.Path), err, reset)
+			exitCode = 1
+			continue
+		}
+		removed++
+	}
+	fmt.Printf("  %s✓ Removed %d file(s)%s\n", green, removed, reset)
+}
@@ -0,0 +1,84 @@
+// Clock skew between this machine and the proxy, from /status's
+// server_time_ms
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxClockSkew is how far the clocks may drift before status and doctor
+// warn. NTP keeps machines well inside it; past it, log lines and metric
+// samples from the two sides stop lining up.
+const maxClockSkew = 2 * time.Second
+
+// clockSkew is the proxy's clock minus ours. The proxy read its clock
+// somewhere within the round trip, so the true skew is Skew ± RTT/2.
+type clockSkew struct {
+	Skew time.Duration
+	RTT  time.Duration
+}
+
+// measureSkew compares the proxy's server_time_ms with the midpoint of the
+// request that fetched it. ok is false when the response has no
+// timestamp, as from proxies older than this field.
+func measureSkew(data map[string]interface{}, sent, received time.Time) (s clockSkew, ok bool) {
+	ms, ok := jsonNumber(data["server_time_ms"])
+	if !ok || ms <= 0 {
+		return s, false
+	}
+	s.RTT = received.Sub(sent)
+	mid := sent.Add(s.RTT / 2)
+	s.Skew = time.UnixMilli(int64(ms)).Sub(mid)
+	return s, true
+}
+
+// uncertainty is how far off Skew may be: half the round trip, plus the
+// millisecond the proxy's timestamp is truncated to.
+func (s clockSkew) uncertainty() time.Duration {
+	return s.RTT/2 + time.Millisecond
+}
+
+// field describes the skew for a status line.
+func (s clockSkew) field() string {
+	abs := s.Skew
+	if abs < 0 {
+		abs = -abs
+	}
+	margin := formatDuration(s.uncertainty())
+	switch {
+	case abs <= s.uncertainty():
+		return fmt.Sprintf("in sync (±%s)", margin)
+	case s.Skew > 0:
+		return fmt.Sprintf("proxy %s ahead (±%s)", formatDuration(abs), margin)
+	}
+	return fmt.Sprintf("proxy %s behind (±%s)", formatDuration(abs), margin)
+}
+
+// warning is non-empty when the skew is past maxClockSkew even at the
+// favourable end of the measurement's margin.
+func (s clockSkew) warning() string {
+	abs := s.Skew
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs-s.uncertainty() <= maxClockSkew {
+		return ""
+	}
+	dir := "ahead of"
+	if s.Skew < 0 {
+		dir = "behind"
+	}
+	return fmt.Sprintf("Proxy clock is %s %s this machine: its logs and metrics won't line up with local times (sync both with NTP)", formatDuration(abs), dir)
+}
+
+// fetchClockSkew times a /status call to measure the skew. ok is false if
+// the proxy is unreachable or doesn't report its time.
+func fetchClockSkew() (clockSkew, bool) {
+	sent := time.Now()
+	data, _, err := adminJSON("GET", "/status")
+	if err != nil {
+		return clockSkew{}, false
+	}
+	return measureSkew(data, sent, time.Now())
+}
@@ -0,0 +1,128 @@
+// config status: whether config.toml on disk is what the proxy loaded
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// configHash is FNV-1a 64 over the file's bytes as 16 hex digits, the same
+// hash the proxy serves from /config/hash.
+func configHash(data []byte) string {
+	h := fnv.New64a()
+	h.Write(data)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// configSourcesHash is configHash over config.toml followed by each include
+// file in merge order, as the proxy hashes them. Unreadable includes are
+// left out, as they are by the proxy.
+func configSourcesHash() (string, error) {
+	data, err := fsys.ReadFile(configPath())
+	if err != nil {
+		return "", err
+	}
+	if cfg, err := parseConfigFile(configPath()); err == nil {
+		files, _ := includeFiles(cfg)
+		for _, f := range files {
+			if b, err := fsys.ReadFile(f); err == nil {
+				data = append(data, b...)
+			}
+		}
+	}
+	return configHash(data), nil
+}
+
+// fetchLoadedHash returns the hash of config.toml and its includes as the
+// running proxy loaded them at startup, and as it last applied them, which
+// a config-only reload moves on. Proxies that only report the first give
+// it for both.
+func fetchLoadedHash() (loaded, applied string, err error) {
+	resp, err := adminOptional("GET", "/config/hash")
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	var data struct {
+		Hash    string `json:"hash"`
+		Applied string `json:"applied"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil || data.Hash == "" {
+		return "", "", fmt.Errorf("invalid response from /config/hash")
+	}
+	if data.Applied == "" {
+		data.Applied = data.Hash
+	}
+	return data.Hash, data.Applied, nil
+}
+
+// configInSync compares config.toml and its includes on disk with what the
+// proxy loaded at startup. It returns both hashes so callers can show them.
+func configInSync() (inSync bool, disk, loaded string, err error) {
+	disk, err = configSourcesHash()
+	if err != nil {
+		return false, "", "", err
+	}
+	loaded, _, err = fetchLoadedHash()
+	if err != nil {
+		return false, disk, "", err
+	}
+	return disk == loaded, disk, loaded, nil
+}
+
+// configApplied is configInSync counting config-only reloads: whether the
+// proxy has re-read the config as it is on disk.
+func configApplied() (bool, error) {
+	disk, err := configSourcesHash()
+	if err != nil {
+		return false, err
+	}
+	_, applied, err := fetchLoadedHash()
+	if err != nil {
+		return false, err
+	}
+	return disk == applied, nil
+}
+
+// printConfigSync is the one-line form shown in status: silent when the
+// proxy can't tell.
+func printConfigSync() {
+	inSync, _, _, err := configInSync()
+	if err != nil {
+		return
+	}
+	if inSync {
+		printStatusField("Config", green+"in sync"+reset)
+	} else {
+		printStatusField("Config", yellow+"modified since load — reload pending"+reset)
+	}
+}
+
+func doConfigStatus() {
+	inSync, disk, loaded, err := configInSync()
+	if errors.Is(err, errUnavailable) {
+		printUnavailable("Config Status")
+		return
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		exitCode = 1
+		return
+	}
+	if jsonOut {
+		printJSONValue(map[string]interface{}{"in_sync": inSync, "disk_hash": disk, "loaded_hash": loaded})
+		return
+	}
+	printStatusField("File", displayPath(configPath()))
+	printStatusField("On disk", disk)
+	printStatusField("Loaded", loaded)
+	if inSync {
+		fmt.Printf("  %s✓ config: in sync%s\n", green, reset)
+		return
+	}
+	fmt.Printf("  %s⚠ config: modified since load — reload pending%s\n", yellow, reset)
+	fmt.Printf("  %s'reload' to apply; 'reload --config-only' applies logging keys but doesn't count as a load%s\n", dim, reset)
+	exitCode = 1
+}
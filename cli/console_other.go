@@ -0,0 +1,30 @@
+//go:build !windows
+
+// Console setup for non-Windows terminals
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableVT is a no-op outside Windows; ANSI terminals handle escapes natively.
+func enableVT() bool {
+	return true
+}
+
+// enableRawInput is unsupported outside the Windows console; callers fall
+// back to requiring explicit arguments.
+func enableRawInput() (restore func(), ok bool) {
+	return nil, false
+}
+
+// consoleSize is the terminal's size in character cells.
+func consoleSize() (width, height int, ok bool) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 0, 0, false
+	}
+	return int(ws.Col), int(ws.Row), true
+}
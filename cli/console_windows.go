@@ -0,0 +1,51 @@
+//go:build windows
+
+// Windows console setup
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableVT turns on ANSI escape handling for the console. Older Windows
+// consoles print the escape codes literally unless this mode is set.
+func enableVT() bool {
+	h := windows.Handle(os.Stdout.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
+
+// enableRawInput switches the console to unbuffered, unechoed input with
+// VT key sequences so arrow keys can be read byte by byte. ok is false
+// when stdin isn't a console (piped or redirected).
+func enableRawInput() (restore func(), ok bool) {
+	h := windows.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return nil, false
+	}
+	raw := mode&^(windows.ENABLE_LINE_INPUT|windows.ENABLE_ECHO_INPUT|windows.ENABLE_PROCESSED_INPUT) | windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	if err := windows.SetConsoleMode(h, raw); err != nil {
+		return nil, false
+	}
+	return func() { windows.SetConsoleMode(h, mode) }, true
+}
+
+// consoleSize is the visible window of the console in character cells.
+func consoleSize() (width, height int, ok bool) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 0, 0, false
+	}
+	w := int(info.Window.Right-info.Window.Left) + 1
+	h := int(info.Window.Bottom-info.Window.Top) + 1
+	return w, h, w > 0 && h > 0
+}
@@ -0,0 +1,133 @@
+// Colored key-level diffs between two configs
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type diffKind int
+
+const (
+	diffAdded diffKind = iota
+	diffRemoved
+	diffChanged
+)
+
+// diffEntry is one key that differs, keyed by its dotted path such as
+// "server.listen_addr" or "modules.cache.ttl".
+type diffEntry struct {
+	kind     diffKind
+	key      string
+	old, new interface{}
+}
+
+// configDiff compares two parsed configs key by key. Tables are walked;
+// arrays and scalars are compared as whole values.
+func configDiff(old, new map[string]interface{}) []diffEntry {
+	var out []diffEntry
+	var walk func(prefix string, a, b map[string]interface{})
+	walk = func(prefix string, a, b map[string]interface{}) {
+		keys := map[string]bool{}
+		for k := range a {
+			keys[k] = true
+		}
+		for k := range b {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			path := prefix + k
+			av, inA := a[k]
+			bv, inB := b[k]
+			am, aTable := av.(map[string]interface{})
+			bm, bTable := bv.(map[string]interface{})
+			switch {
+			case aTable && bTable:
+				walk(path+".", am, bm)
+			case aTable && !inB:
+				walk(path+".", am, nil)
+			case bTable && !inA:
+				walk(path+".", nil, bm)
+			case !inB:
+				out = append(out, diffEntry{kind: diffRemoved, key: path, old: av})
+			case !inA:
+				out = append(out, diffEntry{kind: diffAdded, key: path, new: bv})
+			case !reflect.DeepEqual(av, bv):
+				out = append(out, diffEntry{kind: diffChanged, key: path, old: av, new: bv})
+			}
+		}
+	}
+	walk("", old, new)
+	return out
+}
+
+// printDiff renders entries as "+ key = v" in green, "- key = v" in red and
+// "~ key: old → new" in yellow. The markers carry the meaning on their own
+// when color is off. Secret values are masked unless showSecrets is set.
+func printDiff(entries []diffEntry) {
+	for _, e := range entries {
+		leaf := e.key[strings.LastIndex(e.key, ".")+1:]
+		switch e.kind {
+		case diffAdded:
+			fmt.Printf("  %s+ %s = %v%s\n", green, e.key, redactValue(leaf, e.new, showSecrets), reset)
+		case diffRemoved:
+			fmt.Printf("  %s- %s = %v%s\n", red, e.key, redactValue(leaf, e.old, showSecrets), reset)
+		case diffChanged:
+			fmt.Printf("  %s~ %s: %v → %v%s\n", yellow, e.key,
+				redactValue(leaf, e.old, showSecrets), redactValue(leaf, e.new, showSecrets), reset)
+		}
+	}
+}
+
+// diffSummary is the "2 added, 1 removed, 3 changed" footer line.
+func diffSummary(entries []diffEntry) string {
+	var n [3]int
+	for _, e := range entries {
+		n[e.kind]++
+	}
+	return fmt.Sprintf("%d added, %d removed, %d changed", n[diffAdded], n[diffRemoved], n[diffChanged])
+}
+
+// doConfigDiff shows what would change if config.toml were replaced by
+// file, the .bak left by config import by default.
+func doConfigDiff(args []string) {
+	if len(args) > 1 {
+		fmt.Printf("  %sUsage: config diff [file] [--show-secrets]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+	other := configPath() + ".bak"
+	if len(args) == 1 {
+		other = resolveRootPath(args[0])
+	}
+
+	current, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	target, err := parseConfigFile(other)
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read %s: %s%s\n", red, displayPath(other), err, reset)
+		exitCode = 1
+		return
+	}
+
+	entries := configDiff(current, target)
+	fmt.Printf("  %s%s%s → %s%s\n", bold, cyan, displayPath(configPath()), displayPath(other), reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if len(entries) == 0 {
+		fmt.Printf("  %sNo differences%s\n", dim, reset)
+		return
+	}
+	printDiff(entries)
+	fmt.Printf("\n  %s%s%s\n", dim, diffSummary(entries), reset)
+}
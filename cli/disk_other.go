@@ -0,0 +1,16 @@
+//go:build !windows
+
+// Free disk space on Unix-like systems
+package main
+
+import "golang.org/x/sys/unix"
+
+// diskFree returns the bytes available to unprivileged users and the total
+// size of the filesystem holding path.
+func diskFree(path string) (free, total uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+// Free disk space on Windows
+package main
+
+import "golang.org/x/sys/windows"
+
+// diskFree returns the bytes available to the current user and the total
+// size of the volume holding path.
+func diskFree(path string) (free, total uint64, err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var totalFree uint64
+	err = windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree)
+	return free, total, err
+}
@@ -0,0 +1,285 @@
+// Local health checks: disk space, log growth, build staleness and limits
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lowDiskBytes is the free space below which doctor and status warn.
+	lowDiskBytes = 1 << 30
+	// largeLogBytes is the size above which a log or backup file is flagged.
+	largeLogBytes = 100 << 20
+	// fdPerConn is the descriptors one proxied connection holds: the client
+	// socket and the backend socket.
+	fdPerConn = 2
+	// fdReserve covers listeners, log files and the admin API.
+	fdReserve = 64
+)
+
+// diskFiles are the files that grow while the proxy runs, reported by
+// doctor with their current size.
+func diskFiles() []string {
+	return []string{logPath(), errLogPath(), configPath() + ".bak"}
+}
+
+// diskReport is the free space on the project volume and the sizes of
+// diskFiles that exist.
+type diskReport struct {
+	Free, Total uint64
+	FreeErr     error
+	Sizes       map[string]int64
+}
+
+func checkDisk(root string) diskReport {
+	r := diskReport{Sizes: map[string]int64{}}
+	r.Free, r.Total, r.FreeErr = diskFree(root)
+	for _, path := range diskFiles() {
+		if info, err := fsys.Stat(path); err == nil {
+			r.Sizes[displayPath(path)] = info.Size()
+		}
+	}
+	return r
+}
+
+// warnings lists what in the report needs attention; empty means healthy.
+func (r diskReport) warnings() []string {
+	var out []string
+	if r.FreeErr == nil && r.Free < lowDiskBytes {
+		out = append(out, fmt.Sprintf("Low disk space: %s free", formatBytes(int64(r.Free))))
+	}
+	for _, path := range diskFiles() {
+		name := displayPath(path)
+		if size, ok := r.Sizes[name]; ok && size > largeLogBytes {
+			out = append(out, fmt.Sprintf("%s is %s, consider rotating or deleting it", name, formatBytes(size)))
+		}
+	}
+	return out
+}
+
+// newestSource returns the latest mtime among Cargo.toml, Cargo.lock, the
+// .rs files under src/ and imports/, and imports/ itself, which changes when
+// an import is added; and the file it belongs to.
+func newestSource(root string) (time.Time, string) {
+	var newest time.Time
+	var newestPath string
+	note := func(path string, mod time.Time) {
+		if mod.After(newest) {
+			newest, newestPath = mod, path
+		}
+	}
+	for _, name := range []string{"Cargo.toml", "Cargo.lock"} {
+		if info, err := fsys.Stat(filepath.Join(root, name)); err == nil {
+			note(name, info.ModTime())
+		}
+	}
+	if info, err := fsys.Stat(filepath.Join(root, "imports")); err == nil && info.IsDir() {
+		note("imports"+string(filepath.Separator), info.ModTime())
+	}
+	for _, dir := range []string{"src", "imports"} {
+		filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".rs" {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				rel, _ := filepath.Rel(root, path)
+				note(rel, info.ModTime())
+			}
+			return nil
+		})
+	}
+	return newest, newestPath
+}
+
+// staleBinary reports whether the compiled proxy is older than its newest
+// source file. A missing binary isn't stale; run says to compile anyway.
+func staleBinary(root string) (stale bool, newer string) {
+	info, err := fsys.Stat(filepath.Join(root, binaryPath()))
+	if err != nil {
+		return false, ""
+	}
+	newest, path := newestSource(root)
+	return newest.After(info.ModTime()), path
+}
+
+// connLimit compares max_connections with what the proxy's open-file
+// limit can hold. FDOK is false where there is no such limit (Windows,
+// unlimited) or it can't be read.
+type connLimit struct {
+	Max  int64
+	FDs  uint64
+	FDOK bool
+}
+
+func checkConnLimit(max int64) connLimit {
+	// A stale PID just means /proc has no entry and fdLimit falls back
+	pid, _ := readPID(pidPath())
+	fds, ok := fdLimit(pid)
+	return connLimit{Max: max, FDs: fds, FDOK: ok}
+}
+
+// supported is how many connections fit in the fd limit.
+func (c connLimit) supported() int64 {
+	if c.FDs <= fdReserve {
+		return 0
+	}
+	return int64(c.FDs-fdReserve) / fdPerConn
+}
+
+// warning is non-empty when max_connections can't be reached.
+func (c connLimit) warning() string {
+	if !c.FDOK || c.Max <= c.supported() {
+		return ""
+	}
+	return fmt.Sprintf("max_connections is %d but the open file limit (%d) only supports ~%d — raise ulimit -n to at least %d",
+		c.Max, c.FDs, c.supported(), c.Max*fdPerConn+fdReserve)
+}
+
+// fdField describes the fd limit for a status line.
+func (c connLimit) fdField() string {
+	if !c.FDOK {
+		return "no per-process limit"
+	}
+	return fmt.Sprintf("%d (supports ~%d connections)", c.FDs, c.supported())
+}
+
+// procStats is the proxy process's footprint as the OS reports it.
+// Started is zero when the start time couldn't be read.
+type procStats struct {
+	RSS     uint64
+	CPU     time.Duration
+	Started time.Time
+}
+
+// cpuField describes CPU time, with the average share of one core since
+// the process started when that's known.
+func (p procStats) cpuField(now time.Time) string {
+	up := now.Sub(p.Started)
+	if p.Started.IsZero() || up <= 0 {
+		return formatDuration(p.CPU)
+	}
+	return fmt.Sprintf("%s (%.1f%% of a core on average)", formatDuration(p.CPU), float64(p.CPU)*100/float64(up))
+}
+
+// printProcStats adds Memory and CPU lines for pid, or a dim note when the
+// OS won't say.
+func printProcStats(pid int) {
+	st, err := procUsage(pid)
+	if err != nil {
+		printStatusField("Memory", dim+"unavailable ("+err.Error()+")"+reset)
+		return
+	}
+	printStatusField("Memory", formatBytes(int64(st.RSS)))
+	printStatusField("CPU Time", st.cpuField(time.Now()))
+}
+
+// configuredMaxConns is server.max_connections from config.toml, or its
+// schema default.
+func configuredMaxConns() int64 {
+	if cfg, err := loadConfigTOML(); err == nil {
+		if srv, ok := cfg["server"].(map[string]interface{}); ok {
+			if n, ok := srv["max_connections"].(int64); ok {
+				return n
+			}
+		}
+	}
+	n, _ := serverSchema["max_connections"].Default.(int64)
+	return n
+}
+
+func doDoctor() {
+	root := projectRoot()
+	problems := 0
+
+	fmt.Printf("  %s%sDisk%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	r := checkDisk(root)
+	if r.FreeErr != nil {
+		printStatusField("Free space", fmt.Sprintf("unknown (%s)", r.FreeErr))
+	} else if r.Total > 0 {
+		printStatusField("Free space", fmt.Sprintf("%s of %s (%.0f%%)",
+			formatBytes(int64(r.Free)), formatBytes(int64(r.Total)), float64(r.Free)*100/float64(r.Total)))
+	}
+	for _, path := range diskFiles() {
+		name := displayPath(path)
+		if size, ok := r.Sizes[name]; ok {
+			printStatusField(name, formatBytes(size))
+		} else {
+			printStatusField(name, nil)
+		}
+	}
+	for _, w := range r.warnings() {
+		fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+		problems++
+	}
+
+	fmt.Printf("\n  %s%sBuild%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if info, err := fsys.Stat(filepath.Join(root, binaryPath())); err != nil {
+		printStatusField("Binary", "not built")
+		fmt.Printf("  %s⚠ No proxy binary, run 'compile'%s\n", yellow, reset)
+		problems++
+	} else {
+		printStatusField("Binary", formatTime(info.ModTime()))
+		if newest, path := newestSource(root); !newest.IsZero() {
+			printStatusField("Newest source", fmt.Sprintf("%s (%s)", formatTime(newest), path))
+		}
+		if stale, newer := staleBinary(root); stale {
+			fmt.Printf("  %s⚠ Binary is older than source (%s) — run compile%s\n", yellow, newer, reset)
+			problems++
+		}
+	}
+
+	if pid, err := readPID(pidPath()); err == nil && isProcessRunning(pid) {
+		fmt.Printf("\n  %s%sProcess%s %s(pid %d)%s\n", bold, cyan, reset, dim, pid, reset)
+		fmt.Printf("  %s%s%s\n", dim, sep, reset)
+		printProcStats(pid)
+	}
+
+	if skew, ok := fetchClockSkew(); ok {
+		fmt.Printf("\n  %s%sClock%s\n", bold, cyan, reset)
+		fmt.Printf("  %s%s%s\n", dim, sep, reset)
+		printStatusField("Skew", skew.field())
+		if w := skew.warning(); w != "" {
+			fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+			problems++
+		}
+	}
+
+	fmt.Printf("\n  %s%sLimits%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	cl := checkConnLimit(configuredMaxConns())
+	printStatusField("Max connections", cl.Max)
+	printStatusField("Open file limit", cl.fdField())
+	if w := cl.warning(); w != "" {
+		fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+		problems++
+	}
+
+	fmt.Println()
+	if problems == 0 {
+		fmt.Printf("  %s✓ No problems found%s\n", green, reset)
+		return
+	}
+	fmt.Printf("  %s⚠ %d problem(s) found%s\n", yellow, problems, reset)
+	exitCode = 1
+}
+
+// printDoctorWarnings is the short form for status: silent unless the
+// disk is low, a log has grown past largeLogBytes, the binary is stale or
+// max_connections exceeds the fd limit.
+func printDoctorWarnings() {
+	root := projectRoot()
+	for _, w := range checkDisk(root).warnings() {
+		fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+	}
+	if stale, newer := staleBinary(root); stale {
+		fmt.Printf("  %s⚠ Binary is older than source (%s) — run compile%s\n", yellow, newer, reset)
+	}
+	if w := checkConnLimit(configuredMaxConns()).warning(); w != "" {
+		fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+	}
+}
@@ -0,0 +1,130 @@
+// Duration-typed config keys: accepted as "30s"/"2m", stored as seconds
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationKeys are the integer keys the proxy reads as whole seconds,
+// keyed by section ("server" or a module name) and key. Edits may give
+// them as durations like "90s" or "2m"; they're saved as seconds because a
+// string there makes the proxy reject the value.
+var durationKeys = map[string]bool{
+	"server.client_timeout":            true,
+	"server.backend_timeout":           true,
+	"server.shutdown_timeout":          true,
+	"active_health.interval":           true,
+	"active_health.timeout":            true,
+	"cache.ttl_seconds":                true,
+	"circuit_breaker.recovery_timeout": true,
+	"raw_tcp.timeout":                  true,
+}
+
+func isDurationKey(section, key string) bool {
+	return durationKeys[section+"."+key]
+}
+
+// durationSeconds normalizes a duration key's value to whole seconds.
+// Integers are taken as seconds already; strings may be a bare number or
+// anything time.ParseDuration reads.
+func durationSeconds(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case int64:
+		if x < 0 {
+			return 0, fmt.Errorf("%d is negative", x)
+		}
+		return x, nil
+	case float64:
+		if x < 0 || x != float64(int64(x)) {
+			return 0, fmt.Errorf("%g is not a whole number of seconds", x)
+		}
+		return int64(x), nil
+	case string:
+		s := strings.TrimSpace(x)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+			return n, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a duration (e.g. 30s, 2m, 1h30m)", x)
+		}
+		if d < 0 {
+			return 0, fmt.Errorf("%q is negative", x)
+		}
+		if d%time.Second != 0 {
+			return 0, fmt.Errorf("%q isn't a whole number of seconds, which is all the proxy takes", x)
+		}
+		return int64(d / time.Second), nil
+	}
+	return 0, fmt.Errorf("expected seconds or a duration like 30s, got %v", v)
+}
+
+// formatConfigDuration renders a duration key's seconds the same way
+// everywhere: "30s", or "300s (5m 0s)" once it's a minute or more.
+// Anything that isn't valid seconds is shown as it is.
+func formatConfigDuration(v interface{}) string {
+	if _, isStr := v.(string); isStr {
+		return fmt.Sprintf("%v", v)
+	}
+	secs, err := durationSeconds(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	if secs < 60 {
+		return fmt.Sprintf("%ds", secs)
+	}
+	return fmt.Sprintf("%ds (%s)", secs, formatDuration(time.Duration(secs)*time.Second))
+}
+
+// withDurations returns a copy of section for display with its duration
+// keys formatted by formatConfigDuration.
+func withDurations(name string, section map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(section))
+	for k, v := range section {
+		if isDurationKey(name, k) {
+			v = formatConfigDuration(v)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// durationIssues reports duration keys in cfg holding something other
+// than whole seconds, which the proxy rejects.
+func durationIssues(cfg map[string]interface{}) []string {
+	var out []string
+	check := func(name string, section map[string]interface{}) {
+		for _, k := range sortedKeys(section) {
+			if !isDurationKey(name, k) {
+				continue
+			}
+			v := section[k]
+			secs, err := durationSeconds(v)
+			if _, isInt := v.(int64); isInt && err == nil {
+				continue
+			}
+			key := name + "." + k
+			if name != "server" {
+				key = "modules." + key
+			}
+			if err != nil {
+				out = append(out, fmt.Sprintf("%s: %s", key, err))
+			} else {
+				out = append(out, fmt.Sprintf("%s = %q: the proxy expects whole seconds, use %d", key, fmt.Sprint(v), secs))
+			}
+		}
+	}
+	if srv, ok := cfg["server"].(map[string]interface{}); ok {
+		check("server", srv)
+	}
+	mods := getModules(cfg)
+	for _, name := range sortedKeys(mods) {
+		if section, ok := mods[name].(map[string]interface{}); ok {
+			check(name, section)
+		}
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+// Line input for the interactive edit loop, with Tab completion of keys
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var errEditCancelled = errors.New("cancelled")
+
+// editKeys is what Tab completes against while editing a section: the keys
+// already set plus the documented ones.
+func editKeys(name string, section map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for k := range section {
+		seen[k] = true
+		keys = append(keys, k)
+	}
+	for k := range sectionSchema(name) {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// completeEditLine completes the key being typed in line, which is either
+// the start of key=value or the argument of del/reset. It returns the
+// completed line and, when the prefix is still ambiguous, the candidates.
+func completeEditLine(line string, keys []string) (string, []string) {
+	if strings.Contains(line, "=") {
+		return line, nil
+	}
+	word := strings.TrimLeft(line, " ")
+	head, isArg := line[:len(line)-len(word)], false
+	if f := strings.Fields(word); len(f) >= 1 && (f[0] == "del" || f[0] == "reset") && strings.Contains(word, " ") {
+		i := strings.LastIndex(line, " ")
+		head, word, isArg = line[:i+1], line[i+1:], true
+	} else if strings.Contains(word, " ") {
+		return line, nil
+	}
+	var matches []string
+	for _, k := range keys {
+		if strings.HasPrefix(k, word) {
+			matches = append(matches, k)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return line, nil
+	case 1:
+		if isArg {
+			return head + matches[0], nil
+		}
+		return head + matches[0] + "=", nil
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(word) {
+		return head + common, nil
+	}
+	return line, matches
+}
+
+// editReader reads the lines typed at the edit prompt. In a console it
+// reads keys one at a time so Tab can complete; otherwise it falls back to
+// plain line reads.
+type editReader struct {
+	keys []string
+	sc   *bufio.Scanner
+	raw  bool
+}
+
+func newEditReader(keys []string) *editReader {
+	r := &editReader{keys: keys, sc: bufio.NewScanner(os.Stdin)}
+	if restore, ok := enableRawInput(); ok {
+		restore()
+		r.raw = true
+	}
+	return r
+}
+
+// readLine prints prompt and returns the line typed. ok is false at the
+// end of input; Ctrl+C returns errEditCancelled.
+func (r *editReader) readLine(prompt string) (line string, ok bool, err error) {
+	fmt.Print(prompt)
+	if !r.raw {
+		if !r.sc.Scan() {
+			return "", false, nil
+		}
+		return r.sc.Text(), true, nil
+	}
+	restore, raw := enableRawInput()
+	if !raw {
+		r.raw = false
+		return r.readLine("")
+	}
+	defer restore()
+
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			fmt.Println()
+			return line, line != "", nil
+		}
+		key := string(buf[:n])
+		switch {
+		case key == "\r" || key == "\n" || key == "\r\n":
+			fmt.Println()
+			return line, true, nil
+		case key == "\x03":
+			fmt.Println()
+			return "", false, errEditCancelled
+		case key == "\x04" && line == "":
+			fmt.Println()
+			return "", false, nil
+		case key == "\x7f" || key == "\b":
+			if line != "" {
+				_, size := utf8.DecodeLastRuneInString(line)
+				line = line[:len(line)-size]
+				fmt.Print("\b \b")
+			}
+		case key == "\t":
+			completed, matches := completeEditLine(line, r.keys)
+			if len(matches) > 0 {
+				fmt.Printf("\n    %s%s%s\n%s%s", dim, strings.Join(matches, "  "), reset, prompt, completed)
+			} else {
+				fmt.Print(completed[len(line):])
+			}
+			line = completed
+		case strings.HasPrefix(key, "\x1b"):
+			// arrow and other escape keys aren't line editing we support
+		default:
+			// a paste arrives as one read; its first newline submits
+			nl := strings.IndexAny(key, "\r\n")
+			if nl >= 0 {
+				key = key[:nl]
+			}
+			key = strings.Map(func(c rune) rune {
+				if c < ' ' {
+					return -1
+				}
+				return c
+			}, key)
+			line += key
+			fmt.Print(key)
+			if nl >= 0 {
+				fmt.Println()
+				return line, true, nil
+			}
+		}
+	}
+}
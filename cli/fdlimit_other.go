@@ -0,0 +1,39 @@
+//go:build !windows
+
+// Open file limit on Unix-like systems
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// fdLimit returns the soft open-file limit of process pid, read from
+// /proc where available. Without /proc, or with no pid, it falls back to
+// this process's own limit, which a proxy started by 'run' inherits.
+func fdLimit(pid int) (uint64, bool) {
+	if pid > 0 {
+		if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", pid)); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if !strings.HasPrefix(line, "Max open files") {
+					continue
+				}
+				fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+				if len(fields) == 0 || fields[0] == "unlimited" {
+					return 0, false
+				}
+				n, err := strconv.ParseUint(fields[0], 10, 64)
+				return n, err == nil
+			}
+		}
+	}
+	var rl unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rl); err != nil || rl.Cur == unix.RLIM_INFINITY {
+		return 0, false
+	}
+	return uint64(rl.Cur), true
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+// Open file limit on Windows
+package main
+
+// fdLimit reports no limit: Windows sockets are kernel handles, capped per
+// process at roughly 16 million rather than by a ulimit-style setting.
+func fdLimit(pid int) (uint64, bool) {
+	return 0, false
+}
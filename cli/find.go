@@ -0,0 +1,90 @@
+// config find: search section names, keys and values
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// doConfigFind prints every key in [server] and the module sections whose
+// section name, key or value matches pattern, as section.key = value.
+// Matching is a case-insensitive substring unless --regex is given. Secret
+// values are matched in their masked form so a search can't probe them.
+func doConfigFind(args []string) {
+	useRegex := hasFlag(args, "--regex") || hasFlag(args, "-r")
+	args = stripFlag(stripFlag(args, "--regex"), "-r")
+	if len(args) != 1 {
+		fmt.Printf("  %sUsage: config find <pattern> [--regex] [--show-secrets]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+
+	match := func(s string) bool { return strings.Contains(strings.ToLower(s), strings.ToLower(args[0])) }
+	if useRegex {
+		re, err := regexp.Compile("(?i)" + args[0])
+		if err != nil {
+			fmt.Printf("  %s✗ Invalid pattern: %s%s\n", red, err, reset)
+			exitCode = 2
+			return
+		}
+		match = re.MatchString
+	}
+
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+
+	sections := map[string]map[string]interface{}{}
+	if s, ok := cfg["server"].(map[string]interface{}); ok {
+		sections["server"] = s
+	}
+	for name, m := range getModules(cfg) {
+		if mod, ok := m.(map[string]interface{}); ok {
+			sections[name] = mod
+		}
+	}
+
+	found := 0
+	for _, name := range sortedSectionNames(sections) {
+		section := sections[name]
+		keys := make([]string, 0, len(section))
+		for k := range section {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := fmt.Sprintf("%v", redactValue(k, section[k], showSecrets))
+			if match(name) || match(k) || match(v) {
+				fmt.Printf("  %s%s.%s%s = %v\n", cyan, name, k, reset, v)
+				found++
+			}
+		}
+	}
+
+	if found == 0 {
+		fmt.Printf("  %sNo matches for '%s'%s\n", dim, args[0], reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s%d match(es)%s\n", dim, found, reset)
+}
+
+// sortedSectionNames lists server first, then modules alphabetically.
+func sortedSectionNames(sections map[string]map[string]interface{}) []string {
+	var names []string
+	for name := range sections {
+		if name != "server" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := sections["server"]; ok {
+		names = append([]string{"server"}, names...)
+	}
+	return names
+}
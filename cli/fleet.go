@@ -0,0 +1,99 @@
+// Fleet overview across all profiles
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+type fleetRow struct {
+	profile profile
+	up      bool
+	err     string
+	data    map[string]interface{}
+}
+
+func doFleet() {
+	profiles, err := loadProfiles()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read .proxycache-cli.toml: %s%s\n", red, err, reset)
+		return
+	}
+	if len(profiles) == 0 {
+		fmt.Printf("  %s! No profiles configured%s\n", yellow, reset)
+		fmt.Printf("  %sAdd [profiles.<name>] with addr (and key) to .proxycache-cli.toml%s\n", dim, reset)
+		return
+	}
+
+	rows := make([]fleetRow, len(profiles))
+	var wg sync.WaitGroup
+	for i, p := range profiles {
+		wg.Add(1)
+		go func(i int, p profile) {
+			defer wg.Done()
+			rows[i] = fetchFleet(p, "/status")
+		}(i, p)
+	}
+	wg.Wait()
+
+	t := newTable("NAME", "HOST", "STATE", "REQ/S", "ERR%", "CONNS").alignRight(3, 4)
+	for _, r := range rows {
+		if !r.up {
+			t.row(red+r.profile.Name+reset, red+canonicalAddr(r.profile.Addr)+reset, red+"down"+reset, "", "", dim+r.err+reset)
+			continue
+		}
+		rps, errPct := fleetRates(r.data)
+		color := green
+		if errPct >= 5 {
+			color = yellow
+		}
+		conns := fmt.Sprintf("%v/%v", r.data["active_connections"], r.data["max_connections"])
+		cells := []string{r.profile.Name, canonicalAddr(r.profile.Addr), "up", fmt.Sprintf("%.1f", rps), fmt.Sprintf("%.1f%%", errPct), conns}
+		for i := range cells {
+			cells[i] = color + cells[i] + reset
+		}
+		t.row(cells...)
+	}
+	t.print()
+}
+
+// fetchFleet GETs an admin endpoint from one profile. A profile that
+// can't be reached or answers with an error comes back with up unset.
+func fetchFleet(p profile, path string) fleetRow {
+	row := fleetRow{profile: p}
+	resp, err := adminRequestTo(p, "GET", path)
+	if err != nil {
+		row.err = connErr(err)
+		return row
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		row.err = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return row
+	}
+	if err := json.Unmarshal(body, &row.data); err != nil {
+		row.err = "invalid " + strings.TrimPrefix(path, "/") + " response"
+		return row
+	}
+	row.up = true
+	return row
+}
+
+// fleetRates derives average requests/sec and error percentage from the
+// cumulative counters in /status.
+func fleetRates(d map[string]interface{}) (rps, errPct float64) {
+	total, _ := d["requests_total"].(float64)
+	errs, _ := d["requests_err"].(float64)
+	uptime, _ := d["uptime_seconds"].(float64)
+	if uptime > 0 {
+		rps = total / uptime
+	}
+	if total > 0 {
+		errPct = errs / total * 100
+	}
+	return
+}
@@ -0,0 +1,169 @@
+// fleet diff: config keys that differ between the profiles' live configs
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fleetConfigKeys flattens a /config response to dotted keys:
+// server.<key>, modules.<module>.<key> from the settings the proxy loaded,
+// and "modules" for the list of loaded modules. Proxies from before
+// module_config was added only contribute server keys and the list.
+func fleetConfigKeys(data map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", sub)
+				continue
+			}
+			out[prefix+k] = v
+		}
+	}
+	if srv, ok := data["server"].(map[string]interface{}); ok {
+		walk("server.", srv)
+	}
+	if mods, ok := data["module_config"].(map[string]interface{}); ok {
+		walk("modules.", mods)
+	}
+	if list, ok := data["modules"]; ok {
+		out["modules"] = list
+	}
+	return out
+}
+
+// fleetDrift returns the keys whose value isn't the same on every config,
+// sorted. A key missing from some configs counts as differing.
+func fleetDrift(configs []map[string]interface{}) []string {
+	seen := map[string]bool{}
+	for _, c := range configs {
+		for k := range c {
+			seen[k] = true
+		}
+	}
+	var out []string
+	for k := range seen {
+		first, inFirst := configs[0][k]
+		for _, c := range configs[1:] {
+			v, in := c[k]
+			if in != inFirst || !reflect.DeepEqual(v, first) {
+				out = append(out, k)
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// fleetCell renders one value in the matrix. Loaded-module lists are shown
+// as names only.
+func fleetCell(key string, v interface{}) string {
+	if list, ok := v.([]interface{}); ok && key == "modules" {
+		names := make([]string, 0, len(list))
+		for _, m := range list {
+			if mm, ok := m.(map[string]interface{}); ok {
+				m = mm["name"]
+			}
+			names = append(names, fmt.Sprint(m))
+		}
+		return strings.Join(names, ",")
+	}
+	leaf := key[strings.LastIndex(key, ".")+1:]
+	return fmt.Sprint(redactValue(leaf, v, showSecrets))
+}
+
+func doFleetDiff(args []string) {
+	if len(args) > 0 {
+		fmt.Printf("  %sUsage: fleet diff [--show-secrets]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+	profiles, err := loadProfiles()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read .proxycache-cli.toml: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	if len(profiles) < 2 {
+		fmt.Printf("  %s! fleet diff needs at least two profiles%s\n", yellow, reset)
+		fmt.Printf("  %sAdd [profiles.<name>] with addr (and key) to .proxycache-cli.toml%s\n", dim, reset)
+		return
+	}
+
+	rows := make([]fleetRow, len(profiles))
+	var wg sync.WaitGroup
+	for i, p := range profiles {
+		wg.Add(1)
+		go func(i int, p profile) {
+			defer wg.Done()
+			rows[i] = fetchFleet(p, "/config")
+		}(i, p)
+	}
+	wg.Wait()
+
+	var up []fleetRow
+	var configs []map[string]interface{}
+	for _, r := range rows {
+		if !r.up {
+			fmt.Printf("  %s✗ %s: %s%s\n", red, r.profile.Name, r.err, reset)
+			exitCode = 1
+			continue
+		}
+		up = append(up, r)
+		configs = append(configs, fleetConfigKeys(r.data))
+	}
+	if len(up) < 2 {
+		fmt.Printf("  %s! Fewer than two profiles reachable, nothing to compare%s\n", yellow, reset)
+		return
+	}
+
+	drift := fleetDrift(configs)
+	if len(drift) == 0 {
+		fmt.Printf("  %s✓ No drift: %d instances share the same config%s\n", green, len(up), reset)
+		return
+	}
+
+	header := []string{"KEY"}
+	for _, r := range up {
+		header = append(header, r.profile.Name)
+	}
+	t := newTable(header...)
+	for _, k := range drift {
+		cells := []string{k}
+		// the most common value is plain, the outliers are highlighted
+		counts := map[string]int{}
+		values := make([]string, len(configs))
+		for i, c := range configs {
+			if v, ok := c[k]; ok {
+				values[i] = fleetCell(k, v)
+				counts[values[i]]++
+			}
+		}
+		common, most := "", 0
+		for i := range configs {
+			if _, ok := configs[i][k]; ok && counts[values[i]] > most {
+				common, most = values[i], counts[values[i]]
+			}
+		}
+		for i, c := range configs {
+			_, ok := c[k]
+			switch {
+			case !ok:
+				cells = append(cells, dim+"—"+reset)
+			case values[i] == common && most > 1:
+				cells = append(cells, values[i])
+			default:
+				cells = append(cells, yellow+values[i]+reset)
+			}
+		}
+		t.row(cells...)
+	}
+	t.print()
+	fmt.Printf("\n  %s%d key(s) differ across %d instances; — means not set%s\n", dim, len(drift), len(up), reset)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memFS is an in-memory fileSystem. Files are keyed by cleaned path.
+type memFS struct {
+	files map[string][]byte
+	mtime map[string]time.Time
+	// failWrite makes WriteFile fail for paths with this suffix
+	failWrite string
+}
+
+func newMemFS(files map[string]string) *memFS {
+	m := &memFS{files: map[string][]byte{}, mtime: map[string]time.Time{}}
+	for name, data := range files {
+		m.files[filepath.Clean(name)] = []byte(data)
+		m.mtime[filepath.Clean(name)] = time.Unix(1, 0)
+	}
+	return m
+}
+
+type memInfo struct {
+	name string
+	size int64
+	mod  time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return 0644 }
+func (i memInfo) ModTime() time.Time { return i.mod }
+func (i memInfo) IsDir() bool        { return false }
+func (i memInfo) Sys() interface{}   { return nil }
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	data, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *memFS) WriteFile(name string, data []byte, _ os.FileMode) error {
+	if m.failWrite != "" && strings.HasSuffix(name, m.failWrite) {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("disk full")}
+	}
+	name = filepath.Clean(name)
+	m.files[name] = append([]byte(nil), data...)
+	m.mtime[name] = m.mtime[name].Add(time.Second)
+	return nil
+}
+
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	name = filepath.Clean(name)
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{filepath.Base(name), int64(len(data)), m.mtime[name]}, nil
+}
+
+func (m *memFS) Remove(name string) error {
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+func (m *memFS) Rename(oldpath, newpath string) error {
+	data, err := m.ReadFile(oldpath)
+	if err != nil {
+		return err
+	}
+	delete(m.files, filepath.Clean(oldpath))
+	return m.WriteFile(newpath, data, 0644)
+}
+
+func (m *memFS) Glob(pattern string) ([]string, error) {
+	var out []string
+	for name := range m.files {
+		ok, err := filepath.Match(filepath.Clean(pattern), name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// useMemFS installs an in-memory project rooted at /proj.
+func useMemFS(t *testing.T, files map[string]string) *memFS {
+	t.Helper()
+	root := filepath.FromSlash("/proj")
+	rel := map[string]string{}
+	for name, data := range files {
+		rel[filepath.Join(root, name)] = data
+	}
+	m := newMemFS(rel)
+	prevFS, prevRoot := fsys, rootOverride
+	fsys, rootOverride = m, root
+	t.Cleanup(func() { fsys, rootOverride = prevFS, prevRoot })
+	return m
+}
+
+func TestLoadConfigTOMLMissingFile(t *testing.T) {
+	useMemFS(t, map[string]string{})
+	if _, err := loadConfigTOML(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want not-exist", err)
+	}
+}
+
+func TestLoadConfigTOMLParseError(t *testing.T) {
+	useMemFS(t, map[string]string{"config.toml": "[server\nlisten_addr = 1\n"})
+	if _, err := loadConfigTOML(); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestSaveConfigTOMLWriteFailure(t *testing.T) {
+	m := useMemFS(t, map[string]string{"config.toml": "[server]\nlisten_addr = \"127.0.0.1:3000\"\n"})
+	m.failWrite = "config.toml"
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveConfigTOML(cfg); err == nil {
+		t.Error("expected write error to surface")
+	}
+}
+
+func TestSaveConfigTOMLAtDetectsChange(t *testing.T) {
+	m := useMemFS(t, map[string]string{"config.toml": "[server]\nlisten_addr = \"127.0.0.1:3000\"\n"})
+	version := configVersion()
+	cfg, _ := loadConfigTOML()
+	m.WriteFile(configPath(), []byte("[server]\nlisten_addr = \"0.0.0.0:3000\"\n"), 0644)
+	if err := saveConfigTOMLAt(cfg, version); err != errConfigChanged {
+		t.Errorf("err = %v, want errConfigChanged", err)
+	}
+}
+
+func TestReadPID(t *testing.T) {
+	useMemFS(t, map[string]string{".proxycache.pid": " 4242\r\n", "bad.pid": "pid?"})
+	root := projectRoot()
+	if pid, err := readPID(filepath.Join(root, ".proxycache.pid")); err != nil || pid != 4242 {
+		t.Errorf("readPID = %d, %v", pid, err)
+	}
+	if _, err := readPID(filepath.Join(root, "bad.pid")); err == nil {
+		t.Error("expected error for garbage PID file")
+	}
+	if _, err := readPID(filepath.Join(root, "missing.pid")); err == nil {
+		t.Error("expected error for missing PID file")
+	}
+}
+
+func TestToggleWebKeepsOtherKeys(t *testing.T) {
+	m := useMemFS(t, map[string]string{".proxycache-web.toml": "# dashboard\nhost = \"0.0.0.0\"\nenabled = true # on by default\nauth_token = \"s3cret\"\nrefresh = 5\n"})
+	p := filepath.Join(projectRoot(), ".proxycache-web.toml")
+	captureOutput(t, toggleWeb)
+	want := "# dashboard\nhost = \"0.0.0.0\"\nenabled = false # on by default\nauth_token = \"s3cret\"\nrefresh = 5\n"
+	if got := string(m.files[filepath.Clean(p)]); got != want {
+		t.Errorf("after toggle:\n%s\nwant:\n%s", got, want)
+	}
+	if isWebEnabled() {
+		t.Error("web still enabled after toggle")
+	}
+	captureOutput(t, toggleWeb)
+	if !strings.Contains(string(m.files[filepath.Clean(p)]), "enabled = true # on by default") {
+		t.Errorf("second toggle didn't re-enable:\n%s", m.files[filepath.Clean(p)])
+	}
+}
+
+func TestToggleWebStartsAndStops(t *testing.T) {
+	useMemFS(t, map[string]string{".proxycache-web.toml": "port = \"0\"\nenabled = false\n"})
+	inREPL = true
+	t.Cleanup(func() { inREPL = false; stopWeb() })
+
+	captureOutput(t, toggleWeb)
+	if !webRunning || webServer == nil {
+		t.Fatal("enabling web in the REPL didn't start the dashboard")
+	}
+	captureOutput(t, toggleWeb)
+	if webRunning || webServer != nil {
+		t.Error("disabling web didn't stop the dashboard")
+	}
+}
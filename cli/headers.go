@@ -0,0 +1,266 @@
+// headers: header rules from every module in pipeline order, and adding
+// rules to the headers module
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// headerRule is one header a module sets or removes.
+type headerRule struct {
+	Phase  string `json:"phase"`  // "request" or "response"
+	Action string `json:"action"` // "set" or "remove"
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	Module string `json:"module"`
+	Note   string `json:"note,omitempty"`
+}
+
+// builtinHeaderRules are the headers built-in modules always touch. Their
+// values aren't configurable, so they're described rather than quoted.
+var builtinHeaderRules = map[string][]headerRule{
+	"request_id": {
+		{Phase: "request", Action: "set", Name: "X-Request-Id", Value: "<generated>", Note: "kept if the client sent one"},
+		{Phase: "response", Action: "set", Name: "X-Request-Id", Value: "<request's id>"},
+	},
+	"cache": {
+		{Phase: "response", Action: "set", Name: "X-Cache", Value: "HIT", Note: "cached responses only"},
+	},
+	"compression": {
+		{Phase: "response", Action: "set", Name: "Content-Encoding", Value: "gzip", Note: "when the client accepts gzip"},
+	},
+}
+
+// headersModuleKeys maps a phase and action to the headers module key
+// holding those rules.
+var headersModuleKeys = map[[2]string]string{
+	{"request", "set"}:     "request_set",
+	{"request", "remove"}:  "request_remove",
+	{"response", "set"}:    "response_set",
+	{"response", "remove"}: "response_remove",
+}
+
+// configHeaderRules reads the headers module's rule lists.
+func configHeaderRules(section map[string]interface{}) []headerRule {
+	var out []headerRule
+	for _, phase := range []string{"request", "response"} {
+		for _, action := range []string{"remove", "set"} {
+			list, _ := section[headersModuleKeys[[2]string{phase, action}]].([]interface{})
+			for _, v := range list {
+				s, _ := v.(string)
+				r := headerRule{Phase: phase, Action: action, Name: strings.TrimSpace(s), Module: "headers"}
+				if action == "set" {
+					name, value, ok := strings.Cut(s, ":")
+					if !ok || strings.TrimSpace(name) == "" {
+						r.Note = "ignored, expected 'Name: value'"
+					}
+					r.Name, r.Value = strings.TrimSpace(name), strings.TrimSpace(value)
+				}
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
+// pcmodHeaderRules finds set_header commands in a .pcmod's on_request and
+// on_response blocks. Ones inside an if are marked conditional.
+func pcmodHeaderRules(name, content string) []headerRule {
+	var out []headerRule
+	phase, depth := "", 0
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case depth == 0 && line == "on_request {":
+			phase, depth = "request", 1
+		case depth == 0 && line == "on_response {":
+			phase, depth = "response", 1
+		case depth == 0:
+		case line == "}":
+			depth--
+		case strings.HasSuffix(line, "{"):
+			depth++
+		case strings.HasPrefix(line, "set_header "):
+			parts := strings.SplitN(line, " ", 3)
+			if len(parts) < 3 {
+				continue
+			}
+			r := headerRule{Phase: phase, Action: "set", Name: parts[1], Value: parts[2], Module: name}
+			if depth > 1 {
+				r.Note = "conditional"
+			}
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// collectHeaderRules walks the pipeline from config.toml and mods/ and
+// returns every header rule in the order it's applied: request rules in
+// pipeline order, response rules in reverse (on_response runs back to front).
+func collectHeaderRules(cfg map[string]interface{}) []headerRule {
+	mods := getModules(cfg)
+	scripts := map[string]string{}
+	modsDir := filepath.Join(projectRoot(), "mods")
+	entries, _ := os.ReadDir(modsDir)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pcmod") {
+			continue
+		}
+		if data, err := readTextFile(filepath.Join(modsDir, e.Name())); err == nil {
+			name, _ := parsePcmod(string(data))
+			scripts[name] = string(data)
+		}
+	}
+
+	var req, resp []headerRule
+	for _, c := range computeChain(cfg) {
+		var rules []headerRule
+		switch {
+		case c.script:
+			rules = pcmodHeaderRules(c.name, scripts[c.name])
+		case c.name == "headers":
+			section, _ := mods["headers"].(map[string]interface{})
+			rules = configHeaderRules(section)
+		default:
+			for _, r := range builtinHeaderRules[c.name] {
+				r.Module = c.name
+				rules = append(rules, r)
+			}
+		}
+		var modResp []headerRule
+		for _, r := range rules {
+			if r.Phase == "request" {
+				req = append(req, r)
+			} else {
+				modResp = append(modResp, r)
+			}
+		}
+		resp = append(modResp, resp...)
+	}
+	return append(req, resp...)
+}
+
+// markOverridden notes rules whose effect a later rule in the same phase
+// undoes, so the list reads as the net effect.
+func markOverridden(rules []headerRule) {
+	for i := range rules {
+		for _, later := range rules[i+1:] {
+			if later.Phase == rules[i].Phase && strings.EqualFold(later.Name, rules[i].Name) && later.Note == "" {
+				rules[i].Note = "overridden by " + later.Module
+				break
+			}
+		}
+	}
+}
+
+func doHeaders(args []string) {
+	if len(args) > 0 && args[0] == "add" {
+		doHeadersAdd(args[1:])
+		return
+	}
+	if len(args) > 0 {
+		fmt.Printf("  %sUsage: headers [add \"Name: value\" [--response]]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	rules := collectHeaderRules(cfg)
+	markOverridden(rules)
+	if jsonOut {
+		printJSONValue(rules)
+		return
+	}
+	fmt.Printf("  %s%sHeader Rules%s %s(in the order they're applied)%s\n", bold, cyan, reset, dim, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if len(rules) == 0 {
+		fmt.Printf("  %sNo enabled module sets or removes headers%s\n", dim, reset)
+		fmt.Printf("  %sAdd one with 'headers add \"X-Foo: bar\"'%s\n", dim, reset)
+		return
+	}
+	t := newTable("PHASE", "ACTION", "HEADER", "VALUE", "MODULE", "")
+	for _, r := range rules {
+		cells := []string{r.Phase, r.Action, r.Name, r.Value, r.Module, dim + r.Note + reset}
+		if strings.HasPrefix(r.Note, "ignored") {
+			cells[5] = red + r.Note + reset
+		}
+		if strings.HasPrefix(r.Note, "overridden") || strings.HasPrefix(r.Note, "ignored") {
+			for i := range cells[:5] {
+				cells[i] = dim + cells[i] + reset
+			}
+		}
+		t.row(cells...)
+	}
+	t.print()
+}
+
+// doHeadersAdd appends a set rule to the headers module, enabling it if
+// needed.
+func doHeadersAdd(args []string) {
+	phase := "request"
+	if hasFlag(args, "--response") {
+		phase = "response"
+		args = stripFlag(args, "--response")
+	}
+	rule := strings.TrimSpace(strings.Join(args, " "))
+	name, value, ok := strings.Cut(rule, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		fmt.Printf("  %sUsage: headers add \"Name: value\" [--response]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+	version := configVersion()
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	mods := getModules(cfg)
+	if mods == nil {
+		mods = map[string]interface{}{}
+		cfg["modules"] = mods
+	}
+	section, _ := mods["headers"].(map[string]interface{})
+	if section == nil {
+		section = map[string]interface{}{}
+		mods["headers"] = section
+	}
+	key := headersModuleKeys[[2]string{phase, "set"}]
+	list, _ := section[key].([]interface{})
+	entry := name + ": " + value
+	var kept []interface{}
+	for _, v := range list {
+		s, _ := v.(string)
+		if n, _, _ := strings.Cut(s, ":"); strings.EqualFold(strings.TrimSpace(n), name) {
+			fmt.Printf("  %sReplacing %s%s\n", dim, s, reset)
+			continue
+		}
+		kept = append(kept, v)
+	}
+	section[key] = append(kept, entry)
+	changed := []string{key}
+	if e, _ := section["enabled"].(bool); !e {
+		section["enabled"] = true
+		changed = append(changed, "enabled")
+	}
+	if err := saveConfigTOMLAt(cfg, version); err != nil {
+		fmt.Printf("  %s✗ Not saved: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s✓ headers.%s += %q%s\n", green, key, entry, reset)
+	if len(changed) > 1 {
+		fmt.Printf("  %s✓ headers module enabled%s\n", green, reset)
+	}
+	printApplyHints("headers", changed)
+}
@@ -0,0 +1,304 @@
+// metrics record/query: /metrics snapshots kept in a SQLite file
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The database is written and read through the sqlite3 command-line tool
+// rather than a driver, so the CLI stays free of cgo and large
+// dependencies. One table: a unix-seconds ts column plus one REAL column per
+// metricKinds key.
+
+const (
+	defaultHistoryDB       = "proxycache.db"
+	defaultHistoryColumns  = "requests_total,requests_err,latency_avg_ms,active_connections"
+	defaultHistoryQueryAge = time.Hour
+	historyTable           = "metrics"
+	sqliteDownloadHint     = "Install the sqlite3 command-line tool (https://sqlite.org/download.html) and put it on PATH"
+)
+
+// sqliteBin is the sqlite3 executable; tests point it elsewhere.
+var sqliteBin = "sqlite3"
+
+var errNoSQLite = errors.New("sqlite3 not found on PATH")
+
+func historyColumns() []string {
+	cols := make([]string, 0, len(metricKinds))
+	for k := range metricKinds {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// historySchema creates the table and its ts index if they're missing.
+func historySchema() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (ts INTEGER NOT NULL", historyTable)
+	for _, c := range historyColumns() {
+		fmt.Fprintf(&b, ", %s REAL", c)
+	}
+	fmt.Fprintf(&b, ");\nCREATE INDEX IF NOT EXISTS %s_ts ON %s(ts);\n", historyTable, historyTable)
+	return b.String()
+}
+
+// historyInsert renders one sample as an INSERT. Metrics missing from the
+// sample are left NULL.
+func historyInsert(ts time.Time, sample map[string]float64) string {
+	cols := []string{"ts"}
+	vals := []string{strconv.FormatInt(ts.Unix(), 10)}
+	for _, c := range historyColumns() {
+		if v, ok := sample[c]; ok {
+			cols = append(cols, c)
+			vals = append(vals, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);\n", historyTable, strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+// historyDBPath resolves --db against the project root.
+func historyDBPath(p string) string {
+	if p == "" {
+		p = defaultHistoryDB
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(projectRoot(), p)
+	}
+	return p
+}
+
+func findSQLite() (string, error) {
+	path, err := exec.LookPath(sqliteBin)
+	if err != nil {
+		return "", errNoSQLite
+	}
+	return path, nil
+}
+
+type historyOpts struct {
+	db       string
+	interval time.Duration
+	since    time.Time
+	until    time.Time
+	columns  []string
+}
+
+// parseHistoryArgs reads the flags shared by metrics record and query;
+// record only accepts --db and --interval.
+func parseHistoryArgs(args []string, record bool, now time.Time) (historyOpts, error) {
+	o := historyOpts{
+		interval: defaultPushInterval,
+		since:    now.Add(-defaultHistoryQueryAge),
+		until:    now,
+		columns:  strings.Split(defaultHistoryColumns, ","),
+	}
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return o, fmt.Errorf("missing value for %s", args[i])
+		}
+		var err error
+		switch {
+		case args[i] == "--db":
+			o.db = args[i+1]
+		case args[i] == "--interval" && record:
+			d, perr := time.ParseDuration(args[i+1])
+			if perr != nil || d <= 0 {
+				err = fmt.Errorf("invalid interval: %s", args[i+1])
+			}
+			o.interval = d
+		case args[i] == "--since" && !record:
+			o.since, err = parseTimeArg(args[i+1], now)
+		case args[i] == "--until" && !record:
+			o.until, err = parseTimeArg(args[i+1], now)
+		case args[i] == "--columns" && !record:
+			o.columns = strings.Split(args[i+1], ",")
+		default:
+			err = fmt.Errorf("unknown option: %s", args[i])
+		}
+		if err != nil {
+			return o, err
+		}
+		i++
+	}
+	for i, c := range o.columns {
+		o.columns[i] = strings.TrimSpace(c)
+		if _, ok := metricKinds[o.columns[i]]; !ok {
+			return o, fmt.Errorf("unknown column '%s' (have %s)", o.columns[i], strings.Join(historyColumns(), ", "))
+		}
+	}
+	if o.until.Before(o.since) {
+		return o, fmt.Errorf("--until is before --since")
+	}
+	o.db = historyDBPath(o.db)
+	return o, nil
+}
+
+// doMetricsRecord samples /metrics every interval into the database until
+// interrupted. Each write is its own sqlite3 run, so a failed statement
+// stops recording when it happens rather than surfacing at exit.
+func doMetricsRecord(args []string) {
+	o, err := parseHistoryArgs(args, true, time.Now())
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		fmt.Printf("  %sUsage: metrics record [--db proxycache.db] [--interval 10s]%s\n", dim, reset)
+		exitCode = 2
+		return
+	}
+	db, interval := o.db, o.interval
+	bin, err := findSQLite()
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		fmt.Printf("  %s%s%s\n", dim, sqliteDownloadHint, reset)
+		exitCode = 1
+		return
+	}
+	if err := runSQLite(bin, db, historySchema()); err != nil {
+		fmt.Printf("  %s✗ sqlite3: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+
+	fmt.Printf("  %s✓ Recording metrics to %s every %s%s %s(Ctrl+C to stop)%s\n", green, displayPath(db), interval, reset, dim, reset)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	defer signal.Stop(stop)
+
+	bo := newPollBackoff(interval)
+	samples := 0
+	for {
+		cur, err := sampleMetrics()
+		wait := bo.next(err == nil)
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s %s(retrying in %s)%s\n", red, err, reset, dim, formatDuration(wait), reset)
+		} else if err := runSQLite(bin, db, historyInsert(time.Now(), cur)); err != nil {
+			fmt.Printf("  %s✗ sqlite3: %s%s\n", red, err, reset)
+			fmt.Printf("  %s● %d sample(s) recorded before the error%s\n", dim, samples, reset)
+			exitCode = 1
+			return
+		} else {
+			samples++
+		}
+		select {
+		case <-stop:
+			fmt.Println()
+			fmt.Printf("  %s● %d sample(s) recorded%s\n", dim, samples, reset)
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// runSQLite runs sql against db. -bail stops at the first failing
+// statement, and anything sqlite3 prints to stderr counts as a failure.
+func runSQLite(bin, db, sql string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(bin, "-bail", db)
+	cmd.Stdin = strings.NewReader(sql)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(msg)
+	}
+	return err
+}
+
+// queryHistory returns the rows between since and until, oldest first, as
+// ts plus the requested columns. NULLs come back as empty strings.
+func queryHistory(db string, cols []string, since, until time.Time) ([][]string, error) {
+	bin, err := findSQLite()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(db); err != nil {
+		return nil, err
+	}
+	sql := fmt.Sprintf("SELECT ts, %s FROM %s WHERE ts >= %d AND ts <= %d ORDER BY ts;",
+		strings.Join(cols, ", "), historyTable, since.Unix(), until.Unix())
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(bin, "-readonly", "-csv", db, sql)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return csv.NewReader(&stdout).ReadAll()
+}
+
+func doMetricsQuery(args []string) {
+	o, err := parseHistoryArgs(args, false, time.Now())
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		fmt.Printf("  %sUsage: metrics query [--db proxycache.db] [--since 1h] [--until 10m] [--columns a,b]%s\n", dim, reset)
+		exitCode = 2
+		return
+	}
+	db, cols, since, until := o.db, o.columns, o.since, o.until
+	rows, err := queryHistory(db, cols, since, until)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		if errors.Is(err, errNoSQLite) {
+			fmt.Printf("  %s%s%s\n", dim, sqliteDownloadHint, reset)
+		} else if os.IsNotExist(err) {
+			fmt.Printf("  %sRecord some first with 'metrics record'%s\n", dim, reset)
+		}
+		exitCode = 1
+		return
+	}
+	if jsonOut {
+		out := make([]map[string]interface{}, 0, len(rows))
+		for _, r := range rows {
+			ts, _ := strconv.ParseInt(r[0], 10, 64)
+			m := map[string]interface{}{"ts": time.Unix(ts, 0).UTC().Format(time.RFC3339)}
+			for i, c := range cols {
+				if v, err := strconv.ParseFloat(r[i+1], 64); err == nil {
+					m[c] = v
+				} else {
+					m[c] = nil
+				}
+			}
+			out = append(out, m)
+		}
+		printJSONValue(out)
+		return
+	}
+
+	fmt.Printf("  %s%sMetrics History%s %s%s → %s%s\n", bold, cyan, reset, dim, formatTime(since), formatTime(until), reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if len(rows) == 0 {
+		fmt.Printf("  %sNo samples in this range%s\n", dim, reset)
+		return
+	}
+	header := append([]string{"TIME"}, cols...)
+	right := make([]int, len(cols))
+	for i := range right {
+		right[i] = i + 1
+	}
+	t := newTable(header...).alignRight(right...)
+	for _, r := range rows {
+		ts, _ := strconv.ParseInt(r[0], 10, 64)
+		cells := []string{formatTime(time.Unix(ts, 0))}
+		for _, v := range r[1:] {
+			if v == "" {
+				v = "—"
+			}
+			cells = append(cells, v)
+		}
+		t.row(cells...)
+	}
+	t.print()
+	fmt.Printf("\n  %s%d sample(s)%s\n", dim, len(rows), reset)
+}
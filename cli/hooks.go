@@ -0,0 +1,114 @@
+// Reload hooks: shell commands run around reload/restart, from [hooks] in
+// .proxycache-cli.toml
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// shellCommand runs command through the platform shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// hookList reads a hook key that may be one command or a list of them.
+func hookList(section map[string]interface{}, key string) ([]string, error) {
+	switch v := section[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []interface{}:
+		var out []string
+		for _, c := range v {
+			s, ok := c.(string)
+			if !ok {
+				return nil, fmt.Errorf("hooks.%s: entries must be strings", key)
+			}
+			if strings.TrimSpace(s) != "" {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("hooks.%s: expected a command or a list of commands", key)
+}
+
+// reloadHooks returns [hooks] pre_reload and post_reload.
+func reloadHooks() (pre, post []string, err error) {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	section, _ := cfg["hooks"].(map[string]interface{})
+	if pre, err = hookList(section, "pre_reload"); err != nil {
+		return nil, nil, err
+	}
+	post, err = hookList(section, "post_reload")
+	return pre, post, err
+}
+
+// runHooks runs each command from the project root with env added,
+// printing its output indented. It stops at the first failure.
+func runHooks(stage string, cmds []string, env []string) bool {
+	for _, c := range cmds {
+		fmt.Printf("  %s● %s: %s%s\n", yellow, stage, c, reset)
+		cmd := shellCommand(c)
+		cmd.Dir = projectRoot()
+		cmd.Env = append(os.Environ(), env...)
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		start := time.Now()
+		err := cmd.Run()
+		for _, line := range strings.Split(strings.TrimRight(out.String(), "\r\n"), "\n") {
+			if line != "" {
+				fmt.Printf("    %s%s%s\n", dim, strings.TrimRight(line, "\r"), reset)
+			}
+		}
+		if err != nil {
+			fmt.Printf("  %s✗ %s failed after %s: %s%s\n", red, stage, formatDuration(time.Since(start)), err, reset)
+			return false
+		}
+		fmt.Printf("  %s✓ %s done in %s%s\n", green, stage, formatDuration(time.Since(start)), reset)
+	}
+	return true
+}
+
+// withReloadHooks runs the pre_reload hooks, then reload unless one
+// failed, then the post_reload hooks. Post hooks run even when the reload
+// failed, so one that undoes a pre hook (re-adding the proxy to a load
+// balancer, say) always gets to; PROXYCACHE_RELOAD_RESULT tells them how it
+// went.
+func withReloadHooks(mode string, reload func() bool) bool {
+	pre, post, err := reloadHooks()
+	if err != nil {
+		fmt.Printf("  %s✗ %s: %s%s\n", red, displayPath(cliConfigPath()), err, reset)
+		return false
+	}
+	env := []string{"PROXYCACHE_RELOAD_MODE=" + mode}
+	if !runHooks("pre_reload", pre, env) {
+		fmt.Printf("  %s✗ Reload aborted%s\n", red, reset)
+		return false
+	}
+	ok := reload()
+	result := "ok"
+	if !ok {
+		result = "failed"
+	}
+	if !runHooks("post_reload", post, append(env, "PROXYCACHE_RELOAD_RESULT="+result)) {
+		return false
+	}
+	return ok
+}
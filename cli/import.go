@@ -0,0 +1,121 @@
+// config import: replace config.toml from a file or stdin
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// doConfigImport replaces config.toml with a full config read from a file
+// or, with "-", from stdin. The new config must parse and pass the same
+// checks as verify; the current file is kept as <config>.bak.
+func doConfigImport(args []string) {
+	dryRun := hasFlag(args, "--dry-run")
+	args = stripFlag(args, "--dry-run")
+	if len(args) != 1 {
+		fmt.Printf("  %sUsage: config import <file|-> [--dry-run]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+
+	var data []byte
+	var err error
+	source := args[0]
+	if source == "-" {
+		source = "stdin"
+		data, err = io.ReadAll(os.Stdin)
+		data = normalizeText(data)
+	} else {
+		data, err = readTextFile(resolveRootPath(source))
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read %s: %s%s\n", red, source, err, reset)
+		exitCode = 1
+		return
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		fmt.Printf("  %s✗ %s is empty, nothing imported%s\n", red, source, reset)
+		exitCode = 1
+		return
+	}
+
+	var cfg map[string]interface{}
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		fmt.Printf("  %s✗ Parse error in %s: %s%s\n", red, source, err, reset)
+		fmt.Printf("  %sNothing imported%s\n", dim, reset)
+		exitCode = 1
+		return
+	}
+	merged := map[string]interface{}{}
+	for k, v := range cfg {
+		merged[k] = v
+	}
+	mergeIncludes(merged, loadIncludes(merged))
+	if issues := configIssues(merged); len(issues) > 0 {
+		fmt.Printf("  %s✗ Config issues found in %s:%s\n", red, source, reset)
+		for _, issue := range issues {
+			fmt.Printf("    %s• %s%s\n", yellow, issue, reset)
+		}
+		fmt.Printf("  %sNothing imported%s\n", dim, reset)
+		exitCode = 1
+		return
+	}
+
+	path := configPath()
+	current, _ := parseConfigFile(path)
+	entries := configDiff(current, cfg)
+	if current != nil && len(entries) == 0 {
+		fmt.Printf("  %s✓ %s already matches %s%s\n", green, displayPath(path), source, reset)
+		return
+	}
+	printDiff(entries)
+	if len(entries) > 0 {
+		fmt.Printf("  %s%s%s\n", dim, diffSummary(entries), reset)
+	}
+
+	if dryRun {
+		fmt.Printf("  %sDry run: config is valid, nothing written%s\n", yellow, reset)
+		return
+	}
+
+	backup := path + ".bak"
+	if old, err := fsys.ReadFile(path); err == nil {
+		if err := fsys.WriteFile(backup, old, 0644); err != nil {
+			fmt.Printf("  %s✗ Can't write backup %s: %s%s\n", red, displayPath(backup), err, reset)
+			fmt.Printf("  %sNothing imported%s\n", dim, reset)
+			exitCode = 1
+			return
+		}
+	} else {
+		backup = ""
+	}
+	if err := fsys.WriteFile(path, data, 0644); err != nil {
+		fmt.Printf("  %s✗ Can't write %s: %s%s\n", red, displayPath(path), err, reset)
+		exitCode = 1
+		return
+	}
+
+	fmt.Printf("  %s✓ Imported %s into %s%s\n", green, source, displayPath(path), reset)
+	if backup != "" {
+		fmt.Printf("  %sPrevious config saved as %s ('config diff' compares against it)%s\n", dim, displayPath(backup), reset)
+	}
+	restart := false
+	for _, e := range entries {
+		section, key, ok := strings.Cut(e.key, ".")
+		if section == "modules" {
+			section, key, ok = strings.Cut(key, ".")
+		}
+		if !ok || needsRestart(section, key) {
+			restart = true
+		}
+	}
+	if restart {
+		fmt.Printf("  %sRun 'reload' to restart with the new config%s\n", dim, reset)
+	} else {
+		fmt.Printf("  %sRun 'reload --config-only' to apply (no restart-only keys changed)%s\n", dim, reset)
+	}
+}
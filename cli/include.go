@@ -0,0 +1,214 @@
+// Config includes: include = ["modules.d/*.toml"] pulls [modules.*] tables
+// from other files. Mirrors merge_includes in src/config.rs: config.toml
+// wins, then the first include file in name order.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+type includeFile struct {
+	path string
+	doc  map[string]interface{}
+}
+
+type includeSet struct {
+	files []includeFile
+	// owner maps a module name to the index of the include file it came from
+	owner    map[string]int
+	warnings []string
+}
+
+func parseConfigFile(path string) (map[string]interface{}, error) {
+	data, err := readTextFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]interface{}
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func includePatterns(cfg map[string]interface{}) []string {
+	switch v := cfg["include"].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, p := range v {
+			if s, ok := p.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// includeFiles expands the include globs relative to config.toml. Like the
+// proxy, wildcards are only honored in the file name.
+func includeFiles(cfg map[string]interface{}) ([]string, []string) {
+	base := filepath.Dir(configPath())
+	var files, warnings []string
+	seen := map[string]bool{}
+	for _, pattern := range includePatterns(cfg) {
+		if strings.ContainsAny(filepath.Dir(pattern), "*?[") {
+			warnings = append(warnings, fmt.Sprintf("include '%s': wildcards are only allowed in the file name", pattern))
+			continue
+		}
+		matches, err := fsys.Glob(filepath.Join(base, pattern))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("include '%s': %s", pattern, err))
+			continue
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if fi, err := fsys.Stat(m); err != nil || fi.IsDir() || seen[m] || m == configPath() {
+				continue
+			}
+			seen[m] = true
+			files = append(files, m)
+		}
+	}
+	return files, warnings
+}
+
+// loadIncludes reads every included file and decides which module tables
+// each one contributes, given the main config as parsed from config.toml.
+func loadIncludes(main map[string]interface{}) *includeSet {
+	inc := &includeSet{owner: map[string]int{}}
+	files, warnings := includeFiles(main)
+	inc.warnings = warnings
+	mainMods := getModules(main)
+	for _, path := range files {
+		name := includeDisplayName(path)
+		doc, err := parseConfigFile(path)
+		if err != nil {
+			inc.warnings = append(inc.warnings, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		idx := len(inc.files)
+		inc.files = append(inc.files, includeFile{path: path, doc: doc})
+		for _, k := range sortedKeys(doc) {
+			if k != "modules" {
+				inc.warnings = append(inc.warnings, fmt.Sprintf("%s: only [modules.*] is read from includes, ignoring '%s'", name, k))
+			}
+		}
+		mods, _ := doc["modules"].(map[string]interface{})
+		for _, mod := range sortedKeys(mods) {
+			if _, ok := mainMods[mod]; ok {
+				inc.warnings = append(inc.warnings, fmt.Sprintf("%s: [modules.%s] already defined in config.toml, ignoring", name, mod))
+			} else if prev, ok := inc.owner[mod]; ok {
+				inc.warnings = append(inc.warnings, fmt.Sprintf("%s: [modules.%s] already defined in %s, ignoring", name, mod, includeDisplayName(inc.files[prev].path)))
+			} else {
+				inc.owner[mod] = idx
+			}
+		}
+	}
+	return inc
+}
+
+func includeDisplayName(path string) string {
+	if rel, err := filepath.Rel(filepath.Dir(configPath()), path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// mergeIncludes adds the module tables owned by include files to cfg.
+func mergeIncludes(cfg map[string]interface{}, inc *includeSet) {
+	if len(inc.owner) == 0 {
+		return
+	}
+	mods := getModules(cfg)
+	if mods == nil {
+		mods = map[string]interface{}{}
+		cfg["modules"] = mods
+	}
+	for mod, idx := range inc.owner {
+		src, _ := inc.files[idx].doc["modules"].(map[string]interface{})
+		mods[mod] = src[mod]
+	}
+}
+
+// saveWithIncludes writes modules that came from an include file back to
+// that file and everything else to config.toml, which keeps its include list.
+func saveWithIncludes(cfg map[string]interface{}) error {
+	main, err := parseConfigFile(configPath())
+	if err != nil {
+		main = map[string]interface{}{}
+	}
+	inc := loadIncludes(main)
+	mods := getModules(cfg)
+
+	for idx, f := range inc.files {
+		fileMods, _ := f.doc["modules"].(map[string]interface{})
+		changed := false
+		for mod, owner := range inc.owner {
+			if owner != idx {
+				continue
+			}
+			if v, ok := mods[mod]; ok && !reflect.DeepEqual(fileMods[mod], v) {
+				fileMods[mod] = v
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		data, err := toml.Marshal(f.doc)
+		if err != nil {
+			return err
+		}
+		if err := fsys.WriteFile(f.path, data, 0644); err != nil {
+			return fmt.Errorf("%s: %w", includeDisplayName(f.path), err)
+		}
+	}
+
+	out := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		out[k] = v
+	}
+	if mods != nil {
+		own := make(map[string]interface{}, len(mods))
+		for k, v := range mods {
+			if _, included := inc.owner[k]; !included {
+				own[k] = v
+			}
+		}
+		out["modules"] = own
+	}
+	data, err := toml.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFile(configPath(), data, 0644)
+}
+
+// configIncludeWarnings reports include conflicts and unreadable files.
+func configIncludeWarnings() []string {
+	main, err := parseConfigFile(configPath())
+	if err != nil {
+		return nil
+	}
+	return loadIncludes(main).warnings
+}
+
+func printIncludeWarnings() {
+	warnings := configIncludeWarnings()
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Printf("  %sIncludes:%s\n", cyan, reset)
+	for _, w := range warnings {
+		fmt.Printf("    %s• %s%s\n", yellow, w, reset)
+	}
+}
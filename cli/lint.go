@@ -0,0 +1,164 @@
+// config lint: settings that are valid but risky
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// lintFindings lists settings the proxy accepts but that are likely a
+// mistake or a security hole. configIssues covers what it rejects.
+func lintFindings(cfg map[string]interface{}) []string {
+	var out []string
+	if w := adminExposure(cfg); w != "" {
+		out = append(out, w)
+	}
+	for _, e := range adminAllowList(adminSection(cfg, false)) {
+		if _, err := parseAllowEntry(e); err != nil {
+			out = append(out, fmt.Sprintf("modules.admin_api.allow_ips: %s; the proxy won't start the admin API", err))
+		}
+	}
+	return out
+}
+
+// schemaProblems checks key names and value types against the schema. A
+// wrong type under [server] makes the proxy discard the whole file and run
+// on defaults, so it's an issue; a module just falls back to that key's
+// default, and an unknown server key is ignored, so those are warnings.
+// Duration keys are left to durationIssues and the two addresses to
+// configIssues.
+func schemaProblems(cfg map[string]interface{}) (issues, warnings []string) {
+	if srv, ok := cfg["server"].(map[string]interface{}); ok {
+		for _, k := range sortedKeys(srv) {
+			spec, known := serverSchema[k]
+			switch {
+			case !known:
+				warnings = append(warnings, fmt.Sprintf("server.%s is not a known key; the proxy ignores it", k))
+			case k == "listen_addr" || k == "backend_addr" || isDurationKey("server", k):
+			case !schemaTypeMatches(srv[k], spec.Type):
+				issues = append(issues, fmt.Sprintf("server.%s: expected %s, got %s; the proxy would run on defaults", k, spec.Type, schemaTypeOf(srv[k])))
+			}
+		}
+	}
+	mods := getModules(cfg)
+	for _, name := range sortedKeys(mods) {
+		section, _ := mods[name].(map[string]interface{})
+		for _, k := range sortedKeys(section) {
+			spec, known := moduleSchema[name][k]
+			if known && !isDurationKey(name, k) && !schemaTypeMatches(section[k], spec.Type) {
+				warnings = append(warnings, fmt.Sprintf("modules.%s.%s: expected %s, got %s; the module uses %v", name, k, spec.Type, schemaTypeOf(section[k]), spec.Default))
+			}
+		}
+	}
+	return issues, warnings
+}
+
+func schemaTypeMatches(v interface{}, typ string) bool {
+	return schemaTypeOf(v) == typ
+}
+
+// schemaTypeOf names a decoded TOML value in the schema's terms.
+func schemaTypeOf(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "table"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// configProblems runs every offline check: issues are what the proxy
+// rejects or misreads, warnings are risky or ignored settings.
+func configProblems(cfg map[string]interface{}) (issues, warnings []string) {
+	schemaIssues, schemaWarnings := schemaProblems(cfg)
+	issues = append(configIssues(cfg), schemaIssues...)
+	warnings = append(lintFindings(cfg), schemaWarnings...)
+	return issues, append(warnings, configIncludeWarnings()...)
+}
+
+func doConfigLint() {
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	issues, findings := configProblems(cfg)
+	if jsonOut {
+		printJSONValue(map[string]interface{}{"issues": issues, "warnings": findings})
+		if len(issues)+len(findings) > 0 {
+			exitCode = 1
+		}
+		return
+	}
+	if len(issues)+len(findings) == 0 {
+		fmt.Printf("  %s✓ No problems found in %s%s\n", green, displayPath(configPath()), reset)
+		return
+	}
+	printConfigProblems(issues, findings)
+	exitCode = 1
+}
+
+func printConfigProblems(issues, warnings []string) {
+	for _, issue := range issues {
+		fmt.Printf("  %s✗ %s%s\n", red, issue, reset)
+	}
+	for _, w := range warnings {
+		fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+	}
+}
+
+// doConfigValidate runs the lint checks against a candidate file instead
+// of config.toml, resolving its includes relative to the file. Only issues
+// fail it, so CI can gate on the exit code.
+func doConfigValidate(args []string) {
+	if len(args) != 1 {
+		fmt.Printf("  %sUsage: config validate <file.toml>%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+	path, err := filepath.Abs(args[0])
+	if err == nil {
+		_, err = fsys.Stat(path)
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	configOverride = path
+	defer func() { configOverride = "" }()
+
+	cfg, err := loadConfigTOML()
+	var issues, warnings []string
+	if err != nil {
+		issues = []string{"parse error: " + err.Error()}
+	} else {
+		issues, warnings = configProblems(cfg)
+	}
+	if len(issues) > 0 {
+		exitCode = 1
+	}
+	if jsonOut {
+		printJSONValue(map[string]interface{}{"file": path, "valid": len(issues) == 0, "issues": issues, "warnings": warnings})
+		return
+	}
+	printConfigProblems(issues, warnings)
+	switch {
+	case len(issues) > 0:
+		fmt.Printf("  %s✗ %s is not valid (%d issue(s))%s\n", red, args[0], len(issues), reset)
+	case len(warnings) > 0:
+		fmt.Printf("  %s✓ %s is valid, with %d warning(s)%s\n", green, args[0], len(warnings), reset)
+	default:
+		fmt.Printf("  %s✓ %s is valid%s\n", green, args[0], reset)
+	}
+}
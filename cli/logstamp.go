@@ -0,0 +1,124 @@
+// Timestamped proxy logs: a detached helper that stamps each output line
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// logTimestamps is log_timestamps in .proxycache-cli.toml. Off by default:
+// the proxy's own log lines already carry a time, so this is for builds or
+// modules that print without one.
+var logTimestamps bool
+
+// logStampCmd is the hidden argument that turns the CLI into the stamping
+// helper. It isn't listed in help.
+const logStampCmd = "__logstamp"
+
+const logStampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+func loadLogStampFromCLIConfig() {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return
+	}
+	if b, ok := cfg["log_timestamps"].(bool); ok {
+		logTimestamps = b
+	}
+}
+
+// stampWriter prefixes every line written through it with the time it
+// arrived. Partial lines are carried over so a line split across writes
+// gets one stamp.
+type stampWriter struct {
+	w       io.Writer
+	now     func() time.Time
+	midLine bool
+}
+
+func newStampWriter(w io.Writer) *stampWriter {
+	return &stampWriter{w: w, now: time.Now}
+}
+
+func (s *stampWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	for rest := p; len(rest) > 0; {
+		if !s.midLine {
+			buf.WriteString(s.now().UTC().Format(logStampLayout))
+			buf.WriteByte(' ')
+		}
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			buf.Write(rest)
+			s.midLine = true
+			break
+		}
+		buf.Write(rest[:i+1])
+		rest = rest[i+1:]
+		s.midLine = false
+	}
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// runLogStamper is the helper's whole job: stdin to stdout, stamped, until
+// the proxy closes its end of the pipe.
+func runLogStamper() {
+	out := bufio.NewWriter(os.Stdout)
+	r := bufio.NewReader(os.Stdin)
+	w := newStampWriter(out)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			w.Write(line)
+			if r.Buffered() == 0 {
+				out.Flush()
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	out.Flush()
+}
+
+// stampedLog creates path and starts a detached helper that writes to it.
+// The returned file is the write end of a pipe to the helper, to be given
+// to the proxy as stdout or stderr. The helper outlives the CLI and exits
+// when the proxy does.
+func stampedLog(path string) (*os.File, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	logFile, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer pr.Close()
+
+	cmd := exec.Command(exe, logStampCmd)
+	cmd.Stdin = pr
+	cmd.Stdout = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | 0x00000008, // DETACHED_PROCESS
+	}
+	if err := cmd.Start(); err != nil {
+		pw.Close()
+		return nil, err
+	}
+	cmd.Process.Release()
+	return pw, nil
+}
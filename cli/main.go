@@ -3,13 +3,16 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"sort"
 	"strconv"
@@ -20,7 +23,9 @@ import (
 	toml "github.com/pelletier/go-toml/v2"
 )
 
-const (
+const sep = "──────────────────────────────────────────"
+
+var (
 	reset  = "\033[0m"
 	bold   = "\033[1m"
 	red    = "\033[31m"
@@ -28,47 +33,168 @@ const (
 	yellow = "\033[33m"
 	cyan   = "\033[36m"
 	dim    = "\033[90m"
-	sep    = "──────────────────────────────────────────"
 )
 
 var (
 	addr   = "127.0.0.1:9090"
 	apiKey = ""
-	client = &http.Client{Timeout: 5 * time.Second}
+	// apiPrefix is prepended to every admin path, e.g. "/admin"
+	apiPrefix = ""
+	// authScheme picks the header apiKey is sent in: apikey or bearer
+	authScheme = authAPIKey
+	noColor    = false
+	jsonOut    = false
+	// exitCode is returned to the shell when running a single command
+	exitCode = 0
+	client   = &http.Client{Timeout: 5 * time.Second}
 )
 
 func main() {
+	if len(os.Args) == 2 && os.Args[1] == logStampCmd {
+		runLogStamper()
+		return
+	}
 	args := parseFlags()
+	if noColor || !enableVT() {
+		disableColor()
+	}
+	if err := setupClient(); err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		os.Exit(1)
+	}
+	if verbose {
+		enableVerbose()
+	}
 	if len(args) > 0 {
-		runCmd(strings.Join(args, " "))
+		runLine(argsLine(args))
 		if webRunning {
 			select {}
 		}
-		return
+		os.Exit(exitCode)
 	}
 	repl()
 }
 
 func parseFlags() []string {
 	var rest []string
+	addrSet, keySet, prefixSet, schemeSet := false, false, false, false
+	profileName, root := "", os.Getenv("PROXYCACHE_ROOT")
 	a := os.Args[1:]
 	for i := 0; i < len(a); i++ {
 		if a[i] == "--addr" && i+1 < len(a) {
 			addr = a[i+1]
+			addrSet = true
 			i++
 		} else if a[i] == "--key" && i+1 < len(a) {
 			apiKey = a[i+1]
+			keySet = true
+			i++
+		} else if a[i] == "--api-prefix" && i+1 < len(a) {
+			apiPrefix = normalizePrefix(a[i+1])
+			prefixSet = true
+			i++
+		} else if a[i] == "--auth-scheme" && i+1 < len(a) {
+			s, err := parseAuthScheme(a[i+1])
+			if err != nil {
+				fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+				os.Exit(2)
+			}
+			authScheme, schemeSet = s, true
+			i++
+		} else if a[i] == "--profile" && i+1 < len(a) {
+			profileName = a[i+1]
 			i++
+		} else if a[i] == "--proxy" && i+1 < len(a) {
+			proxyURL = a[i+1]
+			i++
+		} else if a[i] == "--instance" && i+1 < len(a) {
+			if err := setInstance(a[i+1]); err != nil {
+				fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+				os.Exit(2)
+			}
+			i++
+		} else if a[i] == "--log" && i+1 < len(a) {
+			logFileOverride = resolveArgPath(a[i+1])
+			i++
+		} else if a[i] == "--pid-file" && i+1 < len(a) {
+			pidFileOverride = resolveArgPath(a[i+1])
+			i++
+		} else if a[i] == "--root" && i+1 < len(a) {
+			root = a[i+1]
+			i++
+		} else if a[i] == "--no-color" {
+			noColor = true
+		} else if a[i] == "--json" {
+			jsonOut = true
+		} else if a[i] == "--utc" {
+			utcTimes = true
+		} else if a[i] == "--verbose" || (a[i] == "-v" && len(rest) == 0) {
+			// -v only before the command so subcommands keep their own -v
+			verbose = true
 		} else {
 			rest = append(rest, a[i])
 		}
 	}
-	if apiKey == "" {
+	// Root first: profiles and config are read relative to it
+	if root != "" {
+		if err := setProjectRoot(root); err != nil {
+			fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+			os.Exit(1)
+		}
+	}
+	if profileName != "" {
+		p, err := findProfile(profileName)
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+			os.Exit(1)
+		}
+		if !addrSet {
+			addr = p.Addr
+		}
+		if !keySet {
+			apiKey = p.Key
+		}
+		if !prefixSet && p.Prefix != "" {
+			apiPrefix = p.Prefix
+			prefixSet = true
+		}
+		if !schemeSet && p.AuthScheme != "" {
+			authScheme = p.AuthScheme
+			schemeSet = true
+		}
+		addrSet, keySet = true, true
+	}
+	if addrSet {
+		a, err := dialableAddr(addr)
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+			os.Exit(2)
+		}
+		addr = a
+	}
+	if !keySet {
 		loadAPIKeyFromConfig()
 	}
+	if !addrSet {
+		loadAddrFromConfig()
+	}
+	if !prefixSet {
+		loadAPIPrefixFromCLIConfig()
+	}
+	if !schemeSet {
+		loadAuthSchemeFromCLIConfig()
+	}
+	loadPathsFromCLIConfig()
+	loadPingFromCLIConfig()
+	loadLogStampFromCLIConfig()
 	return rest
 }
 
+// disableColor blanks all escape codes so output is plain text.
+func disableColor() {
+	reset, bold, red, green, yellow, cyan, dim = "", "", "", "", "", "", ""
+}
+
 func loadAPIKeyFromConfig() {
 	cfg, err := loadConfigTOML()
 	if err != nil {
@@ -82,16 +208,86 @@ func loadAPIKeyFromConfig() {
 	if !ok {
 		return
 	}
-	if key, ok := admin["api_key"].(string); ok && key != "" {
+	key, err := resolveAPIKey(admin)
+	if err != nil {
+		fmt.Printf("  %s⚠ %s%s\n", yellow, err, reset)
+		return
+	}
+	if key != "" {
 		apiKey = key
 	}
 }
 
+// resolveAPIKey returns the admin key from [modules.admin_api]. When
+// api_key_file is set the key is read from that file (relative paths are
+// resolved against the project root) and takes precedence over api_key.
+// A blank file is an error, as the proxy refuses to start on one.
+func resolveAPIKey(admin map[string]interface{}) (string, error) {
+	if path, ok := admin["api_key_file"].(string); ok && path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(projectRoot(), path)
+		}
+		data, err := fsys.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("can't read api_key_file: %w", err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf("api_key_file %s is empty", displayPath(path))
+		}
+		return key, nil
+	}
+	key, _ := admin["api_key"].(string)
+	return key, nil
+}
+
+// loadAddrFromConfig points the CLI at the admin API's configured listen
+// address. Wildcard binds are reached through loopback.
+func loadAddrFromConfig() {
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		return
+	}
+	mods := getModules(cfg)
+	if mods == nil {
+		return
+	}
+	admin, ok := mods["admin_api"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	listen, ok := admin["listen_addr"].(string)
+	if !ok || listen == "" {
+		return
+	}
+	if a, err := dialableAddr(listen); err == nil {
+		addr = a
+	}
+}
+
+// inREPL is set while the interactive prompt runs, for commands that
+// behave differently when the CLI stays alive after them.
+var inREPL bool
+
 func repl() {
+	inREPL = true
 	fmt.Printf("\n%s%sProxycache CLI%s\n", bold, cyan, reset)
 	fmt.Printf("%s%s%s\n", dim, sep, reset)
+	if instanceName != "" {
+		fmt.Printf("Instance: %s%s%s  |  ", cyan, instanceName, reset)
+	}
 	fmt.Printf("Admin: %s%s%s  |  Type %shelp%s for commands\n\n", cyan, addr, reset, cyan, reset)
 
+	if configMissing() && stdinIsTerminal() {
+		fmt.Printf("  %s! No %s found%s\n", yellow, displayPath(configPath()), reset)
+		if confirm("Run setup now?") {
+			doSetup()
+		} else {
+			fmt.Printf("  %sRun 'setup' any time, or start the proxy once to get the defaults%s\n", dim, reset)
+		}
+		fmt.Println()
+	}
+
 	sc := bufio.NewScanner(os.Stdin)
 	for {
 		fmt.Printf("%s❯%s ", cyan, reset)
@@ -108,68 +304,264 @@ func repl() {
 }
 
 func runCmd(input string) {
-	parts := strings.Fields(input)
+	runLine(input)
+}
+
+// runArgs dispatches an already-split command line; os.Args come here
+// directly so shell quoting is preserved.
+func runArgs(parts []string) {
+	parts, outFile, err := takeOutputFlag(parts)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 2
+		return
+	}
 	if len(parts) == 0 {
 		return
 	}
+	// --json can also be given per command in the REPL
+	if hasFlag(parts, "--json") && !jsonOut {
+		jsonOut = true
+		defer func() { jsonOut = false }()
+		parts = stripFlag(parts, "--json")
+	}
+	if outFile != "" {
+		prev := exitCode
+		exitCode = 0
+		if err := withOutput(outFile, func() { dispatch(parts) }); err != nil {
+			fmt.Printf("  %s✗ Can't write %s: %s%s\n", red, outFile, err, reset)
+			exitCode = 1
+			return
+		}
+		if exitCode != 0 {
+			fmt.Printf("  %s✗ %s failed, see %s%s\n", red, parts[0], outFile, reset)
+			return
+		}
+		exitCode = prev
+		fmt.Printf("  %s✓ Wrote %s%s\n", green, outFile, reset)
+		return
+	}
+	dispatch(parts)
+}
+
+func dispatch(parts []string) {
 	cmd := parts[0]
 	args := parts[1:]
 
 	switch cmd {
+	case "doctor":
+		doDoctor()
 	case "status":
-		doStatus()
+		if hasFlag(args, "--quiet") || hasFlag(args, "-q") {
+			if !isRunning() {
+				exitCode = 1
+			}
+		} else {
+			doStatus()
+		}
+	case "is-running":
+		if !isRunning() {
+			exitCode = 1
+		}
 	case "stop":
 		doStop()
-	case "reload":
-		doReload()
+	case "reload", "restart":
+		mode, reload := "full", func() bool {
+			return doReload() && (!hasFlag(args, "--smoke") || doSmokeTest())
+		}
+		if hasFlag(args, "--config-only") {
+			mode, reload = "config-only", doConfigReload
+		}
+		if hasFlag(args, "--if-changed") && !reloadIfChanged(mode == "config-only") {
+			break
+		}
+		var ok bool
+		if hasFlag(args, "--no-hooks") {
+			ok = reload()
+		} else {
+			ok = withReloadHooks(mode, reload)
+		}
+		if !ok {
+			exitCode = 1
+		}
 	case "ping":
-		doPing()
+		doPing(args)
 	case "logs":
-		doLogs()
+		doLogs(args)
+	case "log":
+		if len(args) > 0 && args[0] == "format" {
+			doLogFormat(args[1:])
+		} else {
+			fmt.Printf("  %sUsage: log format [json|text]%s\n", yellow, reset)
+			exitCode = 2
+		}
 	case "compile", "build":
-		doCompile()
+		if !doCompile(!chainPending) {
+			exitCode = 1
+		}
 	case "run", "start":
-		doRun()
+		wait, err := parseRunArgs(args)
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+			fmt.Printf("  %sUsage: run [--wait] [--timeout 30s]%s\n", dim, reset)
+			exitCode = 2
+		} else if !doRun(wait) {
+			exitCode = 1
+		}
 	case "ls", "modules":
-		doListModules()
-	case "mods":
-		doMods()
+		showSecrets = hasFlag(args, "--show-secrets")
+		doListModules(hasFlag(args, "-v"))
+	case "mods", "mod":
+		if len(args) > 0 && (args[0] == "pause" || args[0] == "resume") {
+			if len(args) < 2 {
+				fmt.Printf("  %sUsage: mods %s <module>%s\n", yellow, args[0], reset)
+			} else {
+				doPauseModule(args[1], args[0] == "pause")
+			}
+		} else if len(args) > 0 && args[0] == "sync" {
+			doModsSync(hasFlag(args, "--dry-run"))
+		} else if len(args) > 0 && args[0] == "order" {
+			doModsOrder()
+		} else if len(args) > 0 && args[0] == "info" {
+			if len(args) < 2 {
+				fmt.Printf("  %sUsage: mods info <module>%s\n", yellow, reset)
+				exitCode = 2
+			} else {
+				doModsInfo(args[1])
+			}
+		} else if len(args) > 0 && args[0] == "timing" {
+			doModsTiming()
+		} else if len(args) > 0 && args[0] == "move" {
+			if len(args) < 4 {
+				fmt.Printf("  %sUsage: mods move <module> before|after <module>%s\n", yellow, reset)
+			} else {
+				doModsMove(args[1], args[2], args[3])
+			}
+		} else {
+			doMods()
+		}
 	case "verify":
 		doVerify()
 	case "repair":
 		doRepair()
 	case "metrics":
-		doMetrics()
+		if len(args) > 0 && args[0] == "push" {
+			doMetricsPush(args[1:])
+		} else if len(args) > 0 && args[0] == "record" {
+			doMetricsRecord(args[1:])
+		} else if len(args) > 0 && args[0] == "query" {
+			doMetricsQuery(args[1:])
+		} else if len(args) > 0 {
+			doModuleMetrics(args[0])
+		} else {
+			doMetrics()
+		}
+	case "snapshot":
+		doSnapshot(args)
+	case "headers":
+		doHeaders(args)
+	case "admin":
+		doAdmin(args)
+	case "fleet":
+		if len(args) > 0 && args[0] == "diff" {
+			showSecrets = hasFlag(args, "--show-secrets")
+			doFleetDiff(stripFlag(args[1:], "--show-secrets"))
+		} else {
+			doFleet()
+		}
+	case "stats":
+		doStats(args)
+	case "setup":
+		doSetup()
+	case "clean":
+		doClean(args)
+	case "watch":
+		doWatch(args)
+	case "bench":
+		doBench(args)
 	case "connections", "conns":
 		doConnections()
 	case "protocols", "proto":
 		doProtocols()
 	case "config":
-		if len(args) > 0 {
-			doEditSection(args[0])
+		showSecrets = hasFlag(args, "--show-secrets")
+		args = stripFlag(args, "--show-secrets")
+		if len(args) > 0 && args[0] == "schema" {
+			doConfigSchema()
+		} else if len(args) > 0 && args[0] == "find" {
+			doConfigFind(args[1:])
+		} else if len(args) > 0 && args[0] == "diff" {
+			doConfigDiff(args[1:])
+		} else if len(args) > 0 && args[0] == "warnings" {
+			doConfigWarnings()
+		} else if len(args) > 0 && args[0] == "status" {
+			doConfigStatus()
+		} else if len(args) > 0 && args[0] == "lint" {
+			doConfigLint()
+		} else if len(args) > 0 && args[0] == "validate" {
+			doConfigValidate(args[1:])
+		} else if len(args) > 0 && args[0] == "import" {
+			doConfigImport(args[1:])
+		} else if len(args) > 0 {
+			force := hasFlag(args, "--force")
+			args = stripFlag(args, "--force")
+			if name, ok := resolveModuleName(args[0], "server"); ok && allowEdit(name, force) {
+				doEditSection(name, false, false, "")
+			}
 		} else {
 			doShowConfig()
 		}
 	case "tls":
-		doTLS()
+		if len(args) > 0 && args[0] == "check" {
+			doTLSCheck(args[1:])
+		} else if len(args) > 0 && args[0] == "acme" {
+			doTLSACME(args[1:])
+		} else {
+			doTLS()
+		}
 	case "server":
 		doShowServer()
 	case "toggle":
 		if len(args) < 1 {
-			fmt.Printf("  %sUsage: toggle <module|web>%s\n", yellow, reset)
-		} else if args[0] == "web" {
+			doToggleMenu()
+		} else if args[0] == "server" {
+			doToggle(args[0])
+		} else if name, ok := resolveModuleName(args[0], "web"); !ok {
+			break
+		} else if name == "web" {
 			toggleWeb()
 		} else {
-			doToggle(args[0])
+			doToggle(name)
 		}
 	case "edit":
-		if len(args) < 1 {
-			fmt.Printf("  %sUsage: edit <module|server>%s\n", yellow, reset)
-		} else {
-			doEditSection(args[0])
+		showSecrets = hasFlag(args, "--show-secrets")
+		args = stripFlag(args, "--show-secrets")
+		dryRun := hasFlag(args, "--dry-run")
+		args = stripFlag(args, "--dry-run")
+		force := hasFlag(args, "--force")
+		args = stripFlag(args, "--force")
+		resetKey := ""
+		for i := 0; i < len(args); i++ {
+			if args[i] == "--reset" && i+1 < len(args) {
+				resetKey = args[i+1]
+				args = append(args[:i:i], args[i+2:]...)
+				break
+			}
+		}
+		if len(args) < 1 || hasFlag(args, "--reset") {
+			fmt.Printf("  %sUsage: edit <module|server> [-] [--reset <key>] [--dry-run] [--force]%s\n", yellow, reset)
+			exitCode = 2
+		} else if name, ok := resolveModuleName(args[0], "server"); ok && allowEdit(name, force) {
+			doEditSection(name, len(args) > 1 && args[1] == "-", dryRun, resetKey)
 		}
 	case "web":
-		doWeb()
+		if hasFlag(args, "--open") {
+			doOpen()
+		} else {
+			doWeb()
+		}
+	case "open":
+		doOpen()
 	case "help":
 		printHelp()
 	case "clear", "cls":
@@ -177,16 +569,24 @@ func runCmd(input string) {
 	case "exit", "quit":
 		os.Exit(0)
 	default:
+		if runAlias(cmd, args) {
+			return
+		}
 		fmt.Printf("  %s✗ Unknown: %s%s  (type 'help' for commands)\n", red, cmd, reset)
 	}
 }
 
-func apiGet(path string) {
-	req, _ := http.NewRequest("GET", fmt.Sprintf("http://%s%s", addr, path), nil)
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
+func hasFlag(args []string, name string) bool {
+	for _, a := range args {
+		if a == name {
+			return true
+		}
 	}
-	resp, err := client.Do(req)
+	return false
+}
+
+func apiGet(path string) {
+	resp, err := adminRequest("GET", path)
 	if err != nil {
 		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
 		return
@@ -197,11 +597,7 @@ func apiGet(path string) {
 }
 
 func apiPost(path string) {
-	req, _ := http.NewRequest("POST", fmt.Sprintf("http://%s%s", addr, path), nil)
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
-	resp, err := client.Do(req)
+	resp, err := adminRequest("POST", path)
 	if err != nil {
 		if path == "/stop" || path == "/reload" {
 			action := strings.TrimPrefix(path, "/")
@@ -216,24 +612,19 @@ func apiPost(path string) {
 	printJSON(body)
 }
 
-func doPing() {
-	start := time.Now()
-	resp, err := client.Get(fmt.Sprintf("http://%s/ping", addr))
-	elapsed := time.Since(start)
-	if err != nil {
-		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
-		return
+// connErr renders an admin call failure for output. A refused connection
+// almost always means the proxy isn't up, so it gets a hint on what to try.
+func connErr(err error) string {
+	if isConnRefused(err) {
+		return err.Error() + " — proxy not running? Start it with 'run', or check --addr/--profile"
 	}
-	resp.Body.Close()
-	fmt.Printf("  %s✓ pong%s %s(%s)%s\n", green, reset, dim, elapsed.Round(time.Millisecond), reset)
+	return err.Error()
 }
 
-func connErr(err error) string {
+// isConnRefused reports whether nothing was listening at the admin address.
+func isConnRefused(err error) bool {
 	s := err.Error()
-	if strings.Contains(s, "refused") || strings.Contains(s, "No connection") || strings.Contains(s, "target machine actively refused") {
-		return "proxy not running"
-	}
-	return s
+	return strings.Contains(s, "refused") || strings.Contains(s, "No connection") || strings.Contains(s, "target machine actively refused")
 }
 
 func printJSON(data []byte) {
@@ -262,7 +653,31 @@ func printJSON(data []byte) {
 	}
 }
 
+// rootOverride is set by --root or PROXYCACHE_ROOT and bypasses the
+// Cargo.toml search.
+var rootOverride = ""
+
+func setProjectRoot(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(abs); err != nil || !fi.IsDir() {
+		return fmt.Errorf("project root '%s' is not a directory", dir)
+	}
+	_, cargoErr := os.Stat(filepath.Join(abs, "Cargo.toml"))
+	_, cfgErr := os.Stat(filepath.Join(abs, "config.toml"))
+	if cargoErr != nil && cfgErr != nil {
+		return fmt.Errorf("'%s' is not a proxycache project (no Cargo.toml or config.toml)", abs)
+	}
+	rootOverride = abs
+	return nil
+}
+
 func projectRoot() string {
+	if rootOverride != "" {
+		return rootOverride
+	}
 	dir, _ := os.Getwd()
 	for {
 		if _, err := os.Stat(filepath.Join(dir, "Cargo.toml")); err == nil {
@@ -278,11 +693,13 @@ func projectRoot() string {
 	return d
 }
 
-func doCompile() {
+// doCompile builds the proxy and CLI, then restarts into the new CLI when
+// restartCLI is set (not while a command chain is still running).
+func doCompile(restartCLI bool) bool {
 	root := projectRoot()
 
 	if !compileRust() {
-		return
+		return false
 	}
 	fmt.Printf("  %sCompiling CLI...%s\n", yellow, reset)
 	cliDir := filepath.Join(root, "cli")
@@ -292,9 +709,13 @@ func doCompile() {
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		fmt.Printf("  %s✗ CLI build failed%s\n", red, reset)
-		return
+		return false
 	}
 	fmt.Printf("  %s✓ CLI build successful%s\n\n", green, reset)
+	if !restartCLI {
+		fmt.Printf("  %sRestart the CLI to use the new build%s\n", dim, reset)
+		return true
+	}
 
 	fmt.Printf("  %sRestarting CLI...%s\n\n", yellow, reset)
 	time.Sleep(200 * time.Millisecond)
@@ -307,33 +728,80 @@ func doCompile() {
 	cmd.Dir = root
 	_ = cmd.Run()
 	os.Exit(0)
+	return true
 }
 
-func doRun() {
+// defaultRunWait is how long 'run --wait' blocks for /ping when no
+// --timeout is given.
+const defaultRunWait = 30 * time.Second
+
+// parseRunArgs reads run's --wait and --timeout options. A zero duration
+// means don't wait beyond the usual startup check.
+func parseRunArgs(args []string) (time.Duration, error) {
+	var wait time.Duration
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--wait":
+			if wait == 0 {
+				wait = defaultRunWait
+			}
+		case "--timeout":
+			if i+1 >= len(args) {
+				return 0, fmt.Errorf("missing value for --timeout")
+			}
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil || d <= 0 {
+				return 0, fmt.Errorf("invalid timeout: %s", args[i+1])
+			}
+			wait = d
+			i++
+		default:
+			return 0, fmt.Errorf("unknown option: %s", args[i])
+		}
+	}
+	return wait, nil
+}
+
+// doRun starts the proxy detached. With wait > 0 it blocks until /ping
+// answers, failing if that takes longer than wait or the process dies.
+func doRun(wait time.Duration) bool {
 	root := projectRoot()
-	pidFile := filepath.Join(root, ".proxycache.pid")
+	pidFile := pidPath()
 
 	if pid, err := readPID(pidFile); err == nil {
 		if isProcessRunning(pid) {
 			fmt.Printf("  %s! Proxy already running%s (pid %d)\n", yellow, reset, pid)
-			return
+			if wait > 0 {
+				if result, _ := verifyStarted(pid, nil, wait); result != startOK {
+					fmt.Printf("  %s✗ Proxy not ready after %s%s\n", red, wait, reset)
+					return false
+				}
+				fmt.Printf("  %s✓ Proxy ready%s\n", green, reset)
+			}
+			return true
 		}
 	}
 
 	bin := filepath.Join(root, binaryPath())
 	if _, err := os.Stat(bin); err != nil {
 		fmt.Printf("  %s✗ Binary not found. Run 'compile' first.%s\n", red, reset)
-		return
+		return false
 	}
 
-	logOut, err := os.Create(filepath.Join(root, ".proxycache.log"))
+	os.MkdirAll(filepath.Dir(logPath()), 0755)
+	os.MkdirAll(filepath.Dir(pidFile), 0755)
+	openLog := os.Create
+	if logTimestamps {
+		openLog = stampedLog
+	}
+	logOut, err := openLog(logPath())
 	if err != nil {
 		fmt.Printf("  %s✗ Can't create log: %s%s\n", red, err, reset)
-		return
+		return false
 	}
-	logErr, _ := os.Create(filepath.Join(root, ".proxycache.err"))
+	logErr, _ := openLog(errLogPath())
 
-	cmd := exec.Command(bin)
+	cmd := exec.Command(bin, proxyArgs()...)
 	cmd.Dir = root
 	cmd.Stdout = logOut
 	cmd.Stderr = logErr
@@ -347,7 +815,7 @@ func doRun() {
 		if logErr != nil {
 			logErr.Close()
 		}
-		return
+		return false
 	}
 
 	logOut.Close()
@@ -360,27 +828,156 @@ func doRun() {
 		fmt.Printf("  %s⚠ Started but couldn't write PID: %s%s\n", yellow, err, reset)
 	}
 
-	cmd.Process.Release()
+	// Watchdog: reap the child so an early crash is seen with its exit code.
+	// The goroutine simply finishes whenever the proxy eventually exits.
+	exited := make(chan int, 1)
+	go func() {
+		cmd.Wait()
+		exited <- cmd.ProcessState.ExitCode()
+	}()
+
+	timeout := startupTimeout
+	if wait > 0 {
+		timeout = wait
+	}
+	result, code := verifyStarted(pid, exited, timeout)
+	switch result {
+	case startDied:
+		fmt.Printf("  %s✗ Proxy exited immediately%s (exit code %d)\n", red, reset, code)
+		printErrTail(errLogPath())
+		fsys.Remove(pidFile)
+		return false
+	case startNoAPI:
+		if wait > 0 {
+			fmt.Printf("  %s✗ Proxy not ready after %s%s (pid %d still running)\n", red, wait, reset, pid)
+			printErrTail(errLogPath())
+			return false
+		}
+		fmt.Printf("  %s✓ Proxy started%s (pid %d) %s— admin API not responding yet%s\n", green, reset, pid, yellow, reset)
+	default:
+		fmt.Printf("  %s✓ Proxy started%s (pid %d)\n", green, reset, pid)
+	}
+	fmt.Printf("  %sLogs:%s %s, %s\n", dim, reset, logPath(), errLogPath())
+	fmt.Printf("  %sPID file:%s %s\n", dim, reset, pidFile)
+	return true
+}
+
+type startResult int
 
-	fmt.Printf("  %s✓ Proxy started%s (pid %d)\n", green, reset, pid)
-	fmt.Printf("  %sLogs:%s .proxycache.log, .proxycache.err\n", dim, reset)
+const (
+	startOK startResult = iota
+	startNoAPI
+	startDied
+)
+
+const startupTimeout = 5 * time.Second
+
+// verifyStarted polls the new process with backoff until the admin API
+// answers, the process exits, or timeout passes. The exit code is only
+// meaningful for startDied; exited may be nil for a process we didn't spawn.
+func verifyStarted(pid int, exited <-chan int, timeout time.Duration) (startResult, int) {
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Millisecond
+	for time.Now().Before(deadline) {
+		select {
+		case code := <-exited:
+			return startDied, code
+		case <-time.After(delay):
+		}
+		if resp, err := adminRequest("GET", "/ping"); err == nil {
+			drainClose(resp)
+			return startOK, 0
+		}
+		if !isProcessRunning(pid) {
+			select {
+			case code := <-exited:
+				return startDied, code
+			case <-time.After(time.Second):
+				return startDied, -1
+			}
+		}
+		if delay < time.Second {
+			delay *= 2
+		}
+	}
+	return startNoAPI, 0
+}
+
+// tailLines returns the last n non-empty lines of a file.
+func tailLines(path string, n int) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return lastLines(string(data), n)
+}
+
+// lastLines returns the last n non-empty lines of text.
+func lastLines(text string, n int) []string {
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) != "" {
+			lines = append(lines, strings.TrimRight(l, "\r"))
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+func printErrTail(path string) {
+	lines := tailLines(path, 15)
+	if len(lines) == 0 {
+		fmt.Printf("  %s(no output in %s)%s\n", dim, filepath.Base(path), reset)
+		return
+	}
+	fmt.Printf("  %sLast lines of %s:%s\n", dim, filepath.Base(path), reset)
+	for _, l := range lines {
+		fmt.Printf("    %s\n", l)
+	}
 }
 
 func doStatus() {
-	root := projectRoot()
-	pidFile := filepath.Join(root, ".proxycache.pid")
+	pidFile := pidPath()
 
 	pid, pidErr := readPID(pidFile)
 	running := pidErr == nil && isProcessRunning(pid)
 
+	sent := time.Now()
 	resp, apiErr := adminRequest("GET", "/status")
+	received := time.Now()
+
+	if jsonOut {
+		out := map[string]interface{}{"running": running, "api": apiErr == nil}
+		if running {
+			out["pid"] = pid
+			if st, err := procUsage(pid); err == nil {
+				out["rss_bytes"] = st.RSS
+				out["cpu_seconds"] = st.CPU.Seconds()
+			}
+		}
+		if apiErr == nil {
+			var data map[string]interface{}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if json.Unmarshal(body, &data) == nil {
+				out["status"] = data
+				if skew, ok := measureSkew(data, sent, received); ok {
+					out["clock_skew_ms"] = skew.Skew.Milliseconds()
+				}
+			}
+		}
+		printJSONValue(out)
+		return
+	}
 
 	if running {
 		fmt.Printf("  %s✓ Process running%s (pid %d)\n", green, reset, pid)
 	} else {
 		fmt.Printf("  %s✗ Process not running%s\n", red, reset)
 		if pidErr == nil {
-			os.Remove(pidFile)
+			fsys.Remove(pidFile)
 		}
 	}
 
@@ -392,11 +989,23 @@ func doStatus() {
 		if json.Unmarshal(body, &data) == nil {
 			fmt.Printf("\n  %s%sOverview%s\n", bold, cyan, reset)
 			fmt.Printf("  %s%s%s\n", dim, sep, reset)
-			printStatusField("Listen", data["listen"])
-			printStatusField("Backend", data["backend"])
+			printStatusField("Listen", addrField(data["listen"]))
+			printStatusField("Backend", addrField(data["backend"]))
 			printStatusField("Scheme", data["scheme"])
 			printStatusField("Protocols", data["protocols"])
-			printStatusField("Uptime", data["uptime"])
+			if _, ok := data["uptime_seconds"]; ok {
+				printStatusField("Uptime", formatSeconds(data["uptime_seconds"]))
+			} else {
+				printStatusField("Uptime", data["uptime"])
+			}
+			skew, hasSkew := measureSkew(data, sent, received)
+			if hasSkew {
+				printStatusField("Clock Skew", skew.field())
+			}
+			if paused := fetchPaused(); len(paused) > 0 {
+				fmt.Printf("  %s%-16s%s %s%s%s\n", cyan, "Paused", reset, yellow, strings.Join(sortedBoolKeys(paused), ", "), reset)
+			}
+			printConfigSync()
 			fmt.Printf("\n  %s%sTraffic%s\n", bold, cyan, reset)
 			fmt.Printf("  %s%s%s\n", dim, sep, reset)
 			printStatusField("Requests", data["requests_total"])
@@ -404,15 +1013,37 @@ func doStatus() {
 			printStatusField("Errors", data["requests_err"])
 			printStatusField("Bytes In", formatBytes(data["bytes_in"]))
 			printStatusField("Bytes Out", formatBytes(data["bytes_out"]))
-			printStatusField("Avg Latency", fmt.Sprintf("%vms", data["avg_latency_ms"]))
+			printStatusField("Avg Latency", formatMillis(data["avg_latency_ms"]))
 			fmt.Printf("\n  %s%sResources%s\n", bold, cyan, reset)
 			fmt.Printf("  %s%s%s\n", dim, sep, reset)
 			printStatusField("Connections", fmt.Sprintf("%v / %v", data["active_connections"], data["max_connections"]))
 			printStatusField("PID", data["pid"])
+			if running {
+				printProcStats(pid)
+			}
+			if w := skew.warning(); hasSkew && w != "" {
+				fmt.Printf("\n  %s⚠ %s%s\n", yellow, w, reset)
+			}
 		}
 	} else {
 		fmt.Printf("  %s✗ API not responding%s\n", red, reset)
 	}
+	printDoctorWarnings()
+}
+
+// isRunning reports whether the proxy process is alive and its admin API
+// answers /ping. It prints nothing, for use from scripts.
+func isRunning() bool {
+	pid, err := readPID(pidPath())
+	if err != nil || !isProcessRunning(pid) {
+		return false
+	}
+	resp, err := adminRequest("GET", "/ping")
+	if err != nil {
+		return false
+	}
+	drainClose(resp)
+	return resp.StatusCode == 200
 }
 
 func printStatusField(label string, value interface{}) {
@@ -446,8 +1077,7 @@ func formatBytes(v interface{}) string {
 }
 
 func doStop() {
-	root := projectRoot()
-	pidFile := filepath.Join(root, ".proxycache.pid")
+	pidFile := pidPath()
 
 	resp, err := adminRequest("POST", "/stop")
 	if err == nil {
@@ -462,24 +1092,97 @@ func doStop() {
 				fmt.Printf("  %s✓ Process killed%s (pid %d)\n", yellow, reset, pid)
 			}
 		}
-		os.Remove(pidFile)
+		fsys.Remove(pidFile)
 	}
 }
 
-func doReload() {
-	fmt.Printf("  %s● Stopping...%s\n", yellow, reset)
+// reloadWait is how long a reload waits for the new proxy's /ping before
+// rolling back to the previous binary.
+const reloadWait = 15 * time.Second
+
+// doReload rebuilds and restarts the proxy, keeping the running instance
+// up until the new build passes 'proxycache --check' and has answered /ping
+// in a trial run on spare ports. The old and new process can't bind the
+// same ports at once, so there is still a short gap at the swap; if the new
+// one doesn't answer /ping then, the previous binary is put back and
+// started again.
+func doReload() bool {
+	root := projectRoot()
+	bin := filepath.Join(root, binaryPath())
+	ext := filepath.Ext(bin)
+	prevBin := strings.TrimSuffix(bin, ext) + "-prev" + ext
+
+	pid, err := readPID(pidPath())
+	if err != nil || !isProcessRunning(pid) {
+		fmt.Printf("  %s● Compiling...%s\n", yellow, reset)
+		if !compileRust() {
+			return false
+		}
+		fmt.Printf("  %s● Starting...%s\n", yellow, reset)
+		return doRun(0)
+	}
+
+	// A running executable can be renamed but not overwritten on Windows,
+	// so move it aside for cargo and keep it for rollback.
+	fsys.Remove(prevBin)
+	kept := fsys.Rename(bin, prevBin) == nil
+
+	fmt.Printf("  %s● Compiling (old proxy still running)...%s\n", yellow, reset)
+	if !compileRust() {
+		if kept {
+			fsys.Rename(prevBin, bin)
+		}
+		fmt.Printf("  %s✗ Reload aborted, old proxy left running%s (pid %d)\n", red, reset, pid)
+		return false
+	}
+
+	fmt.Printf("  %s● Checking config with the new build...%s\n", yellow, reset)
+	check := exec.Command(bin, append(proxyArgs(), "--check")...)
+	check.Dir = root
+	if out, err := check.CombinedOutput(); err != nil {
+		for _, l := range lastLines(string(out), 15) {
+			fmt.Printf("    %s\n", l)
+		}
+		fmt.Printf("  %s✗ Config check failed, old proxy left running%s (pid %d)\n", red, reset, pid)
+		return false
+	}
+
+	fmt.Printf("  %s● Starting the new build on spare ports...%s\n", yellow, reset)
+	if err := trialStart(bin, reloadWait); err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		fmt.Printf("  %s✗ Trial start failed, old proxy left running%s (pid %d)\n", red, reset, pid)
+		return false
+	}
+
+	fmt.Printf("  %s● Swapping...%s\n", yellow, reset)
 	doStop()
 	time.Sleep(300 * time.Millisecond)
-	fmt.Printf("  %s● Compiling...%s\n", yellow, reset)
-	if !compileRust() {
-		return
+	if doRun(reloadWait) {
+		fsys.Remove(prevBin)
+		printConfigWarnings()
+		return true
+	}
+	if !kept {
+		return false
+	}
+
+	fmt.Printf("  %s● New build didn't come up, restoring the previous binary...%s\n", yellow, reset)
+	doStop()
+	failed := strings.TrimSuffix(bin, ext) + "-failed" + ext
+	fsys.Remove(failed)
+	fsys.Rename(bin, failed)
+	if err := fsys.Rename(prevBin, bin); err != nil {
+		fmt.Printf("  %s✗ Can't restore previous binary: %s%s\n", red, err, reset)
+		return false
+	}
+	if doRun(0) {
+		fmt.Printf("  %s! Rolled back to the previous build%s %s(new build kept as %s)%s\n", yellow, reset, dim, filepath.Base(failed), reset)
 	}
-	fmt.Printf("  %s● Starting...%s\n", yellow, reset)
-	doRun()
+	return false
 }
 
 func readPID(path string) (int, error) {
-	data, err := os.ReadFile(path)
+	data, err := fsys.ReadFile(path)
 	if err != nil {
 		return 0, err
 	}
@@ -487,7 +1190,7 @@ func readPID(path string) (int, error) {
 }
 
 func writePID(path string, pid int) error {
-	return os.WriteFile(path, []byte(strconv.Itoa(pid)), 0644)
+	return fsys.WriteFile(path, []byte(strconv.Itoa(pid)), 0644)
 }
 
 func isProcessRunning(pid int) bool {
@@ -506,53 +1209,137 @@ func killProcess(pid int) bool {
 	return proc.Kill() == nil
 }
 
-func doLogs() {
-	root := projectRoot()
-	logPath := filepath.Join(root, ".proxycache.log")
-
-	data, err := os.ReadFile(logPath)
+func doLogs(args []string) {
+	var since time.Time
+	if len(args) > 0 {
+		var err error
+		if len(args) == 2 && args[0] == "--since" {
+			since, err = parseTimeArg(args[1], time.Now())
+		} else {
+			err = fmt.Errorf("usage: logs [--since 10m|2024-01-01T00:00:00]")
+		}
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+			exitCode = 2
+			return
+		}
+	}
+	data, err := os.ReadFile(logPath())
 	if err != nil {
 		fmt.Printf("  %s✗ Can't read logs: %s%s\n", red, err, reset)
 		return
 	}
 
 	lines := strings.Split(string(data), "\n")
+	if !since.IsZero() {
+		doLogsSince(lines, since)
+		return
+	}
 	start := len(lines) - 50
 	if start < 0 {
 		start = 0
 	}
 
-	fmt.Printf("  %sLast 50 lines of .proxycache.log:%s\n", dim, reset)
+	fmt.Printf("  %sLast 50 lines of %s:%s\n", dim, displayPath(logPath()), reset)
 	fmt.Printf("  %s%s%s\n", dim, sep, reset)
 	for _, line := range lines[start:] {
 		if line != "" {
-			fmt.Println(line)
+			fmt.Println(formatLogLine(line))
 		}
 	}
 }
 
+// doLogsSince prints the lines stamped at or after since. Lines without a
+// readable timestamp are skipped.
+func doLogsSince(lines []string, since time.Time) {
+	fmt.Printf("  %sLines since %s in %s:%s\n", dim, formatTime(since), displayPath(logPath()), reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	shown := 0
+	for _, line := range lines {
+		if t, ok := logLineTime(line); ok && !t.Before(since) {
+			fmt.Println(formatLogLine(line))
+			shown++
+		}
+	}
+	if shown == 0 {
+		fmt.Printf("  %sNo log lines since then%s\n", dim, reset)
+	}
+}
+
+// readTextFile reads a config or .pcmod file, dropping a UTF-8 BOM and
+// normalizing CRLF line endings left behind by Windows editors.
+func readTextFile(path string) ([]byte, error) {
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeText(data), nil
+}
+
+func normalizeText(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
 func configPath() string {
+	if configOverride != "" {
+		return configOverride
+	}
+	if p := instanceConfigPath(); p != "" {
+		return p
+	}
 	return filepath.Join(projectRoot(), "config.toml")
 }
 
 func loadConfigTOML() (map[string]interface{}, error) {
-	data, err := os.ReadFile(configPath())
+	cfg, err := parseConfigFile(configPath())
 	if err != nil {
 		return nil, err
 	}
-	var cfg map[string]interface{}
-	if err := toml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
-	}
+	mergeIncludes(cfg, loadIncludes(cfg))
 	return cfg, nil
 }
 
 func saveConfigTOML(cfg map[string]interface{}) error {
+	if len(includePatterns(cfg)) > 0 {
+		return saveWithIncludes(cfg)
+	}
 	data, err := toml.Marshal(cfg)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath(), data, 0644)
+	return fsys.WriteFile(configPath(), data, 0644)
+}
+
+var errConfigChanged = errors.New("config.toml changed on disk since it was loaded")
+
+// configVersion identifies the current on-disk config by mtime and size,
+// including any included files.
+func configVersion() string {
+	fi, err := fsys.Stat(configPath())
+	if err != nil {
+		return ""
+	}
+	v := fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size())
+	if main, err := parseConfigFile(configPath()); err == nil {
+		files, _ := includeFiles(main)
+		for _, f := range files {
+			if fi, err := fsys.Stat(f); err == nil {
+				v += fmt.Sprintf("|%d-%d", fi.ModTime().UnixNano(), fi.Size())
+			}
+		}
+	}
+	return v
+}
+
+// saveConfigTOMLAt saves only if the file is still at the given version,
+// so a concurrent edit isn't silently overwritten. An empty version skips
+// the check.
+func saveConfigTOMLAt(cfg map[string]interface{}, version string) error {
+	if version != "" && configVersion() != version {
+		return errConfigChanged
+	}
+	return saveConfigTOML(cfg)
 }
 
 func getModules(cfg map[string]interface{}) map[string]interface{} {
@@ -567,18 +1354,23 @@ func getModules(cfg map[string]interface{}) map[string]interface{} {
 	return m
 }
 
-func doListModules() {
+// doListModules prints each module's on/off state; withSettings adds its
+// settings inline.
+func doListModules(withSettings bool) {
 	cfg, err := loadConfigTOML()
 	if err != nil {
 		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
 		return
 	}
 
-	fmt.Printf("  %s%-20s %s%s\n", dim, "NAME", "STATUS", reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	t := newTable("NAME", "STATUS")
+	if withSettings {
+		t = newTable("NAME", "STATUS", "SETTINGS")
+	}
+	defer t.print()
 
 	if _, ok := cfg["server"].(map[string]interface{}); ok {
-		fmt.Printf("  %-20s %s%-8s%s\n", "server", cyan, "core", reset)
+		t.row("server", cyan+"core"+reset)
 	}
 
 	mods := getModules(cfg)
@@ -591,10 +1383,11 @@ func doListModules() {
 		names = append(names, k)
 	}
 	sort.Strings(names)
+	paused := fetchPaused()
 
 	for _, name := range names {
 		// Skip internal modules from CLI display
-		if name == "proxy_core" {
+		if protectedModules[name] {
 			continue
 		}
 		mod, ok := mods[name].(map[string]interface{})
@@ -610,7 +1403,10 @@ func doListModules() {
 		}
 
 		var statusIcon, statusColor string
-		if enabled {
+		if enabled && paused[name] {
+			statusIcon = "⏸ paused"
+			statusColor = yellow
+		} else if enabled {
 			statusIcon = "✓ on"
 			statusColor = green
 		} else {
@@ -618,21 +1414,53 @@ func doListModules() {
 			statusColor = red
 		}
 
-		fmt.Printf("  %-20s %s%-8s%s\n", name, statusColor, statusIcon, reset)
+		if withSettings {
+			t.row(name, statusColor+statusIcon+reset, dim+moduleSettingsSummary(name, mod)+reset)
+		} else {
+			t.row(name, statusColor+statusIcon+reset)
+		}
 	}
 
 	if isWebEnabled() {
-		fmt.Printf("  %-20s %s%-8s%s\n", "web", green, "✓ on", reset)
+		t.row("web", green+"✓ on"+reset)
 	} else {
-		fmt.Printf("  %-20s %s%-8s%s\n", "web", red, "✗ off", reset)
+		t.row("web", red+"✗ off"+reset)
 	}
 }
 
+// protectedModules carry the core request path. They're hidden from ls,
+// config and the dashboard; toggle refuses them and edit needs --force.
+var protectedModules = map[string]bool{
+	"proxy_core": true,
+}
+
+// allowEdit guards interactive edits of protected modules, which need
+// --force. Both edit and config <name> go through it.
+func allowEdit(name string, force bool) bool {
+	if !protectedModules[name] {
+		return true
+	}
+	if !force {
+		fmt.Printf("  %s✗ %s is a core module; a bad value can stop the proxy forwarding requests%s\n", red, name, reset)
+		fmt.Printf("  %sAdd --force to edit it anyway%s\n", dim, reset)
+		exitCode = 1
+		return false
+	}
+	fmt.Printf("  %s⚠ Editing core module %s (--force)%s\n", yellow, name, reset)
+	return true
+}
+
 func doToggle(name string) {
 	if name == "server" {
 		fmt.Printf("  %s✗ Can't toggle server, use 'edit server'%s\n", red, reset)
 		return
 	}
+	if protectedModules[name] {
+		fmt.Printf("  %s✗ %s is a core module and can't be toggled%s\n", red, name, reset)
+		fmt.Printf("  %sIt forwards requests to the backend; without it the proxy answers every request with 500%s\n", dim, reset)
+		exitCode = 1
+		return
+	}
 	cfg, err := loadConfigTOML()
 	if err != nil {
 		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
@@ -672,13 +1500,20 @@ func doToggle(name string) {
 	} else {
 		fmt.Printf("  %s✗ %s disabled%s\n", yellow, name, reset)
 	}
-	fmt.Printf("  %sRun 'reload' to apply changes%s\n", dim, reset)
+	printApplyHints(name, []string{"enabled"})
 }
 
-func doEditSection(name string) {
+// doEditSection edits one config section. Lines use the same grammar in
+// both modes: "key=value" sets a key, "del key" removes it and "reset key"
+// restores its schema default. With fromStdin the lines are read from a
+// pipe and applied in a single save; a non-empty resetKey applies just
+// "reset resetKey". dryRun reports the changes without writing config.toml.
+func doEditSection(name string, fromStdin, dryRun bool, resetKey string) {
+	version := configVersion()
 	cfg, err := loadConfigTOML()
 	if err != nil {
 		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
 		return
 	}
 
@@ -689,6 +1524,7 @@ func doEditSection(name string) {
 		s, ok := cfg["server"].(map[string]interface{})
 		if !ok {
 			fmt.Printf("  %s✗ No server section in config%s\n", red, reset)
+			exitCode = 1
 			return
 		}
 		section = s
@@ -697,65 +1533,109 @@ func doEditSection(name string) {
 		mods := getModules(cfg)
 		if mods == nil {
 			fmt.Printf("  %s✗ No modules section in config%s\n", red, reset)
+			exitCode = 1
 			return
 		}
 		m, ok := mods[name].(map[string]interface{})
 		if !ok {
 			fmt.Printf("  %s✗ '%s' not found%s\n", red, name, reset)
 			fmt.Printf("  %sTip: use 'ls' to see available entries%s\n", dim, reset)
+			exitCode = 1
 			return
 		}
 		section = m
 		sectionLabel = fmt.Sprintf("[modules.%s]", name)
 	}
 
-	keys := make([]string, 0, len(section))
-	for k := range section {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	fmt.Printf("  %s%s%s%s\n", bold, cyan, sectionLabel, reset)
-	for _, k := range keys {
-		fmt.Printf("    %s%-20s%s = %v\n", cyan, k, reset, section[k])
-	}
-	fmt.Printf("\n  %sEdit key=value (empty line to finish):%s\n", dim, reset)
-
 	sc := bufio.NewScanner(os.Stdin)
-	changed := false
-	for {
-		fmt.Printf("  %s→%s ", yellow, reset)
-		if !sc.Scan() {
-			break
+	var changed []string
+	if resetKey != "" {
+		key, err := applyEditLine(name, section, "reset "+resetKey)
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+			exitCode = 1
+			return
 		}
-		line := strings.TrimSpace(sc.Text())
-		if line == "" {
-			break
+		if key != "" {
+			changed = append(changed, key)
 		}
-
-		eqIdx := strings.Index(line, "=")
-		if eqIdx < 0 {
-			fmt.Printf("    %s✗ Format: key=value%s\n", red, reset)
-			continue
+	} else if fromStdin {
+		lineNo := 0
+		for sc.Scan() {
+			lineNo++
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, err := applyEditLine(name, section, line)
+			if err != nil {
+				fmt.Printf("  %s✗ stdin line %d: %s%s\n", red, lineNo, err, reset)
+				fmt.Printf("  %sNothing saved%s\n", dim, reset)
+				exitCode = 1
+				return
+			}
+			if key != "" && !hasFlag(changed, key) {
+				changed = append(changed, key)
+			}
 		}
+		if err := sc.Err(); err != nil {
+			fmt.Printf("  %s✗ Can't read stdin: %s%s\n", red, err, reset)
+			exitCode = 1
+			return
+		}
+	} else {
+		keys := make([]string, 0, len(section))
+		for k := range section {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
 
-		key := strings.TrimSpace(line[:eqIdx])
-		valStr := strings.TrimSpace(line[eqIdx+1:])
-
-		if _, exists := section[key]; !exists {
-			fmt.Printf("    %s+ Adding new key '%s'%s\n", yellow, key, reset)
+		fmt.Printf("  %s%s%s%s\n", bold, cyan, sectionLabel, reset)
+		for _, k := range keys {
+			fmt.Printf("    %s%-20s%s = %v\n", cyan, k, reset, redactValue(k, section[k], showSecrets))
 		}
+		printDisabledReminder(name, section)
+		fmt.Printf("\n  %sEdit key=value, 'del key' to remove, 'reset key' for the default (empty line to finish):%s\n", dim, reset)
 
-		section[key] = parseValue(valStr)
-		changed = true
-		fmt.Printf("    %s✓ %s = %v%s\n", green, key, section[key], reset)
+		in := newEditReader(editKeys(name, section))
+		if in.raw {
+			fmt.Printf("  %sTab completes key names%s\n", dim, reset)
+		}
+		for {
+			raw, ok, err := in.readLine(fmt.Sprintf("  %s→%s ", yellow, reset))
+			if err == errEditCancelled {
+				fmt.Printf("  %sCancelled, nothing saved%s\n", dim, reset)
+				return
+			}
+			line := strings.TrimSpace(raw)
+			if !ok || line == "" {
+				break
+			}
+			key, err := applyEditLine(name, section, line)
+			if err != nil {
+				fmt.Printf("    %s✗ %s%s\n", red, err, reset)
+				continue
+			}
+			if key != "" && !hasFlag(changed, key) {
+				changed = append(changed, key)
+			}
+		}
 	}
 
-	if !changed {
+	if len(changed) == 0 {
 		fmt.Printf("  %sNo changes%s\n", dim, reset)
 		return
 	}
 
+	if dryRun {
+		fmt.Printf("  %sDry run: %d key(s) in %s would change, nothing saved%s\n", yellow, len(changed), sectionLabel, reset)
+		printApplyHints(name, changed)
+		if fromStdin || resetKey != "" {
+			printDisabledReminder(name, section)
+		}
+		return
+	}
+
 	if name == "server" {
 		cfg["server"] = section
 	} else {
@@ -764,11 +1644,136 @@ func doEditSection(name string) {
 		cfg["modules"] = mods
 	}
 
-	if err := saveConfigTOML(cfg); err != nil {
+	if err := saveConfigTOMLAt(cfg, version); err == errConfigChanged {
+		fmt.Printf("  %s✗ Not saved: %s%s\n", red, err, reset)
+		fmt.Printf("  %sRun 'edit %s' again to edit the current file%s\n", dim, name, reset)
+		exitCode = 1
+		return
+	} else if err != nil {
 		fmt.Printf("  %s✗ Can't save config: %s%s\n", red, err, reset)
+		exitCode = 1
 		return
 	}
-	fmt.Printf("  %s✓ Saved%s. Run 'reload' to apply changes\n", green, reset)
+	fmt.Printf("  %s✓ Saved%s\n", green, reset)
+	printApplyHints(name, changed)
+	if fromStdin || resetKey != "" {
+		printDisabledReminder(name, section)
+	}
+}
+
+// printDisabledReminder notes that edits to a disabled module won't take
+// effect until it is enabled.
+func printDisabledReminder(name string, section map[string]interface{}) {
+	if name == "server" {
+		return
+	}
+	if enabled, _ := section["enabled"].(bool); enabled {
+		return
+	}
+	fmt.Printf("  %s(module is disabled — enable it to apply these settings)%s\n", dim, reset)
+}
+
+// applyEditLine applies one "key=value", "del key" or "reset key" line to
+// section (named name, for the schema lookup) and returns the key it
+// changed, or "" if the line was a no-op.
+func applyEditLine(name string, section map[string]interface{}, line string) (string, error) {
+	eqIdx := strings.Index(line, "=")
+	if eqIdx < 0 {
+		if f := strings.Fields(line); len(f) == 2 && f[0] == "reset" {
+			return resetToDefault(name, section, f[1])
+		}
+		if f := strings.Fields(line); len(f) == 2 && f[0] == "del" {
+			key := f[1]
+			if _, exists := section[key]; !exists {
+				return "", fmt.Errorf("no key '%s' to delete", key)
+			}
+			delete(section, key)
+			fmt.Printf("    %s- %s removed%s\n", yellow, key, reset)
+			return key, nil
+		}
+		return "", fmt.Errorf("format: key=value, del key or reset key")
+	}
+
+	key := strings.TrimSpace(line[:eqIdx])
+	if key == "" {
+		return "", fmt.Errorf("missing key before '='")
+	}
+	valStr, comment := splitComment(line[eqIdx+1:])
+
+	if _, exists := section[key]; !exists {
+		fmt.Printf("    %s+ Adding new key '%s'%s\n", yellow, key, reset)
+		if best := closestName(key, editKeys(name, section)); best != "" {
+			fmt.Printf("    %sDid you mean '%s'? Use 'del %s' to undo%s\n", dim, best, key, reset)
+		}
+	}
+
+	v := parseValueFor(valStr, section[key], sectionSchema(name)[key].Type)
+	if isDurationKey(name, key) {
+		secs, err := durationSeconds(v)
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", key, err)
+		}
+		if _, isStr := v.(string); isStr {
+			fmt.Printf("    %s(%s stored as %d seconds)%s\n", dim, v, secs, reset)
+		}
+		v = secs
+	}
+	if raw, isStr := v.(string); isStr && isAddrKey(name, key) {
+		addr, err := normalizeAddr(name, key, raw)
+		if err != nil {
+			return "", err
+		}
+		if addr != raw {
+			fmt.Printf("    %s(%s stored as %s)%s\n", dim, raw, addr, reset)
+		}
+		v = addr
+	}
+	section[key] = v
+	fmt.Printf("    %s✓ %s = %v%s\n", green, key, redactValue(key, section[key], showSecrets), reset)
+	if comment != "" {
+		fmt.Printf("    %s(comment dropped: config.toml is rewritten without comments)%s\n", dim, reset)
+	}
+	return key, nil
+}
+
+// resetToDefault sets key back to its documented default and reports the
+// old and new value.
+func resetToDefault(name string, section map[string]interface{}, key string) (string, error) {
+	spec, ok := sectionSchema(name)[key]
+	if !ok {
+		return "", fmt.Errorf("no documented default for '%s' (see 'config schema')", key)
+	}
+	old, exists := section[key]
+	if exists && reflect.DeepEqual(old, spec.Default) {
+		fmt.Printf("    %s%s is already at its default (%v)%s\n", dim, key, redactValue(key, old, showSecrets), reset)
+		return "", nil
+	}
+	section[key] = spec.Default
+	was := "unset"
+	if exists {
+		was = fmt.Sprint(redactValue(key, old, showSecrets))
+	}
+	fmt.Printf("    %s✓ %s: %s → %v (default)%s\n", green, key, was, redactValue(key, spec.Default, showSecrets), reset)
+	return key, nil
+}
+
+// splitComment separates a trailing "# comment" from a value, ignoring
+// '#' inside quoted strings.
+func splitComment(s string) (val, comment string) {
+	var quote rune
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+		}
+	}
+	return strings.TrimSpace(s), ""
 }
 
 func parseValue(s string) interface{} {
@@ -802,6 +1807,39 @@ func parseValue(s string) interface{} {
 	return s
 }
 
+// parseValueFor parses s like parseValue but keeps the key's type where
+// the literal is ambiguous: an unquoted 1234 for a string key such as
+// api_key stays a string, and a whole number for a float key stays a float,
+// since the proxy reads each key as one TOML type and ignores the rest. ref
+// is the key's current value, or nil to go by typ, its schema type.
+func parseValueFor(s string, ref interface{}, typ string) interface{} {
+	v := parseValue(s)
+	if ref == nil {
+		switch typ {
+		case "string":
+			ref = ""
+		case "integer":
+			ref = int64(0)
+		}
+	}
+	switch ref.(type) {
+	case string:
+		switch v.(type) {
+		case int64, float64, bool:
+			return s
+		}
+	case float64:
+		if n, ok := v.(int64); ok {
+			return float64(n)
+		}
+	case int64:
+		if f, ok := v.(float64); ok && f == float64(int64(f)) {
+			return int64(f)
+		}
+	}
+	return v
+}
+
 func compileRust() bool {
 	root := projectRoot()
 	fmt.Printf("  %sCompiling Rust...%s\n", yellow, reset)
@@ -826,7 +1864,11 @@ func binaryPath() string {
 }
 
 func doMetrics() {
-	resp, err := adminRequest("GET", "/metrics")
+	resp, err := adminOptional("GET", "/metrics")
+	if errors.Is(err, errUnavailable) {
+		printUnavailable("Metrics")
+		return
+	}
 	if err != nil {
 		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
 		return
@@ -838,37 +1880,21 @@ func doMetrics() {
 		fmt.Println(string(body))
 		return
 	}
-	fmt.Printf("  %s%sRequests%s\n", bold, cyan, reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
-	printStatusField("Total", data["requests_total"])
-	printStatusField("OK", data["requests_ok"])
-	printStatusField("Errors", data["requests_err"])
-	fmt.Printf("\n  %s%sBandwidth%s\n", bold, cyan, reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
-	printStatusField("Bytes In", formatBytes(data["bytes_in"]))
-	printStatusField("Bytes Out", formatBytes(data["bytes_out"]))
-	fmt.Printf("\n  %s%sLatency%s\n", bold, cyan, reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
-	printStatusField("Avg (ms)", data["avg_latency_ms"])
-	printStatusField("Max (ms)", data["latency_max_ms"])
-	printStatusField("Sum (ms)", data["latency_sum_ms"])
-	fmt.Printf("\n  %s%sConnections%s\n", bold, cyan, reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
-	printStatusField("Active", data["active_connections"])
-	printStatusField("Total", data["connections_total"])
-	printStatusField("Pool Hits", data["pool_hits"])
-	printStatusField("Pool Misses", data["pool_misses"])
-	fmt.Printf("\n  %s%sCircuit Breaker%s\n", bold, cyan, reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
-	printStatusField("Trips", data["cb_trips"])
-	printStatusField("Rejects", data["cb_rejects"])
-	fmt.Printf("\n  %s%sSystem%s\n", bold, cyan, reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
-	printStatusField("Uptime", fmt.Sprintf("%vs", data["uptime_secs"]))
+	if jsonOut {
+		printJSONValue(data)
+		return
+	}
+	global, modules := groupMetrics(data)
+	printGlobalMetrics(global, data)
+	printMetricGroups(modules, data)
 }
 
 func doConnections() {
-	resp, err := adminRequest("GET", "/connections")
+	resp, err := adminOptional("GET", "/connections")
+	if errors.Is(err, errUnavailable) {
+		printUnavailable("Connections")
+		return
+	}
 	if err != nil {
 		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
 		return
@@ -888,11 +1914,21 @@ func doConnections() {
 	printStatusField("Active", active)
 	printStatusField("Max Allowed", max)
 	printStatusField("Total Served", total)
+	if m, ok := max.(float64); ok {
+		cl := checkConnLimit(int64(m))
+		printStatusField("Open File Limit", cl.fdField())
+		if w := cl.warning(); w != "" {
+			fmt.Printf("  %s⚠ %s%s\n", yellow, w, reset)
+		}
+	}
 }
 
 func doProtocols() {
-	resp, err := adminRequest("GET", "/protocols")
+	resp, err := adminOptional("GET", "/protocols")
 	if err != nil {
+		if errors.Is(err, errUnavailable) {
+			fmt.Printf("  %s/protocols: %s, showing config%s\n\n", dim, err, reset)
+		}
 		// Offline: read from config
 		cfg, cfgErr := loadConfigTOML()
 		if cfgErr != nil {
@@ -966,8 +2002,11 @@ func doProtocols() {
 }
 
 func doTLS() {
-	resp, err := adminRequest("GET", "/tls")
+	resp, err := adminOptional("GET", "/tls")
 	if err != nil {
+		if errors.Is(err, errUnavailable) {
+			fmt.Printf("  %s/tls: %s, showing config%s\n\n", dim, err, reset)
+		}
 		// Offline
 		cfg, cfgErr := loadConfigTOML()
 		if cfgErr != nil {
@@ -985,6 +2024,9 @@ func doTLS() {
 		} else {
 			printStatusField("Cert", cert)
 			printStatusField("Key", key)
+			if ocsp, _ := srv["tls_ocsp"].(string); ocsp != "" {
+				printStatusField("OCSP", ocsp)
+			}
 		}
 		return
 	}
@@ -1014,7 +2056,8 @@ func doTLS() {
 			fmt.Printf("  %sKey File:%s  %s✗ missing%s\n", cyan, reset, red, reset)
 		}
 		printStatusField("ALPN", data["alpn_protocols"])
-		printStatusField("Session Cache", data["session_cache_size"])
+		printTLSProbe()
+		printTLSSession(data)
 	} else {
 		fmt.Printf("  %s✗ TLS not configured%s\n", red, reset)
 		fmt.Printf("  %sSet tls_cert and tls_key in [server] to enable%s\n", dim, reset)
@@ -1022,6 +2065,10 @@ func doTLS() {
 }
 
 func doShowConfig() {
+	if jsonOut {
+		doShowConfigJSON()
+		return
+	}
 	// Try API first
 	resp, err := adminRequest("GET", "/server")
 	if err != nil {
@@ -1034,7 +2081,7 @@ func doShowConfig() {
 		fmt.Printf("  %s%s[server]%s %s(from config.toml)%s\n", bold, cyan, reset, dim, reset)
 		fmt.Printf("  %s%s%s\n", dim, sep, reset)
 		if srv, ok := cfg["server"].(map[string]interface{}); ok {
-			printSortedKV(srv)
+			printSortedKV(withDurations("server", redacted(srv, showSecrets)))
 		}
 		fmt.Printf("\n  %s%s[modules]%s %s(from config.toml)%s\n", bold, cyan, reset, dim, reset)
 		fmt.Printf("  %s%s%s\n", dim, sep, reset)
@@ -1042,7 +2089,7 @@ func doShowConfig() {
 			names := sortedKeys(mods)
 			for _, name := range names {
 				// Skip internal modules from CLI display
-				if name == "proxy_core" {
+				if protectedModules[name] {
 					continue
 				}
 				mod, ok := mods[name].(map[string]interface{})
@@ -1058,17 +2105,8 @@ func doShowConfig() {
 					icon = green + "✓" + reset
 				}
 				fmt.Printf("  %s %s%-16s%s", icon, cyan, name, reset)
-				// Show key settings inline
-				keys := sortedKeys(mod)
-				parts := []string{}
-				for _, k := range keys {
-					if k == "enabled" {
-						continue
-					}
-					parts = append(parts, fmt.Sprintf("%s=%v", k, mod[k]))
-				}
-				if len(parts) > 0 {
-					fmt.Printf(" %s%s%s", dim, strings.Join(parts, ", "), reset)
+				if summary := moduleSettingsSummary(name, mod); summary != "" {
+					fmt.Printf(" %s%s%s", dim, summary, reset)
 				}
 				fmt.Println()
 			}
@@ -1084,7 +2122,21 @@ func doShowConfig() {
 	}
 	fmt.Printf("  %s%s[server]%s %s(live)%s\n", bold, cyan, reset, dim, reset)
 	fmt.Printf("  %s%s%s\n", dim, sep, reset)
-	printSortedKV(data)
+	printSortedKV(withDurations("server", redacted(data, showSecrets)))
+}
+
+// moduleSettingsSummary renders a module's settings other than enabled as
+// "key=value, ..." with secrets masked.
+func moduleSettingsSummary(name string, mod map[string]interface{}) string {
+	parts := []string{}
+	mod = withDurations(name, mod)
+	for _, k := range sortedKeys(mod) {
+		if k == "enabled" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", k, redactValue(k, mod[k], showSecrets)))
+	}
+	return strings.Join(parts, ", ")
 }
 
 func doShowServer() {
@@ -1125,31 +2177,71 @@ func sortedKeys(m map[string]interface{}) []string {
 
 func printHelp() {
 	fmt.Printf("  %s%sProxy Control%s\n", bold, cyan, reset)
-	fmt.Printf("    %srun%s         Start proxy (detached)\n", cyan, reset)
+	fmt.Printf("    %srun%s         Start proxy (detached)     %s(run --wait --timeout 30s blocks until /ping answers)%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %sstatus%s      Full proxy status + metrics summary\n", cyan, reset)
+	fmt.Printf("    %sdoctor%s      Local health checks: disk space, log sizes, stale build\n", cyan, reset)
+	fmt.Printf("    %sis-running%s  Exit 0 if proxy + API are up, no output\n", cyan, reset)
 	fmt.Printf("    %sstop%s        Stop the proxy\n", cyan, reset)
-	fmt.Printf("    %sreload%s      Stop → compile → start\n", cyan, reset)
-	fmt.Printf("    %slogs%s        Show last 50 log lines\n", cyan, reset)
-	fmt.Printf("    %sping%s        Quick connectivity check\n\n", cyan, reset)
+	fmt.Printf("    %sreload%s      Compile → check → swap, rolls back on failure  %s(--smoke to test traffic after, --no-hooks to skip [hooks])%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sreload --config-only%s  Hot-reload config.toml, no compile or restart\n", cyan, reset)
+	fmt.Printf("    %sreload --if-changed%s  Skip the reload when neither source nor config changed since the proxy started\n", cyan, reset)
+	fmt.Printf("    %slogs%s        Show last 50 log lines  %s(logs --since 10m)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %swatch logs%s  Live metrics header above a scrolling log tail  %s(watch logs --interval 1s)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %slog format%s  Switch proxy logs between text and JSON  %s(log format json)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sping%s        Quick connectivity check  %s(ping --path /healthz --expect-status 200 --expect-body ok)%s\n\n", cyan, reset, dim, reset)
 	fmt.Printf("  %s%sMonitoring%s\n", bold, cyan, reset)
-	fmt.Printf("    %smetrics%s     Full metrics (requests, latency, pool, CB)\n", cyan, reset)
+	fmt.Printf("    %smetrics%s     Metrics, proxy-wide first, then grouped by module  %s(metrics cache)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %smetrics push%s  Forward metrics to a collector  %s(metrics push --statsd 127.0.0.1:8125)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %smetrics record%s  Keep metrics history in SQLite  %s(metrics record --db proxycache.db --interval 30s)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %smetrics query%s  Metrics history over a time range  %s(metrics query --since 2h --columns requests_total,latency_avg_ms)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sstats reset%s Zero the counters before a measurement  %s(stats reset --yes)%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %sconns%s       Active/max/total connections\n", cyan, reset)
 	fmt.Printf("    %sprotocols%s   HTTP/1.1, HTTP/2, HTTP/3 status\n", cyan, reset)
-	fmt.Printf("    %stls%s         TLS configuration and cert status\n\n", cyan, reset)
+	fmt.Printf("    %stls%s         TLS configuration and cert status\n", cyan, reset)
+	fmt.Printf("    %stls check%s   Cert expiry check for cron  %s(tls check --warn-days 30 --exec \"notify.sh\")%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %stls acme%s    Get a Let's Encrypt cert      %s(tls acme --domain example.com --agree-tos --email me@example.com)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %ssnapshot%s    Status, metrics, config + logs in one JSON file for bug reports\n", cyan, reset)
+	fmt.Printf("    %sclean%s       Remove stale .bak, log and PID files, keeps the ones in use  %s(clean --dry-run)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sfleet%s       Status of every profile in .proxycache-cli.toml\n", cyan, reset)
+	fmt.Printf("    %sfleet diff%s  Config keys that differ between profiles, key × instance  %s(--show-secrets to unmask keys)%s\n\n", cyan, reset, dim, reset)
 	fmt.Printf("  %s%sConfiguration%s\n", bold, cyan, reset)
-	fmt.Printf("    %sconfig%s      Show full server + module config\n", cyan, reset)
-	fmt.Printf("    %sls%s          List modules with on/off status\n", cyan, reset)
-	fmt.Printf("    %stoggle%s      Toggle module on/off       %s(toggle rate_limiter)%s\n", cyan, reset, dim, reset)
-	fmt.Printf("    %sedit%s        Edit server or module      %s(edit server, edit cache)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %ssetup%s       Guided first-run config: addresses, TLS, admin key, modules\n", cyan, reset)
+	fmt.Printf("    %sconfig%s      Show full server + module config  %s(--show-secrets to unmask keys)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sconfig schema%s  JSON Schema for editor validation\n", cyan, reset)
+	fmt.Printf("    %sconfig find%s  Search keys and values     %s(config find timeout, config find --regex '^max_')%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sconfig status%s Whether config.toml changed since the proxy loaded it, i.e. a reload is pending\n", cyan, reset)
+	fmt.Printf("    %sconfig lint%s  Config problems plus risky settings, e.g. an open admin API\n", cyan, reset)
+	fmt.Printf("    %sconfig validate%s Lint a candidate file without touching config.toml, exit 1 on errors  %s(config validate next.toml)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sconfig warnings%s Keys the running proxy ignored on its last load (typos, removed settings)\n", cyan, reset)
+	fmt.Printf("    %sconfig import%s Replace config.toml after validating, keeps a .bak  %s(cat new.toml | proxycache config import -)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sconfig diff%s  Preview replacing config.toml with a file  %s(config diff, config diff staging.toml)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sls%s          List modules with on/off status  %s(ls -v adds each module's settings)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %stoggle%s      Toggle module on/off       %s(toggle rate_limiter, bare 'toggle' opens a checklist)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sedit%s        Edit server or module      %s(edit server, edit cache; core modules need --force)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sedit --reset%s Restore a key's documented default  %s(edit cache --reset ttl_seconds)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sedit -%s      Apply key=value / del key lines from stdin  %s(echo \"listen_addr=0.0.0.0:8080\" | proxycache edit server - --dry-run)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sadmin%s       Admin API bind address, key and IP allowlist  %s(admin bind 127.0.0.1:9090, admin allow 10.0.0.0/8)%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %sverify%s      Verify config.toml integrity\n", cyan, reset)
-	fmt.Printf("    %srepair%s      Auto-repair config with missing defaults\n\n", cyan, reset)
+	fmt.Printf("    %srepair%s      Auto-repair config with missing defaults and modules\n\n", cyan, reset)
 	fmt.Printf("  %s%sModules%s\n", bold, cyan, reset)
-	fmt.Printf("    %smods%s        List script (.pcmod) + Rust + imported modules\n\n", cyan, reset)
+	fmt.Printf("    %smods%s        List script (.pcmod) + Rust + imported modules\n", cyan, reset)
+	fmt.Printf("    %smods sync%s   Add modules found in src/modules/ and mods/ to config.toml, disabled  %s(mods sync --dry-run)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %smods info%s   Version, hooks, file, build state, settings and live state of one module  %s(mods info cache)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %smods order%s  Request pipeline execution order\n", cyan, reset)
+	fmt.Printf("    %smods timing%s Average time each module adds per request, slowest first\n", cyan, reset)
+	fmt.Printf("    %smods move%s   Reorder a module           %s(mods move cache before rate_limiter)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %smods pause%s  Suspend a module at runtime  %s(mods pause cache, mods resume cache)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sheaders%s     Header rules of every enabled module in the order applied  %s(headers add \"X-Foo: bar\" [--response])%s\n\n", cyan, reset, dim, reset)
 	fmt.Printf("  %s%sDevelopment%s\n", bold, cyan, reset)
 	fmt.Printf("    %scompile%s     Build Rust + CLI & restart CLI\n", cyan, reset)
-	fmt.Printf("    %sweb%s         Launch web dashboard\n", cyan, reset)
+	fmt.Printf("    %sbench%s       Load test through the proxy   %s(bench / -n 1000 -c 20)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sbench --compare%s Same load over HTTP/1.1, HTTP/2 and HTTP/3, side by side  %s(bench / --proto http2 for one)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sweb%s         Launch web dashboard  %s(web --open also opens it in the browser)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %sopen%s        Open the web dashboard in the browser, starting it if needed\n", cyan, reset)
 	fmt.Printf("    %sclear%s       Clear screen\n", cyan, reset)
 	fmt.Printf("    %sexit%s        Exit CLI (proxy keeps running)\n", cyan, reset)
+	fmt.Printf("\n  %sChain commands with ; && ||  (stop; compile && run)%s\n", dim, reset)
+	printUserAliases()
 }
 
 func doMods() {
@@ -1160,26 +2252,27 @@ func doMods() {
 	entries, err := os.ReadDir(modsDir)
 
 	fmt.Printf("  %s%sScript Modules (.pcmod)%s\n", bold, cyan, reset)
-	fmt.Printf("  %s%-20s %-10s %s%s\n", dim, "NAME", "VERSION", "FILE", reset)
-	fmt.Printf("  %s%s%s\n", dim, sep, reset)
 
 	if err != nil {
+		fmt.Printf("  %s%s%s\n", dim, sep, reset)
 		fmt.Printf("  %sNo mods/ directory found%s\n", dim, reset)
 	} else {
+		t := newTable("NAME", "VERSION", "FILE")
 		found := false
 		for _, e := range entries {
 			if e.IsDir() || !strings.HasSuffix(e.Name(), ".pcmod") {
 				continue
 			}
 			found = true
-			data, err := os.ReadFile(filepath.Join(modsDir, e.Name()))
+			data, err := readTextFile(filepath.Join(modsDir, e.Name()))
 			if err != nil {
-				fmt.Printf("  %-20s %s(error reading)%s\n", e.Name(), red, reset)
+				t.row(e.Name(), red+"(error reading)"+reset)
 				continue
 			}
 			name, version := parsePcmod(string(data))
-			fmt.Printf("  %-20s %-10s %s%s%s\n", name, version, dim, e.Name(), reset)
+			t.row(name, version, dim+e.Name()+reset)
 		}
+		t.print()
 		if !found {
 			fmt.Printf("  %sNo .pcmod files found (check mods/examples/ for templates)%s\n", dim, reset)
 		}
@@ -1191,14 +2284,16 @@ func doMods() {
 	if exErr == nil && len(exEntries) > 0 {
 		fmt.Printf("\n  %s%sExample Templates (mods/examples/)%s\n", bold, cyan, reset)
 		fmt.Printf("  %s%s%s\n", dim, sep, reset)
+		t := newTable()
 		for _, e := range exEntries {
 			if !strings.HasSuffix(e.Name(), ".pcmod") {
 				continue
 			}
-			data, _ := os.ReadFile(filepath.Join(exDir, e.Name()))
+			data, _ := readTextFile(filepath.Join(exDir, e.Name()))
 			name, version := parsePcmod(string(data))
-			fmt.Printf("  %-20s %-10s %s%s%s\n", name, version, dim, e.Name(), reset)
+			t.row(name, version, dim+e.Name()+reset)
 		}
+		t.print()
 		fmt.Printf("\n  %sCopy examples to mods/ to activate: copy mods\\examples\\*.pcmod mods\\%s\n", dim, reset)
 	}
 
@@ -1207,20 +2302,22 @@ func doMods() {
 	fmt.Printf("  %s%s%s\n", dim, sep, reset)
 	srcDir := filepath.Join(root, "src", "modules")
 	srcEntries, _ := os.ReadDir(srcDir)
+	t := newTable()
 	for _, e := range srcEntries {
 		n := e.Name()
 		if e.IsDir() || n == "mod.rs" || n == "helpers.rs" || !strings.HasSuffix(n, ".rs") {
 			continue
 		}
-		name := strings.TrimSuffix(n, ".rs")
-		fmt.Printf("  %-20s %s(built-in)%s\n", name, dim, reset)
+		t.row(strings.TrimSuffix(n, ".rs"), dim+"(built-in)"+reset)
 	}
+	t.print()
 
 	// List imports
 	impDir := filepath.Join(root, "imports")
 	impEntries, impErr := os.ReadDir(impDir)
 	if impErr == nil {
 		hasImports := false
+		t := newTable()
 		for _, e := range impEntries {
 			if strings.HasSuffix(e.Name(), ".rs") {
 				if !hasImports {
@@ -1228,10 +2325,10 @@ func doMods() {
 					fmt.Printf("  %s%s%s\n", dim, sep, reset)
 					hasImports = true
 				}
-				name := strings.TrimSuffix(e.Name(), ".rs")
-				fmt.Printf("  %-20s %s(needs compile)%s\n", name, yellow, reset)
+				t.row(strings.TrimSuffix(e.Name(), ".rs"), yellow+"(needs compile)"+reset)
 			}
 		}
+		t.print()
 	}
 }
 
@@ -1252,16 +2349,74 @@ func parsePcmod(content string) (name, version string) {
 	return
 }
 
+// doShowConfigJSON prints config.toml (secrets masked) as JSON, falling
+// back to the live /server view when the file can't be read.
+func doShowConfigJSON() {
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		data, _, apiErr := adminJSON("GET", "/server")
+		if apiErr != nil {
+			fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+			exitCode = 1
+			return
+		}
+		printJSONValue(map[string]interface{}{"server": redacted(data, showSecrets)})
+		return
+	}
+	out := map[string]interface{}{}
+	if srv, ok := cfg["server"].(map[string]interface{}); ok {
+		out["server"] = redacted(srv, showSecrets)
+	}
+	mods := map[string]interface{}{}
+	for name, v := range getModules(cfg) {
+		if m, ok := v.(map[string]interface{}); ok {
+			mods[name] = redacted(m, showSecrets)
+		}
+	}
+	out["modules"] = mods
+	printJSONValue(out)
+}
+
+// configIssues is the offline counterpart of the proxy's /config/verify:
+// required sections and addresses present and parseable, and no two
+// sockets on the same port.
+func configIssues(cfg map[string]interface{}) []string {
+	issues := []string{}
+	if srv, ok := cfg["server"].(map[string]interface{}); !ok {
+		issues = append(issues, "missing [server] section")
+	} else {
+		for _, key := range []string{"listen_addr", "backend_addr"} {
+			v, ok := srv[key].(string)
+			if !ok {
+				issues = append(issues, fmt.Sprintf("server.%s missing", key))
+			} else if _, err := normalizeAddr("server", key, v); err != nil {
+				issues = append(issues, fmt.Sprintf("server.%s: %s", key, err))
+			}
+		}
+	}
+	if _, ok := cfg["modules"]; !ok {
+		issues = append(issues, "missing [modules] section")
+	}
+	issues = append(issues, durationIssues(cfg)...)
+	return append(issues, portConflicts(configBindings(cfg))...)
+}
+
 func doVerify() {
+	defer printIncludeWarnings()
 	// Try API first (if proxy is running)
-	resp, err := adminRequest("GET", "/config/verify")
+	resp, err := adminOptional("GET", "/config/verify")
 	if err == nil {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
 		var result map[string]interface{}
 		if json.Unmarshal(body, &result) == nil {
 			ok, _ := result["ok"].(bool)
-			if ok {
+			// the proxy doesn't know the dashboard's port, so ports are checked here
+			var ports []string
+			if cfg, err := loadConfigTOML(); err == nil {
+				ports = portConflicts(configBindings(cfg))
+			}
+			if ok && len(ports) == 0 {
 				fmt.Printf("  %s✓ Config is valid%s\n", green, reset)
 			} else {
 				fmt.Printf("  %s✗ Config issues found:%s\n", red, reset)
@@ -1270,6 +2425,9 @@ func doVerify() {
 						fmt.Printf("    %s• %v%s\n", yellow, issue, reset)
 					}
 				}
+				for _, issue := range ports {
+					fmt.Printf("    %s• %s%s\n", yellow, issue, reset)
+				}
 				if errMsg, ok := result["error"].(string); ok {
 					fmt.Printf("    %s• %s%s\n", red, errMsg, reset)
 				}
@@ -1281,7 +2439,7 @@ func doVerify() {
 	// Offline verify: just check config.toml parse
 	root := projectRoot()
 	cfgPath := filepath.Join(root, "config.toml")
-	data, err := os.ReadFile(cfgPath)
+	data, err := readTextFile(cfgPath)
 	if err != nil {
 		fmt.Printf("  %s✗ Cannot read config.toml: %s%s\n", red, err, reset)
 		return
@@ -1291,15 +2449,9 @@ func doVerify() {
 		fmt.Printf("  %s✗ Parse error: %s%s\n", red, err, reset)
 		return
 	}
+	mergeIncludes(cfg, loadIncludes(cfg))
 
-	issues := []string{}
-	if _, ok := cfg["server"]; !ok {
-		issues = append(issues, "missing [server] section")
-	}
-	if _, ok := cfg["modules"]; !ok {
-		issues = append(issues, "missing [modules] section")
-	}
-
+	issues := configIssues(cfg)
 	if len(issues) == 0 {
 		fmt.Printf("  %s✓ Config is valid%s\n", green, reset)
 	} else {
@@ -1311,10 +2463,19 @@ func doVerify() {
 }
 
 func doRepair() {
-	resp, err := adminRequest("POST", "/config/repair")
+	resp, err := adminOptional("POST", "/config/repair")
+	if errors.Is(err, errUnavailable) {
+		printUnavailable("Repair")
+		return
+	}
+	if err != nil && isConnRefused(err) {
+		fmt.Printf("  %s! Proxy not running, only syncing modules from the tree%s\n", yellow, reset)
+		doModsSync(false)
+		return
+	}
 	if err != nil {
-		fmt.Printf("  %s✗ Proxy not running. Repair requires running proxy (for module discovery).%s\n", red, reset)
-		fmt.Printf("  %sTip: start the proxy with 'run', then try 'repair' again%s\n", dim, reset)
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		exitCode = 1
 		return
 	}
 	defer resp.Body.Close()
@@ -0,0 +1,1275 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// useProject points projectRoot() at a temporary tree for the test.
+func useProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["Cargo.toml"] = "[package]\nname = \"proxycache\"\n"
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func TestLoadConfigTOMLWithBOMAndCRLF(t *testing.T) {
+	useProject(t, map[string]string{
+		"config.toml": "\xef\xbb\xbf[server]\r\nlisten_addr = \"0.0.0.0:3000\"\r\n\r\n[modules.cache]\r\nenabled = true\r\n",
+	})
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		t.Fatalf("loadConfigTOML: %v", err)
+	}
+	srv, _ := cfg["server"].(map[string]interface{})
+	if srv["listen_addr"] != "0.0.0.0:3000" {
+		t.Errorf("listen_addr = %v", srv["listen_addr"])
+	}
+	cache, _ := getModules(cfg)["cache"].(map[string]interface{})
+	if cache["enabled"] != true {
+		t.Errorf("cache.enabled = %v", cache["enabled"])
+	}
+}
+
+func TestParsePcmodWithBOMAndCRLF(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"mods/hello.pcmod": "\xef\xbb\xbfmod \"hello\"\r\nversion \"1.2\"\r\n",
+	})
+	data, err := readTextFile(filepath.Join(dir, "mods", "hello.pcmod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, version := parsePcmod(string(data))
+	if name != "hello" || version != "1.2" {
+		t.Errorf("parsePcmod = %q, %q", name, version)
+	}
+}
+
+func TestConfigIncludesMergeAndSaveBack(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"config.toml":         "include = [\"modules.d/*.toml\"]\n\n[server]\nlisten_addr = \"127.0.0.1:3000\"\n\n[modules.cache]\nenabled = true\n",
+		"modules.d/a.toml":    "[modules.cache]\nenabled = false\n\n[modules.rate_limiter]\nenabled = true\n",
+		"modules.d/b.toml":    "[modules.rate_limiter]\nenabled = false\n",
+		"modules.d/skip.conf": "[modules.ignored]\nenabled = true\n",
+	})
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		t.Fatalf("loadConfigTOML: %v", err)
+	}
+	mods := getModules(cfg)
+	if mods["cache"].(map[string]interface{})["enabled"] != true {
+		t.Error("config.toml should win over includes")
+	}
+	if mods["rate_limiter"].(map[string]interface{})["enabled"] != true {
+		t.Error("first include should win over later ones")
+	}
+	if _, ok := mods["ignored"]; ok {
+		t.Error("non-matching file was included")
+	}
+	if w := configIncludeWarnings(); len(w) != 2 {
+		t.Errorf("warnings = %v, want 2 conflicts", w)
+	}
+
+	mods["rate_limiter"].(map[string]interface{})["enabled"] = false
+	if err := saveConfigTOML(cfg); err != nil {
+		t.Fatalf("saveConfigTOML: %v", err)
+	}
+	inc, err := parseConfigFile(filepath.Join(dir, "modules.d", "a.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getModules(inc)["rate_limiter"].(map[string]interface{})["enabled"] != false {
+		t.Error("included module was not written back to its file")
+	}
+	main, _ := parseConfigFile(filepath.Join(dir, "config.toml"))
+	if _, ok := getModules(main)["rate_limiter"]; ok {
+		t.Error("included module was copied into config.toml")
+	}
+	if len(includePatterns(main)) != 1 {
+		t.Error("include list was dropped from config.toml")
+	}
+}
+
+// roundTripConfig is config.toml as shipped plus the value shapes a
+// hand-edited file tends to grow.
+const roundTripConfig = `title = "edge"
+notes = """
+first line
+second line"""
+win_path = 'C:\certs\proxy.pem'
+started = 2024-05-01T10:00:00Z
+ratio = 1.0
+big = 9223372036854775807
+timeout = "30s"
+"dotted.key" = "kept"
+
+[server]
+backend_addr = "127.0.0.1:8080"
+backend_timeout = 30
+h3_port = 0
+http2 = true
+listen_addr = "0.0.0.0:3000"
+log_level = "info"
+max_body_size = 16777216
+tls_cert = "cert.pem"
+
+[modules.admin_api]
+api_key = "0123"
+enabled = true
+listen_addr = "127.0.0.1:9090"
+
+[modules.cache]
+enabled = false
+ttl_seconds = 300
+warm_urls = []
+
+[modules.load_balancer]
+backends = ["127.0.0.1:8081", "127.0.0.1:8082"]
+enabled = false
+weights = { a = 1, b = 2.5 }
+
+[[modules.load_balancer.pools]]
+name = "primary"
+members = [1, 2]
+
+[[modules.load_balancer.pools]]
+name = "backup"
+`
+
+func TestConfigSaveRoundTrip(t *testing.T) {
+	useProject(t, map[string]string{"config.toml": roundTripConfig})
+	before, err := loadConfigTOML()
+	if err != nil {
+		t.Fatalf("loadConfigTOML: %v", err)
+	}
+	if err := saveConfigTOML(before); err != nil {
+		t.Fatalf("saveConfigTOML: %v", err)
+	}
+	after, err := loadConfigTOML()
+	if err != nil {
+		t.Fatalf("saved config doesn't parse: %v", err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		for _, k := range configDiff(before, after) {
+			t.Errorf("changed on save: %s: %#v -> %#v", k.key, k.old, k.new)
+		}
+		t.Fatal("config changed on save")
+	}
+	// A second save must be byte-for-byte stable.
+	first, _ := os.ReadFile(configPath())
+	if err := saveConfigTOML(after); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := os.ReadFile(configPath())
+	if string(first) != string(second) {
+		t.Errorf("second save differs:\n%s\n---\n%s", first, second)
+	}
+}
+
+func TestEditKeepsValueTypes(t *testing.T) {
+	section := map[string]interface{}{"api_key": "x", "ratio": 1.5, "ttl_seconds": int64(300)}
+	for _, c := range []struct {
+		line string
+		key  string
+		want interface{}
+	}{
+		{"api_key = 0123", "api_key", "0123"},
+		{"api_key = true", "api_key", "true"},
+		{"ratio = 2", "ratio", float64(2)},
+		{"ttl_seconds = 60.0", "ttl_seconds", int64(60)},
+		{"api_key_file = 9090", "api_key_file", "9090"},
+		{"enabled = true", "enabled", true},
+		{"new_key = 7", "new_key", int64(7)},
+	} {
+		captureOutput(t, func() {
+			if _, err := applyEditLine("admin_api", section, c.line); err != nil {
+				t.Errorf("%s: %v", c.line, err)
+			}
+		})
+		if got := section[c.key]; !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, want %#v", c.line, got, c.want)
+		}
+	}
+	if got := coerceValue(1.5, float64(2)); got != float64(2) {
+		t.Errorf("web form turned a float key into %#v", got)
+	}
+}
+
+func TestEditKeyCompletion(t *testing.T) {
+	keys := editKeys("cache", map[string]interface{}{"enabled": true, "custom": int64(1)})
+	if want := []string{"custom", "enabled", "max_size", "ttl_seconds", "warm_urls"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("editKeys = %v, want %v", keys, want)
+	}
+	for _, c := range []struct {
+		in, want string
+		matches  []string
+	}{
+		{"tt", "ttl_seconds=", nil},
+		{"del ma", "del max_size", nil},
+		{"reset w", "reset warm_urls", nil},
+		{"ttl_seconds=3", "ttl_seconds=3", nil},
+		{"zz", "zz", nil},
+		{"", "", keys},
+		{"del ", "del ", keys},
+	} {
+		got, matches := completeEditLine(c.in, keys)
+		if got != c.want || !reflect.DeepEqual(matches, c.matches) {
+			t.Errorf("completeEditLine(%q) = %q, %v; want %q, %v", c.in, got, matches, c.want, c.matches)
+		}
+	}
+	got, _ := completeEditLine("c", []string{"client_timeout", "client_max_body"})
+	if got != "client_" {
+		t.Errorf("common prefix = %q, want client_", got)
+	}
+
+	out := captureOutput(t, func() {
+		applyEditLine("cache", map[string]interface{}{"ttl_seconds": int64(300)}, "ttl_secnds = 5")
+	})
+	if !strings.Contains(out, "Did you mean 'ttl_seconds'?") {
+		t.Errorf("no suggestion for a mistyped key:\n%s", out)
+	}
+}
+
+func TestDurationKeys(t *testing.T) {
+	srv := map[string]interface{}{"backend_timeout": int64(30)}
+	captureOutput(t, func() {
+		if _, err := applyEditLine("server", srv, `backend_timeout = "2m"`); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if srv["backend_timeout"] != int64(120) {
+		t.Errorf("2m stored as %#v, want 120", srv["backend_timeout"])
+	}
+	for _, bad := range []string{"backend_timeout = soon", "backend_timeout = 1500ms", "backend_timeout = -5"} {
+		if _, err := applyEditLine("server", srv, bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+	if srv["backend_timeout"] != int64(120) {
+		t.Errorf("rejected edit changed the value to %#v", srv["backend_timeout"])
+	}
+	raw := map[string]interface{}{"timeout": int64(30)}
+	captureOutput(t, func() {
+		if _, err := applyEditLine("raw_tcp", raw, `timeout = "1m"`); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if raw["timeout"] != int64(60) {
+		t.Errorf("raw_tcp timeout 1m stored as %#v, want 60", raw["timeout"])
+	}
+
+	cfg := map[string]interface{}{
+		"server":  map[string]interface{}{"client_timeout": "30s", "shutdown_timeout": int64(15)},
+		"modules": map[string]interface{}{"cache": map[string]interface{}{"ttl_seconds": "a while"}},
+	}
+	issues := durationIssues(cfg)
+	if len(issues) != 2 || !strings.Contains(issues[0], "use 30") || !strings.Contains(issues[1], "modules.cache.ttl_seconds") {
+		t.Errorf("issues = %q", issues)
+	}
+
+	for v, want := range map[interface{}]string{int64(30): "30s", int64(300): "300s (5m 0s)", float64(90): "90s (1m 30s)", "2m": "2m"} {
+		if got := formatConfigDuration(v); got != want {
+			t.Errorf("formatConfigDuration(%#v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestApplyHintsOnlyHotKeysSkipRestart(t *testing.T) {
+	for key, want := range map[string]bool{"server.log_level": false, "server.logging": false, "server.backend_timeout": true, "cache.ttl_seconds": true, "cache.enabled": true} {
+		section, k, _ := strings.Cut(key, ".")
+		if got := needsRestart(section, k); got != want {
+			t.Errorf("needsRestart(%s) = %v, want %v", key, got, want)
+		}
+	}
+	out := captureOutput(t, func() { printApplyHints("server", []string{"log_format"}) })
+	if !strings.Contains(out, "can hot-apply") || !strings.Contains(out, "reload --config-only") {
+		t.Errorf("hot key output:\n%s", out)
+	}
+	out = captureOutput(t, func() { printApplyHints("rate_limiter", []string{"requests_per_second"}) })
+	if !strings.Contains(out, "rate_limiter.requests_per_second — requires restart") {
+		t.Errorf("module key output:\n%s", out)
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	steps, err := parseChain(`stop; tls check --exec "a; b && c" && run || ping;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []chainStep{
+		{op: "", args: []string{"stop"}},
+		{op: ";", args: []string{"tls", "check", "--exec", "a; b && c"}},
+		{op: "&&", args: []string{"run"}},
+		{op: "||", args: []string{"ping"}},
+	}
+	if fmt.Sprint(steps) != fmt.Sprint(want) {
+		t.Errorf("parseChain = %v, want %v", steps, want)
+	}
+	for _, bad := range []string{"&& run", "stop ||", `ping "oops`} {
+		if _, err := parseChain(bad); err == nil {
+			t.Errorf("parseChain(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		850 * time.Microsecond:                        "850µs",
+		12 * time.Millisecond:                         "12ms",
+		2500 * time.Microsecond:                       "2.5ms",
+		1400 * time.Millisecond:                       "1.4s",
+		200 * time.Second:                             "3m 20s",
+		2*24*time.Hour + 4*time.Hour + 15*time.Second: "2d 4h 0m 15s",
+	}
+	for d, want := range cases {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestTableAlignsColoredCells(t *testing.T) {
+	tb := newTable("NAME", "STATUS")
+	tb.row("a_really_long_module_name", "\x1b[32m✓ on\x1b[0m")
+	tb.row("cache", "\x1b[31m✗ off\x1b[0m")
+	out := ansiRE.ReplaceAllString(captureOutput(t, tb.print), "")
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines:\n%s", len(lines), out)
+	}
+	if !strings.HasSuffix(lines[3], "cache                     ✗ off") {
+		t.Errorf("misaligned row %q", lines[3])
+	}
+	if strings.Index(lines[2], "✓") != strings.Index(lines[3], "✗") {
+		t.Errorf("status column not aligned:\n%s", out)
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	names := []string{"cache", "compression", "rate_limiter", "server"}
+	if got := closestName("cahce", names); got != "cache" {
+		t.Errorf("closestName(cahce) = %q", got)
+	}
+	if got := closestName("zzzzzz", names); got != "" {
+		t.Errorf("closestName(zzzzzz) = %q, want no suggestion", got)
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	old := map[string]interface{}{
+		"server":  map[string]interface{}{"listen_addr": "0.0.0.0:8080", "http2": true},
+		"modules": map[string]interface{}{"cache": map[string]interface{}{"enabled": true}},
+	}
+	new := map[string]interface{}{
+		"server": map[string]interface{}{"listen_addr": "0.0.0.0:9000", "http3": true},
+	}
+	got := configDiff(old, new)
+	want := []diffEntry{
+		{kind: diffRemoved, key: "modules.cache.enabled", old: true},
+		{kind: diffRemoved, key: "server.http2", old: true},
+		{kind: diffAdded, key: "server.http3", new: true},
+		{kind: diffChanged, key: "server.listen_addr", old: "0.0.0.0:8080", new: "0.0.0.0:9000"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configDiff = %+v\nwant %+v", got, want)
+	}
+	if s := diffSummary(got); s != "1 added, 2 removed, 1 changed" {
+		t.Errorf("diffSummary = %q", s)
+	}
+}
+
+func TestCanonicalAddr(t *testing.T) {
+	cases := map[string]string{
+		"127.0.0.1:9090":         "127.0.0.1:9090",
+		"[::1]:9090":             "[::1]:9090",
+		"[0:0:0:0:0:0:0:1]:9090": "[::1]:9090",
+		"[2001:DB8::0001]:443":   "[2001:db8::1]:443",
+		"Proxy.Example.COM:8080": "proxy.example.com:8080",
+		"localhost:3000":         "localhost:3000",
+		"::1:9090":               "::1:9090",
+		"not an address":         "not an address",
+		"[::ffff:127.0.0.1]:80":  "127.0.0.1:80",
+		"backend.internal:https": "backend.internal:https",
+	}
+	for in, want := range cases {
+		if got := canonicalAddr(in); got != want {
+			t.Errorf("canonicalAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeAddrOnEdit(t *testing.T) {
+	for _, c := range []struct {
+		section, key, in, want string
+	}{
+		{"server", "listen_addr", ":8080", "0.0.0.0:8080"},
+		{"server", "backend_addr", ":3000", "127.0.0.1:3000"},
+		{"server", "backend_addr", "localhost:3000", "127.0.0.1:3000"},
+		{"server", "listen_addr", " [::0]:443 ", "[::]:443"},
+		{"admin_api", "listen_addr", "Admin.Local:9090", "admin.local:9090"},
+		{"raw_tcp", "backend_addr", "db.internal:5432", "db.internal:5432"},
+	} {
+		if got, err := normalizeAddr(c.section, c.key, c.in); err != nil || got != c.want {
+			t.Errorf("normalizeAddr(%s.%s, %q) = %q, %v, want %q", c.section, c.key, c.in, got, err, c.want)
+		}
+	}
+	for _, c := range []struct{ section, key, in, want string }{
+		{"server", "listen_addr", "localhost8080", "port"},
+		{"server", "listen_addr", "0.0.0.0:0", "1-65535"},
+		{"server", "backend_addr", "app.internal:3000", "needs an IP"},
+		{"admin_api", "listen_addr", "127.0.0.1:http", "1-65535"},
+	} {
+		if _, err := normalizeAddr(c.section, c.key, c.in); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("normalizeAddr(%s.%s, %q) error = %v, want it to mention %q", c.section, c.key, c.in, err, c.want)
+		}
+	}
+
+	srv := map[string]interface{}{"listen_addr": "0.0.0.0:8080"}
+	out := captureOutput(t, func() {
+		if _, err := applyEditLine("server", srv, "listen_addr = localhost8080"); err == nil {
+			t.Error("malformed listen_addr was accepted")
+		}
+		if _, err := applyEditLine("server", srv, "listen_addr = :9000"); err != nil {
+			t.Error(err)
+		}
+	})
+	if srv["listen_addr"] != "0.0.0.0:9000" || !strings.Contains(out, "stored as 0.0.0.0:9000") {
+		t.Errorf("listen_addr = %v, output %q", srv["listen_addr"], out)
+	}
+	if _, err := coerceKey("server", "backend_addr", "127.0.0.1:3000", "nowhere"); err == nil {
+		t.Error("web update accepted a malformed backend_addr")
+	}
+}
+
+func TestDialableAddr(t *testing.T) {
+	cases := map[string]string{
+		"0.0.0.0:9090":   "127.0.0.1:9090",
+		":9090":          "127.0.0.1:9090",
+		"[::]:9090":      "[::1]:9090",
+		"[fe80::1]:9090": "[fe80::1]:9090",
+		"api.local:9090": "api.local:9090",
+	}
+	for in, want := range cases {
+		got, err := dialableAddr(in)
+		if err != nil || got != want {
+			t.Errorf("dialableAddr(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := dialableAddr("::1:9090"); err == nil || !strings.Contains(err.Error(), "brackets") {
+		t.Errorf("unbracketed IPv6 should suggest brackets, got %v", err)
+	}
+	if _, err := dialableAddr("localhost"); err == nil {
+		t.Error("missing port should be an error")
+	}
+}
+
+func TestProbeTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{
+		MaxVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	r, err := probeTLS(strings.TrimPrefix(srv.URL, "https://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Versions) == 0 || r.Versions[len(r.Versions)-1] != tls.VersionTLS12 || r.Suite13 != 0 {
+		t.Errorf("versions = %v, want TLS 1.2 as the highest", r.Versions)
+	}
+	weak := map[string]string{}
+	for _, id := range r.Suites {
+		weak[tls.CipherSuiteName(id)] = suiteWeakness(id)
+	}
+	want := map[string]string{
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": "",
+		"TLS_ECDHE_RSA_WITH_RC4_128_SHA":        "insecure",
+	}
+	if !reflect.DeepEqual(weak, want) {
+		t.Errorf("suites = %v, want %v", weak, want)
+	}
+}
+
+// testOCSPStaple encodes a minimal OCSP response with the given status
+// flag set and validity window.
+func testOCSPStaple(t *testing.T, good bool, this, next time.Time) []byte {
+	t.Helper()
+	single := ocspSingleResponse{
+		CertID:     asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true},
+		Good:       asn1.Flag(good),
+		Unknown:    asn1.Flag(!good),
+		ThisUpdate: this,
+		NextUpdate: next,
+	}
+	basic, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData: ocspResponseData{
+			ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: []byte{4, 1, 0}},
+			ProducedAt:  this,
+			Responses:   []ocspSingleResponse{single},
+		},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}},
+		Signature:          asn1.BitString{Bytes: []byte{0}, BitLength: 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(ocspResponse{Response: ocspResponseBytes{ResponseType: oidOCSPBasic, Response: basic}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestTLSSessionAndStaple(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	srv.TLS.Certificates[0].OCSPStaple = testOCSPStaple(t, true, now.Add(-time.Hour), now.Add(6*24*time.Hour))
+
+	p, err := probeSession(strings.TrimPrefix(srv.URL, "https://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Resumed {
+		t.Error("second connection didn't resume the session")
+	}
+	st, err := parseOCSPStaple(p.Staple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != "good" || !st.NextUpdate.Equal(now.Add(6*24*time.Hour)) || st.expired(now) {
+		t.Errorf("staple = %+v", st)
+	}
+
+	p.Staple = testOCSPStaple(t, false, now.Add(-48*time.Hour), now.Add(-time.Hour))
+	out := captureOutput(t, func() { printStapleStatus(p, true, now) })
+	if !strings.Contains(out, "expired 1h 0m 0s ago") {
+		t.Errorf("expired staple not flagged:\n%s", out)
+	}
+	out = captureOutput(t, func() { printStapleStatus(sessionProbe{}, true, now) })
+	if !strings.Contains(out, "configured but no response was stapled") {
+		t.Errorf("missing staple not flagged:\n%s", out)
+	}
+	if _, err := parseOCSPStaple([]byte("not der")); err == nil {
+		t.Error("garbage parsed as a staple")
+	}
+}
+
+func TestHeadlessBrowser(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	if got := headlessReason(env(map[string]string{"SSH_CONNECTION": "10.0.0.2 5022 10.0.0.1 22", "DISPLAY": ":0"})); got != "SSH session" {
+		t.Errorf("over SSH: %q", got)
+	}
+	if got := headlessReason(env(map[string]string{"DISPLAY": ":0"})); got != "" {
+		t.Errorf("with a display: %q", got)
+	}
+	want := "no display"
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		want = ""
+	}
+	if got := headlessReason(env(nil)); got != want {
+		t.Errorf("no DISPLAY: %q, want %q", got, want)
+	}
+
+	t.Setenv("SSH_TTY", "/dev/pts/0")
+	webPort = "8999"
+	t.Cleanup(func() { webPort = "8900" })
+	out := captureOutput(t, func() { openBrowser(webURL()) })
+	if !strings.Contains(out, "No browser to open (SSH session), visit http://127.0.0.1:8999") {
+		t.Errorf("headless open should print the URL:\n%s", out)
+	}
+}
+
+func TestWebAssets(t *testing.T) {
+	page, assets, err := loadWebAssets()
+	if err != nil {
+		t.Fatalf("embedded dashboard: %v", err)
+	}
+	if len(assets) != len(webAssetNames) {
+		t.Fatalf("got %d assets, want %d", len(assets), len(webAssetNames))
+	}
+	for url := range assets {
+		if !strings.Contains(page, `"`+url+`" integrity="sha256-`) {
+			t.Errorf("page doesn't reference %s with an integrity hash", url)
+		}
+	}
+	if err := checkWebHTML(page[:len(page)/2]); err == nil {
+		t.Error("truncated dashboard passed the check")
+	}
+	if err := checkWebHTML("  "); err == nil {
+		t.Error("empty dashboard passed the check")
+	}
+}
+
+func TestModsSyncAddsDisabledModules(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"config.toml":                "[server]\nlisten_addr = \"127.0.0.1:3000\"\n\n[modules.cache]\nenabled = true\n",
+		"src/modules/cache.rs":       "",
+		"src/modules/helpers.rs":     "",
+		"src/modules/compression.rs": "",
+		"mods/hello.pcmod":           "mod \"hello\"\nconfig {\n  enabled bool true\n  greeting str \"hi\"\n  hosts list [\"a\", 'b']\n}\n",
+	})
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := syncModules(cfg, discoverModules(dir))
+	if !reflect.DeepEqual(added, []string{"compression", "hello"}) {
+		t.Fatalf("added = %v", added)
+	}
+	mods := getModules(cfg)
+	hello, _ := mods["hello"].(map[string]interface{})
+	want := map[string]interface{}{"enabled": false, "greeting": "hi", "hosts": []interface{}{"a", "b"}}
+	if !reflect.DeepEqual(hello, want) {
+		t.Errorf("hello = %v, want %v", hello, want)
+	}
+	if comp, _ := mods["compression"].(map[string]interface{}); comp["enabled"] != false || comp["min_size"] != int64(256) {
+		t.Errorf("compression = %v", comp)
+	}
+	if cache, _ := mods["cache"].(map[string]interface{}); cache["enabled"] != true {
+		t.Errorf("existing cache section changed: %v", cache)
+	}
+}
+
+func TestSetupWizard(t *testing.T) {
+	useProject(t, map[string]string{
+		"mods/hello.pcmod": "mod \"hello\"\nconfig {\n  enabled bool true\n}\n",
+	})
+	answers := strings.Join([]string{
+		"0.0.0.0:8080", // listen
+		"[::1",         // backend, rejected
+		"127.0.0.1:9000",
+		"n", // TLS
+		"",  // admin API: default yes
+		"",  // admin listen: default
+		"",  // generate key: default yes
+		"bogus",
+		"cache, hello",
+	}, "\n") + "\n"
+	var cfg map[string]interface{}
+	var key string
+	var err error
+	out := captureOutput(t, func() { cfg, key, err = runSetup(strings.NewReader(answers)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Unknown module(s): bogus") {
+		t.Errorf("unknown module not reported:\n%s", out)
+	}
+	srv := cfg["server"].(map[string]interface{})
+	if srv["listen_addr"] != "0.0.0.0:8080" || srv["backend_addr"] != "127.0.0.1:9000" || srv["tls_cert"] != "" {
+		t.Errorf("server = %v", srv)
+	}
+	mods := getModules(cfg)
+	admin := mods["admin_api"].(map[string]interface{})
+	if len(key) != 32 || admin["api_key"] != key || admin["enabled"] != true {
+		t.Errorf("admin_api = %v, key %q", admin, key)
+	}
+	for name, want := range map[string]bool{"cache": true, "hello": true, "compression": false} {
+		if got := mods[name].(map[string]interface{})["enabled"]; got != want {
+			t.Errorf("%s enabled = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCleanFindsGeneratedFiles(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"config.toml":          "[server]\n",
+		"config.toml.bak":      "[server]\n",
+		".proxycache.log":      "line\n",
+		".proxycache-dev.err":  "",
+		".proxycache.pid":      "999999999",
+		".proxycache-cli.toml": "",
+		".proxycache-web.toml": "enabled = true\n",
+	})
+	var names []string
+	for _, f := range findCleanFiles(dir) {
+		if f.Active != "" {
+			t.Errorf("%s reported active: %s", f.Path, f.Active)
+		}
+		names = append(names, filepath.Base(f.Path))
+	}
+	want := []string{".proxycache-dev.err", ".proxycache-web.toml", ".proxycache.log", ".proxycache.pid", "config.toml.bak"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
+
+func TestLogAndPIDPathsAreAbsolute(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		".proxycache-cli.toml": "log_file = \"logs/proxy.log\"\n",
+		"sub/keep":             "",
+	})
+	dir, _ = filepath.EvalSymlinks(dir)
+	if err := os.Chdir(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFileOverride, pidFileOverride = "", "" })
+
+	pidFileOverride = resolveArgPath("run/proxy.pid")
+	loadPathsFromCLIConfig()
+	for got, want := range map[string]string{
+		logPath():    filepath.Join(dir, "logs", "proxy.log"),
+		errLogPath(): filepath.Join(dir, "logs", "proxy.err"),
+		pidPath():    filepath.Join(dir, "sub", "run", "proxy.pid"),
+	} {
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	logFileOverride, pidFileOverride = "", ""
+	if got, want := pidPath(), filepath.Join(dir, ".proxycache.pid"); got != want {
+		t.Errorf("default pid path = %s, want %s", got, want)
+	}
+}
+
+func TestStampWriter(t *testing.T) {
+	var buf strings.Builder
+	w := newStampWriter(&buf)
+	w.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	w.Write([]byte("first\nsec"))
+	w.Write([]byte("ond\n"))
+	want := "2024-05-01T12:00:00.000Z first\n2024-05-01T12:00:00.000Z second\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReadLogTailSkipsCutLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.log")
+	long := strings.Repeat("x", watchTailBytes) + "\nsecond\r\n\nthird\n"
+	if err := os.WriteFile(path, []byte(long), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readLogTail(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"second", "third"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestPollBackoff(t *testing.T) {
+	b := newPollBackoff(time.Second)
+	var got []time.Duration
+	for _, ok := range []bool{false, false, false, false, false, true, true, true, true, true} {
+		got = append(got, b.next(ok))
+	}
+	want := []time.Duration{2, 4, 8, 16, 16, 8, 4, 2, 1, 1}
+	for i := range want {
+		want[i] *= time.Second
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("intervals = %v, want %v", got, want)
+	}
+}
+
+func TestFormatLogLine(t *testing.T) {
+	cases := map[string]string{
+		`{"ts":"2024-05-01T12:00:00.123Z","level":"info","msg":"response","status":200,"ms":4}`:  "2024-05-01 12:00:00.123 INFO  response  ms=4  status=200",
+		`2024-05-01T12:00:01.000Z {"ts":"2024-05-01T12:00:00.999Z","level":"warn","msg":"slow"}`: "2024-05-01T12:00:01.000Z 2024-05-01 12:00:00.999 WARN  slow",
+		"2024-05-01 12:00:00.123 plain text {not json}":                                          "2024-05-01 12:00:00.123 plain text {not json}",
+		`{"unrelated":true}`: `{"unrelated":true}`,
+	}
+	for in, want := range cases {
+		if got := captureOutput(t, func() { fmt.Print(formatLogLine(in)) }); got != want {
+			t.Errorf("formatLogLine(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLogsSince(t *testing.T) {
+	dir := useProject(t, map[string]string{})
+	now := time.Now().UTC()
+	stamp := func(ago time.Duration) string { return now.Add(-ago).Format(proxyLogLayout) }
+	log := strings.Join([]string{
+		"\x1b[2m" + stamp(2*time.Hour) + "\x1b[0m old request",
+		stamp(5*time.Minute) + " recent request",
+		"  at continuation line without a stamp",
+		`{"ts":"` + now.Add(-time.Minute).Format("2006-01-02T15:04:05.000Z") + `","level":"warn","msg":"json line"}`,
+		now.Add(-30*time.Second).Format(logStampLayout) + " stamped by the cli",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, ".proxycache.log"), []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := captureOutput(t, func() { doLogs([]string{"--since", "10m"}) })
+	for _, want := range []string{"recent request", "json line", "stamped by the cli"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"old request", "continuation"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestProtectedModuleGuards(t *testing.T) {
+	useProject(t, map[string]string{
+		"config.toml": "[server]\nlisten_addr = \"127.0.0.1:3000\"\n\n[modules.proxy_core]\nenabled = true\n",
+	})
+	t.Cleanup(func() { exitCode = 0 })
+	out := captureOutput(t, func() { runArgs([]string{"toggle", "proxy_core"}) })
+	if !strings.Contains(out, "can't be toggled") || exitCode != 1 {
+		t.Errorf("toggle not refused (exit %d):\n%s", exitCode, out)
+	}
+	out = captureOutput(t, func() { runArgs([]string{"edit", "proxy_core", "--reset", "enabled"}) })
+	if !strings.Contains(out, "--force") {
+		t.Errorf("edit without --force not refused:\n%s", out)
+	}
+	exitCode = 0
+	out = captureOutput(t, func() { runArgs([]string{"config", "proxy_core"}) })
+	if !strings.Contains(out, "--force") || exitCode != 1 {
+		t.Errorf("config <name> without --force not refused (exit %d):\n%s", exitCode, out)
+	}
+	cfg, _ := loadConfigTOML()
+	if getModules(cfg)["proxy_core"].(map[string]interface{})["enabled"] != true {
+		t.Error("proxy_core was changed")
+	}
+}
+
+func TestOutputFlagReportsFailure(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"config.toml": "[server]\nlisten_addr = \"127.0.0.1:3000\"\n\n[modules.proxy_core]\nenabled = true\n\n[modules.cache]\nenabled = true\n",
+	})
+	t.Cleanup(func() { exitCode = 0 })
+	file := filepath.Join(dir, "out.txt")
+	out := captureOutput(t, func() { runArgs([]string{"toggle", "proxy_core", "--output", file}) })
+	if strings.Contains(out, "Wrote") || !strings.Contains(out, "toggle failed") || exitCode != 1 {
+		t.Errorf("failed command reported as written (exit %d):\n%s", exitCode, out)
+	}
+	if data, _ := os.ReadFile(file); !strings.Contains(string(data), "can't be toggled") {
+		t.Errorf("error not in output file:\n%s", data)
+	}
+	exitCode = 0
+	out = captureOutput(t, func() { runArgs([]string{"mods", "--output", file}) })
+	if !strings.Contains(out, "Wrote") || exitCode != 0 {
+		t.Errorf("successful command not reported (exit %d):\n%s", exitCode, out)
+	}
+}
+
+func TestModsMoveWritesPriority(t *testing.T) {
+	useProject(t, map[string]string{
+		"config.toml":        "[server]\nlisten_addr = \"127.0.0.1:3000\"\n\n[modules.request_id]\nenabled = true\n\n[modules.cache]\nenabled = true\n",
+		"mods/greeter.pcmod": "mod greeter\nversion 1.0\non_request {\n}\n",
+	})
+	captureOutput(t, func() { doModsMove("greeter", "before", "request_id") })
+	cfg, _ := loadConfigTOML()
+	greeter, _ := getModules(cfg)["greeter"].(map[string]interface{})
+	if greeter["enabled"] != true {
+		t.Errorf("new section for an active script should be enabled: %v", greeter)
+	}
+	chain := computeChain(cfg)
+	if len(chain) < 2 || chain[0].name != "greeter" || chain[1].name != "request_id" {
+		t.Errorf("chain = %+v", chain)
+	}
+}
+
+func TestHeaderRules(t *testing.T) {
+	useProject(t, map[string]string{
+		"config.toml": "[modules.request_id]\nenabled = true\n[modules.compression]\nenabled = true\n" +
+			"[modules.headers]\nenabled = true\nresponse_set = [\"Content-Encoding: identity\"]\n",
+		"mods/tag.pcmod": "mod tag\npriority 85\non_request {\n  set_header X-Tag one\n  if path == /a {\n    set_header X-Request-Id fixed\n  }\n}\n",
+	})
+	captureOutput(t, func() { doHeadersAdd([]string{"X-Foo:", "bar"}) })
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getModules(cfg)["headers"].(map[string]interface{})["request_set"]; !reflect.DeepEqual(got, []interface{}{"X-Foo: bar"}) {
+		t.Fatalf("request_set after add = %v", got)
+	}
+
+	rules := collectHeaderRules(cfg)
+	markOverridden(rules)
+	var got []string
+	for _, r := range rules {
+		got = append(got, fmt.Sprintf("%s %s %s %s %s", r.Phase, r.Module, r.Action, r.Name, r.Note))
+	}
+	want := []string{
+		"request request_id set X-Request-Id kept if the client sent one",
+		"request headers set X-Foo ",
+		"request tag set X-Tag ",
+		"request tag set X-Request-Id conditional",
+		"response compression set Content-Encoding overridden by headers",
+		"response headers set Content-Encoding ",
+		"response request_id set X-Request-Id ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rules =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPortConflicts(t *testing.T) {
+	useProject(t, map[string]string{".proxycache-web.toml": "port = \"9090\"\n"})
+	cfg := map[string]interface{}{
+		"server": map[string]interface{}{"listen_addr": "0.0.0.0:9090", "backend_addr": "127.0.0.1:8080", "http3": true},
+		"modules": map[string]interface{}{
+			"admin_api": map[string]interface{}{"enabled": true, "listen_addr": "127.0.0.1:9090"},
+		},
+	}
+	issues := configIssues(cfg)
+	want := []string{
+		"port 9090/tcp is bound by both server.listen_addr and modules.admin_api.listen_addr; move modules.admin_api.listen_addr to a free port, e.g. 127.0.0.1:9091",
+		"port 9090/tcp is bound by both server.listen_addr and .proxycache-web.toml port; move .proxycache-web.toml port to a free port, e.g. 127.0.0.1:9092",
+		"port 9090/tcp is bound by both modules.admin_api.listen_addr and .proxycache-web.toml port; move .proxycache-web.toml port to a free port, e.g. 127.0.0.1:9092",
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("issues =\n%s\nwant\n%s", strings.Join(issues, "\n"), strings.Join(want, "\n"))
+	}
+
+	// different hosts, or TCP next to HTTP/3's UDP, are fine
+	cfg["modules"] = map[string]interface{}{"admin_api": map[string]interface{}{"listen_addr": "127.0.0.2:9090"}}
+	cfg["server"].(map[string]interface{})["listen_addr"] = "127.0.0.1:9090"
+	os.Remove(webConfigPath())
+	if issues := configIssues(cfg); len(issues) != 0 {
+		t.Errorf("unexpected issues: %v", issues)
+	}
+}
+
+func TestBenchForcesProtocol(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	for proto, want := range map[string]string{"http1": "HTTP/1.1", "http2": "HTTP/2.0"} {
+		res, err := runBench(srv.URL, 6, 2, proto)
+		if err != nil {
+			t.Fatalf("%s: %v", proto, err)
+		}
+		if res.Protocol != want || res.Mismatched != 0 || res.Statuses["200"] != 6 {
+			t.Errorf("%s: protocol %s, %d mismatched, statuses %v", proto, res.Protocol, res.Mismatched, res.Statuses)
+		}
+	}
+	if _, err := runBench("http://127.0.0.1:1/", 1, 1, "http2"); err == nil {
+		t.Error("http2 over plain http should be refused")
+	}
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h3 := &http3.Server{Handler: srv.Config.Handler, TLSConfig: srv.TLS.Clone()}
+	go h3.Serve(udp)
+	defer h3.Close()
+	h3URL := "https://" + udp.LocalAddr().String() + "/"
+	res, err := runBench(h3URL, 6, 2, "http3")
+	if err != nil {
+		t.Fatalf("http3: %v", err)
+	}
+	if res.Protocol != "HTTP/3.0" || res.Mismatched != 0 || res.Statuses["200"] != 6 {
+		t.Errorf("http3: protocol %s, %d mismatched, statuses %v, %d errors", res.Protocol, res.Mismatched, res.Statuses, res.Errors)
+	}
+	if _, err := runBench("http://127.0.0.1:1/", 1, 1, "http3"); err == nil {
+		t.Error("http3 over plain http should be refused")
+	}
+}
+
+func TestConfigValidateFile(t *testing.T) {
+	active := "[server]\nlisten_addr = \"127.0.0.1:3000\"\nbackend_addr = \"127.0.0.1:8080\"\n\n[modules]\n"
+	dir := useProject(t, map[string]string{
+		"config.toml":          active,
+		"next/good.toml":       "include = [\"mods/*.toml\"]\n[server]\nlisten_addr = \"127.0.0.1:3000\"\nbackend_addr = \"127.0.0.1:8080\"\nbuffer_size = 4096\nfavourite = 1\n\n[modules]\n",
+		"next/mods/cache.toml": "[modules.cache]\nenabled = true\nttl_seconds = 60\nmax_size = \"lots\"\n",
+		"next/bad.toml":        "[server]\nlisten_addr = \"127.0.0.1:3000\"\nbackend_addr = \"nowhere\"\nbuffer_size = \"8k\"\n\n[modules]\n",
+		"next/broken.toml":     "[server\n",
+	})
+	validate := func(file string) string {
+		exitCode = 0
+		return captureOutput(t, func() { doConfigValidate([]string{filepath.Join("next", file)}) })
+	}
+	t.Cleanup(func() { exitCode = 0 })
+
+	out := validate("good.toml")
+	if exitCode != 0 || !strings.Contains(out, "is valid, with 2 warning(s)") {
+		t.Errorf("good.toml: exit %d\n%s", exitCode, out)
+	}
+	if !strings.Contains(out, "server.favourite is not a known key") || !strings.Contains(out, "modules.cache.max_size: expected integer, got string") {
+		t.Errorf("good.toml warnings (include not read relative to the file?):\n%s", out)
+	}
+
+	out = validate("bad.toml")
+	if exitCode != 1 || !strings.Contains(out, "server.backend_addr") || !strings.Contains(out, "server.buffer_size: expected integer, got string") {
+		t.Errorf("bad.toml: exit %d\n%s", exitCode, out)
+	}
+	if out := validate("broken.toml"); exitCode != 1 || !strings.Contains(out, "parse error") {
+		t.Errorf("broken.toml: exit %d\n%s", exitCode, out)
+	}
+	if validate("missing.toml"); exitCode != 1 {
+		t.Errorf("missing file: exit %d", exitCode)
+	}
+
+	if configOverride != "" || configPath() != filepath.Join(dir, "config.toml") {
+		t.Errorf("config path left at %s", configPath())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.toml")); string(data) != active {
+		t.Errorf("active config changed:\n%s", data)
+	}
+}
+
+func TestAdminAccessAndLint(t *testing.T) {
+	dir := useProject(t, map[string]string{
+		"config.toml": "[server]\nlisten_addr = \"0.0.0.0:3000\"\nbackend_addr = \"127.0.0.1:8080\"\n\n[modules.admin_api]\nenabled = true\nlisten_addr = \"127.0.0.1:9090\"\napi_key = \"\"\n",
+	})
+	lint := func() string {
+		exitCode = 0
+		return captureOutput(t, doConfigLint)
+	}
+	if out := lint(); exitCode != 0 || !strings.Contains(out, "No problems") {
+		t.Fatalf("loopback admin flagged (exit %d):\n%s", exitCode, out)
+	}
+
+	captureOutput(t, func() { doAdmin([]string{"bind", "0.0.0.0:9090"}) })
+	if out := lint(); exitCode != 1 || !strings.Contains(out, "no api_key or allow_ips") {
+		t.Errorf("open admin API not flagged (exit %d):\n%s", exitCode, out)
+	}
+
+	out := captureOutput(t, func() { doAdmin([]string{"allow", "10.1.2.3/8"}) })
+	if !strings.Contains(out, "allow_ips += 10.0.0.0/8") || !strings.Contains(out, "127.0.0.1 isn't allowed") {
+		t.Errorf("unexpected allow output:\n%s", out)
+	}
+	if out := lint(); exitCode != 0 {
+		t.Errorf("allowlisted admin still flagged:\n%s", out)
+	}
+	cfg, _ := loadConfigTOML()
+	if got := adminAllowList(adminSection(cfg, false)); !reflect.DeepEqual(got, []string{"10.0.0.0/8"}) {
+		t.Errorf("allow_ips = %v", got)
+	}
+
+	exitCode = 0
+	captureOutput(t, func() { doAdmin([]string{"allow", "not-an-ip"}) })
+	if exitCode != 2 {
+		t.Errorf("bad entry: exit %d, want 2", exitCode)
+	}
+	exitCode = 0
+	captureOutput(t, func() { doAdmin([]string{"allow", "--remove", "10.0.0.0/8"}) })
+	if out := lint(); exitCode != 1 {
+		t.Errorf("removing the last entry should reopen the API:\n%s", out)
+	}
+
+	keyed := "[server]\nlisten_addr = \"0.0.0.0:3000\"\nbackend_addr = \"127.0.0.1:8080\"\n\n[modules.admin_api]\nenabled = true\nlisten_addr = \"0.0.0.0:9090\"\napi_key_file = \"admin.key\"\n"
+	for _, f := range []struct{ name, data string }{{"config.toml", keyed}, {"admin.key", " \n"}} {
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(f.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out := lint(); exitCode != 1 || !strings.Contains(out, "admin.key is empty") {
+		t.Errorf("blank api_key_file not flagged (exit %d):\n%s", exitCode, out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "admin.key"), []byte("s3cret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out := lint(); exitCode != 0 {
+		t.Errorf("keyed admin API still flagged:\n%s", out)
+	}
+	exitCode = 0
+}
+
+func TestProcUsageOfSelf(t *testing.T) {
+	start := time.Now()
+	for time.Since(start) < 20*time.Millisecond {
+	}
+	st, err := procUsage(os.Getpid())
+	if err != nil {
+		t.Skipf("process stats unavailable here: %v", err)
+	}
+	if st.RSS < 1<<20 {
+		t.Errorf("RSS = %d, expected at least 1 MB for a Go test binary", st.RSS)
+	}
+	if st.CPU <= 0 {
+		t.Errorf("CPU = %v after a busy loop", st.CPU)
+	}
+	if !st.Started.IsZero() && (st.Started.After(time.Now()) || time.Since(st.Started) > time.Hour) {
+		t.Errorf("Started = %v", st.Started)
+	}
+	if f := st.cpuField(time.Now()); !strings.Contains(f, "core") && !st.Started.IsZero() {
+		t.Errorf("cpuField = %q", f)
+	}
+}
+
+func TestReloadHooks(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	dir := useProject(t, map[string]string{
+		".proxycache-cli.toml": "[hooks]\npre_reload = [\"echo draining\", \"echo pre >> hooks.log\"]\npost_reload = \"echo post-$PROXYCACHE_RELOAD_MODE-$PROXYCACHE_RELOAD_RESULT >> hooks.log\"\n",
+	})
+	reloaded := false
+	var ok bool
+	out := captureOutput(t, func() {
+		ok = withReloadHooks("full", func() bool { reloaded = true; return true })
+	})
+	data, _ := os.ReadFile(filepath.Join(dir, "hooks.log"))
+	if !ok || !reloaded || string(data) != "pre\npost-full-ok\n" {
+		t.Errorf("ok=%v reloaded=%v log=%q", ok, reloaded, data)
+	}
+	if !strings.Contains(out, "draining") {
+		t.Errorf("hook output not shown:\n%s", out)
+	}
+
+	os.WriteFile(filepath.Join(dir, ".proxycache-cli.toml"), []byte("[hooks]\npre_reload = \"exit 3\"\npost_reload = \"echo post >> hooks.log\"\n"), 0644)
+	os.Remove(filepath.Join(dir, "hooks.log"))
+	reloaded = false
+	out = captureOutput(t, func() {
+		ok = withReloadHooks("full", func() bool { reloaded = true; return true })
+	})
+	if ok || reloaded || !strings.Contains(out, "Reload aborted") {
+		t.Errorf("failed pre hook should abort: ok=%v reloaded=%v\n%s", ok, reloaded, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hooks.log")); err == nil {
+		t.Error("post hook ran after an aborted reload")
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	sent := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	received := sent.Add(20 * time.Millisecond)
+	at := func(d time.Duration) map[string]interface{} {
+		return map[string]interface{}{"server_time_ms": float64(sent.Add(10*time.Millisecond + d).UnixMilli())}
+	}
+
+	s, ok := measureSkew(at(0), sent, received)
+	if !ok || s.Skew != 0 || s.RTT != 20*time.Millisecond {
+		t.Fatalf("measureSkew = %+v, %v", s, ok)
+	}
+	if f := s.field(); !strings.HasPrefix(f, "in sync") || s.warning() != "" {
+		t.Errorf("synced clocks: field %q, warning %q", f, s.warning())
+	}
+
+	s, _ = measureSkew(at(3500*time.Millisecond), sent, received)
+	if f := s.field(); !strings.Contains(f, "proxy 3.5s ahead") {
+		t.Errorf("field = %q", f)
+	}
+	if w := s.warning(); !strings.Contains(w, "3.5s ahead of") {
+		t.Errorf("warning = %q", w)
+	}
+	s, _ = measureSkew(at(-4*time.Second), sent, received)
+	if w := s.warning(); !strings.Contains(w, "behind") {
+		t.Errorf("warning = %q", w)
+	}
+
+	// A slow round trip widens the margin: 3.2s off ±1.5s could be in range.
+	slow := map[string]interface{}{"server_time_ms": float64(sent.Add(4700 * time.Millisecond).UnixMilli())}
+	s, _ = measureSkew(slow, sent, sent.Add(3*time.Second))
+	if s.Skew != 3200*time.Millisecond {
+		t.Errorf("skew = %s", s.Skew)
+	}
+	if w := s.warning(); w != "" {
+		t.Errorf("skew within the margin warned: %q", w)
+	}
+	if _, ok := measureSkew(map[string]interface{}{"status": "running"}, sent, received); ok {
+		t.Error("older /status without server_time_ms measured a skew")
+	}
+}
+
+func TestACMESetsTLSPaths(t *testing.T) {
+	useProject(t, map[string]string{
+		"config.toml": "[server]\nlisten_addr = \"127.0.0.1:3000\"\n\n[modules.cache]\nenabled = true\n",
+	})
+	o, err := parseACMEArgs([]string{"--domain", "example.com,www.example.com", "--staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.directory != acmeStaging || len(o.domains) != 2 {
+		t.Errorf("parsed %+v", o)
+	}
+	if err := setTLSPaths(o.certOut, o.keyOut); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := loadConfigTOML()
+	srv := cfg["server"].(map[string]interface{})
+	if srv["tls_cert"] != "certs/example.com.crt" || srv["tls_key"] != "certs/example.com.key" {
+		t.Errorf("server = %v", srv)
+	}
+	if srv["listen_addr"] != "127.0.0.1:3000" || getModules(cfg)["cache"] == nil {
+		t.Errorf("other settings lost: %v", cfg)
+	}
+	if _, err := parseACMEArgs([]string{"--email", "a@b"}); err == nil {
+		t.Error("missing --domain accepted")
+	}
+}
+
+func TestWatchClipsColoredLinesAndTinyTerminals(t *testing.T) {
+	red, reset := "\x1b[31m", "\x1b[0m"
+	line := red + "[ERROR]" + reset + " backend refused"
+	if got := clip(line, 100); got != line {
+		t.Errorf("short line changed: %q", got)
+	}
+	got := clip(line, 10)
+	if want := red + "[ERROR]" + reset + " b…"; !strings.HasPrefix(got, want) {
+		t.Errorf("clip = %q, want prefix %q", got, want)
+	}
+	if visibleWidth(got) != 10 {
+		t.Errorf("clipped to %d columns, want 10", visibleWidth(got))
+	}
+	for _, h := range []int{0, 3, 4, 5} {
+		captureOutput(t, func() {
+			drawWatch([]string{"a", "b", "c"}, []string{"one", "two"}, nil, 20, h)
+		})
+	}
+}
+
+func TestACMERequiresAgreeTOS(t *testing.T) {
+	dir := useProject(t, map[string]string{"config.toml": "[server]\nlisten_addr = \"127.0.0.1:3000\"\n"})
+	terms := "https://ca.example/terms-v3.pdf"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"newAccount":"x","newOrder":"y","newNonce":"z","meta":{"termsOfService":%q}}`, terms)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { exitCode = 0 })
+
+	out := captureOutput(t, func() { doTLSACME([]string{"--domain", "example.com", "--directory", srv.URL}) })
+	if exitCode != 2 || !strings.Contains(out, terms) || !strings.Contains(out, "--agree-tos") {
+		t.Errorf("ran without --agree-tos (exit %d):\n%s", exitCode, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".proxycache-acme.key")); !os.IsNotExist(err) {
+		t.Error("account key created before the terms were agreed to")
+	}
+	if o, err := parseACMEArgs([]string{"-d", "example.com", "--agree-tos"}); err != nil || !o.agreeTOS {
+		t.Errorf("--agree-tos not parsed: %+v, %v", o, err)
+	}
+}
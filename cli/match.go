@@ -0,0 +1,89 @@
+// Module name resolution: exact, unambiguous prefix, or a suggestion
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// resolveModuleName maps what the user typed to a module in config.toml,
+// also accepting the extra names given (server, web). An unambiguous
+// prefix is accepted; otherwise the candidates or the closest name are
+// printed and ok is false.
+func resolveModuleName(input string, extra ...string) (name string, ok bool) {
+	names := append([]string{}, extra...)
+	if cfg, err := loadConfigTOML(); err == nil {
+		for n := range getModules(cfg) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	var matches []string
+	for _, n := range names {
+		if n == input {
+			return n, true
+		}
+		if strings.HasPrefix(n, input) {
+			matches = append(matches, n)
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		fmt.Printf("  %s→ %s%s\n", dim, matches[0], reset)
+		return matches[0], true
+	case 0:
+		fmt.Printf("  %s✗ '%s' not found%s\n", red, input, reset)
+		if best := closestName(input, names); best != "" {
+			fmt.Printf("  %sDid you mean '%s'?%s\n", dim, best, reset)
+		} else {
+			fmt.Printf("  %sTip: use 'ls' to see available entries%s\n", dim, reset)
+		}
+	default:
+		fmt.Printf("  %s✗ '%s' is ambiguous:%s %s\n", red, input, reset, strings.Join(matches, ", "))
+	}
+	exitCode = 1
+	return "", false
+}
+
+// closestName returns the candidate with the smallest edit distance to s,
+// if it is close enough to be a plausible typo.
+func closestName(s string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		if d := editDistance(s, c); bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	limit := len(s) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDist < 0 || bestDist > limit {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
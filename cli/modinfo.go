@@ -0,0 +1,355 @@
+// mods info: everything known about one module, from its file, the config
+// and the running proxy
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// modInfo is a module's metadata as read from its source file.
+type modInfo struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"` // script, built-in or import
+	File        string   `json:"file"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Priority    int      `json:"priority"`
+	Hooks       []string `json:"hooks"`
+	Overrides   []string `json:"overrides,omitempty"`
+	Build       string   `json:"build"`
+}
+
+// parsePcmodInfo reads a .pcmod's header. description and author lines
+// are for people; the proxy's parser skips them, so a leading # comment
+// works as the description too.
+func parsePcmodInfo(content string) modInfo {
+	info := modInfo{Kind: "script", Priority: parsePcmodPriority(content), Hooks: []string{}}
+	info.Name, info.Version = parsePcmod(content)
+	comment := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#"):
+			if comment == "" && info.Description == "" {
+				comment = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			}
+		case strings.HasPrefix(line, "description "):
+			info.Description = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "description ")), "\"")
+		case strings.HasPrefix(line, "author "):
+			info.Author = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "author ")), "\"")
+		case strings.HasPrefix(line, "overrides "):
+			inner := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "overrides ")), "[]")
+			for _, s := range strings.Split(inner, ",") {
+				if s = strings.Trim(strings.TrimSpace(s), "\""); s != "" {
+					info.Overrides = append(info.Overrides, s)
+				}
+			}
+		case line == "on_init {", line == "on_request {", line == "on_response {":
+			info.Hooks = append(info.Hooks, strings.TrimSuffix(line, " {"))
+		}
+	}
+	if info.Description == "" {
+		info.Description = comment
+	}
+	return info
+}
+
+// parseRustModInfo reads what a module source file says about itself: the
+// first-line comment and which trait methods it implements.
+func parseRustModInfo(name, content string) modInfo {
+	info := modInfo{Name: name, Kind: "built-in", Priority: defaultScriptPriority, Hooks: []string{}}
+	for _, b := range builtinPriority {
+		if b.name == name {
+			info.Priority = b.priority
+		}
+	}
+	if first, _, _ := strings.Cut(content, "\n"); strings.HasPrefix(first, "//") {
+		info.Description = strings.TrimSpace(strings.TrimLeft(first, "/"))
+	}
+	if strings.Contains(content, "Module for ") {
+		info.Hooks = append(info.Hooks, "on_request")
+	}
+	if strings.Contains(content, "fn on_response(") {
+		info.Hooks = append(info.Hooks, "on_response")
+	}
+	if strings.Contains(content, "RawHandler for ") {
+		info.Hooks = append(info.Hooks, "raw_tcp")
+	}
+	return info
+}
+
+// cargoPackage returns the crate's version and authors, which built-in
+// modules share.
+func cargoPackage(root string) (version string, authors []string) {
+	data, err := readTextFile(filepath.Join(root, "Cargo.toml"))
+	if err != nil {
+		return "?", nil
+	}
+	var manifest struct {
+		Package struct {
+			Version string
+			Authors []string
+		}
+	}
+	if toml.Unmarshal(data, &manifest) != nil || manifest.Package.Version == "" {
+		return "?", nil
+	}
+	return manifest.Package.Version, manifest.Package.Authors
+}
+
+// findModule looks for name as a script in mods/, a built-in in
+// src/modules/ and an import in imports/, in the order the proxy lets
+// them take precedence.
+func findModule(root, name string) (modInfo, bool) {
+	modsDir := filepath.Join(root, "mods")
+	entries, _ := os.ReadDir(modsDir)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pcmod") {
+			continue
+		}
+		path := filepath.Join(modsDir, e.Name())
+		data, err := readTextFile(path)
+		if err != nil {
+			continue
+		}
+		if info := parsePcmodInfo(string(data)); info.Name == name {
+			info.File = path
+			info.Build = "interpreted, loaded at start"
+			return info, true
+		}
+	}
+
+	version, authors := cargoPackage(root)
+	for _, dir := range []string{filepath.Join("src", "modules"), "imports"} {
+		if name == "mod" || name == "helpers" {
+			break
+		}
+		path := filepath.Join(root, dir, name+".rs")
+		data, err := readTextFile(path)
+		if err != nil {
+			continue
+		}
+		info := parseRustModInfo(name, string(data))
+		info.File, info.Version = path, version
+		info.Author = strings.Join(authors, ", ")
+		if dir == "imports" {
+			info.Kind, info.Build = "import", "not compiled: 'compile' brings it into src/modules/"
+		} else {
+			info.Build = rustBuildState(root, path)
+		}
+		return info, true
+	}
+	return modInfo{}, false
+}
+
+// rustBuildState says whether the compiled proxy includes the current
+// source of a built-in module.
+func rustBuildState(root, src string) string {
+	bin, err := fsys.Stat(filepath.Join(root, binaryPath()))
+	if err != nil {
+		return "not compiled"
+	}
+	if st, err := fsys.Stat(src); err == nil && st.ModTime().After(bin.ModTime()) {
+		return "changed since the last compile"
+	}
+	return "compiled"
+}
+
+// knownModuleNames lists every module in the tree or the config, for
+// suggestions.
+func knownModuleNames(root string, cfg map[string]interface{}) []string {
+	set := map[string]bool{}
+	for name := range discoverModules(root) {
+		set[name] = true
+	}
+	for name := range getModules(cfg) {
+		set[name] = true
+	}
+	if entries, err := os.ReadDir(filepath.Join(root, "imports")); err == nil {
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".rs") {
+				set[strings.TrimSuffix(e.Name(), ".rs")] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(set))
+	for n := range set {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// moduleLive is the running proxy's view of a module. Reachable is false
+// when the admin API didn't answer.
+type moduleLive struct {
+	Reachable bool     `json:"reachable"`
+	Loaded    bool     `json:"loaded"`
+	Paused    bool     `json:"paused"`
+	Requests  float64  `json:"requests,omitempty"`
+	AvgUS     *float64 `json:"avg_us,omitempty"`
+}
+
+// fetchModuleLive finds name in the pipeline's timings, which list every
+// loaded module, and in the paused set.
+func fetchModuleLive(name string) moduleLive {
+	var live moduleLive
+	data, _, err := adminJSON("GET", "/modules/timing")
+	if err != nil {
+		return live
+	}
+	live.Reachable = true
+	for _, t := range parseModuleTimings(data) {
+		if t.Name == name {
+			avg := t.AvgUS
+			live.Loaded, live.Requests, live.AvgUS = true, t.Requests, &avg
+		}
+	}
+	live.Paused = fetchPaused()[name]
+	return live
+}
+
+// moduleDefaults is what each of the module's keys falls back to: the
+// schema for a built-in, the config block for a script.
+func moduleDefaults(info modInfo) map[string]interface{} {
+	if info.Kind == "script" {
+		if data, err := readTextFile(info.File); err == nil {
+			return pcmodDefaults(string(data))
+		}
+		return nil
+	}
+	out := map[string]interface{}{}
+	for k, spec := range moduleSchema[info.Name] {
+		out[k] = spec.Default
+	}
+	return out
+}
+
+func doModsInfo(name string) {
+	root := projectRoot()
+	cfg, cfgErr := loadConfigTOML()
+	info, found := findModule(root, name)
+	section, inConfig := getModules(cfg)[name].(map[string]interface{})
+	if !found && !inConfig {
+		fmt.Printf("  %s✗ No module named '%s'%s\n", red, name, reset)
+		if best := closestName(name, knownModuleNames(root, cfg)); best != "" {
+			fmt.Printf("  %sDid you mean '%s'?%s\n", dim, best, reset)
+		}
+		exitCode = 1
+		return
+	}
+	live := fetchModuleLive(name)
+	enabled := inConfig && section["enabled"] == true
+
+	if jsonOut {
+		out := map[string]interface{}{"name": name, "in_config": inConfig, "enabled": enabled, "live": live}
+		if found {
+			out["module"] = info
+		}
+		if inConfig {
+			out["settings"] = redacted(withDurations(name, section), showSecrets)
+		}
+		printJSONValue(out)
+		return
+	}
+
+	kind := info.Kind
+	if !found {
+		kind = "config only"
+	}
+	fmt.Printf("  %s%s%s%s %s(%s)%s\n", bold, cyan, name, reset, dim, kind, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if found {
+		if info.Description != "" {
+			printStatusField("Description", info.Description)
+		}
+		printStatusField("Version", info.Version)
+		if info.Author != "" {
+			printStatusField("Author", info.Author)
+		}
+		printStatusField("File", displayPath(info.File))
+		hooks := dim + "none (runs its own listener)" + reset
+		if len(info.Hooks) > 0 {
+			hooks = strings.Join(info.Hooks, ", ")
+		}
+		printStatusField("Hooks", hooks)
+		printStatusField("Priority", configPriority(getModules(cfg), name, info.Priority))
+		if len(info.Overrides) > 0 {
+			printStatusField("Overrides", strings.Join(info.Overrides, ", "))
+		}
+		switch info.Build {
+		case "compiled", "interpreted, loaded at start":
+			printStatusField("Build", info.Build)
+		default:
+			printStatusField("Build", yellow+info.Build+reset)
+		}
+	} else {
+		fmt.Printf("  %s⚠ In config.toml but no source file found in mods/, src/modules/ or imports/%s\n", yellow, reset)
+	}
+
+	switch {
+	case !inConfig:
+		printStatusField("Enabled", dim+"not in config.toml ('mods sync' adds it)"+reset)
+	case enabled:
+		printStatusField("Enabled", green+"yes"+reset)
+	default:
+		printStatusField("Enabled", red+"no"+reset)
+	}
+
+	fmt.Printf("\n  %s%sLive%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	switch {
+	case !live.Reachable:
+		fmt.Printf("  %sProxy not reachable, no live state%s\n", dim, reset)
+	case !live.Loaded && info.Kind == "built-in" && (len(info.Hooks) == 0 || info.Hooks[0] == "raw_tcp"):
+		printStatusField("Loaded", dim+"runs outside the request pipeline, no timings"+reset)
+	case !live.Loaded:
+		printStatusField("Loaded", dim+"no, not in the running pipeline"+reset)
+	default:
+		state := green + "yes" + reset
+		if live.Paused {
+			state = yellow + "yes, paused" + reset
+		}
+		printStatusField("Loaded", state)
+		if live.Requests > 0 && live.AvgUS != nil {
+			printStatusField("Avg Time", fmt.Sprintf("%s over %s requests", formatDuration(time.Duration(*live.AvgUS*float64(time.Microsecond))), formatCount(live.Requests)))
+		}
+	}
+
+	if !inConfig {
+		if cfgErr != nil {
+			fmt.Printf("\n  %s✗ Can't read config: %s%s\n", red, cfgErr, reset)
+		}
+		return
+	}
+	fmt.Printf("\n  %s%sSettings%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	defaults := moduleDefaults(info)
+	shown := withDurations(name, section)
+	t := newTable()
+	for _, k := range sortedKeys(shown) {
+		if k == "enabled" {
+			continue
+		}
+		note := ""
+		if d, ok := defaults[k]; ok && reflect.DeepEqual(section[k], d) {
+			note = dim + "(default)" + reset
+		} else if !ok && found && k != "priority" {
+			note = yellow + "(not read by this module)" + reset
+		}
+		t.row(k, fmt.Sprint(redactValue(k, shown[k], showSecrets)), note)
+	}
+	t.print()
+	if len(shown) <= 1 {
+		fmt.Printf("  %sNo settings besides enabled%s\n", dim, reset)
+	}
+}
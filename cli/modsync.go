@@ -0,0 +1,147 @@
+// mods sync: add modules found in the tree but missing from config.toml
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pcmodDefaults reads the config { key type default } block of a .pcmod
+// the way the proxy's script parser does.
+func pcmodDefaults(content string) map[string]interface{} {
+	out := map[string]interface{}{}
+	inConfig := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !inConfig {
+			inConfig = line == "config {"
+			continue
+		}
+		if line == "}" {
+			break
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		key, val := parts[0], parts[2]
+		switch parts[1] {
+		case "bool":
+			out[key] = val == "true"
+		case "int":
+			n, _ := strconv.ParseInt(val, 10, 64)
+			out[key] = n
+		case "str":
+			out[key] = strings.Trim(val, "\"")
+		case "list":
+			list := []interface{}{}
+			if inner := strings.TrimSpace(strings.Trim(val, "[]")); inner != "" {
+				for _, s := range strings.Split(inner, ",") {
+					list = append(list, strings.Trim(strings.TrimSpace(s), "\"'"))
+				}
+			}
+			out[key] = list
+		}
+	}
+	return out
+}
+
+// discoverModules lists the modules in the tree with their default
+// settings: built-ins from src/modules/ (defaults from the schema) and
+// script modules from mods/*.pcmod.
+func discoverModules(root string) map[string]map[string]interface{} {
+	found := map[string]map[string]interface{}{}
+	if entries, err := os.ReadDir(filepath.Join(root, "src", "modules")); err == nil {
+		for _, e := range entries {
+			n := e.Name()
+			if e.IsDir() || n == "mod.rs" || n == "helpers.rs" || !strings.HasSuffix(n, ".rs") {
+				continue
+			}
+			name := strings.TrimSuffix(n, ".rs")
+			defaults := map[string]interface{}{}
+			for k, spec := range moduleSchema[name] {
+				defaults[k] = spec.Default
+			}
+			found[name] = defaults
+		}
+	}
+	modsDir := filepath.Join(root, "mods")
+	if entries, err := os.ReadDir(modsDir); err == nil {
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".pcmod") {
+				continue
+			}
+			data, err := readTextFile(filepath.Join(modsDir, e.Name()))
+			if err != nil {
+				continue
+			}
+			if name, _ := parsePcmod(string(data)); name != "unknown" {
+				found[name] = pcmodDefaults(string(data))
+			}
+		}
+	}
+	return found
+}
+
+// syncModules adds every discovered module missing from cfg, disabled, and
+// returns the names added.
+func syncModules(cfg map[string]interface{}, found map[string]map[string]interface{}) []string {
+	mods := getModules(cfg)
+	if mods == nil {
+		mods = map[string]interface{}{}
+		cfg["modules"] = mods
+	}
+	var added []string
+	for name, defaults := range found {
+		if _, ok := mods[name]; ok {
+			continue
+		}
+		section := map[string]interface{}{}
+		for k, v := range defaults {
+			section[k] = v
+		}
+		section["enabled"] = false
+		mods[name] = section
+		added = append(added, name)
+	}
+	sort.Strings(added)
+	return added
+}
+
+// doModsSync brings [modules] in line with the tree so new modules show up
+// in ls and toggle.
+func doModsSync(dryRun bool) {
+	version := configVersion()
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	added := syncModules(cfg, discoverModules(projectRoot()))
+	if len(added) == 0 {
+		fmt.Printf("  %s✓ Every module in the tree is already in config.toml%s\n", green, reset)
+		return
+	}
+	for _, name := range added {
+		fmt.Printf("    %s+ %s%s %s(disabled)%s\n", green, name, reset, dim, reset)
+	}
+	if dryRun {
+		fmt.Printf("  %sDry run: %d module(s) would be added, nothing saved%s\n", yellow, len(added), reset)
+		return
+	}
+	if err := saveConfigTOMLAt(cfg, version); err != nil {
+		fmt.Printf("  %s✗ Not saved: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s✓ Added %d module(s) to config.toml%s\n", green, len(added), reset)
+	fmt.Printf("  %sEnable one with 'toggle <name>'%s\n", dim, reset)
+}
@@ -0,0 +1,77 @@
+// mods timing: time each pipeline module adds per request
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// moduleTiming is one row of /modules/timing.
+type moduleTiming struct {
+	Name     string
+	Requests float64
+	TotalUS  float64
+	AvgUS    float64
+}
+
+// parseModuleTimings reads /modules/timing, slowest module first.
+func parseModuleTimings(data map[string]interface{}) []moduleTiming {
+	list, _ := data["modules"].([]interface{})
+	var out []moduleTiming
+	for _, v := range list {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t := moduleTiming{}
+		t.Name, _ = m["name"].(string)
+		t.Requests, _ = jsonNumber(m["requests"])
+		t.TotalUS, _ = jsonNumber(m["total_us"])
+		t.AvgUS, _ = jsonNumber(m["avg_us"])
+		out = append(out, t)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].AvgUS > out[j].AvgUS })
+	return out
+}
+
+func doModsTiming() {
+	data, code, err := adminJSON("GET", "/modules/timing")
+	if code == 404 {
+		printUnavailable("Module Timing")
+		return
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		exitCode = 1
+		return
+	}
+	if jsonOut {
+		printJSONValue(data)
+		return
+	}
+
+	rows := parseModuleTimings(data)
+	fmt.Printf("  %s%sModule Timing%s %s(avg per request, slowest first)%s\n", bold, cyan, reset, dim, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	var total float64
+	for _, r := range rows {
+		total += r.TotalUS
+	}
+	if total == 0 {
+		fmt.Printf("  %sNo requests timed yet%s\n", dim, reset)
+		return
+	}
+	t := newTable("MODULE", "AVG", "REQUESTS", "SHARE").alignRight(1, 2, 3)
+	for _, r := range rows {
+		avg := formatDuration(time.Duration(r.AvgUS * float64(time.Microsecond)))
+		share := fmt.Sprintf("%.1f%%", r.TotalUS/total*100)
+		name := r.Name
+		if r.Requests == 0 {
+			name, avg = dim+name+reset, dim+"—"+reset
+		}
+		t.row(name, avg, fmt.Sprintf("%.0f", r.Requests), share)
+	}
+	t.print()
+	fmt.Printf("\n  %sproxy_core includes the backend round trip%s\n", dim, reset)
+}
@@ -0,0 +1,247 @@
+// Per-module metrics, split out of /metrics by the module that records them
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// builtinMetricSources is the module behind each module-recorded /metrics
+// field, for proxies that don't publish "sources" themselves.
+var builtinMetricSources = map[string]string{
+	"circuit_breaker_trips":   "circuit_breaker",
+	"circuit_breaker_rejects": "circuit_breaker",
+	"rate_limiter_rejects":    "rate_limiter",
+	"cache_hits":              "cache",
+	"cache_misses":            "cache",
+}
+
+// metricGroup is one module's share of /metrics. Events sums its counters.
+type metricGroup struct {
+	Module string
+	Keys   []string
+	Events float64
+}
+
+// groupMetrics splits the numeric /metrics fields by the module that
+// records them. Fields without a source are proxy-wide and returned as
+// global; module groups come sorted by name.
+func groupMetrics(data map[string]interface{}) (global []string, modules []metricGroup) {
+	sources := builtinMetricSources
+	if published, ok := data["sources"].(map[string]interface{}); ok {
+		sources = map[string]string{}
+		for k, v := range published {
+			if m, ok := v.(string); ok && m != "" {
+				sources[k] = m
+			}
+		}
+	}
+	byModule := map[string]*metricGroup{}
+	for _, k := range sortedKeys(data) {
+		v, ok := data[k].(float64)
+		if !ok {
+			continue
+		}
+		m, ok := sources[k]
+		if !ok {
+			global = append(global, k)
+			continue
+		}
+		g := byModule[m]
+		if g == nil {
+			g = &metricGroup{Module: m}
+			byModule[m] = g
+		}
+		g.Keys = append(g.Keys, k)
+		if isCounterMetric(k) {
+			g.Events += v
+		}
+	}
+	for _, g := range byModule {
+		modules = append(modules, *g)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Module < modules[j].Module })
+	return global, modules
+}
+
+// isCounterMetric reports whether a field only ever grows. Fields the CLI
+// doesn't know count as counters unless their name carries a unit.
+func isCounterMetric(k string) bool {
+	if kind, ok := metricKinds[k]; ok {
+		return kind == "counter"
+	}
+	return metricUnit(k) == ""
+}
+
+func metricUnit(k string) string {
+	for _, u := range []string{"_ms", "_seconds", "_bytes"} {
+		if strings.HasSuffix(k, u) {
+			return u
+		}
+	}
+	if strings.HasPrefix(k, "bytes_") {
+		return "_bytes"
+	}
+	return ""
+}
+
+// metricLabel turns circuit_breaker_trips into "Trips" within its module's
+// group.
+func metricLabel(module, k string) string {
+	k = strings.TrimPrefix(k, module+"_")
+	k = strings.TrimSuffix(k, metricUnit(k))
+	words := strings.Split(k, "_")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// formatMetric renders a value by the unit in its field name.
+func formatMetric(k string, v interface{}) string {
+	switch metricUnit(k) {
+	case "_ms":
+		return formatMillis(v)
+	case "_seconds":
+		return formatSeconds(v)
+	case "_bytes":
+		return formatBytes(v)
+	}
+	return formatCount(v)
+}
+
+// formatCount prints whole numbers with thousands separators, 1234567 as
+// 1,234,567, and anything else as it is.
+func formatCount(v interface{}) string {
+	f, ok := jsonNumber(v)
+	if !ok {
+		return "—"
+	}
+	if f != math.Trunc(f) || math.Abs(f) >= 1e15 {
+		return fmt.Sprintf("%.2f", f)
+	}
+	digits := fmt.Sprintf("%d", int64(math.Abs(f)))
+	var b strings.Builder
+	if f < 0 {
+		b.WriteByte('-')
+	}
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// globalMetricLayout orders the proxy-wide fields /metrics is known to
+// carry. Alternatives cover field names that changed between versions.
+var globalMetricLayout = []struct {
+	label  string
+	keys   []string
+	format func(interface{}) string
+}{
+	{"Requests", []string{"requests_total"}, formatCount},
+	{"OK", []string{"requests_ok"}, formatCount},
+	{"Errors", []string{"requests_err"}, formatCount},
+	{"Bytes In", []string{"bytes_in"}, formatBytes},
+	{"Bytes Out", []string{"bytes_out"}, formatBytes},
+	{"Latency Avg", []string{"avg_latency_ms", "latency_avg_ms"}, formatMillis},
+	{"Latency Max", []string{"latency_max_ms"}, formatMillis},
+	{"Latency Sum", []string{"latency_sum_ms"}, formatMillis},
+	{"Active Conns", []string{"active_connections"}, formatCount},
+	{"Connections", []string{"connections_total"}, formatCount},
+	{"Pool Hits", []string{"pool_hits"}, formatCount},
+	{"Pool Misses", []string{"pool_misses"}, formatCount},
+	{"Pool Waiting", []string{"pool_waiting"}, formatCount},
+	{"Pool Avg Wait", []string{"pool_wait_avg_ms"}, formatMillis},
+	{"Uptime", []string{"uptime_secs", "uptime_seconds"}, formatSeconds},
+}
+
+// printGlobalMetrics prints the proxy-wide fields in globalMetricLayout
+// order, then any the layout doesn't know yet.
+func printGlobalMetrics(global []string, data map[string]interface{}) {
+	fmt.Printf("  %s%sGlobal%s %s(proxy-wide)%s\n", bold, cyan, reset, dim, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	shown := map[string]bool{}
+	for _, f := range globalMetricLayout {
+		for _, k := range f.keys {
+			if v, ok := data[k]; ok && !shown[k] {
+				printStatusField(f.label, f.format(v))
+				break
+			}
+		}
+		for _, k := range f.keys {
+			shown[k] = true
+		}
+	}
+	for _, k := range global {
+		if !shown[k] {
+			printStatusField(metricLabel("", k), formatMetric(k, data[k]))
+		}
+	}
+}
+
+// printMetricGroups prints one section per module that records metrics,
+// with the sum of its counters in the heading.
+func printMetricGroups(groups []metricGroup, data map[string]interface{}) {
+	for _, g := range groups {
+		fmt.Printf("\n  %s%s%s%s %s(module, %s events)%s\n", bold, cyan, g.Module, reset, dim, formatCount(g.Events), reset)
+		fmt.Printf("  %s%s%s\n", dim, sep, reset)
+		for _, k := range g.Keys {
+			printStatusField(metricLabel(g.Module, k), formatMetric(k, data[k]))
+		}
+	}
+}
+
+// fetchModuleMetrics returns the /metrics fields recorded by name, or nil
+// if it records none, along with every module that records some.
+func fetchModuleMetrics(name string) (fields map[string]interface{}, modules []string, err error) {
+	data, _, err := adminJSON("GET", "/metrics")
+	if err != nil {
+		return nil, nil, err
+	}
+	_, groups := groupMetrics(data)
+	for _, g := range groups {
+		modules = append(modules, g.Module)
+		if g.Module == name {
+			fields = map[string]interface{}{}
+			for _, k := range g.Keys {
+				fields[k] = data[k]
+			}
+		}
+	}
+	return fields, modules, nil
+}
+
+func doModuleMetrics(name string) {
+	fields, modules, err := fetchModuleMetrics(name)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		exitCode = 1
+		return
+	}
+	if fields == nil {
+		fmt.Printf("  %s✗ No metrics for '%s'%s\n", red, name, reset)
+		if len(modules) > 0 {
+			fmt.Printf("  %sModules with metrics: %s%s\n", dim, strings.Join(modules, ", "), reset)
+		} else {
+			fmt.Printf("  %sNo module records its own metrics in this proxy%s\n", dim, reset)
+		}
+		exitCode = 1
+		return
+	}
+	if jsonOut {
+		printJSONValue(fields)
+		return
+	}
+	fmt.Printf("  %s%s%s Metrics%s\n", bold, cyan, name, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	for _, k := range sortedKeys(fields) {
+		printStatusField(metricLabel(name, k), formatMetric(k, fields[k]))
+	}
+}
@@ -0,0 +1,247 @@
+// Module execution order (mirrors Pipeline ordering in src/modules/mod.rs)
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtinPriority mirrors default_priority() in src/modules/mod.rs.
+// Modules listed in registration order; unlisted script modules get 75.
+var builtinPriority = []struct {
+	name     string
+	priority int
+}{
+	{"request_id", 20},
+	{"rate_limiter", 30},
+	{"circuit_breaker", 40},
+	{"health_check", 50},
+	{"metrics_exporter", 60},
+	{"headers", 75},
+	{"cache", 80},
+	{"url_rewriter", 90},
+	{"compression", 100},
+	{"load_balancer", 110},
+	{"proxy_core", 120},
+}
+
+const defaultScriptPriority = 75
+
+type chainEntry struct {
+	name     string
+	priority int
+	script   bool
+}
+
+func parsePcmodPriority(content string) int {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "priority ") {
+			if p, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "priority "))); err == nil {
+				return p
+			}
+		}
+	}
+	return defaultScriptPriority
+}
+
+func moduleEnabled(mods map[string]interface{}, name string, def bool) bool {
+	m, ok := mods[name].(map[string]interface{})
+	if !ok {
+		return def
+	}
+	if e, ok := m["enabled"].(bool); ok {
+		return e
+	}
+	return def
+}
+
+// configPriority returns a `priority` override from [modules.<name>].
+func configPriority(mods map[string]interface{}, name string, def int) int {
+	m, _ := mods[name].(map[string]interface{})
+	if p, ok := m["priority"].(int64); ok {
+		return int(p)
+	}
+	return def
+}
+
+// computeChain derives the request pipeline from config.toml and mods/.
+func computeChain(cfg map[string]interface{}) []chainEntry {
+	mods := getModules(cfg)
+	var chain []chainEntry
+	loaded := map[string]bool{}
+	for _, b := range builtinPriority {
+		// load_balancer always registers a single-backend forwarder
+		if b.name != "load_balancer" && !moduleEnabled(mods, b.name, false) {
+			continue
+		}
+		chain = append(chain, chainEntry{name: b.name, priority: configPriority(mods, b.name, b.priority)})
+		loaded[b.name] = true
+	}
+
+	modsDir := filepath.Join(projectRoot(), "mods")
+	entries, _ := os.ReadDir(modsDir)
+	var scripts []chainEntry
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pcmod") {
+			continue
+		}
+		data, err := readTextFile(filepath.Join(modsDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		name, _ := parsePcmod(string(data))
+		if loaded[name] || !moduleEnabled(mods, name, true) {
+			continue
+		}
+		prio := configPriority(mods, name, parsePcmodPriority(string(data)))
+		scripts = append(scripts, chainEntry{name: name, priority: prio, script: true})
+	}
+	sort.SliceStable(scripts, func(i, j int) bool { return scripts[i].priority < scripts[j].priority })
+	chain = append(chain, scripts...)
+
+	sort.SliceStable(chain, func(i, j int) bool { return chain[i].priority < chain[j].priority })
+	return chain
+}
+
+func doModsOrder() {
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		return
+	}
+	printChain(computeChain(cfg), "from config")
+
+	if moduleEnabled(getModules(cfg), "raw_tcp", false) {
+		fmt.Printf("\n  %s! raw_tcp is enabled: connections bypass the HTTP pipeline%s\n", yellow, reset)
+	}
+}
+
+func printChain(chain []chainEntry, source string) {
+	fmt.Printf("  %s%sModule Order%s %s(%s)%s\n", bold, cyan, reset, dim, source, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	names := make([]string, 0, len(chain)+1)
+	t := newTable().alignRight(0)
+	for i, e := range chain {
+		prio := "—"
+		if e.priority >= 0 {
+			prio = strconv.Itoa(e.priority)
+		}
+		kind := ""
+		if e.script {
+			kind = dim + " (.pcmod)" + reset
+		}
+		t.row(fmt.Sprintf("%s%d.%s", dim, i+1, reset), e.name, dim+"prio "+prio+reset+kind)
+		names = append(names, e.name)
+	}
+	t.print()
+	names = append(names, "backend")
+	fmt.Printf("\n  %s\n", strings.Join(names, " → "))
+	fmt.Printf("  %sRequests run top to bottom; responses unwind in reverse%s\n", dim, reset)
+}
+
+// doModsMove reorders a module by writing `priority` into its config
+// section. Only the moved module changes unless there's no integer gap
+// at the target, in which case the whole chain is renumbered.
+func doModsMove(name, where, other string) {
+	if where != "before" && where != "after" {
+		fmt.Printf("  %sUsage: mods move <module> before|after <module>%s\n", yellow, reset)
+		return
+	}
+	version := configVersion()
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		return
+	}
+	mods := getModules(cfg)
+	if mods == nil {
+		fmt.Printf("  %s✗ No modules section in config%s\n", red, reset)
+		return
+	}
+	chain := computeChain(cfg)
+	var rest []chainEntry
+	var moved *chainEntry
+	for i := range chain {
+		if chain[i].name == name {
+			moved = &chain[i]
+		} else {
+			rest = append(rest, chain[i])
+		}
+	}
+	if moved == nil {
+		fmt.Printf("  %s✗ '%s' is not in the active chain%s\n", red, name, reset)
+		fmt.Printf("  %sTip: use 'mods order' to see enabled modules%s\n", dim, reset)
+		return
+	}
+	idx := -1
+	for i, e := range rest {
+		if e.name == other {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		fmt.Printf("  %s✗ '%s' is not in the active chain%s\n", red, other, reset)
+		return
+	}
+	if where == "after" {
+		idx++
+	}
+
+	m := *moved
+	ordered := append(append(append([]chainEntry{}, rest[:idx]...), m), rest[idx:]...)
+	lo, hi := m.priority-20, m.priority+20
+	if idx > 0 {
+		lo = rest[idx-1].priority
+		if idx == len(rest) {
+			hi = lo + 20
+		}
+	}
+	if idx < len(rest) {
+		hi = rest[idx].priority
+		if idx == 0 {
+			lo = hi - 20
+		}
+	}
+	if hi-lo >= 2 {
+		ordered[idx].priority = lo + (hi-lo)/2
+		setPriority(mods, name, ordered[idx].priority)
+	} else {
+		for i := range ordered {
+			ordered[i].priority = (i + 1) * 10
+			setPriority(mods, ordered[i].name, ordered[i].priority)
+		}
+	}
+	cfg["modules"] = mods
+
+	if err := saveConfigTOMLAt(cfg, version); err != nil {
+		fmt.Printf("  %s✗ Not saved: %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s✓ Moved %s %s %s%s\n\n", green, name, where, other, reset)
+	printChain(ordered, "pending reload")
+	for i, e := range ordered {
+		if e.name == "proxy_core" && i < len(ordered)-1 {
+			fmt.Printf("  %s! Modules after proxy_core never see requests%s\n", yellow, reset)
+			break
+		}
+	}
+	fmt.Printf("  %sRun 'reload' to apply changes%s\n", dim, reset)
+}
+
+// setPriority writes prio into name's section. Only modules in the active
+// chain are moved, so a section created here is enabled, as the module
+// already was by default.
+func setPriority(mods map[string]interface{}, name string, prio int) {
+	m, ok := mods[name].(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{"enabled": true}
+	}
+	m["priority"] = int64(prio)
+	mods[name] = m
+}
@@ -0,0 +1,55 @@
+// --output <file>: write a command's result to a file instead of stdout
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// takeOutputFlag removes "--output <file>" from parts and returns the file.
+func takeOutputFlag(parts []string) ([]string, string, error) {
+	out := parts[:0:0]
+	file := ""
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "--output" {
+			if i+1 >= len(parts) {
+				return nil, "", fmt.Errorf("--output needs a file path")
+			}
+			file = parts[i+1]
+			i++
+			continue
+		}
+		out = append(out, parts[i])
+	}
+	return out, file, nil
+}
+
+// withOutput runs fn with stdout redirected to path (parent dirs created)
+// and colors off, so the file holds plain text or JSON without escapes.
+func withOutput(path string, fn func()) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	stdout := os.Stdout
+	colors := []string{reset, bold, red, green, yellow, cyan, dim}
+	os.Stdout = f
+	disableColor()
+	defer func() {
+		os.Stdout = stdout
+		reset, bold, red, green, yellow, cyan, dim = colors[0], colors[1], colors[2], colors[3], colors[4], colors[5], colors[6]
+	}()
+	fn()
+	return f.Close()
+}
+
+// printJSONValue writes v as indented JSON, used by --json output.
+func printJSONValue(v interface{}) {
+	out, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(out))
+}
@@ -0,0 +1,138 @@
+// Log, PID and per-instance file locations
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// instanceName is set by --instance. It namespaces the PID, log and web
+// dashboard files (.proxycache-<name>.pid, ...) and selects
+// config-<name>.toml when present, so several proxies can run from one
+// checkout.
+var instanceName string
+
+var instanceNameRE = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func setInstance(name string) error {
+	if !instanceNameRE.MatchString(name) {
+		return fmt.Errorf("invalid instance name '%s' (letters, digits, - and _ only)", name)
+	}
+	instanceName = name
+	return nil
+}
+
+// instanceFile inserts the instance name before the extension:
+// .proxycache.pid becomes .proxycache-dev.pid.
+func instanceFile(name string) string {
+	if instanceName == "" {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + instanceName + ext
+}
+
+// configOverride, when set, replaces config.toml for every config read; config
+// validate points it at the candidate file.
+var configOverride string
+
+// instanceConfigPath is the instance's own config file, or "" when there
+// is no instance or it has no config-<name>.toml.
+func instanceConfigPath() string {
+	if instanceName == "" {
+		return ""
+	}
+	p := filepath.Join(projectRoot(), instanceFile("config.toml"))
+	if _, err := fsys.Stat(p); err != nil {
+		return ""
+	}
+	return p
+}
+
+// proxyArgs are the command-line arguments run passes to the proxy.
+func proxyArgs() []string {
+	if p := instanceConfigPath(); p != "" {
+		return []string{"--config", p}
+	}
+	return nil
+}
+
+func webConfigPath() string {
+	return filepath.Join(projectRoot(), instanceFile(".proxycache-web.toml"))
+}
+
+// logFileOverride and pidFileOverride come from --log/--pid-file, or
+// log_file/pid_file in .proxycache-cli.toml. Empty means the default file
+// in the project root. They're stored absolute: a flag is relative to the
+// directory the CLI was started in, like any other command-line path, and
+// a config value to the project root the file lives in.
+var logFileOverride, pidFileOverride string
+
+// loadPathsFromCLIConfig fills in overrides not given as flags.
+func loadPathsFromCLIConfig() {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return
+	}
+	if p, ok := cfg["log_file"].(string); ok && p != "" && logFileOverride == "" {
+		logFileOverride = resolveRootPath(p)
+	}
+	if p, ok := cfg["pid_file"].(string); ok && p != "" && pidFileOverride == "" {
+		pidFileOverride = resolveRootPath(p)
+	}
+}
+
+func resolveRootPath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(projectRoot(), p)
+}
+
+// resolveArgPath makes a path given on the command line absolute against
+// the working directory.
+func resolveArgPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// logPath is where run sends the proxy's stdout.
+func logPath() string {
+	if logFileOverride != "" {
+		return logFileOverride
+	}
+	return filepath.Join(projectRoot(), instanceFile(".proxycache.log"))
+}
+
+// errLogPath is where run sends stderr: next to the log, with .err in
+// place of its extension.
+func errLogPath() string {
+	if logFileOverride == "" {
+		return filepath.Join(projectRoot(), instanceFile(".proxycache.err"))
+	}
+	l := logPath()
+	p := strings.TrimSuffix(l, filepath.Ext(l)) + ".err"
+	if p == l {
+		p += ".err"
+	}
+	return p
+}
+
+func pidPath() string {
+	if pidFileOverride != "" {
+		return pidFileOverride
+	}
+	return filepath.Join(projectRoot(), instanceFile(".proxycache.pid"))
+}
+
+// displayPath shortens paths inside the project root for messages.
+func displayPath(p string) string {
+	if rel, err := filepath.Rel(projectRoot(), p); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return p
+}
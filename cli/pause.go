@@ -0,0 +1,81 @@
+// Runtime module pause/resume (not persisted to config.toml)
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func doPauseModule(name string, pause bool) {
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		return
+	}
+	if _, ok := getModules(cfg)[name].(map[string]interface{}); !ok {
+		fmt.Printf("  %s✗ Module '%s' not found%s\n", red, name, reset)
+		fmt.Printf("  %sTip: use 'ls' to see available modules%s\n", dim, reset)
+		return
+	}
+
+	if protectedModules[name] {
+		fmt.Printf("  %s✗ %s forwards every request and can't be paused%s\n", red, name, reset)
+		exitCode = 1
+		return
+	}
+
+	if !endpointAvailable("/modules/paused") {
+		fmt.Printf("  %s✗ This proxy version doesn't support runtime pause%s\n", red, reset)
+		fmt.Printf("  %sUse 'toggle %s' + 'reload' instead%s\n", dim, name, reset)
+		return
+	}
+	action := "resume"
+	if pause {
+		action = "pause"
+	}
+	_, code, err := adminJSON("POST", fmt.Sprintf("/modules/%s/%s", name, action))
+	if code == 404 {
+		fmt.Printf("  %s✗ %s isn't loaded in the running proxy%s\n", red, name, reset)
+		fmt.Printf("  %sEnable it and 'reload' first%s\n", dim, reset)
+		return
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		return
+	}
+	if pause {
+		fmt.Printf("  %s⏸ %s paused%s %s(runtime only, config unchanged)%s\n", yellow, name, reset, dim, reset)
+	} else {
+		fmt.Printf("  %s✓ %s resumed%s\n", green, name, reset)
+	}
+}
+
+// fetchPaused returns the modules the running proxy reports as paused.
+// Any failure (proxy down, unsupported endpoint) yields an empty set.
+func fetchPaused() map[string]bool {
+	paused := map[string]bool{}
+	if !endpointAvailable("/modules/paused") {
+		return paused
+	}
+	data, _, err := adminJSON("GET", "/modules/paused")
+	if err != nil {
+		return paused
+	}
+	if list, ok := data["paused"].([]interface{}); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				paused[s] = true
+			}
+		}
+	}
+	return paused
+}
+
+func sortedBoolKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+// ping: health check against /ping or a custom health endpoint
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pingCheck is what ping requests and what counts as healthy. With no
+// expectations any HTTP response is a pong, as /ping always answers.
+type pingCheck struct {
+	Method       string
+	Path         string
+	ExpectStatus int    // 0 = any status
+	ExpectBody   string // substring; "" = don't check
+}
+
+// defaultPing is the check from .proxycache-cli.toml (ping_path,
+// ping_method, ping_status, ping_body), falling back to GET /ping.
+var defaultPing = pingCheck{Method: "GET", Path: "/ping"}
+
+func loadPingFromCLIConfig() {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return
+	}
+	if p, ok := cfg["ping_path"].(string); ok && p != "" {
+		defaultPing.Path = "/" + strings.TrimPrefix(p, "/")
+	}
+	if m, ok := cfg["ping_method"].(string); ok && m != "" {
+		defaultPing.Method = strings.ToUpper(m)
+	}
+	if n, ok := cfg["ping_status"].(int64); ok {
+		defaultPing.ExpectStatus = int(n)
+	}
+	if b, ok := cfg["ping_body"].(string); ok {
+		defaultPing.ExpectBody = b
+	}
+}
+
+// parsePingArgs applies --path, --method, --expect-status and
+// --expect-body on top of the default check.
+func parsePingArgs(args []string) (pingCheck, error) {
+	pc := defaultPing
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return pc, fmt.Errorf("missing value for %s", args[i])
+		}
+		v := args[i+1]
+		switch args[i] {
+		case "--path":
+			pc.Path = "/" + strings.TrimPrefix(v, "/")
+		case "--method":
+			pc.Method = strings.ToUpper(v)
+		case "--expect-status":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 100 || n > 599 {
+				return pc, fmt.Errorf("invalid --expect-status: %s", v)
+			}
+			pc.ExpectStatus = n
+		case "--expect-body":
+			pc.ExpectBody = v
+		default:
+			return pc, fmt.Errorf("unknown option: %s", args[i])
+		}
+		i++
+	}
+	return pc, nil
+}
+
+// checkPing runs pc against the admin API and returns the round-trip time,
+// or why the endpoint doesn't count as healthy.
+func checkPing(pc pingCheck) (time.Duration, error) {
+	start := time.Now()
+	resp, err := adminRequest(pc.Method, pc.Path)
+	elapsed := time.Since(start)
+	if err != nil {
+		return elapsed, fmt.Errorf("%s", connErr(err))
+	}
+	defer resp.Body.Close()
+	if pc.ExpectStatus != 0 && resp.StatusCode != pc.ExpectStatus {
+		return elapsed, fmt.Errorf("%s %s returned %d, expected %d", pc.Method, pc.Path, resp.StatusCode, pc.ExpectStatus)
+	}
+	if pc.ExpectBody != "" {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if !strings.Contains(string(body), pc.ExpectBody) {
+			return elapsed, fmt.Errorf("%s %s response doesn't contain %q", pc.Method, pc.Path, pc.ExpectBody)
+		}
+	} else {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	}
+	return elapsed, nil
+}
+
+func doPing(args []string) {
+	pc, err := parsePingArgs(args)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		fmt.Printf("  %sUsage: ping [--path /healthz] [--method HEAD] [--expect-status 200] [--expect-body ok]%s\n", dim, reset)
+		exitCode = 2
+		return
+	}
+	elapsed, err := checkPing(pc)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	label := "pong"
+	if pc.Path != "/ping" || pc.Method != http.MethodGet {
+		label = fmt.Sprintf("%s %s ok", pc.Method, pc.Path)
+	}
+	fmt.Printf("  %s✓ %s%s %s(%s)%s\n", green, label, reset, dim, formatDuration(elapsed), reset)
+}
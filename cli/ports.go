@@ -0,0 +1,124 @@
+// Port conflict check across every socket the proxy and dashboard bind
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// portBinding is one socket a config key asks for.
+type portBinding struct {
+	Key   string // dotted config key, e.g. "modules.admin_api.listen_addr"
+	Host  string
+	Port  int
+	Proto string // "tcp" or "udp"
+}
+
+// configBindings lists the sockets cfg binds at startup: the proxy listener,
+// the HTTP/3 UDP port when http3 is on, the admin API when enabled, and the
+// web dashboard from .proxycache-web.toml when it's enabled. Keys that don't
+// parse are left to the address checks.
+func configBindings(cfg map[string]interface{}) []portBinding {
+	var out []portBinding
+	add := func(key, addr, proto string) {
+		host, port, err := splitAddr(addr)
+		if err != nil {
+			return
+		}
+		if p, err := strconv.Atoi(port); err == nil && p > 0 {
+			out = append(out, portBinding{Key: key, Host: host, Port: p, Proto: proto})
+		}
+	}
+	srv, _ := cfg["server"].(map[string]interface{})
+	listen, _ := srv["listen_addr"].(string)
+	add("server.listen_addr", listen, "tcp")
+	if h3, _ := srv["http3"].(bool); h3 {
+		if host, port, err := splitAddr(listen); err == nil {
+			if p, ok := srv["h3_port"].(int64); ok && p > 0 {
+				port = strconv.FormatInt(p, 10)
+			}
+			add("server.h3_port", net.JoinHostPort(host, port), "udp")
+		}
+	}
+	mods := getModules(cfg)
+	if moduleEnabled(mods, "admin_api", true) {
+		admin, _ := mods["admin_api"].(map[string]interface{})
+		a, ok := admin["listen_addr"].(string)
+		if !ok {
+			a = moduleSchema["admin_api"]["listen_addr"].Default.(string)
+		}
+		add("modules.admin_api.listen_addr", a, "tcp")
+	}
+	if isWebEnabled() {
+		port := webPort
+		if data, err := readTextFile(webConfigPath()); err == nil {
+			var wc map[string]interface{}
+			if toml.Unmarshal(data, &wc) == nil {
+				if p, ok := wc["port"].(string); ok && p != "" {
+					port = p
+				}
+			}
+		}
+		add(displayPath(webConfigPath())+" port", net.JoinHostPort("127.0.0.1", port), "tcp")
+	}
+	return out
+}
+
+// bindHost normalizes a bind host for overlap checks: wildcards become ""
+// and localhost becomes 127.0.0.1.
+func bindHost(h string) string {
+	h = strings.ToLower(strings.Trim(h, "[]"))
+	if h == "localhost" {
+		return "127.0.0.1"
+	}
+	if ip := net.ParseIP(h); ip != nil {
+		if ip.IsUnspecified() {
+			return ""
+		}
+		return ip.String()
+	}
+	return h
+}
+
+// bindingsOverlap reports whether two bindings would fight over a socket:
+// same port and protocol, and the same host or a wildcard on either side.
+func bindingsOverlap(a, b portBinding) bool {
+	if a.Port != b.Port || a.Proto != b.Proto {
+		return false
+	}
+	ha, hb := bindHost(a.Host), bindHost(b.Host)
+	return ha == "" || hb == "" || ha == hb
+}
+
+// portConflicts describes each pair of bindings that can't both succeed,
+// with a free port to move the second key to.
+func portConflicts(bindings []portBinding) []string {
+	used := map[int]bool{}
+	for _, b := range bindings {
+		used[b.Port] = true
+	}
+	suggested := map[string]string{}
+	var out []string
+	for i, a := range bindings {
+		for _, b := range bindings[i+1:] {
+			if !bindingsOverlap(a, b) {
+				continue
+			}
+			if suggested[b.Key] == "" {
+				free := b.Port + 1
+				for used[free] && free < 65535 {
+					free++
+				}
+				used[free] = true
+				suggested[b.Key] = net.JoinHostPort(b.Host, strconv.Itoa(free))
+			}
+			out = append(out, fmt.Sprintf("port %d/%s is bound by both %s and %s; move %s to a free port, e.g. %s",
+				a.Port, a.Proto, a.Key, b.Key, b.Key, suggested[b.Key]))
+		}
+	}
+	return out
+}
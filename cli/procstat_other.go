@@ -0,0 +1,101 @@
+//go:build !windows
+
+// Process memory and CPU time on Unix-like systems
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc/<pid>/stat. It's
+// 100 on every mainstream Linux build; reading it properly needs cgo.
+const clockTicks = 100
+
+// procUsage reads pid's resident memory and CPU time from /proc, or from
+// ps where there's no /proc (macOS, the BSDs).
+func procUsage(pid int) (procStats, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		if _, statErr := os.Stat("/proc/self"); statErr == nil {
+			return procStats{}, err
+		}
+		return psUsage(pid)
+	}
+	// The command name is in parentheses and may contain spaces, so fields
+	// are counted from the closing one: utime and stime are fields 14 and 15,
+	// starttime is 22, rss (in pages) is 24.
+	s := string(stat)
+	i := strings.LastIndexByte(s, ')')
+	if i < 0 {
+		return procStats{}, fmt.Errorf("unexpected /proc/%d/stat format", pid)
+	}
+	f := strings.Fields(s[i+1:])
+	if len(f) < 22 {
+		return procStats{}, fmt.Errorf("unexpected /proc/%d/stat format", pid)
+	}
+	num := func(idx int) int64 {
+		n, _ := strconv.ParseInt(f[idx-3], 10, 64)
+		return n
+	}
+	st := procStats{
+		CPU: time.Duration(num(14)+num(15)) * time.Second / clockTicks,
+		RSS: uint64(num(24)) * uint64(os.Getpagesize()),
+	}
+	if up, err := os.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(up)); len(fields) > 0 {
+			if secs, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				boot := time.Now().Add(-time.Duration(secs * float64(time.Second)))
+				st.Started = boot.Add(time.Duration(num(22)) * time.Second / clockTicks)
+			}
+		}
+	}
+	return st, nil
+}
+
+// psUsage asks ps for rss (KiB), cumulative CPU time and elapsed time.
+func psUsage(pid int) (procStats, error) {
+	out, err := exec.Command("ps", "-o", "rss=,time=,etime=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return procStats{}, err
+	}
+	f := strings.Fields(string(out))
+	if len(f) < 3 {
+		return procStats{}, fmt.Errorf("no such process: %d", pid)
+	}
+	kb, err := strconv.ParseUint(f[0], 10, 64)
+	if err != nil {
+		return procStats{}, fmt.Errorf("unexpected ps output: %q", out)
+	}
+	st := procStats{RSS: kb * 1024}
+	st.CPU, _ = parsePSTime(f[1])
+	if elapsed, err := parsePSTime(f[2]); err == nil {
+		st.Started = time.Now().Add(-elapsed)
+	}
+	return st, nil
+}
+
+// parsePSTime reads ps's [[dd-]hh:]mm:ss[.cc] durations.
+func parsePSTime(s string) (time.Duration, error) {
+	var days int64
+	if d, rest, ok := strings.Cut(s, "-"); ok {
+		n, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		days, s = n, rest
+	}
+	var total float64
+	for _, part := range strings.Split(s, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ps time %q", s)
+		}
+		total = total*60 + v
+	}
+	return time.Duration(days)*24*time.Hour + time.Duration(total*float64(time.Second)), nil
+}
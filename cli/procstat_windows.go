@@ -0,0 +1,58 @@
+//go:build windows
+
+// Process memory and CPU time on Windows
+package main
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS from psapi.h.
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// K32GetProcessMemoryInfo is kernel32's export of psapi's
+// GetProcessMemoryInfo, present since Windows 7.
+var procGetProcessMemoryInfo = windows.NewLazySystemDLL("kernel32.dll").NewProc("K32GetProcessMemoryInfo")
+
+// procUsage reads pid's working set and CPU time with
+// GetProcessMemoryInfo and GetProcessTimes.
+func procUsage(pid int) (procStats, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return procStats{}, err
+	}
+	defer windows.CloseHandle(h)
+
+	var created, exited, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &created, &exited, &kernel, &user); err != nil {
+		return procStats{}, err
+	}
+	// Filetime counts 100ns intervals
+	ticks := func(f windows.Filetime) int64 { return int64(f.HighDateTime)<<32 | int64(f.LowDateTime) }
+	st := procStats{
+		CPU:     time.Duration(ticks(kernel)+ticks(user)) * 100,
+		Started: time.Unix(0, created.Nanoseconds()),
+	}
+
+	var mem processMemoryCounters
+	mem.cb = uint32(unsafe.Sizeof(mem))
+	if r, _, err := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&mem)), uintptr(mem.cb)); r == 0 {
+		return procStats{}, err
+	}
+	st.RSS = uint64(mem.WorkingSetSize)
+	return st, nil
+}
@@ -0,0 +1,118 @@
+// CLI profiles: named admin targets in .proxycache-cli.toml
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+type profile struct {
+	Name       string
+	Addr       string
+	Key        string
+	Prefix     string
+	AuthScheme string
+}
+
+func cliConfigPath() string {
+	return filepath.Join(projectRoot(), ".proxycache-cli.toml")
+}
+
+// loadCLIConfig reads .proxycache-cli.toml. A missing file is not an error.
+func loadCLIConfig() (map[string]interface{}, error) {
+	data, err := readTextFile(cliConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, err
+	}
+	var cfg map[string]interface{}
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadProfiles returns the [profiles.<name>] entries sorted by name.
+func loadProfiles() ([]profile, error) {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return nil, err
+	}
+	section, _ := cfg["profiles"].(map[string]interface{})
+	var out []profile
+	for _, name := range sortedKeys(section) {
+		p, ok := section[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		a, _ := p["addr"].(string)
+		k, _ := p["key"].(string)
+		pre, _ := p["api_prefix"].(string)
+		if a == "" {
+			continue
+		}
+		scheme := ""
+		if s, ok := p["auth_scheme"].(string); ok {
+			if scheme, err = parseAuthScheme(s); err != nil {
+				return nil, fmt.Errorf("profile '%s': %w", name, err)
+			}
+		}
+		out = append(out, profile{Name: name, Addr: a, Key: k, Prefix: normalizePrefix(pre), AuthScheme: scheme})
+	}
+	return out, nil
+}
+
+func findProfile(name string) (profile, error) {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return profile{}, err
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return profile{}, fmt.Errorf("profile '%s' not found in .proxycache-cli.toml", name)
+}
+
+// loadAPIPrefixFromCLIConfig reads the top-level api_prefix default.
+func loadAPIPrefixFromCLIConfig() {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return
+	}
+	if pre, ok := cfg["api_prefix"].(string); ok {
+		apiPrefix = normalizePrefix(pre)
+	}
+}
+
+// loadAuthSchemeFromCLIConfig reads the top-level auth_scheme default.
+func loadAuthSchemeFromCLIConfig() {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return
+	}
+	if s, ok := cfg["auth_scheme"].(string); ok {
+		scheme, err := parseAuthScheme(s)
+		if err != nil {
+			fmt.Printf("  %s⚠ .proxycache-cli.toml: %s%s\n", yellow, err, reset)
+			return
+		}
+		authScheme = scheme
+	}
+}
+
+// normalizePrefix turns "admin/", "/admin" or "/admin/" into "/admin".
+func normalizePrefix(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
@@ -0,0 +1,235 @@
+// metrics push: forward /metrics samples to a StatsD or OTLP collector
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metricKinds maps the admin /metrics fields to collector types. Counters
+// only ever grow; everything else is reported as a gauge.
+var metricKinds = map[string]string{
+	"requests_total":          "counter",
+	"requests_ok":             "counter",
+	"requests_err":            "counter",
+	"bytes_in":                "counter",
+	"bytes_out":               "counter",
+	"connections_total":       "counter",
+	"pool_hits":               "counter",
+	"pool_misses":             "counter",
+	"circuit_breaker_trips":   "counter",
+	"circuit_breaker_rejects": "counter",
+	"rate_limiter_rejects":    "counter",
+	"cache_hits":              "counter",
+	"cache_misses":            "counter",
+	"active_connections":      "gauge",
+	"latency_avg_ms":          "gauge",
+	"latency_max_ms":          "gauge",
+	"uptime_seconds":          "gauge",
+	"pool_waiting":            "gauge",
+	"pool_wait_avg_ms":        "gauge",
+}
+
+const defaultPushInterval = 10 * time.Second
+
+type pushOpts struct {
+	statsd   string
+	otlp     string
+	prefix   string
+	interval time.Duration
+}
+
+func parsePushArgs(args []string) (pushOpts, error) {
+	o := pushOpts{prefix: "proxycache", interval: defaultPushInterval}
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return o, fmt.Errorf("missing value for %s", args[i])
+		}
+		switch args[i] {
+		case "--statsd":
+			o.statsd = args[i+1]
+		case "--otlp":
+			o.otlp = args[i+1]
+		case "--prefix":
+			o.prefix = args[i+1]
+		case "--interval":
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil || d <= 0 {
+				return o, fmt.Errorf("invalid interval: %s", args[i+1])
+			}
+			o.interval = d
+		default:
+			return o, fmt.Errorf("unknown option: %s", args[i])
+		}
+		i++
+	}
+	if (o.statsd == "") == (o.otlp == "") {
+		return o, fmt.Errorf("specify exactly one of --statsd host:port or --otlp URL")
+	}
+	if o.otlp != "" && !strings.Contains(o.otlp, "://") {
+		o.otlp = "http://" + o.otlp
+	}
+	if o.otlp != "" && !strings.Contains(strings.SplitN(o.otlp, "://", 2)[1], "/") {
+		o.otlp += "/v1/metrics"
+	}
+	return o, nil
+}
+
+// doMetricsPush samples /metrics every interval and forwards the values to
+// a StatsD or OTLP/HTTP collector until interrupted.
+func doMetricsPush(args []string) {
+	o, err := parsePushArgs(args)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		fmt.Printf("  %sUsage: metrics push --statsd host:port | --otlp URL [--interval 10s] [--prefix name]%s\n", dim, reset)
+		exitCode = 2
+		return
+	}
+	var send func(cur, prev map[string]float64) error
+	target := o.statsd
+	if o.statsd != "" {
+		conn, err := net.Dial("udp", o.statsd)
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+			exitCode = 1
+			return
+		}
+		defer conn.Close()
+		send = func(cur, prev map[string]float64) error {
+			_, err := conn.Write(statsdPayload(o.prefix, cur, prev))
+			return err
+		}
+	} else {
+		target = o.otlp
+		start := time.Now()
+		send = func(cur, _ map[string]float64) error {
+			return pushOTLP(o.otlp, otlpPayload(o.prefix, cur, start))
+		}
+	}
+
+	fmt.Printf("  %s✓ Pushing metrics to %s every %s%s %s(Ctrl+C to stop)%s\n", green, target, o.interval, reset, dim, reset)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	defer signal.Stop(stop)
+
+	var prev map[string]float64
+	bo := newPollBackoff(o.interval)
+	for {
+		cur, err := sampleMetrics()
+		wait := bo.next(err == nil)
+		if err != nil {
+			fmt.Printf("  %s✗ %s%s %s(retrying in %s)%s\n", red, err, reset, dim, formatDuration(wait), reset)
+		} else {
+			if err := send(cur, prev); err != nil {
+				fmt.Printf("  %s✗ push failed: %s%s\n", red, err, reset)
+			}
+			prev = cur
+		}
+		select {
+		case <-stop:
+			fmt.Println()
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// sampleMetrics returns the numeric fields of /metrics that have a known kind.
+func sampleMetrics() (map[string]float64, error) {
+	data, _, err := adminJSON("GET", "/metrics")
+	if err != nil {
+		return nil, fmt.Errorf("%s", connErr(err))
+	}
+	out := map[string]float64{}
+	for k := range metricKinds {
+		if v, ok := data[k].(float64); ok {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// statsdPayload renders one sample as StatsD lines. Counters are sent as
+// deltas since the previous sample; a restart (counter going backwards)
+// sends the new absolute value.
+func statsdPayload(prefix string, cur, prev map[string]float64) []byte {
+	var b bytes.Buffer
+	for _, k := range sortedFloatKeys(cur) {
+		v := cur[k]
+		if metricKinds[k] == "counter" {
+			if prev == nil {
+				continue
+			}
+			if p, ok := prev[k]; ok && v >= p {
+				v -= p
+			}
+			fmt.Fprintf(&b, "%s.%s:%g|c\n", prefix, k, v)
+		} else {
+			fmt.Fprintf(&b, "%s.%s:%g|g\n", prefix, k, v)
+		}
+	}
+	return b.Bytes()
+}
+
+// otlpPayload builds an OTLP/HTTP JSON metrics export. Counters are
+// cumulative monotonic sums starting at the push start time.
+func otlpPayload(prefix string, cur map[string]float64, start time.Time) []byte {
+	now := fmt.Sprintf("%d", time.Now().UnixNano())
+	startNano := fmt.Sprintf("%d", start.UnixNano())
+	var metrics []map[string]interface{}
+	for _, k := range sortedFloatKeys(cur) {
+		point := map[string]interface{}{"asDouble": cur[k], "timeUnixNano": now}
+		m := map[string]interface{}{"name": prefix + "." + k}
+		if metricKinds[k] == "counter" {
+			point["startTimeUnixNano"] = startNano
+			m["sum"] = map[string]interface{}{
+				"dataPoints":             []interface{}{point},
+				"aggregationTemporality": 2,
+				"isMonotonic":            true,
+			}
+		} else {
+			m["gauge"] = map[string]interface{}{"dataPoints": []interface{}{point}}
+		}
+		metrics = append(metrics, m)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": []interface{}{
+				map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": prefix}},
+			}},
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope":   map[string]interface{}{"name": "proxycache-cli"},
+				"metrics": metrics,
+			}},
+		}},
+	})
+	return body
+}
+
+func pushOTLP(url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	drainClose(resp)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func sortedFloatKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+// Masking of sensitive config values in CLI and dashboard output
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const secretMask = "****"
+
+// showSecrets is set by --show-secrets on config/edit.
+var showSecrets = false
+
+// isSecretKey matches keys holding credentials or key material: the admin
+// api_key, tls_key, and anything named like a password, secret or token.
+func isSecretKey(k string) bool {
+	k = strings.ToLower(k)
+	if strings.HasSuffix(k, "_key") || k == "key" {
+		return true
+	}
+	for _, s := range []string{"password", "passphrase", "secret", "token"} {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValue(k string, v interface{}, reveal bool) interface{} {
+	if reveal || !isSecretKey(k) {
+		return v
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return v
+	}
+	return secretMask
+}
+
+// redacted returns a copy of m with secret values masked.
+func redacted(m map[string]interface{}, reveal bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = redactValue(k, v, reveal)
+	}
+	return out
+}
+
+// secretPairRE matches key=value, key: value and "key":"value" in free text
+// such as log lines.
+var secretPairRE = regexp.MustCompile(`([A-Za-z][\w.-]*)("?\s*[:=]\s*)("[^"]*"|(?i:bearer|basic)\s+\S+|[^\s",;&]+)`)
+
+// redactLine masks what redacted would in a line of text: values of
+// secret-named keys (and Authorization headers), plus any of the known
+// secret values wherever they appear.
+func redactLine(line string, secrets []string) string {
+	line = secretPairRE.ReplaceAllStringFunc(line, func(m string) string {
+		g := secretPairRE.FindStringSubmatch(m)
+		k := strings.ReplaceAll(g[1], "-", "_")
+		if !isSecretKey(k) && !strings.EqualFold(k, "authorization") {
+			return m
+		}
+		if strings.HasPrefix(g[3], `"`) {
+			return g[1] + g[2] + `"` + secretMask + `"`
+		}
+		return g[1] + g[2] + secretMask
+	})
+	for _, s := range secrets {
+		line = strings.ReplaceAll(line, s, secretMask)
+	}
+	return line
+}
+
+// secretValues returns the values redacted would mask in cfg, plus the
+// admin key from api_key_file. Values under four characters are left out
+// so masking them can't mangle unrelated text.
+func secretValues(cfg map[string]interface{}) []string {
+	tables := []interface{}{cfg["server"]}
+	for _, m := range getModules(cfg) {
+		tables = append(tables, m)
+	}
+	var out []string
+	for _, t := range tables {
+		m, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range m {
+			if s, ok := v.(string); ok && isSecretKey(k) && len(s) >= 4 {
+				out = append(out, s)
+			}
+		}
+	}
+	if m, ok := getModules(cfg)["admin_api"].(map[string]interface{}); ok {
+		if key, err := resolveAPIKey(m); err == nil && len(key) >= 4 {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// stripFlag removes every occurrence of flag from args.
+func stripFlag(args []string, flag string) []string {
+	out := args[:0:0]
+	for _, a := range args {
+		if a != flag {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+// reload --if-changed: skip reloads that wouldn't change anything
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reloadReasons lists what a reload would pick up from the proxy running
+// as pid: a source newer than the binary, a binary or script module newer
+// than the process, or config.toml or an include differing from what it
+// loaded. configOnly limits it to the config as a config-only reload
+// applies it, since that doesn't compile or reload scripts. Anything that
+// can't be checked counts as a reason, so an unknown state still reloads.
+func reloadReasons(pid int, configOnly bool) []string {
+	var why []string
+	if !configOnly {
+		root := projectRoot()
+		bin, err := fsys.Stat(filepath.Join(root, binaryPath()))
+		if err != nil {
+			return []string{"no proxy binary"}
+		}
+		st, err := procUsage(pid)
+		started := err == nil && !st.Started.IsZero()
+		if stale, newer := staleBinary(root); stale {
+			why = append(why, fmt.Sprintf("%s is newer than the binary", newer))
+		} else if started && bin.ModTime().After(st.Started) {
+			why = append(why, "binary rebuilt since the proxy started")
+		}
+		if started {
+			if changed := scriptChangedSince(root, st.Started); changed != "" {
+				why = append(why, fmt.Sprintf("%s changed since the proxy started", changed))
+			}
+		}
+	}
+	var inSync bool
+	var err error
+	if configOnly {
+		inSync, err = configApplied()
+	} else {
+		inSync, _, _, err = configInSync()
+	}
+	switch {
+	case errors.Is(err, errUnavailable):
+		why = append(why, "proxy can't report its loaded config")
+	case err != nil:
+		why = append(why, fmt.Sprintf("can't compare config: %s", connErr(err)))
+	case !inSync:
+		why = append(why, fmt.Sprintf("%s or its includes changed since it was loaded", displayPath(configPath())))
+	}
+	return why
+}
+
+// scriptChangedSince returns a script module in mods/ modified after t, or
+// mods/ itself when one was added or removed since. The proxy loads
+// scripts when it starts, so either needs a full reload.
+func scriptChangedSince(root string, t time.Time) string {
+	dir := filepath.Join(root, "mods")
+	info, err := fsys.Stat(dir)
+	if err != nil {
+		return ""
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pcmod") {
+			continue
+		}
+		if fi, err := e.Info(); err == nil && fi.ModTime().After(t) {
+			return filepath.Join("mods", e.Name())
+		}
+	}
+	if info.ModTime().After(t) {
+		return "mods" + string(filepath.Separator)
+	}
+	return ""
+}
+
+// reloadIfChanged reports whether reload should go ahead, printing why or
+// that there's nothing to do.
+func reloadIfChanged(configOnly bool) bool {
+	why := []string{"proxy not running"}
+	if pid, err := readPID(pidPath()); err == nil && isProcessRunning(pid) {
+		why = reloadReasons(pid, configOnly)
+	}
+	if len(why) == 0 {
+		what := "binary and config match"
+		if configOnly {
+			what = "config matches"
+		}
+		fmt.Printf("  %s✓ Nothing to do: %s the running proxy%s\n", green, what, reset)
+		return false
+	}
+	for _, w := range why {
+		fmt.Printf("  %s● Reloading: %s%s\n", dim, w, reset)
+	}
+	return true
+}
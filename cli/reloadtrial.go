@@ -0,0 +1,118 @@
+// reload: start the new build on spare ports before the old proxy stops
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// trialStart runs bin against a copy of the config that listens on spare
+// loopback ports, with the admin API forced on, and waits up to timeout for
+// its /ping. The copy sits next to config.toml so includes and relative
+// paths resolve the same. A nil error means the build starts and serves.
+func trialStart(bin string, timeout time.Duration) error {
+	cfgPath := configPath()
+	cfg, err := parseConfigFile(cfgPath)
+	if err != nil {
+		return fmt.Errorf("can't read %s: %w", displayPath(cfgPath), err)
+	}
+	ports, err := freePorts(3)
+	if err != nil {
+		return fmt.Errorf("no free port for the trial: %w", err)
+	}
+	srv, _ := cfg["server"].(map[string]interface{})
+	if srv == nil {
+		srv = map[string]interface{}{}
+		cfg["server"] = srv
+	}
+	srv["listen_addr"] = "127.0.0.1:" + strconv.Itoa(ports[0])
+	srv["h3_port"] = int64(ports[1])
+	mods := getModules(cfg)
+	if mods == nil {
+		mods = map[string]interface{}{}
+		cfg["modules"] = mods
+	}
+	adm, _ := mods["admin_api"].(map[string]interface{})
+	if adm == nil {
+		adm = map[string]interface{}{}
+		mods["admin_api"] = adm
+	}
+	adminAddr := "127.0.0.1:" + strconv.Itoa(ports[2])
+	adm["enabled"] = true
+	adm["listen_addr"] = adminAddr
+	delete(adm, "allow_ips")
+
+	data, err := toml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	trial := filepath.Join(filepath.Dir(cfgPath), instanceFile(".proxycache-trial.toml"))
+	if err := fsys.WriteFile(trial, data, 0600); err != nil {
+		return fmt.Errorf("can't write %s: %w", displayPath(trial), err)
+	}
+	defer fsys.Remove(trial)
+
+	var out bytes.Buffer
+	cmd := exec.Command(bin, "--config", trial)
+	cmd.Dir = projectRoot()
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	tail := func() string {
+		if lines := lastLines(out.String(), 15); len(lines) > 0 {
+			return "\n    " + strings.Join(lines, "\n    ")
+		}
+		return ""
+	}
+
+	probe := httpAdmin{Addr: adminAddr, HTTP: client}
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Millisecond
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("new build exited at startup (%v)%s", err, tail())
+		case <-time.After(delay):
+		}
+		if resp, err := probe.Get("/ping"); err == nil {
+			drainClose(resp)
+			cmd.Process.Kill()
+			<-exited
+			return nil
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			<-exited
+			return fmt.Errorf("new build didn't answer /ping within %s%s", timeout, tail())
+		}
+		if delay < time.Second {
+			delay *= 2
+		}
+	}
+}
+
+// freePorts reserves n distinct loopback ports and releases them for the
+// caller to bind.
+func freePorts(n int) ([]int, error) {
+	var ports []int
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer ln.Close()
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
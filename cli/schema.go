@@ -0,0 +1,155 @@
+// Built-in config schema, mirroring the Rust-side defaults
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type keySpec struct {
+	Type    string // JSON Schema type: string, integer, boolean, array
+	Default interface{}
+	Desc    string
+}
+
+var serverSchema = map[string]keySpec{
+	"listen_addr":      {"string", "127.0.0.1:3000", "Address the proxy listens on (ip:port)"},
+	"backend_addr":     {"string", "127.0.0.1:8080", "Backend to forward requests to (ip:port)"},
+	"buffer_size":      {"integer", int64(8192), "I/O buffer size in bytes"},
+	"client_timeout":   {"integer", int64(30), "Client read timeout in seconds"},
+	"backend_timeout":  {"integer", int64(30), "Backend response timeout in seconds"},
+	"max_header_size":  {"integer", int64(65536), "Maximum request header size in bytes"},
+	"max_body_size":    {"integer", int64(16777216), "Maximum request body size in bytes"},
+	"max_connections":  {"integer", int64(10000), "Maximum concurrent client connections"},
+	"worker_threads":   {"integer", int64(0), "Worker threads (0 = number of CPUs)"},
+	"shutdown_timeout": {"integer", int64(15), "Graceful shutdown timeout in seconds"},
+	"log_level":        {"string", "info", "Log level (error, warn, info, debug)"},
+	"log_format":       {"string", "text", "Log line format (text, json)"},
+	"logging":          {"boolean", true, "Enable request logging"},
+	"tls_cert":         {"string", "", "Path to the TLS certificate (PEM)"},
+	"tls_key":          {"string", "", "Path to the TLS private key (PEM)"},
+	"tls_ocsp":         {"string", "", "Path to a DER OCSP response to staple (read at startup)"},
+	"http2":            {"boolean", true, "Enable HTTP/2 via ALPN (requires TLS)"},
+	"http3":            {"boolean", false, "Enable HTTP/3 over QUIC (requires TLS)"},
+	"h3_port":          {"integer", int64(0), "UDP port for HTTP/3 (0 = same as listen port)"},
+}
+
+var moduleSchema = map[string]map[string]keySpec{
+	"active_health": {
+		"enabled":  {"boolean", false, "Enable the module"},
+		"interval": {"integer", int64(10), "Seconds between backend health probes"},
+		"timeout":  {"integer", int64(3), "Probe timeout in seconds"},
+	},
+	"admin_api": {
+		"enabled":      {"boolean", true, "Enable the module"},
+		"listen_addr":  {"string", "127.0.0.1:9090", "Address the admin API listens on (ip:port)"},
+		"api_key":      {"string", "", "Key required in the X-API-Key or Authorization: Bearer header (empty = unprotected)"},
+		"api_key_file": {"string", "", "File holding the API key, overrides api_key (keeps the secret out of config.toml)"},
+		"allow_ips":    {"array", []interface{}{}, "Client IPs or CIDR networks allowed to connect (empty = any)"},
+	},
+	"cache": {
+		"enabled":     {"boolean", false, "Enable the module"},
+		"ttl_seconds": {"integer", int64(300), "Cache entry lifetime in seconds"},
+		"max_size":    {"integer", int64(100), "Maximum number of cached responses"},
+		"warm_urls":   {"array", []interface{}{}, "URLs to prefetch at startup"},
+	},
+	"circuit_breaker": {
+		"enabled":           {"boolean", false, "Enable the module"},
+		"failure_threshold": {"integer", int64(5), "Consecutive failures before the circuit opens"},
+		"recovery_timeout":  {"integer", int64(30), "Seconds before a half-open retry"},
+	},
+	"compression": {
+		"enabled":  {"boolean", false, "Enable the module"},
+		"min_size": {"integer", int64(256), "Minimum response size in bytes to compress"},
+	},
+	"headers": {
+		"enabled":         {"boolean", false, "Enable the module"},
+		"request_set":     {"array", []interface{}{}, "Headers set on requests to the backend (\"Name: value\")"},
+		"request_remove":  {"array", []interface{}{}, "Header names removed from requests to the backend"},
+		"response_set":    {"array", []interface{}{}, "Headers set on responses to clients (\"Name: value\")"},
+		"response_remove": {"array", []interface{}{}, "Header names removed from responses to clients"},
+	},
+	"health_check": {
+		"enabled":  {"boolean", true, "Enable the module"},
+		"endpoint": {"string", "/health", "Path answered directly by the proxy"},
+	},
+	"load_balancer": {
+		"enabled":  {"boolean", false, "Enable the module"},
+		"backends": {"array", []interface{}{}, "Backend addresses for round-robin (ip:port)"},
+	},
+	"metrics_exporter": {
+		"enabled":  {"boolean", false, "Enable the module"},
+		"endpoint": {"string", "/metrics", "Path serving Prometheus metrics"},
+	},
+	"proxy_core": {
+		"enabled": {"boolean", true, "Enable the module"},
+	},
+	"rate_limiter": {
+		"enabled":             {"boolean", false, "Enable the module"},
+		"requests_per_second": {"integer", int64(10), "Sustained requests per second per client"},
+		"burst":               {"integer", int64(20), "Burst allowance above the sustained rate"},
+	},
+	"raw_tcp": {
+		"enabled": {"boolean", false, "Enable the module"},
+	},
+	"request_id": {
+		"enabled": {"boolean", false, "Enable the module"},
+	},
+	"url_rewriter": {
+		"enabled": {"boolean", false, "Enable the module"},
+	},
+}
+
+// sectionSchema returns the known keys for "server" or a module name.
+func sectionSchema(name string) map[string]keySpec {
+	if name == "server" {
+		return serverSchema
+	}
+	return moduleSchema[name]
+}
+
+func jsonSchemaProps(section string, keys map[string]keySpec) map[string]interface{} {
+	props := make(map[string]interface{}, len(keys))
+	for k, spec := range keys {
+		props[k] = map[string]interface{}{
+			"type":        spec.Type,
+			"default":     spec.Default,
+			"description": spec.Desc,
+			"x-restart":   needsRestart(section, k),
+		}
+	}
+	return props
+}
+
+func buildJSONSchema() map[string]interface{} {
+	mods := make(map[string]interface{}, len(moduleSchema))
+	for name, keys := range moduleSchema {
+		mods[name] = map[string]interface{}{
+			"type":       "object",
+			"properties": jsonSchemaProps(name, keys),
+		}
+	}
+	return map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "Proxycache config",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"server": map[string]interface{}{
+				"type":                 "object",
+				"properties":           jsonSchemaProps("server", serverSchema),
+				"additionalProperties": false,
+			},
+			"modules": map[string]interface{}{
+				"type":       "object",
+				"properties": mods,
+				// Script modules (.pcmod) declare their own settings
+				"additionalProperties": map[string]interface{}{"type": "object"},
+			},
+		},
+	}
+}
+
+func doConfigSchema() {
+	out, _ := json.MarshalIndent(buildJSONSchema(), "", "  ")
+	fmt.Println(string(out))
+}
@@ -0,0 +1,233 @@
+// setup: interactive first-run wizard that writes config.toml
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultConfig is a complete config built from the schema defaults: the
+// same values the proxy generates on its first start.
+func defaultConfig() map[string]interface{} {
+	srv := map[string]interface{}{}
+	for k, spec := range serverSchema {
+		srv[k] = spec.Default
+	}
+	mods := map[string]interface{}{}
+	for name, keys := range moduleSchema {
+		m := map[string]interface{}{}
+		for k, spec := range keys {
+			m[k] = spec.Default
+		}
+		mods[name] = m
+	}
+	return map[string]interface{}{"server": srv, "modules": mods}
+}
+
+// generateAPIKey returns a random 32-character hex key for admin_api.
+func generateAPIKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// configMissing reports whether there is no config file yet, the case
+// where the REPL offers setup.
+func configMissing() bool {
+	_, err := fsys.Stat(configPath())
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// stdinIsTerminal is false when input is piped, so prompts aren't offered
+// to scripts.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// wizard reads answers line by line; an empty answer takes the default.
+type wizard struct {
+	in  *bufio.Reader
+	eof bool
+}
+
+func (w *wizard) ask(label, def string) string {
+	if def != "" {
+		fmt.Printf("  %s%s%s %s[%s]%s: ", cyan, label, reset, dim, def, reset)
+	} else {
+		fmt.Printf("  %s%s%s: ", cyan, label, reset)
+	}
+	line, err := w.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		w.eof = true
+		fmt.Println()
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def
+	}
+	return line
+}
+
+func (w *wizard) yes(label string, def bool) bool {
+	d := "y/N"
+	if def {
+		d = "Y/n"
+	}
+	switch strings.ToLower(w.ask(label, d)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// askAddr repeats the question until the answer is an address the proxy
+// accepts for section.key, and returns it normalized.
+func (w *wizard) askAddr(label, section, key, def string) string {
+	for {
+		a := w.ask(label, def)
+		if addr, err := normalizeAddr(section, key, a); err == nil {
+			return addr
+		} else if w.eof {
+			return a
+		} else {
+			fmt.Printf("    %s✗ %s%s\n", red, err, reset)
+		}
+	}
+}
+
+// runSetup walks through the settings a first config needs and returns
+// the finished config and the generated API key, if any.
+func runSetup(in io.Reader) (map[string]interface{}, string, error) {
+	w := &wizard{in: bufio.NewReader(in)}
+	cfg := defaultConfig()
+	srv := cfg["server"].(map[string]interface{})
+	mods := cfg["modules"].(map[string]interface{})
+	for name, defaults := range discoverModules(projectRoot()) {
+		if _, ok := mods[name]; !ok {
+			defaults["enabled"] = false
+			mods[name] = defaults
+		}
+	}
+
+	fmt.Printf("\n  %s%sServer%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	srv["listen_addr"] = w.askAddr("Listen address", "server", "listen_addr", srv["listen_addr"].(string))
+	srv["backend_addr"] = w.askAddr("Backend address", "server", "backend_addr", srv["backend_addr"].(string))
+
+	if w.yes("Serve HTTPS (needs a cert and key)?", false) {
+		cert := w.ask("TLS cert path (PEM)", "cert.pem")
+		key := w.ask("TLS key path (PEM)", "key.pem")
+		for _, p := range []string{cert, key} {
+			if _, err := fsys.Stat(resolveRootPath(p)); err != nil {
+				fmt.Printf("    %s⚠ %s doesn't exist yet; the proxy won't start TLS until it does%s\n", yellow, p, reset)
+			}
+		}
+		srv["tls_cert"], srv["tls_key"] = cert, key
+	}
+
+	fmt.Printf("\n  %s%sAdmin API%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	admin := mods["admin_api"].(map[string]interface{})
+	apiKey := ""
+	admin["enabled"] = w.yes("Enable the admin API (used by this CLI)?", true)
+	if admin["enabled"] == true {
+		admin["listen_addr"] = w.askAddr("Admin listen address", "admin_api", "listen_addr", admin["listen_addr"].(string))
+		if w.yes("Generate an API key?", true) {
+			k, err := generateAPIKey()
+			if err != nil {
+				return nil, "", fmt.Errorf("can't generate API key: %w", err)
+			}
+			admin["api_key"], apiKey = k, k
+		}
+	}
+
+	fmt.Printf("\n  %s%sModules%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	var names, on []string
+	for name, m := range mods {
+		if name == "admin_api" {
+			continue
+		}
+		names = append(names, name)
+		if m.(map[string]interface{})["enabled"] == true {
+			on = append(on, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(on)
+	fmt.Printf("  %sAvailable: %s%s\n", dim, strings.Join(names, ", "), reset)
+	for {
+		answer := w.ask("Enable (comma-separated, 'none' for none)", strings.Join(on, ","))
+		chosen := map[string]bool{}
+		var unknown []string
+		if answer != "none" {
+			for _, n := range strings.Split(answer, ",") {
+				if n = strings.TrimSpace(n); n == "" {
+					continue
+				} else if _, ok := mods[n]; ok && n != "admin_api" {
+					chosen[n] = true
+				} else {
+					unknown = append(unknown, n)
+				}
+			}
+		}
+		if len(unknown) > 0 && !w.eof {
+			fmt.Printf("    %s✗ Unknown module(s): %s%s\n", red, strings.Join(unknown, ", "), reset)
+			continue
+		}
+		for _, n := range names {
+			mods[n].(map[string]interface{})["enabled"] = chosen[n]
+		}
+		break
+	}
+
+	if issues := configIssues(cfg); len(issues) > 0 {
+		return nil, "", fmt.Errorf("config is invalid: %s", strings.Join(issues, "; "))
+	}
+	return cfg, apiKey, nil
+}
+
+func doSetup() {
+	if !configMissing() {
+		fmt.Printf("  %s! %s already exists%s\n", yellow, displayPath(configPath()), reset)
+		if !confirm("Replace it with a new config? The current file is kept as .bak.") {
+			fmt.Printf("  %sCancelled, nothing was changed%s\n", dim, reset)
+			return
+		}
+	}
+	cfg, key, err := runSetup(os.Stdin)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 1
+		return
+	}
+	path := configPath()
+	if old, err := fsys.ReadFile(path); err == nil {
+		fsys.WriteFile(path+".bak", old, 0644)
+	}
+	if err := saveConfigTOML(cfg); err != nil {
+		fmt.Printf("  %s✗ Can't write %s: %s%s\n", red, displayPath(path), err, reset)
+		exitCode = 1
+		return
+	}
+
+	fmt.Printf("\n  %s✓ Wrote %s%s\n", green, displayPath(path), reset)
+	if key != "" {
+		fmt.Printf("  %sAdmin API key:%s %s\n", cyan, reset, key)
+		fmt.Printf("  %sThe CLI reads it from config.toml; other clients send it as X-API-Key or a bearer token%s\n", dim, reset)
+	}
+	loadAPIKeyFromConfig()
+	loadAddrFromConfig()
+	fmt.Printf("  %sNext: 'compile' then 'run'%s\n", dim, reset)
+}
@@ -0,0 +1,83 @@
+// Post-reload smoke test through the proxy listener
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const smokeTimeout = 15 * time.Second
+
+// smokeSettings reads [smoke] from .proxycache-cli.toml. expect 0 accepts
+// any non-5xx response.
+func smokeSettings() (path string, expect int) {
+	path = "/"
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return
+	}
+	s, _ := cfg["smoke"].(map[string]interface{})
+	if p, ok := s["path"].(string); ok && p != "" {
+		path = p
+	}
+	if e, ok := s["expect_status"].(int64); ok {
+		expect = int(e)
+	}
+	return
+}
+
+// waitForAPI polls /ping until the admin API answers or the timeout passes.
+func waitForAPI(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if resp, err := adminRequest("GET", "/ping"); err == nil {
+			drainClose(resp)
+			return true
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return false
+}
+
+func doSmokeTest() bool {
+	fmt.Printf("  %s● Smoke test...%s\n", yellow, reset)
+	if !waitForAPI(smokeTimeout) {
+		fmt.Printf("  %s✗ Smoke test failed: admin API not up after %s%s\n", red, smokeTimeout, reset)
+		return false
+	}
+	base, err := proxyBaseURL()
+	if err != nil {
+		fmt.Printf("  %s✗ Smoke test failed: %s%s\n", red, err, reset)
+		return false
+	}
+	path, expect := smokeSettings()
+	sc := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	start := time.Now()
+	resp, err := sc.Get(base + path)
+	if err != nil {
+		fmt.Printf("  %s✗ Smoke test failed: GET %s: %s%s\n", red, path, err, reset)
+		return false
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	elapsed := formatDuration(time.Since(start))
+
+	ok := resp.StatusCode < 500
+	if expect != 0 {
+		ok = resp.StatusCode == expect
+	}
+	if !ok {
+		fmt.Printf("  %s✗ Smoke test failed: GET %s → %d%s\n", red, path, resp.StatusCode, reset)
+		return false
+	}
+	fmt.Printf("  %s✓ Smoke test passed%s GET %s → %d %s(%s)%s\n", green, reset, path, resp.StatusCode, dim, elapsed, reset)
+	return true
+}
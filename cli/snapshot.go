@@ -0,0 +1,91 @@
+// snapshot [file]: one JSON bundle of live state, config and logs for
+// bug reports
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const snapshotLogLines = 200
+
+// snapshotEndpoints are captured as-is; failures are recorded, not fatal.
+var snapshotEndpoints = []string{"/status", "/metrics", "/connections", "/protocols", "/tls"}
+
+func doSnapshot(args []string) {
+	now := time.Now()
+	file := fmt.Sprintf("proxycache-snapshot-%s.json", now.Format("20060102-150405"))
+	if len(args) > 0 {
+		file = args[0]
+	}
+
+	snap := map[string]interface{}{
+		"taken_at": now.Format(time.RFC3339),
+		"admin":    admin().Target(),
+	}
+	errs := map[string]string{}
+	for _, ep := range snapshotEndpoints {
+		data, _, err := adminJSON("GET", ep)
+		if err != nil {
+			errs[ep] = connErr(err)
+			continue
+		}
+		// /tls and /status carry file paths only, but mask anything key-like
+		snap[ep[1:]] = redacted(data, false)
+	}
+
+	var secrets []string
+	if cfg, err := loadConfigTOML(); err != nil {
+		errs["config.toml"] = err.Error()
+	} else {
+		conf := map[string]interface{}{}
+		if srv, ok := cfg["server"].(map[string]interface{}); ok {
+			conf["server"] = redacted(srv, false)
+		}
+		mods := map[string]interface{}{}
+		for name, v := range getModules(cfg) {
+			if m, ok := v.(map[string]interface{}); ok {
+				mods[name] = redacted(m, false)
+			}
+		}
+		conf["modules"] = mods
+		secrets = secretValues(cfg)
+		if inc := includePatterns(cfg); len(inc) > 0 {
+			conf["include"] = inc
+		}
+		snap["config"] = conf
+		if w := configIncludeWarnings(); len(w) > 0 {
+			snap["config_warnings"] = w
+		}
+	}
+
+	logs := map[string][]string{}
+	for _, path := range []string{logPath(), errLogPath()} {
+		name := displayPath(path)
+		lines := tailLines(path, snapshotLogLines)
+		for i, l := range lines {
+			lines[i] = redactLine(ansiRE.ReplaceAllString(l, ""), secrets)
+		}
+		if lines != nil {
+			logs[name] = lines
+		}
+	}
+	snap["logs"] = logs
+	if len(errs) > 0 {
+		snap["errors"] = errs
+	}
+
+	if err := withOutput(file, func() { printJSONValue(snap) }); err != nil {
+		fmt.Printf("  %s✗ Can't write %s: %s%s\n", red, file, err, reset)
+		exitCode = 1
+		return
+	}
+	fmt.Printf("  %s✓ Snapshot written to %s%s\n", green, file, reset)
+	if len(errs) > 0 {
+		fmt.Printf("  %s%d section(s) unavailable, see \"errors\" in the file%s\n", yellow, len(errs), reset)
+	}
+	if fi, err := os.Stat(file); err == nil {
+		fmt.Printf("  %s%s, secrets masked%s\n", dim, formatBytes(float64(fi.Size())), reset)
+	}
+}
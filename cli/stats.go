@@ -0,0 +1,67 @@
+// Resetting the proxy's cumulative counters
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func doStats(args []string) {
+	if len(args) == 0 || args[0] != "reset" {
+		fmt.Printf("  %sUsage: stats reset [--yes]%s\n", dim, reset)
+		exitCode = 2
+		return
+	}
+	doStatsReset(hasFlag(args[1:], "--yes") || hasFlag(args[1:], "-y"))
+}
+
+// doStatsReset zeroes requests, bytes, latency, pool and circuit breaker
+// counters so a following bench starts from a clean slate.
+func doStatsReset(yes bool) {
+	if !yes && !confirm("Reset all proxy counters? This discards the current totals.") {
+		fmt.Printf("  %sCancelled, nothing was reset%s\n", dim, reset)
+		return
+	}
+	resp, err := adminOptional("POST", "/metrics/reset")
+	if errors.Is(err, errUnavailable) {
+		fmt.Printf("  %s✗ This proxy doesn't support resetting metrics%s\n", red, reset)
+		fmt.Printf("  %sRestart it with 'reload' to start the counters from zero%s\n", dim, reset)
+		exitCode = 1
+		return
+	}
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, connErr(err), reset)
+		exitCode = 1
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		fmt.Printf("  %s✗ Reset failed: %s%s\n", red, resp.Status, reset)
+		exitCode = 1
+		return
+	}
+	var data map[string]interface{}
+	json.Unmarshal(body, &data)
+	fmt.Printf("  %s✓ Counters reset%s\n", green, reset)
+	if n, ok := data["discarded_requests"].(float64); ok {
+		fmt.Printf("  %s● discarded %d request(s) of history%s\n", dim, int64(n), reset)
+	}
+	fmt.Printf("  %sRequests, bytes, latency, pool and CB counters now start from zero%s\n", dim, reset)
+}
+
+// confirm asks a yes/no question on stdin; anything but y/yes is a no.
+func confirm(question string) bool {
+	fmt.Printf("  %s%s%s [y/N] ", yellow, question, reset)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+// Column-aligned tables that measure text without ANSI color codes
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var ansiRE = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// visibleWidth is the number of characters s takes on screen.
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(ansiRE.ReplaceAllString(s, ""))
+}
+
+// table collects rows and prints them with each column as wide as its
+// widest cell. Cells may carry color codes; they don't count as width.
+type table struct {
+	header []string
+	rows   [][]string
+	right  map[int]bool
+}
+
+// newTable starts a table; with a header, print also draws the separator
+// under it.
+func newTable(header ...string) *table {
+	return &table{header: header, right: map[int]bool{}}
+}
+
+// alignRight right-aligns the given columns, for numbers.
+func (t *table) alignRight(cols ...int) *table {
+	for _, c := range cols {
+		t.right[c] = true
+	}
+	return t
+}
+
+func (t *table) row(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+func (t *table) print() {
+	widths := map[int]int{}
+	measure := func(cells []string) {
+		for i, c := range cells {
+			if w := visibleWidth(c); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	measure(t.header)
+	for _, r := range t.rows {
+		measure(r)
+	}
+
+	if len(t.header) > 0 {
+		fmt.Printf("  %s%s%s\n", dim, t.format(t.header, widths), reset)
+		fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	}
+	for _, r := range t.rows {
+		fmt.Printf("  %s\n", t.format(r, widths))
+	}
+}
+
+func (t *table) format(cells []string, widths map[int]int) string {
+	var b strings.Builder
+	for i, c := range cells {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		pad := ""
+		if n := widths[i] - visibleWidth(c); n > 0 {
+			pad = strings.Repeat(" ", n)
+		}
+		switch {
+		case t.right[i]:
+			b.WriteString(pad + c)
+		case i == len(cells)-1:
+			b.WriteString(c)
+		default:
+			b.WriteString(c + pad)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,117 @@
+// Shared timestamp and duration formatting
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// utcTimes is set by --utc. The proxy writes its log timestamps in UTC, so
+// this makes CLI output line up with .proxycache.log.
+var utcTimes bool
+
+const timeLayout = "2006-01-02 15:04:05 MST"
+
+// formatTime renders an absolute time in local time, or UTC with --utc.
+func formatTime(t time.Time) string {
+	if utcTimes {
+		return t.UTC().Format(timeLayout)
+	}
+	return t.Local().Format(timeLayout)
+}
+
+// formatDuration renders d compactly: "850µs", "12ms", "1.4s", "3m 20s",
+// "2d 4h 0m 15s". Sub-second values keep precision, longer ones drop it.
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + formatDuration(-d)
+	}
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	case d < time.Second:
+		ms := float64(d) / float64(time.Millisecond)
+		if ms >= 100 || ms == float64(int64(ms)) {
+			return fmt.Sprintf("%.0fms", ms)
+		}
+		return fmt.Sprintf("%.1fms", ms)
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	secs := int64(d / time.Second)
+	parts := []string{}
+	for _, u := range []struct {
+		n      int64
+		suffix string
+	}{{86400, "d"}, {3600, "h"}, {60, "m"}, {1, "s"}} {
+		if v := secs / u.n; v > 0 || len(parts) > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", v, u.suffix))
+		}
+		secs %= u.n
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatSeconds and formatMillis format numeric JSON fields from the admin
+// API as durations, or "—" when the field is missing.
+func formatSeconds(v interface{}) string {
+	f, ok := jsonNumber(v)
+	if !ok {
+		return "—"
+	}
+	return formatDuration(time.Duration(f * float64(time.Second)))
+}
+
+func formatMillis(v interface{}) string {
+	f, ok := jsonNumber(v)
+	if !ok {
+		return "—"
+	}
+	return formatDuration(time.Duration(f * float64(time.Millisecond)))
+}
+
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// firstPresent returns the first of keys set in data. /status and /metrics
+// don't agree on field names across proxy versions.
+func firstPresent(data map[string]interface{}, keys ...string) interface{} {
+	for _, k := range keys {
+		if v, ok := data[k]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// parseTimeArg reads a --since/--until value: a duration back from now
+// ("90m", "2h"), or a date or time in local time, UTC with --utc
+// ("2024-05-01", "2024-05-01 14:30", RFC 3339).
+func parseTimeArg(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	loc := time.Local
+	if utcTimes {
+		loc = time.UTC
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s': use a duration (2h) or a date (2024-05-01 14:30)", s)
+}
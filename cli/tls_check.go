@@ -0,0 +1,188 @@
+// Certificate expiry check for cron: tls check --warn-days N
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultWarnDays = 30
+
+type certStatus struct {
+	Cert     string   `json:"cert"`
+	Subject  string   `json:"subject"`
+	Issuer   string   `json:"issuer"`
+	DNSNames []string `json:"dns_names,omitempty"`
+	NotAfter string   `json:"not_after"`
+	DaysLeft int      `json:"days_left"`
+	Status   string   `json:"status"` // ok, expiring, expired
+}
+
+// doTLSCheck inspects the configured certificate and sets a non-zero exit
+// code when it expires within the window (1) or can't be checked (2).
+// --exec and --webhook fire only when the cert needs attention.
+func doTLSCheck(args []string) {
+	warnDays, execCmd, webhook := defaultWarnDays, "", ""
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			fmt.Printf("  %s✗ missing value for %s%s\n", red, args[i], reset)
+			exitCode = 2
+			return
+		}
+		switch args[i] {
+		case "--warn-days":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				fmt.Printf("  %s✗ invalid --warn-days: %s%s\n", red, args[i+1], reset)
+				exitCode = 2
+				return
+			}
+			warnDays = n
+		case "--exec":
+			execCmd = args[i+1]
+		case "--webhook":
+			webhook = args[i+1]
+		default:
+			fmt.Printf("  %s✗ unknown option: %s%s\n", red, args[i], reset)
+			fmt.Printf("  %sUsage: tls check [--warn-days 30] [--exec \"cmd\"] [--webhook URL]%s\n", dim, reset)
+			exitCode = 2
+			return
+		}
+		i++
+	}
+
+	st, err := checkCert(warnDays)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		exitCode = 2
+		return
+	}
+	if jsonOut {
+		out, _ := json.MarshalIndent(st, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		printCertStatus(st, warnDays)
+	}
+	if st.Status == "ok" {
+		return
+	}
+	exitCode = 1
+	if execCmd != "" {
+		if err := runCertHook(execCmd, st); err != nil {
+			fmt.Printf("  %s✗ --exec failed: %s%s\n", red, err, reset)
+		}
+	}
+	if webhook != "" {
+		if err := postCertWebhook(webhook, st); err != nil {
+			fmt.Printf("  %s✗ --webhook failed: %s%s\n", red, err, reset)
+		}
+	}
+}
+
+func checkCert(warnDays int) (certStatus, error) {
+	var st certStatus
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		return st, fmt.Errorf("can't read config: %s", err)
+	}
+	srv, _ := cfg["server"].(map[string]interface{})
+	path, _ := srv["tls_cert"].(string)
+	if path == "" {
+		return st, fmt.Errorf("TLS not configured (server.tls_cert is empty)")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(projectRoot(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, fmt.Errorf("can't read cert: %s", err)
+	}
+	// The leaf is the first CERTIFICATE block; skip keys bundled before it
+	block, rest := pem.Decode(data)
+	for block != nil && block.Type != "CERTIFICATE" {
+		block, rest = pem.Decode(rest)
+	}
+	if block == nil {
+		return st, fmt.Errorf("no PEM certificate in %s", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return st, fmt.Errorf("invalid certificate: %s", err)
+	}
+
+	left := time.Until(cert.NotAfter)
+	st = certStatus{
+		Cert:     path,
+		Subject:  cert.Subject.String(),
+		Issuer:   cert.Issuer.String(),
+		DNSNames: cert.DNSNames,
+		NotAfter: cert.NotAfter.UTC().Format(time.RFC3339),
+		DaysLeft: int(left.Hours() / 24),
+		Status:   "ok",
+	}
+	if left <= 0 {
+		st.Status = "expired"
+	} else if left <= time.Duration(warnDays)*24*time.Hour {
+		st.Status = "expiring"
+	}
+	return st, nil
+}
+
+func printCertStatus(st certStatus, warnDays int) {
+	fmt.Printf("  %s%sTLS Certificate%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	printStatusField("File", st.Cert)
+	printStatusField("Subject", st.Subject)
+	printStatusField("Issuer", st.Issuer)
+	if len(st.DNSNames) > 0 {
+		printStatusField("DNS Names", strings.Join(st.DNSNames, ", "))
+	}
+	if t, err := time.Parse(time.RFC3339, st.NotAfter); err == nil {
+		printStatusField("Expires", formatTime(t))
+	} else {
+		printStatusField("Expires", st.NotAfter)
+	}
+	switch st.Status {
+	case "expired":
+		fmt.Printf("  %s✗ Expired %d days ago%s\n", red, -st.DaysLeft, reset)
+	case "expiring":
+		fmt.Printf("  %s⚠ Expires in %d days (warning window %d days)%s\n", yellow, st.DaysLeft, warnDays, reset)
+	default:
+		fmt.Printf("  %s✓ Valid for %d more days%s\n", green, st.DaysLeft, reset)
+	}
+}
+
+// runCertHook runs a shell command with the cert details in its environment.
+func runCertHook(command string, st certStatus) error {
+	cmd := shellCommand(command)
+	cmd.Env = append(os.Environ(),
+		"PROXYCACHE_CERT="+st.Cert,
+		"PROXYCACHE_CERT_STATUS="+st.Status,
+		"PROXYCACHE_CERT_EXPIRES="+st.NotAfter,
+		"PROXYCACHE_CERT_DAYS_LEFT="+strconv.Itoa(st.DaysLeft),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func postCertWebhook(url string, st certStatus) error {
+	body, _ := json.Marshal(st)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	drainClose(resp)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Live TLS audit: which protocol versions and cipher suites the proxy accepts
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const tlsProbeTimeout = 3 * time.Second
+
+// probedVersions are tried one at a time, oldest first.
+var probedVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// tlsProbeResult is what a handshake-per-option scan found. Suites lists
+// the TLS 1.2-and-below suites accepted; TLS 1.3 suites aren't negotiable
+// per suite from Go, so Suite13 is just the one picked by default.
+type tlsProbeResult struct {
+	Versions []uint16
+	Suites   []uint16
+	Suite13  uint16
+}
+
+// handshake reports whether target completes a handshake with cfg. The
+// certificate isn't verified: this is about what the server offers.
+func handshake(target string, cfg *tls.Config) (tls.ConnectionState, bool) {
+	cfg.InsecureSkipVerify = true
+	d := &net.Dialer{Timeout: tlsProbeTimeout}
+	conn, err := tls.DialWithDialer(d, "tcp", target, cfg)
+	if err != nil {
+		return tls.ConnectionState{}, false
+	}
+	defer conn.Close()
+	return conn.ConnectionState(), true
+}
+
+func probeTLS(target string) (tlsProbeResult, error) {
+	var r tlsProbeResult
+	for _, v := range probedVersions {
+		st, ok := handshake(target, &tls.Config{MinVersion: v, MaxVersion: v})
+		if !ok {
+			continue
+		}
+		r.Versions = append(r.Versions, v)
+		if v == tls.VersionTLS13 {
+			r.Suite13 = st.CipherSuite
+		}
+	}
+	if len(r.Versions) == 0 {
+		return r, fmt.Errorf("no TLS handshake succeeded with %s", target)
+	}
+	if r.Versions[0] > tls.VersionTLS12 {
+		return r, nil
+	}
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if !supportsPre13(cs) {
+			continue
+		}
+		cfg := &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{cs.ID}}
+		if _, ok := handshake(target, cfg); ok {
+			r.Suites = append(r.Suites, cs.ID)
+		}
+	}
+	return r, nil
+}
+
+func supportsPre13(cs *tls.CipherSuite) bool {
+	for _, v := range cs.SupportedVersions {
+		if v <= tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
+
+// suiteWeakness explains why a suite is flagged, or "" if it's fine.
+func suiteWeakness(id uint16) string {
+	for _, cs := range tls.InsecureCipherSuites() {
+		if cs.ID == id {
+			return "insecure"
+		}
+	}
+	if strings.HasPrefix(tls.CipherSuiteName(id), "TLS_RSA_") {
+		return "no forward secrecy"
+	}
+	return ""
+}
+
+// proxyTLSTarget is the proxy's dialable host:port when it serves HTTPS.
+func proxyTLSTarget() (string, bool) {
+	base, err := proxyBaseURL()
+	if err != nil || !strings.HasPrefix(base, "https://") {
+		return "", false
+	}
+	return strings.TrimPrefix(base, "https://"), true
+}
+
+// printTLSProbe runs probeTLS against the proxy and prints the versions and
+// suites it accepts, flagging TLS 1.0/1.1 and weak suites.
+func printTLSProbe() {
+	target, ok := proxyTLSTarget()
+	if !ok {
+		return
+	}
+	fmt.Printf("\n  %s%sNegotiation%s %s(probed %s)%s\n", bold, cyan, reset, dim, target, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	r, err := probeTLS(target)
+	if err != nil {
+		fmt.Printf("  %s✗ %s%s\n", red, err, reset)
+		return
+	}
+
+	var names []string
+	for _, v := range r.Versions {
+		names = append(names, tls.VersionName(v))
+	}
+	printStatusField("Versions", fmt.Sprintf("%s – %s", names[0], names[len(names)-1]))
+	if r.Suite13 != 0 {
+		printStatusField("TLS 1.3 Suite", tls.CipherSuiteName(r.Suite13))
+	}
+	if len(r.Suites) > 0 {
+		printStatusField("TLS 1.2 Suites", len(r.Suites))
+	}
+
+	issues := 0
+	for _, v := range r.Versions {
+		if v < tls.VersionTLS12 {
+			fmt.Printf("  %s⚠ %s is enabled (deprecated by RFC 8996)%s\n", yellow, tls.VersionName(v), reset)
+			issues++
+		}
+	}
+	for _, id := range r.Suites {
+		if why := suiteWeakness(id); why != "" {
+			fmt.Printf("    %s⚠ %s%s %s(%s)%s\n", yellow, tls.CipherSuiteName(id), reset, dim, why, reset)
+			issues++
+		} else {
+			fmt.Printf("    %s✓ %s%s\n", green, tls.CipherSuiteName(id), reset)
+		}
+	}
+	if issues == 0 {
+		fmt.Printf("  %s✓ No deprecated versions or weak suites offered%s\n", green, reset)
+	}
+}
@@ -0,0 +1,232 @@
+// TLS session resumption and OCSP stapling: what's configured and what the
+// proxy actually serves
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// oidMustStaple is the TLS Feature extension (RFC 7633); certificates that
+// carry it must be served with a staple.
+var oidMustStaple = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+var oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+// OCSP response structures from RFC 6960, only as far as status and
+// validity go. The responder's signature isn't checked here; clients do
+// that.
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     asn1.RawValue
+	Good       asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// ocspStaple is what a stapled response says about the certificate.
+type ocspStaple struct {
+	Status     string // good, revoked or unknown
+	ProducedAt time.Time
+	ThisUpdate time.Time
+	NextUpdate time.Time // zero if the responder didn't set one
+}
+
+// expired reports whether the staple is past its nextUpdate.
+func (s ocspStaple) expired(now time.Time) bool {
+	return !s.NextUpdate.IsZero() && now.After(s.NextUpdate)
+}
+
+func parseOCSPStaple(der []byte) (ocspStaple, error) {
+	var st ocspStaple
+	var resp ocspResponse
+	if _, err := asn1.Unmarshal(der, &resp); err != nil {
+		return st, fmt.Errorf("not an OCSP response: %w", err)
+	}
+	if resp.Status != 0 {
+		return st, fmt.Errorf("OCSP responder returned status %d instead of a response", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return st, errors.New("unsupported OCSP response type")
+	}
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return st, fmt.Errorf("malformed OCSP response: %w", err)
+	}
+	if len(basic.TBSResponseData.Responses) == 0 {
+		return st, errors.New("OCSP response holds no certificate status")
+	}
+	single := basic.TBSResponseData.Responses[0]
+	switch {
+	case bool(single.Good):
+		st.Status = "good"
+	case bool(single.Unknown):
+		st.Status = "unknown"
+	default:
+		st.Status = "revoked"
+	}
+	st.ProducedAt = basic.TBSResponseData.ProducedAt
+	st.ThisUpdate, st.NextUpdate = single.ThisUpdate, single.NextUpdate
+	return st, nil
+}
+
+// sessionProbe is what two back-to-back handshakes found: whether the
+// second resumed the first's session and what was stapled.
+type sessionProbe struct {
+	Version    uint16
+	Resumed    bool
+	Staple     []byte
+	MustStaple bool
+}
+
+// probeSession connects twice with one client session cache. TLS 1.3
+// tickets arrive after the handshake, so the first connection reads
+// briefly to pick them up.
+func probeSession(target string) (sessionProbe, error) {
+	var p sessionProbe
+	cfg := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+	d := &net.Dialer{Timeout: tlsProbeTimeout}
+	for i := 0; i < 2; i++ {
+		conn, err := tls.DialWithDialer(d, "tcp", target, cfg)
+		if err != nil {
+			return p, err
+		}
+		st := conn.ConnectionState()
+		if i == 0 {
+			p.Version, p.Staple = st.Version, st.OCSPResponse
+			if len(st.PeerCertificates) > 0 {
+				p.MustStaple = hasMustStaple(st.PeerCertificates[0])
+			}
+			conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+			conn.Read(make([]byte, 1))
+		} else {
+			p.Resumed = st.DidResume
+		}
+		conn.Close()
+	}
+	return p, nil
+}
+
+func hasMustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidMustStaple) {
+			return true
+		}
+	}
+	return false
+}
+
+// printTLSSession shows resumption and stapling from /tls, then checks
+// both against a live connection when the proxy serves HTTPS.
+func printTLSSession(data map[string]interface{}) {
+	fmt.Printf("\n  %s%sSessions & OCSP%s\n", bold, cyan, reset)
+	fmt.Printf("  %s%s%s\n", dim, sep, reset)
+	if size, ok := data["session_cache_size"]; ok {
+		printStatusField("Session Cache", size)
+	}
+	if st, ok := data["stateless_tickets"].(bool); ok {
+		if st {
+			printStatusField("Stateless Tickets", green+"on"+reset)
+		} else {
+			printStatusField("Stateless Tickets", dim+"off (cache-backed resumption only)"+reset)
+		}
+	}
+	ocspPath, _ := data["ocsp_path"].(string)
+	if ocspPath == "" {
+		printStatusField("OCSP File", dim+"not configured"+reset)
+	} else if ok, _ := data["ocsp_exists"].(bool); ok {
+		printStatusField("OCSP File", ocspPath)
+	} else {
+		printStatusField("OCSP File", ocspPath+red+" (missing)"+reset)
+	}
+
+	target, ok := proxyTLSTarget()
+	if !ok {
+		return
+	}
+	p, err := probeSession(target)
+	if err != nil {
+		fmt.Printf("  %s✗ Can't probe %s: %s%s\n", red, target, err, reset)
+		return
+	}
+	if p.Resumed {
+		fmt.Printf("  %s✓ Session resumed on reconnect%s %s(%s)%s\n", green, reset, dim, tls.VersionName(p.Version), reset)
+	} else {
+		fmt.Printf("  %s⚠ Session not resumed on reconnect%s %s(%s)%s\n", yellow, reset, dim, tls.VersionName(p.Version), reset)
+	}
+	printStapleStatus(p, ocspPath != "", time.Now())
+}
+
+// printStapleStatus reports the stapled OCSP response, warning when one is
+// expected (configured, or the certificate is Must-Staple) but missing,
+// unusable, expired or not "good".
+func printStapleStatus(p sessionProbe, configured bool, now time.Time) {
+	if len(p.Staple) == 0 {
+		switch {
+		case p.MustStaple:
+			fmt.Printf("  %s⚠ Certificate is Must-Staple but no OCSP response was stapled: browsers will refuse it%s\n", yellow, reset)
+		case configured:
+			fmt.Printf("  %s⚠ OCSP stapling is configured but no response was stapled%s\n", yellow, reset)
+			fmt.Printf("  %sCheck the server.tls_ocsp file, then 'restart'%s\n", dim, reset)
+		default:
+			printStatusField("OCSP Staple", dim+"none"+reset)
+		}
+		return
+	}
+	st, err := parseOCSPStaple(p.Staple)
+	if err != nil {
+		fmt.Printf("  %s⚠ Stapled OCSP response is unusable: %s%s\n", yellow, err, reset)
+		return
+	}
+	validity := "produced " + formatTime(st.ProducedAt)
+	if !st.NextUpdate.IsZero() {
+		validity = "valid until " + formatTime(st.NextUpdate)
+	}
+	switch {
+	case st.expired(now):
+		fmt.Printf("  %s⚠ Stapled OCSP response expired %s ago%s %s(nextUpdate %s)%s\n", yellow, formatDuration(now.Sub(st.NextUpdate)), reset, dim, formatTime(st.NextUpdate), reset)
+		fmt.Printf("  %sFetch a fresh response into server.tls_ocsp, then 'restart'%s\n", dim, reset)
+	case st.Status != "good":
+		fmt.Printf("  %s⚠ Stapled OCSP status: %s%s %s(%s)%s\n", yellow, st.Status, reset, dim, validity, reset)
+	default:
+		fmt.Printf("  %s✓ OCSP response stapled: good%s %s(%s)%s\n", green, reset, dim, validity, reset)
+	}
+}
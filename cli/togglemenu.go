@@ -0,0 +1,134 @@
+// Interactive checklist for toggling several modules at once
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type menuItem struct {
+	name    string
+	enabled bool
+	orig    bool
+}
+
+// doToggleMenu lists every module with its on/off state. Up/down (or k/j)
+// moves, space flips, Enter saves all flips in one write, Esc/q cancels.
+func doToggleMenu() {
+	restore, ok := enableRawInput()
+	if !ok {
+		fmt.Printf("  %sUsage: toggle <module|web>%s\n", yellow, reset)
+		return
+	}
+	version := configVersion()
+	cfg, err := loadConfigTOML()
+	if err != nil {
+		restore()
+		fmt.Printf("  %s✗ Can't read config: %s%s\n", red, err, reset)
+		return
+	}
+	mods := getModules(cfg)
+	if len(mods) == 0 {
+		restore()
+		fmt.Printf("  %s✗ No modules section in config%s\n", red, reset)
+		return
+	}
+
+	var items []menuItem
+	for name, m := range mods {
+		if protectedModules[name] {
+			continue
+		}
+		if mod, ok := m.(map[string]interface{}); ok {
+			e, _ := mod["enabled"].(bool)
+			items = append(items, menuItem{name: name, enabled: e, orig: e})
+		}
+	}
+	if len(items) == 0 {
+		restore()
+		fmt.Printf("  %sNo modules to toggle (protected modules stay on)%s\n", dim, reset)
+		return
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].name < items[j].name })
+
+	fmt.Printf("  %s↑/↓ move, space toggle, Enter apply, Esc cancel%s\n", dim, reset)
+	fmt.Print("\x1b[?25l")
+	cursor := 0
+	drawToggleMenu(items, cursor, false)
+	apply := false
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			break
+		}
+		key := string(buf[:n])
+		if key == "\x1b[A" || key == "k" {
+			cursor = (cursor + len(items) - 1) % len(items)
+		} else if key == "\x1b[B" || key == "j" {
+			cursor = (cursor + 1) % len(items)
+		} else if key == " " {
+			items[cursor].enabled = !items[cursor].enabled
+		} else if key == "\r" || key == "\n" {
+			apply = true
+			break
+		} else if key == "\x1b" || key == "q" || key == "\x03" {
+			break
+		}
+		drawToggleMenu(items, cursor, true)
+	}
+	fmt.Print("\x1b[?25h")
+	restore()
+
+	if !apply {
+		fmt.Printf("  %sCancelled%s\n", dim, reset)
+		return
+	}
+	var changed []menuItem
+	for _, it := range items {
+		if it.enabled != it.orig {
+			mods[it.name].(map[string]interface{})["enabled"] = it.enabled
+			changed = append(changed, it)
+		}
+	}
+	if len(changed) == 0 {
+		fmt.Printf("  %sNo changes%s\n", dim, reset)
+		return
+	}
+	cfg["modules"] = mods
+	if err := saveConfigTOMLAt(cfg, version); err != nil {
+		fmt.Printf("  %s✗ Not saved: %s%s\n", red, err, reset)
+		return
+	}
+	for _, it := range changed {
+		if it.enabled {
+			fmt.Printf("  %s✓ %s enabled%s\n", green, it.name, reset)
+		} else {
+			fmt.Printf("  %s✗ %s disabled%s\n", yellow, it.name, reset)
+		}
+	}
+	fmt.Printf("  %sRun 'reload' to restart with the new config%s\n", dim, reset)
+}
+
+// drawToggleMenu prints the checklist, first moving back over the previous
+// rendering when redraw is set.
+func drawToggleMenu(items []menuItem, cursor int, redraw bool) {
+	if redraw {
+		fmt.Printf("\x1b[%dA", len(items))
+	}
+	for i, it := range items {
+		pointer, box, color := " ", "[ ]", dim
+		if it.enabled {
+			box, color = "[x]", green
+		}
+		if i == cursor {
+			pointer = cyan + "❯" + reset
+		}
+		changed := ""
+		if it.enabled != it.orig {
+			changed = yellow + " *" + reset
+		}
+		fmt.Printf("\r\x1b[2K  %s %s%s%s %s%s\n", pointer, color, box, reset, it.name, changed)
+	}
+}
@@ -0,0 +1,96 @@
+// Admin client transport: --proxy, proxy environment and NO_PROXY
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// proxyURL is set by --proxy and overrides HTTP_PROXY/HTTPS_PROXY for
+// admin calls. NO_PROXY and loopback addresses are still bypassed.
+var proxyURL = ""
+
+// newAdminTransport builds the transport behind the shared admin client.
+func newAdminTransport() (*http.Transport, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = http.ProxyFromEnvironment
+	// watch loops poll the same admin host every tick; keep those
+	// connections warm instead of re-dialing each time
+	tr.MaxIdleConnsPerHost = 4
+	tr.IdleConnTimeout = 90 * time.Second
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid --proxy URL: %s", proxyURL)
+		}
+		tr.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Hostname()) {
+				return nil, nil
+			}
+			return u, nil
+		}
+	}
+	return tr, nil
+}
+
+// bypassProxy reports whether host should be dialed directly: loopback
+// addresses always, plus anything matched by NO_PROXY.
+func bypassProxy(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	for _, p := range strings.Split(noProxy, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if p == "*" {
+			return true
+		}
+		if h, _, err := net.SplitHostPort(p); err == nil {
+			p = h
+		}
+		h := strings.ToLower(host)
+		p = strings.TrimPrefix(p, "*")
+		if h == strings.TrimPrefix(p, ".") || (strings.HasPrefix(p, ".") && strings.HasSuffix(h, p)) ||
+			strings.HasSuffix(h, "."+p) {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(p); err == nil {
+			if ip := net.ParseIP(host); ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setupClient installs the configured transport on the shared client.
+func setupClient() error {
+	tr, err := newAdminTransport()
+	if err != nil {
+		return err
+	}
+	client = &http.Client{Timeout: 5 * time.Second, Transport: tr}
+	return nil
+}
+
+// drainClose reads what is left of a response body before closing it so
+// the underlying connection goes back to the idle pool.
+func drainClose(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
@@ -0,0 +1,65 @@
+// --verbose: trace admin API requests and responses to stderr
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// verbose logs every admin API call to stderr (--verbose / -v).
+var verbose = false
+
+// verboseTransport wraps a RoundTripper and traces each request/response
+// pair so connection and auth problems can be diagnosed without a debugger.
+type verboseTransport struct {
+	next http.RoundTripper
+}
+
+func (t verboseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fmt.Fprintf(os.Stderr, "%s> %s %s%s\n", dim, req.Method, req.URL, reset)
+	for _, k := range sortedHeaderKeys(req.Header) {
+		v := req.Header.Get(k)
+		if isSecretHeader(k) {
+			v = secretMask
+		}
+		fmt.Fprintf(os.Stderr, "%s>   %s: %s%s\n", dim, k, v, reset)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := formatDuration(time.Since(start))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s< error after %s: %v%s\n", dim, elapsed, err, reset)
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "%s< %s (%s)%s\n", dim, resp.Status, elapsed, reset)
+	return resp, nil
+}
+
+func isSecretHeader(k string) bool {
+	switch http.CanonicalHeaderKey(k) {
+	case "X-Api-Key", "Authorization", "Cookie":
+		return true
+	}
+	return false
+}
+
+func sortedHeaderKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// enableVerbose installs the tracing transport on the shared admin client.
+func enableVerbose() {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	client.Transport = verboseTransport{next: next}
+}
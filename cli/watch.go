@@ -0,0 +1,218 @@
+// watch logs: live metrics header above a tail of the proxy log
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultWatchInterval = 2 * time.Second
+	watchLogPoll         = 500 * time.Millisecond
+	watchTailBytes       = 64 << 10
+)
+
+// Cursor control used by watch instead of a TUI library: an alternate
+// screen so the REPL's scrollback survives, a hidden cursor, and redraws
+// from the top-left with each line cleared to its end.
+const (
+	escAltScreen = "\033[?1049h\033[?25l"
+	escMainScr   = "\033[?25h\033[?1049l"
+	escHome      = "\033[H"
+	escClearEOL  = "\033[K"
+	escClearDown = "\033[J"
+)
+
+// readLogTail is tailLines for a log that may be large: only the last
+// watchTailBytes are read, and the line cut by the seek is dropped.
+func readLogTail(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	off := info.Size() - watchTailBytes
+	if off < 0 {
+		off = 0
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	if off > 0 {
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+	return lastLines(text, n), nil
+}
+
+// clip cuts s to width columns so long log lines don't wrap and push the
+// header off screen. Color codes take no columns and are never cut; a
+// clipped line ends with a reset so its color doesn't leak.
+func clip(s string, width int) string {
+	if visibleWidth(s) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	var b strings.Builder
+	cols := 0
+	codes := ansiRE.FindAllStringIndex(s, -1)
+	for i := 0; i < len(s) && cols < width-1; {
+		if len(codes) > 0 && codes[0][0] == i {
+			b.WriteString(s[i:codes[0][1]])
+			i = codes[0][1]
+			codes = codes[1:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(r)
+		i += size
+		cols++
+	}
+	return b.String() + "…" + reset
+}
+
+// watchHeader renders the metrics panel from /metrics. rate is requests
+// per second since the previous sample, negative when there isn't one yet.
+func watchHeader(m map[string]interface{}, rate float64, metricsErr error) []string {
+	title := fmt.Sprintf("%s%sproxycache watch%s  %s%s  %s  (Ctrl+C to stop)%s", bold, cyan, reset, dim, addr, formatTime(time.Now()), reset)
+	if metricsErr != nil {
+		return []string{title, fmt.Sprintf("%s✗ %s%s", red, metricsErr, reset), ""}
+	}
+	rateStr := "—"
+	if rate >= 0 {
+		rateStr = fmt.Sprintf("%.1f/s", rate)
+	}
+	errColor := ""
+	if n, _ := jsonNumber(m["requests_err"]); n > 0 {
+		errColor = yellow
+	}
+	return []string{
+		title,
+		fmt.Sprintf("%sRequests%s %v  %sOK%s %v  %sErrors%s %s%v%s  %sRate%s %s",
+			cyan, reset, m["requests_total"], cyan, reset, m["requests_ok"],
+			cyan, reset, errColor, m["requests_err"], reset, cyan, reset, rateStr),
+		fmt.Sprintf("%sLatency%s avg %s max %s  %sConns%s %v active  %sIn%s %s  %sOut%s %s  %sUptime%s %s",
+			cyan, reset, formatMillis(firstPresent(m, "avg_latency_ms", "latency_avg_ms")), formatMillis(m["latency_max_ms"]),
+			cyan, reset, m["active_connections"], cyan, reset, formatBytes(m["bytes_in"]),
+			cyan, reset, formatBytes(m["bytes_out"]), cyan, reset, formatSeconds(firstPresent(m, "uptime_secs", "uptime_seconds"))),
+	}
+}
+
+// drawWatch paints one frame: the header, a separator naming the log,
+// then as many log lines as fit.
+func drawWatch(header []string, logLines []string, logErr error, width, height int) {
+	var b strings.Builder
+	b.WriteString(escHome)
+	for _, l := range header {
+		b.WriteString(l + escClearEOL + "\n")
+	}
+	b.WriteString(fmt.Sprintf("%s%s %s%s%s\n", dim, sep, displayPath(logPath()), escClearEOL, reset))
+	// keep the last row free so the final newline doesn't scroll the frame
+	room := max(height-len(header)-2, 0)
+	if logErr != nil {
+		b.WriteString(fmt.Sprintf("%s✗ Can't read logs: %s%s%s\n", red, logErr, reset, escClearEOL))
+	} else {
+		if len(logLines) > room {
+			logLines = logLines[len(logLines)-room:]
+		}
+		for _, l := range logLines {
+			b.WriteString(clip(l, width) + escClearEOL + "\n")
+		}
+	}
+	b.WriteString(escClearDown)
+	fmt.Print(b.String())
+}
+
+func doWatch(args []string) {
+	interval := defaultWatchInterval
+	if len(args) > 0 && args[0] == "logs" {
+		args = args[1:]
+	}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--interval" && i+1 < len(args) {
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil || d <= 0 {
+				fmt.Printf("  %s✗ invalid interval: %s%s\n", red, args[i+1], reset)
+				exitCode = 2
+				return
+			}
+			interval = d
+			i++
+			continue
+		}
+		fmt.Printf("  %sUsage: watch logs [--interval 2s]%s\n", yellow, reset)
+		exitCode = 2
+		return
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	defer signal.Stop(stop)
+	fmt.Print(escAltScreen)
+	defer fmt.Print(escMainScr)
+
+	var (
+		prevTotal float64
+		prevAt    time.Time
+		header    []string
+	)
+	bo := newPollBackoff(interval)
+	sample := func() time.Duration {
+		m, _, err := adminJSON("GET", "/metrics")
+		if err != nil {
+			err = fmt.Errorf("%s", connErr(err))
+		}
+		rate := -1.0
+		if err == nil {
+			now := time.Now()
+			total, _ := jsonNumber(m["requests_total"])
+			if !prevAt.IsZero() && total >= prevTotal {
+				rate = (total - prevTotal) / now.Sub(prevAt).Seconds()
+			}
+			prevTotal, prevAt = total, now
+		}
+		wait := bo.next(err == nil)
+		if err != nil {
+			err = fmt.Errorf("%v (retrying in %s)", err, formatDuration(wait))
+		}
+		header = watchHeader(m, rate, err)
+		return wait
+	}
+
+	metricsTimer := time.NewTimer(sample())
+	defer metricsTimer.Stop()
+	logTick := time.NewTicker(watchLogPoll)
+	defer logTick.Stop()
+	for {
+		width, height, ok := consoleSize()
+		if !ok {
+			width, height = 80, 24
+		}
+		lines, err := readLogTail(logPath(), height)
+		drawWatch(header, lines, err, width, height)
+		select {
+		case <-stop:
+			return
+		case <-metricsTimer.C:
+			metricsTimer.Reset(sample())
+		case <-logTick.C:
+		}
+	}
+}
@@ -0,0 +1,383 @@
+var modules=[], proxyStatus={}, metricsData={}, protocolsData={}, tlsData={}, serverData={}, configVersion='', reveal=false;
+var api=function(p,o){return fetch(p,o).then(function(r){return r.json()}).catch(function(){return {}})};
+// Config writes carry the version the dashboard last loaded; 409 means someone else changed config.toml
+function configWrite(p,o){
+  o=o||{};o.headers=Object.assign({'If-Match':configVersion},o.headers||{});
+  return fetch(p,o).then(function(r){
+    if(r.status===409){alert('config.toml was changed elsewhere. Reloading the latest version.');refreshConfig();refreshModules();return {conflict:true}}
+    return r.json();
+  }).catch(function(){return {}});
+}
+
+function switchTab(n){
+  document.querySelectorAll('.tab').forEach(function(t){t.classList.remove('active')});
+  document.querySelectorAll('.sidebar nav button').forEach(function(b){b.classList.remove('active')});
+  document.getElementById('tab-'+n).classList.add('active');
+  document.querySelector('[data-tab="'+n+'"]').classList.add('active');
+  if(n==='metrics')drawCharts();
+}
+
+function esc(s){return String(s).replace(/[&<>"]/g,function(c){return {'&':'&amp;','<':'&lt;','>':'&gt;','"':'&quot;'}[c]})}
+function card(l,v,c){return '<div class="card"><div class="label">'+l+'</div><div class="val '+(c||'')+'">'+v+'</div></div>'}
+function fmtB(b){if(!b||b===0)return '0 B';b=Number(b);if(b<1024)return b+' B';if(b<1048576)return (b/1024).toFixed(1)+' KB';if(b<1073741824)return (b/1048576).toFixed(1)+' MB';return (b/1073741824).toFixed(2)+' GB'}
+// Same rendering as the CLI's formatDuration: 12ms, 1.4s, 3m 20s, 2d 4h 0m 15s
+function fmtDur(ms){
+  if(ms===undefined||ms===null||isNaN(ms))return '—';ms=Number(ms);
+  if(ms<1)return Math.round(ms*1000)+'µs';
+  if(ms<1000)return (ms>=100||ms===Math.floor(ms)?Math.round(ms):ms.toFixed(1))+'ms';
+  if(ms<60000)return (ms/1000).toFixed(1)+'s';
+  var s=Math.floor(ms/1000),out=[];
+  [[86400,'d'],[3600,'h'],[60,'m'],[1,'s']].forEach(function(u){var v=Math.floor(s/u[0]);if(v>0||out.length)out.push(v+u[1]);s%=u[0]});
+  return out.join(' ');
+}
+function fmtS(s){return s===undefined||s===null?'—':fmtDur(Number(s)*1000)}
+function val(d,k){var v=d[k];return v!==undefined&&v!==null?v:'—'}
+
+// ── Overview ──
+function refreshOverview(){
+  return api('/api/proxy/status').then(function(d){
+    proxyStatus=d;
+    var up=d.process_running||d.api_responding;
+    document.getElementById('overview-cards').innerHTML=
+      card('Status',up?'Running':'Stopped',up?'g':'r')+
+      card('API',d.api_responding?'Online':'Offline',d.api_responding?'g':'r')+
+      card('Uptime',d.uptime_seconds!==undefined?fmtS(d.uptime_seconds):(d.uptime||'—'),'b')+
+      card('PID',d.pid||'—','b')+
+      card('Listen',d.listen||'—','')+
+      card('Backend',d.backend||'—','');
+    document.getElementById('sidebar-status').innerHTML=up
+      ?'<span class="dot on"></span>Running (pid '+val(d,'pid')+')'
+      :'<span class="dot off"></span>Stopped';
+    document.getElementById('overview-metrics').innerHTML=
+      card('Requests',val(d,'requests_total'),'b')+
+      card('OK',val(d,'requests_ok'),'g')+
+      card('Errors',val(d,'requests_err'),'r')+
+      card('Bytes In',fmtB(d.bytes_in),'')+
+      card('Bytes Out',fmtB(d.bytes_out),'')+
+      card('Avg Latency',fmtDur(d.avg_latency_ms),'y');
+  });
+}
+function refreshProtoOverview(){
+  return api('/api/proxy/protocols').then(function(d){
+    protocolsData=d;
+    var html='';
+    html+=protoRow('HTTP/1.1',true,'Always enabled','TCP');
+    var h2=d.http2||{};var h3=d.http3||{};
+    html+=protoRow('HTTP/2',h2.enabled,'ALPN negotiation','TLS');
+    html+=protoRow('HTTP/3',h3.enabled,'QUIC transport, port '+(h3.port||'—'),'UDP');
+    document.getElementById('overview-protocols').innerHTML=html;
+  });
+}
+function protoRow(name,en,detail,transport){
+  var dot=en?'on':'off';
+  return '<div class="proto-row"><div class="proto-dot '+dot+'"></div><div class="proto-name">'+name+'</div><div class="proto-detail">'+detail+'</div><div style="margin-left:auto;font-size:11px;color:var(--fg2)">'+transport+'</div></div>';
+}
+function proxyAction(a){
+  api('/api/proxy/'+a,{method:'POST'}).then(function(r){
+    if(a==='ping'&&r.alive!==undefined)alert(r.alive?'Pong! '+fmtDur(r.latency_ms):'Not responding');
+    setTimeout(refreshAll,800);
+  });
+}
+function refreshLogs(){
+  return api('/api/proxy/logs').then(function(r){
+    var b=document.getElementById('log-box');
+    b.textContent=(r.logs||'').replace(/\x1b\[[0-9;]*m/g,'')||'No logs yet';
+    b.scrollTop=b.scrollHeight;
+  });
+}
+
+// ── Metrics ──
+function refreshMetrics(){
+  return api('/api/proxy/metrics').then(function(d){
+    metricsData=d;
+    recordSample(d);
+    document.getElementById('m-requests').innerHTML=
+      card('Total',val(d,'requests_total'),'b')+card('OK',val(d,'requests_ok'),'g')+card('Errors',val(d,'requests_err'),'r');
+    document.getElementById('m-bandwidth').innerHTML=
+      card('Bytes In',fmtB(d.bytes_in),'b')+card('Bytes Out',fmtB(d.bytes_out),'b');
+    document.getElementById('m-latency').innerHTML=
+      card('Avg',d.requests_total>0?fmtDur(d.latency_sum_ms/d.requests_total):'—','y')+
+      card('Max',fmtDur(d.latency_max_ms),'r')+
+      card('Sum',fmtDur(d.latency_sum_ms),'');
+    document.getElementById('m-connections').innerHTML=
+      card('Active',val(d,'active_connections'),'b')+card('Total Served',val(d,'connections_total'),'');
+    document.getElementById('m-pool').innerHTML=
+      card('Pool Hits',val(d,'pool_hits'),'g')+card('Pool Misses',val(d,'pool_misses'),'r')+
+      card('Hit Rate',d.pool_hits+d.pool_misses>0?Math.round(d.pool_hits/(d.pool_hits+d.pool_misses)*100)+'%':'—','b')+
+      card('Waiting',val(d,'pool_waiting'),'y')+card('Avg Wait',fmtDur(d.pool_wait_avg_ms),'y');
+    document.getElementById('m-cb').innerHTML=
+      card('Trips',val(d,'cb_trips'),'y')+card('Rejects',val(d,'cb_rejects'),'r');
+    document.getElementById('m-system').innerHTML=
+      card('Uptime',fmtS(d.uptime_secs!==undefined?d.uptime_secs:d.uptime_seconds),'');
+  });
+}
+
+// ── Metric trends ──
+// Ring buffer of polled /metrics samples; rates come from deltas between
+// consecutive samples so a proxy restart (counters reset) just skips a point.
+var samples=[], maxSamples=120;
+function num(v){v=Number(v);return isNaN(v)?0:v}
+function recordSample(d){
+  if(d.requests_total===undefined)return;
+  var s={t:Date.now(),req:num(d.requests_total),bytes:num(d.bytes_in)+num(d.bytes_out),
+    lsum:num(d.latency_sum_ms),lavg:num(d.avg_latency_ms!==undefined?d.avg_latency_ms:d.latency_avg_ms)};
+  var p=samples[samples.length-1];
+  if(p){
+    var dt=(s.t-p.t)/1000,dr=s.req-p.req;
+    if(dt>0&&dr>=0&&s.bytes>=p.bytes){
+      s.rps=dr/dt;s.bps=(s.bytes-p.bytes)/dt;
+      s.lat=dr>0&&s.lsum>=p.lsum?(s.lsum-p.lsum)/dr:(dr===0?0:s.lavg);
+    }
+  }
+  samples.push(s);
+  if(samples.length>maxSamples)samples.shift();
+  drawCharts();
+}
+function drawCharts(){
+  var css=getComputedStyle(document.documentElement);
+  drawChart('c-rps','rps',css.getPropertyValue('--accent'),function(v){return v.toFixed(v<10?1:0)});
+  drawChart('c-lat','lat',css.getPropertyValue('--yellow'),fmtDur);
+  drawChart('c-bytes','bps',css.getPropertyValue('--green'),function(v){return fmtB(Math.round(v))});
+}
+function drawChart(id,key,color,fmt){
+  var c=document.getElementById(id);if(!c)return;
+  var pts=samples.filter(function(s){return s[key]!==undefined});
+  document.getElementById(id+'-now').textContent=pts.length?fmt(pts[pts.length-1][key]):'—';
+  var dpr=window.devicePixelRatio||1,w=c.clientWidth,h=c.clientHeight;
+  if(!w||!h)return;
+  c.width=w*dpr;c.height=h*dpr;
+  var g=c.getContext('2d');g.setTransform(dpr,0,0,dpr,0,0);g.clearRect(0,0,w,h);
+  g.font='10px system-ui,sans-serif';g.fillStyle=getComputedStyle(document.documentElement).getPropertyValue('--fg2');
+  if(pts.length<2){g.fillText('Collecting samples…',4,h/2);return}
+  var max=0;pts.forEach(function(p){if(p[key]>max)max=p[key]});
+  if(max===0)max=1;
+  var t0=samples[0].t,span=Math.max(samples[samples.length-1].t-t0,1),top=12,bottom=h-2;
+  g.strokeStyle='rgba(0,0,0,.08)';g.beginPath();g.moveTo(0,top+.5);g.lineTo(w,top+.5);g.moveTo(0,bottom+.5);g.lineTo(w,bottom+.5);g.stroke();
+  g.fillText(fmt(max),4,top-2);
+  g.strokeStyle=color;g.lineWidth=1.5;g.beginPath();
+  pts.forEach(function(p,i){
+    var x=(p.t-t0)/span*(w-2)+1,y=bottom-(p[key]/max)*(bottom-top);
+    if(i===0)g.moveTo(x,y);else g.lineTo(x,y);
+  });
+  g.stroke();
+}
+window.addEventListener('resize',drawCharts);
+
+// ── Config ──
+function refreshConfig(){
+  return api('/api/proxy/server'+(reveal?'?reveal=1':'')).then(function(d){
+    serverData=d;
+    var tb=document.querySelector('#server-table tbody');
+    var html='<tr><th>Setting</th><th>Value</th></tr>';
+    var keys=Object.keys(d).filter(function(k){return k!=='offline'}).sort();
+    for(var i=0;i<keys.length;i++){
+      var k=keys[i],v=d[k];
+      var vc=typeof v==='boolean'?(v?'<span class="badge on">true</span>':'<span class="badge off">false</span>'):String(v);
+      html+='<tr><td class="k">'+k+'</td><td>'+vc+'</td></tr>';
+    }
+    tb.innerHTML=html;
+  });
+}
+function refreshModules(){
+  return fetch('/api/config'+(reveal?'?reveal=1':'')).then(function(r){
+    configVersion=r.headers.get('ETag')||'';
+    return r.json();
+  }).catch(function(){return []}).then(function(data){
+    modules=Array.isArray(data)?data:[];
+    renderConfigError(Array.isArray(data)?null:data);
+    var grid=document.getElementById('mod-grid');
+    var html='';
+    for(var i=0;i<modules.length;i++){
+      var m=modules[i];
+      var settings=m.settings||{};
+      var keys=Object.keys(settings).sort();
+      var shtml='';
+      for(var j=0;j<keys.length;j++){
+        shtml+=keys[j]+' = '+settings[keys[j]]+'<br>';
+      }
+      if(!shtml)shtml='<em style="color:var(--fg2)">no settings</em>';
+      html+='<div class="mod-card" onclick="'+(m.is_server?'openEdit':'openDrill')+'(\''+m.name+'\','+m.is_server+')">';
+      html+='<div class="mod-head"><span class="mod-name">'+m.name+'</span>';
+      if(!m.is_server){
+        html+='<div class="toggle-sw '+(m.enabled?'on':'')+'" onclick="event.stopPropagation();toggleMod(\''+m.name+'\')"><div class="knob"></div></div>';
+      } else {
+        html+='<span class="badge on">core</span>';
+      }
+      html+='</div><div class="mod-settings">'+shtml+'</div></div>';
+    }
+    grid.innerHTML=html;
+  });
+}
+function renderConfigError(d){
+  var el=document.getElementById('config-error');
+  if(!d||!d.error){el.innerHTML='';return}
+  var html='<div class="cfg-err">';
+  if(d.error==='config_parse'){
+    html+='<b>config.toml has a syntax error</b> at line '+d.line+', column '+d.column+': '+esc(d.message);
+    if(d.line_text!==undefined)html+='<pre>'+d.line+' | '+esc(d.line_text)+'</pre>';
+  } else {
+    html+='<b>config.toml can\'t be read:</b> '+esc(d.message)+'<br>';
+  }
+  html+='<a onclick="doRepairWeb()">Run repair</a></div>';
+  el.innerHTML=html;
+}
+function toggleReveal(){
+  reveal=!reveal;
+  document.getElementById('reveal-btn').innerHTML=reveal?'&#128065; Hide secrets':'&#128065; Show secrets';
+  refreshConfig();refreshModules();
+}
+function toggleMod(name){
+  configWrite('/api/toggle/'+name,{method:'POST'}).then(function(r){if(!r.conflict)refreshModules()});
+}
+function openEdit(name,isServer){
+  var mod=modules.find(function(m){return m.name===name});
+  if(!mod)return;
+  var panel=document.getElementById('edit-panel');
+  var settings=isServer?Object.assign({},mod.settings):Object.assign({},mod.settings);
+  var keys=Object.keys(settings).sort();
+  var html='<h3>'+name+'</h3>';
+  for(var i=0;i<keys.length;i++){
+    var k=keys[i],v=String(settings[k]).replace(/"/g,'&quot;');
+    html+='<div class="field"><label>'+k+'</label><input data-key="'+k+'" value="'+v+'"></div>';
+  }
+  html+='<div class="edit-actions"><button class="btn" onclick="closeEdit()">Cancel</button>';
+  html+='<button class="btn primary" onclick="saveEdit(\''+name+'\')">Save</button></div>';
+  panel.innerHTML=html;
+  document.getElementById('edit-overlay').classList.add('show');
+}
+// Drilldown: a module's settings next to its live metrics, with Edit to change them
+function kvRows(o){
+  var keys=Object.keys(o).sort(),h='';
+  for(var i=0;i<keys.length;i++)h+='<tr><td class="k">'+esc(keys[i])+'</td><td>'+esc(o[keys[i]])+'</td></tr>';
+  return h;
+}
+function openDrill(name){
+  var mod=modules.find(function(m){return m.name===name});
+  if(!mod)return;
+  var panel=document.getElementById('edit-panel');
+  var settings=mod.settings||{};
+  var html='<h3>'+esc(name)+' <span class="badge '+(mod.enabled?'on':'off')+'">'+(mod.enabled?'enabled':'disabled')+'</span></h3>';
+  html+='<div class="drill-sec">Settings</div>';
+  html+=Object.keys(settings).length?'<table class="tbl">'+kvRows(settings)+'</table>':'<em style="color:var(--fg2);font-size:12px">no settings</em>';
+  html+='<div class="drill-sec">Metrics</div><div id="drill-metrics" style="font-size:12px;color:var(--fg2)">Loading…</div>';
+  html+='<div class="edit-actions"><button class="btn" onclick="closeEdit()">Close</button>';
+  html+='<button class="btn primary" onclick="openEdit(\''+name+'\',false)">Edit</button></div>';
+  panel.innerHTML=html;
+  document.getElementById('edit-overlay').classList.add('show');
+  api('/api/modules/'+encodeURIComponent(name)+'/metrics').then(function(d){
+    var el=document.getElementById('drill-metrics');
+    if(!el)return;
+    if(d.unavailable)el.innerHTML='<em>No metrics exposed for this module</em>';
+    else if(d.error)el.innerHTML='<em>'+esc(d.error)+'</em>';
+    else if(!d.metrics||!Object.keys(d.metrics).length)el.innerHTML='<em>No counters recorded yet</em>';
+    else el.innerHTML='<table class="tbl">'+kvRows(d.metrics)+'</table>';
+  });
+}
+function closeEdit(){document.getElementById('edit-overlay').classList.remove('show')}
+function saveEdit(name){
+  var inputs=document.querySelectorAll('#edit-panel input');
+  var u={};
+  inputs.forEach(function(inp){
+    var v=inp.value;
+    if(v==='true')v=true;else if(v==='false')v=false;
+    else if(/^\d+$/.test(v))v=parseInt(v);
+    else if(/^\d+\.\d+$/.test(v))v=parseFloat(v);
+    u[inp.dataset.key]=v;
+  });
+  configWrite('/api/update/'+name,{method:'POST',headers:{'Content-Type':'application/json'},body:JSON.stringify(u)})
+    .then(function(r){closeEdit();if(!r.conflict){refreshConfig();refreshModules()}});
+}
+function doVerifyWeb(){
+  var el=document.getElementById('verify-result');
+  el.innerHTML='<div style="padding:8px;color:var(--fg2)">Verifying...</div>';
+  api('/api/proxy/verify').then(function(d){
+    if(d.ok){
+      el.innerHTML='<div style="padding:8px;color:var(--green)">&#10003; Config is valid</div>';
+    } else {
+      var issues=(d.issues||[]).map(function(i){return '<div style="color:var(--yellow);padding:2px 0">&#8226; '+i+'</div>'}).join('');
+      el.innerHTML='<div style="padding:8px"><div style="color:var(--red);margin-bottom:4px">&#10007; Issues found:</div>'+issues+'</div>';
+    }
+    setTimeout(function(){el.innerHTML=''},8000);
+  });
+}
+function doRepairWeb(){
+  api('/api/proxy/repair',{method:'POST'}).then(function(d){
+    var el=document.getElementById('verify-result');
+    if(d.ok){
+      var fixes=(d.fixes||[]);
+      if(fixes.length>0){
+        el.innerHTML='<div style="padding:8px;color:var(--green)">&#10003; Repaired: '+fixes.join(', ')+'</div>';
+      } else {
+        el.innerHTML='<div style="padding:8px;color:var(--green)">&#10003; No repairs needed</div>';
+      }
+    } else {
+      el.innerHTML='<div style="padding:8px;color:var(--red)">&#10007; '+(d.error||'Repair failed')+'</div>';
+    }
+    setTimeout(function(){el.innerHTML='';refreshConfig();refreshModules()},5000);
+  });
+}
+
+// ── Protocols ──
+function refreshProtocols(){
+  api('/api/proxy/protocols').then(function(d){
+    protocolsData=d;
+    var tls=d.tls_enabled;
+    var h1=d.http1||{};var h2=d.http2||{};var h3=d.http3||{};
+    var html='';
+    html+='<div class="proto-row"><div class="proto-dot on"></div><div class="proto-name">HTTP/1.1</div><div class="proto-detail">Always enabled — TCP transport — Port: '+(h1.port||'—')+'</div></div>';
+    if(h2.enabled){
+      html+='<div class="proto-row"><div class="proto-dot on"></div><div class="proto-name">HTTP/2</div><div class="proto-detail">ALPN: "'+val(h2,'alpn')+'" — Requires TLS — Multiplexed streams</div></div>';
+    } else {
+      html+='<div class="proto-row"><div class="proto-dot off"></div><div class="proto-name">HTTP/2</div><div class="proto-detail">'+(tls?'Disabled in config':'Requires TLS (not configured)')+'</div></div>';
+    }
+    if(h3.enabled){
+      html+='<div class="proto-row"><div class="proto-dot on"></div><div class="proto-name">HTTP/3</div><div class="proto-detail">QUIC/UDP — Port: '+val(h3,'port')+' — 0-RTT capable</div></div>';
+    } else {
+      html+='<div class="proto-row"><div class="proto-dot off"></div><div class="proto-name">HTTP/3</div><div class="proto-detail">'+(tls?'Disabled in config':'Requires TLS (not configured)')+'</div></div>';
+    }
+    html+='<div class="proto-row"><div class="proto-dot '+(tls?'on':'off')+'"></div><div class="proto-name">TLS</div><div class="proto-detail">'+(tls?'Enabled — ALPN auto-negotiation':'Not configured')+'</div></div>';
+    document.getElementById('proto-detail').innerHTML=html;
+  });
+  api('/api/proxy/tls').then(function(d){
+    tlsData=d;
+    var html='';
+    if(d.enabled){
+      html+='<table class="tbl"><tr><th>Setting</th><th>Value</th></tr>';
+      html+='<tr><td class="k">Status</td><td><span class="badge on">Enabled</span></td></tr>';
+      html+='<tr><td class="k">cert_path</td><td>'+val(d,'cert_path')+'</td></tr>';
+      html+='<tr><td class="k">key_path</td><td>'+val(d,'key_path')+'</td></tr>';
+      html+='<tr><td class="k">cert_exists</td><td>'+(d.cert_exists?'<span class="badge on">yes</span>':'<span class="badge off">no</span>')+'</td></tr>';
+      html+='<tr><td class="k">key_exists</td><td>'+(d.key_exists?'<span class="badge on">yes</span>':'<span class="badge off">no</span>')+'</td></tr>';
+      html+='<tr><td class="k">alpn_protocols</td><td>'+val(d,'alpn_protocols')+'</td></tr>';
+      html+='<tr><td class="k">session_cache_size</td><td>'+val(d,'session_cache_size')+'</td></tr>';
+      html+='</table>';
+    } else {
+      html+='<div class="proto-row"><div class="proto-dot off"></div><div class="proto-name">TLS</div><div class="proto-detail">Not configured — set tls_cert and tls_key in server config</div></div>';
+    }
+    document.getElementById('tls-detail').innerHTML=html;
+  });
+}
+
+// ── Dev ──
+function devCompile(){
+  var o=document.getElementById('dev-output');o.textContent='Compiling Rust...\n';
+  api('/api/proxy/compile',{method:'POST'}).then(function(r){
+    o.textContent+=r.status==='success'?'\u2713 Build successful\n':'\u2717 Build failed: '+(r.error||'unknown')+'\n';
+  });
+}
+function devReload(){
+  var o=document.getElementById('dev-output');o.textContent='Reloading (stop \u2192 compile \u2192 start)...\n';
+  api('/api/proxy/reload',{method:'POST'}).then(function(){
+    o.textContent+='Reload started...\n';
+    setTimeout(function(){refreshAll().then(function(){o.textContent+='Done.\n'})},5000);
+  });
+}
+
+// ── Init ──
+function refreshAll(){
+  return Promise.all([refreshOverview(),refreshProtoOverview(),refreshLogs(),refreshMetrics(),refreshConfig(),refreshModules(),refreshProtocols()]);
+}
+refreshAll();
+setInterval(function(){refreshOverview();refreshMetrics()},5000);
+setInterval(refreshLogs,10000);
+setInterval(function(){refreshProtoOverview();refreshProtocols()},15000);
@@ -2,7 +2,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -11,6 +13,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	toml "github.com/pelletier/go-toml/v2"
@@ -19,17 +22,23 @@ import (
 var webPort = "8900"
 var webRunning = false
 
+// webServer is the running dashboard, kept so 'toggle web' can stop it.
+var webServer *http.Server
+
+// webShutdownTimeout bounds how long stopping the dashboard waits for
+// in-flight requests (a running compile or log fetch) to finish.
+const webShutdownTimeout = 5 * time.Second
+
 func doWeb() {
 	if webRunning {
-		fmt.Printf("  %s! Web already running%s → %shttp://127.0.0.1:%s%s\n", yellow, reset, cyan, webPort, reset)
+		fmt.Printf("  %s! Web already running%s → %s%s%s\n", yellow, reset, cyan, webURL(), reset)
 		return
 	}
 
-	root := projectRoot()
-	webCfgPath := filepath.Join(root, ".proxycache-web.toml")
+	webCfgPath := webConfigPath()
 
 	// Check if web is enabled via virtual config
-	if data, err := os.ReadFile(webCfgPath); err == nil {
+	if data, err := readTextFile(webCfgPath); err == nil {
 		var wc map[string]interface{}
 		if toml.Unmarshal(data, &wc) == nil {
 			if e, ok := wc["enabled"].(bool); ok && !e {
@@ -42,10 +51,19 @@ func doWeb() {
 		}
 	}
 
+	page, assets, err := loadWebAssets()
+	if err != nil {
+		fmt.Printf("  %s✗ Web dashboard is broken in this build: %s%s\n", red, err, reset)
+		fmt.Printf("  %sRebuild the CLI; cli/web/ must hold index.html and its assets%s\n", dim, reset)
+		exitCode = 1
+		return
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/config", webHandleConfig)
 	mux.HandleFunc("/api/toggle/", webHandleToggle)
 	mux.HandleFunc("/api/update/", webHandleUpdate)
+	mux.HandleFunc("/api/modules/", webHandleModuleMetrics)
 	mux.HandleFunc("/api/proxy/status", webHandleProxyStatus)
 	mux.HandleFunc("/api/proxy/start", webHandleProxyStart)
 	mux.HandleFunc("/api/proxy/stop", webHandleProxyStop)
@@ -60,10 +78,21 @@ func doWeb() {
 	mux.HandleFunc("/api/proxy/connections", webHandleProxyConnections)
 	mux.HandleFunc("/api/proxy/verify", webHandleProxyVerify)
 	mux.HandleFunc("/api/proxy/repair", webHandleProxyRepair)
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		a, ok := assets[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// The URL changes with the content, so it never needs revalidating
+		w.Header().Set("Content-Type", a.ContentType)
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Write(a.Data)
+	})
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		w.Write([]byte(webIndexHTML))
+		w.Write([]byte(page))
 	})
 
 	ln, err := net.Listen("tcp", "127.0.0.1:"+webPort)
@@ -72,15 +101,31 @@ func doWeb() {
 		return
 	}
 	webRunning = true
-	url := fmt.Sprintf("http://127.0.0.1:%s", webPort)
-	fmt.Printf("  %s✓ Web dashboard%s → %s%s%s\n", green, reset, cyan, url, reset)
-	go http.Serve(ln, mux)
+	webServer = &http.Server{Handler: mux}
+	fmt.Printf("  %s✓ Web dashboard%s → %s%s%s\n", green, reset, cyan, webURL(), reset)
+	go webServer.Serve(ln)
+}
+
+// stopWeb shuts the dashboard down, letting open requests finish for up to
+// webShutdownTimeout before their connections are closed.
+func stopWeb() error {
+	if webServer == nil {
+		webRunning = false
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webShutdownTimeout)
+	defer cancel()
+	err := webServer.Shutdown(ctx)
+	if err != nil {
+		webServer.Close()
+	}
+	webServer, webRunning = nil, false
+	return err
 }
 
 func isWebEnabled() bool {
-	root := projectRoot()
-	p := filepath.Join(root, ".proxycache-web.toml")
-	data, err := os.ReadFile(p)
+	p := webConfigPath()
+	data, err := readTextFile(p)
 	if err != nil {
 		return true
 	}
@@ -93,18 +138,75 @@ func isWebEnabled() bool {
 	return true
 }
 
+// toggleWeb flips enabled in the web config file. Only that line is
+// rewritten so other keys (host, auth token, refresh interval) and comments
+// survive.
 func toggleWeb() {
-	root := projectRoot()
-	p := filepath.Join(root, ".proxycache-web.toml")
+	p := webConfigPath()
 	enabled := isWebEnabled()
-	data := fmt.Sprintf("enabled = %v\nport = \"%s\"\n", !enabled, webPort)
-	os.WriteFile(p, []byte(data), 0644)
+	data, err := readTextFile(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("  %s✗ Can't read %s: %s%s\n", red, filepath.Base(p), err, reset)
+		return
+	}
+	doc := string(data)
+	if err != nil {
+		doc = fmt.Sprintf("port = \"%s\"\n", webPort)
+	}
+	doc = setTopLevelKey(doc, "enabled", fmt.Sprint(!enabled))
+	if err := fsys.WriteFile(p, []byte(doc), 0644); err != nil {
+		fmt.Printf("  %s✗ Can't write %s: %s%s\n", red, filepath.Base(p), err, reset)
+		return
+	}
 	if !enabled {
 		fmt.Printf("  %s✓ web enabled%s\n", green, reset)
-	} else {
-		fmt.Printf("  %s✗ web disabled%s\n", yellow, reset)
-		webRunning = false
+		// a one-shot command would have to block to keep serving
+		if !inREPL {
+			fmt.Printf("  %sRun 'web' to start the dashboard%s\n", dim, reset)
+		} else if !webRunning {
+			doWeb()
+		}
+		return
+	}
+	fmt.Printf("  %s✗ web disabled%s\n", yellow, reset)
+	if webRunning {
+		if err := stopWeb(); err != nil {
+			fmt.Printf("  %s! Dashboard closed before open requests finished: %s%s\n", yellow, err, reset)
+		} else {
+			fmt.Printf("  %s✓ Web dashboard stopped%s\n", green, reset)
+		}
+	}
+}
+
+// setTopLevelKey sets key = value among the top-level keys of a TOML
+// document, keeping a trailing comment on the line. A missing key is
+// inserted before the first table header.
+func setTopLevelKey(doc, key, value string) string {
+	lines := strings.Split(doc, "\n")
+	insertAt := len(lines)
+	if insertAt > 0 && lines[insertAt-1] == "" {
+		insertAt--
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			insertAt = i
+			break
+		}
+		eq := strings.Index(trimmed, "=")
+		if eq < 0 || strings.HasPrefix(trimmed, "#") || strings.TrimSpace(trimmed[:eq]) != key {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		newLine := indent + key + " = " + value
+		if _, comment := splitComment(trimmed[eq+1:]); comment != "" {
+			newLine += " # " + comment
+		}
+		lines[i] = newLine
+		return strings.Join(lines, "\n")
 	}
+	lines = append(lines[:insertAt], append([]string{key + " = " + value}, lines[insertAt:]...)...)
+	return strings.Join(lines, "\n")
 }
 
 func webJSON(w http.ResponseWriter, data interface{}) {
@@ -113,27 +215,38 @@ func webJSON(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func adminRequest(method, path string) (*http.Response, error) {
-	req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", addr, path), nil)
-	if err != nil {
-		return nil, err
-	}
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
-	return client.Do(req)
-}
-
 func webErr(w http.ResponseWriter, code int, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(map[string]string{"error": msg})
 }
 
+// webConfigMu serializes read-modify-write cycles on config.toml from
+// concurrent dashboard requests.
+var webConfigMu sync.Mutex
+
+// webConfigVersion returns the If-Match version sent by the dashboard.
+func webConfigVersion(r *http.Request) string {
+	return strings.Trim(r.Header.Get("If-Match"), `"`)
+}
+
+func webSaveConfig(w http.ResponseWriter, r *http.Request, cfg map[string]interface{}) bool {
+	if err := saveConfigTOMLAt(cfg, webConfigVersion(r)); err != nil {
+		code := 500
+		if err == errConfigChanged {
+			code = 409
+		}
+		webErr(w, code, err.Error())
+		return false
+	}
+	return true
+}
+
 func webHandleConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", `"`+configVersion()+`"`)
 	cfg, err := loadConfigTOML()
 	if err != nil {
-		webErr(w, 500, err.Error())
+		webConfigLoadErr(w, err)
 		return
 	}
 
@@ -145,8 +258,9 @@ func webHandleConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	var result []modInfo
 
+	reveal := r.URL.Query().Get("reveal") == "1"
 	if srv, ok := cfg["server"].(map[string]interface{}); ok {
-		result = append(result, modInfo{Name: "server", Enabled: true, Settings: srv, IsServer: true})
+		result = append(result, modInfo{Name: "server", Enabled: true, Settings: redacted(srv, reveal), IsServer: true})
 	}
 	if mods := getModules(cfg); mods != nil {
 		names := make([]string, 0, len(mods))
@@ -156,7 +270,7 @@ func webHandleConfig(w http.ResponseWriter, r *http.Request) {
 		sort.Strings(names)
 		for _, name := range names {
 			// Skip internal modules from UI
-			if name == "proxy_core" {
+			if protectedModules[name] {
 				continue
 			}
 			mod, ok := mods[name].(map[string]interface{})
@@ -170,7 +284,7 @@ func webHandleConfig(w http.ResponseWriter, r *http.Request) {
 			settings := make(map[string]interface{})
 			for k, v := range mod {
 				if k != "enabled" {
-					settings[k] = v
+					settings[k] = redactValue(k, v, reveal)
 				}
 			}
 			result = append(result, modInfo{Name: name, Enabled: enabled, Settings: settings, IsServer: false})
@@ -179,12 +293,40 @@ func webHandleConfig(w http.ResponseWriter, r *http.Request) {
 	webJSON(w, result)
 }
 
+// webConfigLoadErr reports why config.toml couldn't be loaded, including
+// the offending line for syntax errors, so the dashboard can show it.
+func webConfigLoadErr(w http.ResponseWriter, err error) {
+	result := map[string]interface{}{"error": "config_unreadable", "message": err.Error()}
+	var derr *toml.DecodeError
+	if errors.As(err, &derr) {
+		row, col := derr.Position()
+		result["error"] = "config_parse"
+		result["line"] = row
+		result["column"] = col
+		if data, rerr := readTextFile(configPath()); rerr == nil {
+			lines := strings.Split(string(data), "\n")
+			if row >= 1 && row <= len(lines) {
+				result["line_text"] = lines[row-1]
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(422)
+	json.NewEncoder(w).Encode(result)
+}
+
 func webHandleToggle(w http.ResponseWriter, r *http.Request) {
 	name := strings.TrimPrefix(r.URL.Path, "/api/toggle/")
 	if name == "" || name == "server" {
 		webErr(w, 400, "can't toggle server")
 		return
 	}
+	if protectedModules[name] {
+		webErr(w, 403, name+" is a core module and can't be toggled")
+		return
+	}
+	webConfigMu.Lock()
+	defer webConfigMu.Unlock()
 	cfg, err := loadConfigTOML()
 	if err != nil {
 		webErr(w, 500, err.Error())
@@ -207,8 +349,7 @@ func webHandleToggle(w http.ResponseWriter, r *http.Request) {
 	mod["enabled"] = !enabled
 	mods[name] = mod
 	cfg["modules"] = mods
-	if err := saveConfigTOML(cfg); err != nil {
-		webErr(w, 500, err.Error())
+	if !webSaveConfig(w, r, cfg) {
 		return
 	}
 	webJSON(w, map[string]interface{}{"name": name, "enabled": !enabled})
@@ -220,12 +361,18 @@ func webHandleUpdate(w http.ResponseWriter, r *http.Request) {
 		webErr(w, 400, "missing name")
 		return
 	}
+	if protectedModules[name] {
+		webErr(w, 403, name+" is a core module, edit it with 'edit "+name+" --force'")
+		return
+	}
 	body, _ := io.ReadAll(r.Body)
 	var updates map[string]interface{}
 	if err := json.Unmarshal(body, &updates); err != nil {
 		webErr(w, 400, "invalid json")
 		return
 	}
+	webConfigMu.Lock()
+	defer webConfigMu.Unlock()
 	cfg, err := loadConfigTOML()
 	if err != nil {
 		webErr(w, 500, err.Error())
@@ -238,7 +385,15 @@ func webHandleUpdate(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		for k, v := range updates {
-			srv[k] = coerceValue(srv[k], v)
+			if keepSecret(k, v) {
+				continue
+			}
+			cv, err := coerceKey("server", k, srv[k], v)
+			if err != nil {
+				webErr(w, 400, err.Error())
+				return
+			}
+			srv[k] = cv
 		}
 		cfg["server"] = srv
 	} else {
@@ -253,21 +408,55 @@ func webHandleUpdate(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		for k, v := range updates {
-			mod[k] = coerceValue(mod[k], v)
+			if keepSecret(k, v) {
+				continue
+			}
+			cv, err := coerceKey(name, k, mod[k], v)
+			if err != nil {
+				webErr(w, 400, err.Error())
+				return
+			}
+			mod[k] = cv
 		}
 		mods[name] = mod
 		cfg["modules"] = mods
 	}
-	if err := saveConfigTOML(cfg); err != nil {
-		webErr(w, 500, err.Error())
+	if !webSaveConfig(w, r, cfg) {
 		return
 	}
 	webJSON(w, map[string]string{"status": "saved"})
 }
 
+// keepSecret reports an untouched masked value echoed back by the edit
+// form, which must not overwrite the real secret.
+func keepSecret(k string, v interface{}) bool {
+	s, ok := v.(string)
+	return ok && s == secretMask && isSecretKey(k)
+}
+
+// coerceKey is coerceValue plus duration keys, which take "90s" or "2m"
+// and are saved as whole seconds.
+func coerceKey(section, key string, existing, incoming interface{}) (interface{}, error) {
+	v := coerceValue(existing, incoming)
+	if raw, ok := v.(string); ok && isAddrKey(section, key) {
+		return normalizeAddr(section, key, raw)
+	}
+	if !isDurationKey(section, key) {
+		return v, nil
+	}
+	secs, err := durationSeconds(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err)
+	}
+	return secs, nil
+}
+
 func coerceValue(existing, incoming interface{}) interface{} {
 	switch v := incoming.(type) {
 	case float64:
+		if _, ok := existing.(float64); ok {
+			return v
+		}
 		if _, ok := existing.(int64); ok {
 			return int64(v)
 		}
@@ -281,8 +470,7 @@ func coerceValue(existing, incoming interface{}) interface{} {
 }
 
 func webHandleProxyStatus(w http.ResponseWriter, r *http.Request) {
-	root := projectRoot()
-	pidFile := filepath.Join(root, ".proxycache.pid")
+	pidFile := pidPath()
 	result := map[string]interface{}{"process_running": false, "api_responding": false}
 	if pid, err := readPID(pidFile); err == nil && isProcessRunning(pid) {
 		result["process_running"] = true
@@ -305,14 +493,15 @@ func webHandleProxyStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func webHandleProxyStart(w http.ResponseWriter, r *http.Request) {
-	root := projectRoot()
-	pidFile := filepath.Join(root, ".proxycache.pid")
+	pidFile := pidPath()
 	if pid, err := readPID(pidFile); err == nil && isProcessRunning(pid) {
 		webJSON(w, map[string]interface{}{"status": "already_running", "pid": pid})
 		return
 	}
-	doRun()
-	time.Sleep(500 * time.Millisecond)
+	if !doRun(0) {
+		webErr(w, 500, "proxy failed to start, see "+displayPath(errLogPath()))
+		return
+	}
 	if pid, err := readPID(pidFile); err == nil {
 		webJSON(w, map[string]interface{}{"status": "started", "pid": pid})
 	} else {
@@ -331,20 +520,16 @@ func webHandleProxyReload(w http.ResponseWriter, r *http.Request) {
 }
 
 func webHandleProxyPing(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	resp, err := adminRequest("GET", "/ping")
-	elapsed := time.Since(start)
+	elapsed, err := checkPing(defaultPing)
 	if err != nil {
-		webJSON(w, map[string]interface{}{"alive": false, "error": connErr(err)})
+		webJSON(w, map[string]interface{}{"alive": false, "error": err.Error()})
 		return
 	}
-	resp.Body.Close()
 	webJSON(w, map[string]interface{}{"alive": true, "latency_ms": elapsed.Milliseconds()})
 }
 
 func webHandleProxyLogs(w http.ResponseWriter, r *http.Request) {
-	root := projectRoot()
-	data, err := os.ReadFile(filepath.Join(root, ".proxycache.log"))
+	data, err := os.ReadFile(logPath())
 	if err != nil {
 		webJSON(w, map[string]string{"logs": ""})
 		return
@@ -381,6 +566,28 @@ func webHandleProxyMetrics(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// webHandleModuleMetrics serves /api/modules/<name>/metrics for the
+// dashboard drilldown: the module's share of /metrics, or
+// {"unavailable":true} for a module that records none.
+func webHandleModuleMetrics(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/modules/")
+	name := strings.TrimSuffix(rest, "/metrics")
+	if name == "" || name == rest || strings.Contains(name, "/") {
+		webErr(w, 404, "not found")
+		return
+	}
+	fields, _, err := fetchModuleMetrics(name)
+	if err != nil {
+		webJSON(w, map[string]interface{}{"error": connErr(err)})
+		return
+	}
+	if fields == nil {
+		webJSON(w, map[string]interface{}{"unavailable": true})
+		return
+	}
+	webJSON(w, map[string]interface{}{"metrics": fields})
+}
+
 func webHandleProxyProtocols(w http.ResponseWriter, r *http.Request) {
 	resp, err := adminRequest("GET", "/protocols")
 	if err != nil {
@@ -441,6 +648,7 @@ func webHandleProxyServer(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if srv, ok := cfg["server"].(map[string]interface{}); ok {
+			srv = redacted(srv, r.URL.Query().Get("reveal") == "1")
 			srv["offline"] = true
 			webJSON(w, srv)
 		} else {
@@ -452,7 +660,7 @@ func webHandleProxyServer(w http.ResponseWriter, r *http.Request) {
 	body, _ := io.ReadAll(resp.Body)
 	var data map[string]interface{}
 	if json.Unmarshal(body, &data) == nil {
-		webJSON(w, data)
+		webJSON(w, redacted(data, r.URL.Query().Get("reveal") == "1"))
 	} else {
 		webJSON(w, map[string]interface{}{"error": "parse error"})
 	}
@@ -1,7 +1,34 @@
 // Configuration loading, validation, and default generation
 use serde::Deserialize;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::fs;
+use std::sync::{Mutex, OnceLock};
+
+/// Every [server] key Srv reads; anything else in that table is ignored.
+const SERVER_KEYS: &[&str] = &[
+    "listen_addr", "backend_addr", "buffer_size", "client_timeout", "backend_timeout",
+    "max_header_size", "max_body_size", "max_connections", "worker_threads",
+    "shutdown_timeout", "log_level", "log_format", "logging", "tls_cert", "tls_key", "tls_ocsp", "http2", "http3", "h3_port",
+];
+
+/// Module keys that are read but left out of default_config() on purpose.
+const OPTIONAL_MODULE_KEYS: &[(&str, &str)] = &[
+    ("admin_api", "api_key_file"),
+    ("raw_tcp", "backend_addr"),
+    ("raw_tcp", "buffer_size"),
+    ("raw_tcp", "timeout"),
+    ("url_rewriter", "rules"),
+];
+
+/// Module defaults from startup, used to recognize module keys on a reread.
+static KNOWN_MODULES: OnceLock<HashMap<String, toml::Value>> = OnceLock::new();
+/// Keys the last load didn't recognize, served by the admin API's /config/warnings.
+static UNKNOWN_KEYS: Mutex<Vec<String>> = Mutex::new(Vec::new());
+/// Hash of config.toml and its includes as of startup, served by the admin
+/// API's /config/hash.
+static LOADED_HASH: Mutex<String> = Mutex::new(String::new());
+/// The same hash as of the last config-only reload, or startup.
+static APPLIED_HASH: Mutex<String> = Mutex::new(String::new());
 
 #[derive(Deserialize)]
 #[serde(default)]
@@ -9,6 +36,15 @@ pub struct Config {
     pub server: Srv,
     #[serde(default)]
     pub modules: HashMap<String, toml::Value>,
+    /// Globs (relative to the config file) whose [modules.*] tables are merged in
+    #[serde(default)]
+    pub include: Vec<String>,
+    /// Modules that came from an include file; not written back to config.toml
+    #[serde(skip)]
+    pub included: HashSet<String>,
+    /// Files the include globs matched, in merge order
+    #[serde(skip)]
+    pub include_files: Vec<std::path::PathBuf>,
 }
 
 #[derive(Deserialize, Clone)]
@@ -25,9 +61,12 @@ pub struct Srv {
     pub worker_threads: usize,
     pub shutdown_timeout: u64,
     pub log_level: String,
+    pub log_format: String,
     pub logging: bool,
     pub tls_cert: String,
     pub tls_key: String,
+    /// DER OCSP response to staple, kept fresh by whatever fetches it.
+    pub tls_ocsp: String,
     pub http2: bool,
     pub http3: bool,
     pub h3_port: u16,
@@ -35,7 +74,13 @@ pub struct Srv {
 
 impl Default for Config {
     fn default() -> Self {
-        Config { server: Srv::default(), modules: HashMap::new() }
+        Config {
+            server: Srv::default(),
+            modules: HashMap::new(),
+            include: Vec::new(),
+            included: HashSet::new(),
+            include_files: Vec::new(),
+        }
     }
 }
 
@@ -53,9 +98,11 @@ impl Default for Srv {
             worker_threads: 0,
             shutdown_timeout: 15,
             log_level: "info".to_string(),
+            log_format: "text".to_string(),
             logging: true,
             tls_cert: String::new(),
             tls_key: String::new(),
+            tls_ocsp: String::new(),
             http2: true,
             http3: false,
             h3_port: 0,
@@ -106,6 +153,10 @@ impl Srv {
         if self.shutdown_timeout == 0 {
             self.shutdown_timeout = 15;
         }
+        if !self.log_format.eq_ignore_ascii_case("text") && !self.log_format.eq_ignore_ascii_case("json") {
+            crate::log::warn(&format!("log_format '{}' is not text or json, using text", self.log_format));
+            self.log_format = "text".to_string();
+        }
         if self.max_connections > 100_000 {
             crate::log::warn(&format!("max_connections very high ({}), may exhaust file descriptors", self.max_connections));
         }
@@ -128,11 +179,106 @@ impl Srv {
                 }
             }
         }
+        if !self.tls_ocsp.is_empty() && !std::path::Path::new(&self.tls_ocsp).exists() {
+            crate::log::warn(&format!("tls_ocsp file not found: {}, serving without a stapled OCSP response", self.tls_ocsp));
+        }
 
         valid
     }
 }
 
+/// Dotted paths of keys in `txt` that nothing reads: typos, removed settings
+/// and modules that don't exist. Module keys are only checked once the
+/// module defaults are known.
+pub fn find_unknown_keys(txt: &str, modules: Option<&HashMap<String, toml::Value>>) -> Vec<String> {
+    let Ok(table) = txt.parse::<toml::Table>() else { return Vec::new() };
+    let mut out = Vec::new();
+    for (key, value) in &table {
+        match key.as_str() {
+            "include" => {}
+            "server" => {
+                for k in value.as_table().into_iter().flat_map(|t| t.keys()) {
+                    if !SERVER_KEYS.contains(&k.as_str()) {
+                        out.push(format!("server.{k}"));
+                    }
+                }
+            }
+            "modules" => {
+                let Some(known) = modules else { continue };
+                for (name, m) in value.as_table().into_iter().flatten() {
+                    let Some(defaults) = known.get(name).and_then(|d| d.as_table()) else {
+                        out.push(format!("modules.{name}"));
+                        continue;
+                    };
+                    for k in m.as_table().into_iter().flat_map(|t| t.keys()) {
+                        let optional = OPTIONAL_MODULE_KEYS.contains(&(name.as_str(), k.as_str()));
+                        // every module takes enabled, and priority is read by the pipeline
+                        let common = k == "enabled" || k == "priority";
+                        if !common && !optional && !defaults.contains_key(k) {
+                            out.push(format!("modules.{name}.{k}"));
+                        }
+                    }
+                }
+            }
+            _ => out.push(key.clone()),
+        }
+    }
+    out.sort();
+    out
+}
+
+fn note_unknown_keys(txt: &str) {
+    let keys = find_unknown_keys(txt, KNOWN_MODULES.get());
+    for k in &keys {
+        crate::log::warn(&format!("Unknown config key '{k}' ignored"));
+    }
+    *UNKNOWN_KEYS.lock().unwrap_or_else(|e| e.into_inner()) = keys;
+}
+
+/// Keys ignored by the last load or reread of config.toml.
+pub fn unknown_keys() -> Vec<String> {
+    UNKNOWN_KEYS.lock().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+/// FNV-1a 64 of a config file's bytes, as 16 hex digits. The CLI hashes
+/// config.toml the same way to tell whether the running proxy is behind it.
+pub fn content_hash(data: &[u8]) -> String {
+    let mut h: u64 = 0xcbf29ce484222325;
+    for b in data {
+        h ^= *b as u64;
+        h = h.wrapping_mul(0x100000001b3);
+    }
+    format!("{h:016x}")
+}
+
+/// content_hash over config.toml followed by each include file in merge
+/// order, so an edited include counts as a config change. Unreadable files
+/// are left out.
+pub fn sources_hash(cfg_path: &str, include_files: &[std::path::PathBuf]) -> String {
+    let mut data = fs::read(cfg_path).unwrap_or_default();
+    for f in include_files {
+        data.extend(fs::read(f).unwrap_or_default());
+    }
+    content_hash(&data)
+}
+
+/// Hash of config.toml and its includes as this process loaded them,
+/// including any module defaults written back at startup. A config-only
+/// reload doesn't change it: most keys only take effect on a full reload.
+pub fn loaded_hash() -> String {
+    LOADED_HASH.lock().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+/// Hash of the config last applied, by startup or a config-only reload.
+pub fn applied_hash() -> String {
+    APPLIED_HASH.lock().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+/// Records cfg, from reread(), as applied by a config-only reload.
+pub fn mark_applied(cfg: &Config) {
+    *APPLIED_HASH.lock().unwrap_or_else(|e| e.into_inner()) = sources_hash(&path(), &cfg.include_files);
+}
+
 fn atomic_write(path: &str, content: &str) -> std::io::Result<()> {
     let tmp = format!("{path}.tmp");
     fs::write(&tmp, content)?;
@@ -140,12 +286,46 @@ fn atomic_write(path: &str, content: &str) -> std::io::Result<()> {
     Ok(())
 }
 
+/// Parse and validate config.toml without starting anything. `proxycache --check`
+/// uses this so a reload can test a new build before stopping the running one.
+pub fn check_config() -> bool {
+    reread().is_some()
+}
+
+/// Read config.toml again for a running proxy, logging why it's unusable.
+/// Only the parsed config is returned; callers decide what they can apply live.
+pub fn reread() -> Option<Config> {
+    let p = path();
+    let txt = match fs::read_to_string(&p) {
+        Ok(t) => t,
+        Err(e) => {
+            crate::log::error(&format!("Can't read {p}: {e}"));
+            return None;
+        }
+    };
+    let mut cfg: Config = match toml::from_str(&txt) {
+        Ok(c) => c,
+        Err(e) => {
+            crate::log::error(&format!("Parse error {p}: {e}"));
+            return None;
+        }
+    };
+    note_unknown_keys(&txt);
+    merge_includes(&mut cfg, &p);
+    if !cfg.server.validate() {
+        return None;
+    }
+    Some(cfg)
+}
+
 pub fn load_config(module_defaults: &HashMap<String, toml::Value>) -> Config {
     let p = path();
+    let _ = KNOWN_MODULES.set(module_defaults.clone());
     let mut cfg = match fs::read_to_string(&p) {
         Ok(txt) => match toml::from_str(&txt) {
             Ok(c) => {
                 crate::log::info(&format!("Loaded {p}"));
+                note_unknown_keys(&txt);
                 c
             }
             Err(e) => {
@@ -166,6 +346,7 @@ pub fn load_config(module_defaults: &HashMap<String, toml::Value>) -> Config {
             cfg
         }
     };
+    merge_includes(&mut cfg, &p);
     if !cfg.server.validate() {
         crate::log::error("Fatal configuration errors — falling back to safe defaults for invalid fields");
         if cfg.server.listen_addr.parse::<std::net::SocketAddr>().is_err() {
@@ -203,11 +384,100 @@ pub fn load_config(module_defaults: &HashMap<String, toml::Value>) -> Config {
             crate::log::info("Config updated with new module defaults");
         }
     }
+    let hash = sources_hash(&p, &cfg.include_files);
+    *APPLIED_HASH.lock().unwrap_or_else(|e| e.into_inner()) = hash.clone();
+    *LOADED_HASH.lock().unwrap_or_else(|e| e.into_inner()) = hash;
     cfg
 }
 
+// Merges [modules.*] tables from the `include` globs. config.toml wins over
+// includes, and among includes the first file (sorted by name) wins.
+pub fn merge_includes(cfg: &mut Config, cfg_path: &str) {
+    let base = std::path::Path::new(cfg_path).parent().map(|d| d.to_path_buf()).unwrap_or_default();
+    for pattern in cfg.include.clone() {
+        for file in expand_glob(&base, &pattern) {
+            if file == std::path::Path::new(cfg_path) || cfg.include_files.contains(&file) {
+                continue;
+            }
+            cfg.include_files.push(file.clone());
+            let shown = file.display().to_string();
+            let table = match fs::read_to_string(&file).map(|t| toml::from_str::<toml::Table>(&t)) {
+                Ok(Ok(t)) => t,
+                Ok(Err(e)) => {
+                    crate::log::error(&format!("Parse error {shown}: {e}"));
+                    continue;
+                }
+                Err(e) => {
+                    crate::log::error(&format!("Can't read {shown}: {e}"));
+                    continue;
+                }
+            };
+            for key in table.keys().filter(|k| k.as_str() != "modules") {
+                crate::log::warn(&format!("{shown}: only [modules.*] is read from includes, ignoring '{key}'"));
+            }
+            let Some(toml::Value::Table(mods)) = table.get("modules") else { continue };
+            for (name, value) in mods {
+                if cfg.modules.contains_key(name) {
+                    crate::log::warn(&format!("{shown}: [modules.{name}] already defined, ignoring"));
+                    continue;
+                }
+                cfg.modules.insert(name.clone(), value.clone());
+                cfg.included.insert(name.clone());
+            }
+        }
+        crate::log::info(&format!("Included {pattern}"));
+    }
+}
+
+// Expands a glob whose wildcards ('*', '?') are limited to the file name,
+// e.g. "modules.d/*.toml". Matches are returned sorted.
+pub fn expand_glob(base: &std::path::Path, pattern: &str) -> Vec<std::path::PathBuf> {
+    let full = base.join(pattern);
+    let (Some(dir), Some(name)) = (full.parent(), full.file_name().and_then(|n| n.to_str())) else {
+        return Vec::new();
+    };
+    if !name.contains(['*', '?']) {
+        return if full.is_file() { vec![full.clone()] } else { Vec::new() };
+    }
+    let mut out: Vec<_> = match fs::read_dir(dir) {
+        Ok(entries) => entries
+            .filter_map(|e| e.ok())
+            .map(|e| e.path())
+            .filter(|p| p.is_file() && p.file_name().and_then(|n| n.to_str()).is_some_and(|n| wildcard_match(name, n)))
+            .collect(),
+        Err(_) => Vec::new(),
+    };
+    out.sort();
+    out
+}
+
+pub fn wildcard_match(pattern: &str, text: &str) -> bool {
+    let (p, t): (Vec<char>, Vec<char>) = (pattern.chars().collect(), text.chars().collect());
+    let (mut pi, mut ti, mut star, mut mark) = (0, 0, None, 0);
+    while ti < t.len() {
+        if pi < p.len() && (p[pi] == '?' || p[pi] == t[ti]) {
+            pi += 1;
+            ti += 1;
+        } else if pi < p.len() && p[pi] == '*' {
+            star = Some(pi);
+            mark = ti;
+            pi += 1;
+        } else if let Some(s) = star {
+            pi = s + 1;
+            mark += 1;
+            ti = mark;
+        } else {
+            return false;
+        }
+    }
+    p[pi..].iter().all(|&c| c == '*')
+}
+
 fn generate_config(cfg: &Config) -> String {
     let mut doc = toml::Table::new();
+    if !cfg.include.is_empty() {
+        doc.insert("include".into(), toml::Value::Array(cfg.include.iter().map(|s| toml::Value::String(s.clone())).collect()));
+    }
     let mut srv = toml::Table::new();
     srv.insert("listen_addr".into(), toml::Value::String(cfg.server.listen_addr.clone()));
     srv.insert("backend_addr".into(), toml::Value::String(cfg.server.backend_addr.clone()));
@@ -220,15 +490,19 @@ fn generate_config(cfg: &Config) -> String {
     srv.insert("worker_threads".into(), toml::Value::Integer(cfg.server.worker_threads as i64));
     srv.insert("shutdown_timeout".into(), toml::Value::Integer(cfg.server.shutdown_timeout as i64));
     srv.insert("log_level".into(), toml::Value::String(cfg.server.log_level.clone()));
+    srv.insert("log_format".into(), toml::Value::String(cfg.server.log_format.clone()));
     srv.insert("logging".into(), toml::Value::Boolean(cfg.server.logging));
     srv.insert("tls_cert".into(), toml::Value::String(cfg.server.tls_cert.clone()));
     srv.insert("tls_key".into(), toml::Value::String(cfg.server.tls_key.clone()));
+    if !cfg.server.tls_ocsp.is_empty() {
+        srv.insert("tls_ocsp".into(), toml::Value::String(cfg.server.tls_ocsp.clone()));
+    }
     srv.insert("http2".into(), toml::Value::Boolean(cfg.server.http2));
     srv.insert("http3".into(), toml::Value::Boolean(cfg.server.http3));
     srv.insert("h3_port".into(), toml::Value::Integer(cfg.server.h3_port as i64));
     doc.insert("server".into(), toml::Value::Table(srv));
     let mut mods = toml::Table::new();
-    for (name, value) in &cfg.modules {
+    for (name, value) in cfg.modules.iter().filter(|(n, _)| !cfg.included.contains(*n)) {
         mods.insert(name.clone(), value.clone());
     }
     doc.insert("modules".into(), toml::Value::Table(mods));
@@ -241,6 +515,15 @@ fn generate_config(cfg: &Config) -> String {
     }
 }
 
+/// Resolves a path from the config against the directory holding
+/// config.toml, as includes are, rather than the working directory.
+/// Absolute paths are returned as they are.
+pub fn resolve_path(p: &str) -> std::path::PathBuf {
+    let cfg = path();
+    let base = std::path::Path::new(&cfg).parent().map(|d| d.to_path_buf()).unwrap_or_default();
+    base.join(p)
+}
+
 fn path() -> String {
     let args: Vec<String> = std::env::args().collect();
     args.windows(2)
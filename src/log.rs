@@ -7,6 +7,7 @@ use crate::colors::*;
 
 static ENABLED: AtomicBool = AtomicBool::new(true);
 static LOG_LEVEL: AtomicU8 = AtomicU8::new(0);
+static JSON: AtomicBool = AtomicBool::new(false);
 
 const LEVEL_DEBUG: u8 = 0;
 const LEVEL_INFO: u8 = 1;
@@ -28,6 +29,47 @@ pub fn set_level(level: &str) {
     LOG_LEVEL.store(l, Ordering::Relaxed);
 }
 
+/// `log_format = "json"` writes one JSON object per line with ts, level and
+/// msg (plus method/path/ip or status/ms for requests); anything else keeps
+/// the colored text format.
+pub fn set_format(format: &str) {
+    JSON.store(format.eq_ignore_ascii_case("json"), Ordering::Relaxed);
+}
+
+fn json() -> bool {
+    JSON.load(Ordering::Relaxed)
+}
+
+/// Escapes s for use inside a JSON string literal.
+pub fn json_escape(s: &str) -> String {
+    let mut out = String::with_capacity(s.len());
+    for c in s.chars() {
+        match c {
+            '"' => out.push_str("\\\""),
+            '\\' => out.push_str("\\\\"),
+            '\n' => out.push_str("\\n"),
+            '\r' => out.push_str("\\r"),
+            '\t' => out.push_str("\\t"),
+            c if (c as u32) < 0x20 => out.push_str(&format!("\\u{:04x}", c as u32)),
+            c => out.push(c),
+        }
+    }
+    out
+}
+
+/// One JSON log line. `extra` is pre-rendered `,"key":value` pairs.
+fn write_json(to_stderr: bool, level: &str, msg: &str, extra: &str) {
+    let ts = timestamp().replacen(' ', "T", 1);
+    let line = format!(r#"{{"ts":"{ts}Z","level":"{level}","msg":"{}"{extra}}}"#, json_escape(msg));
+    if to_stderr {
+        let _ = writeln!(io::stderr(), "{line}");
+        let _ = io::stderr().flush();
+    } else {
+        let _ = writeln!(io::stdout(), "{line}");
+        let _ = io::stdout().flush();
+    }
+}
+
 fn active() -> bool {
     ENABLED.load(Ordering::Relaxed)
 }
@@ -80,6 +122,7 @@ fn is_leap(y: u64) -> bool {
 #[allow(dead_code)]
 pub fn debug(msg: &str) {
     if !active() || !above_level(LEVEL_DEBUG) { return; }
+    if json() { return write_json(false, "debug", msg, ""); }
     let ts = timestamp();
     let _ = writeln!(io::stdout(), "{DIM}{ts}{RESET} {DIM}DBG{RESET} {msg}");
     let _ = io::stdout().flush();
@@ -87,6 +130,7 @@ pub fn debug(msg: &str) {
 
 pub fn info(msg: &str) {
     if !active() || !above_level(LEVEL_INFO) { return; }
+    if json() { return write_json(false, "info", msg, ""); }
     let ts = timestamp();
     let _ = writeln!(io::stdout(), "{DIM}{ts}{RESET} {BOLD}{CYAN}{msg}{RESET}");
     let _ = io::stdout().flush();
@@ -94,6 +138,7 @@ pub fn info(msg: &str) {
 
 pub fn warn(msg: &str) {
     if !active() || !above_level(LEVEL_WARN) { return; }
+    if json() { return write_json(true, "warn", msg, ""); }
     let ts = timestamp();
     let _ = writeln!(io::stderr(), "{DIM}{ts}{RESET} {YELLOW}⚠ {msg}{RESET}");
     let _ = io::stderr().flush();
@@ -101,6 +146,7 @@ pub fn warn(msg: &str) {
 
 pub fn error(msg: &str) {
     if !active() { return; }
+    if json() { return write_json(true, "error", msg, ""); }
     let ts = timestamp();
     let _ = writeln!(io::stderr(), "{DIM}{ts}{RESET} {RED}✗ {msg}{RESET}");
     let _ = io::stderr().flush();
@@ -108,18 +154,24 @@ pub fn error(msg: &str) {
 
 pub fn module_loaded(name: &str) {
     if !active() { return; }
+    if json() { return write_json(false, "info", "module loaded", &format!(r#","module":"{}""#, json_escape(name))); }
     let _ = writeln!(io::stdout(), "  {GREEN}✓{RESET} {name}");
     let _ = io::stdout().flush();
 }
 
 pub fn module_skipped(name: &str) {
     if !active() { return; }
+    if json() { return write_json(false, "info", "module overridden", &format!(r#","module":"{}""#, json_escape(name))); }
     let _ = writeln!(io::stdout(), "  {YELLOW}⊘ {name} [overridden]{RESET}");
     let _ = io::stdout().flush();
 }
 
 pub fn request(method: &str, path: &str, ip: &str) {
     if !active() || !above_level(LEVEL_INFO) { return; }
+    if json() {
+        let extra = format!(r#","method":"{}","path":"{}","ip":"{}""#, json_escape(method), json_escape(path), json_escape(ip));
+        return write_json(false, "info", "request", &extra);
+    }
     let ts = timestamp();
     let _ = writeln!(io::stdout(), "{DIM}{ts}{RESET} {YELLOW}→{RESET} {BOLD}{method}{RESET} {path} from {ip}");
     let _ = io::stdout().flush();
@@ -127,6 +179,9 @@ pub fn request(method: &str, path: &str, ip: &str) {
 
 pub fn response(status: u16, ms: u128, is_cache_hit: bool) {
     if !active() || !above_level(LEVEL_INFO) { return; }
+    if json() {
+        return write_json(false, "info", "response", &format!(r#","status":{status},"ms":{ms},"cache_hit":{is_cache_hit}"#));
+    }
     let ts = timestamp();
     let col = status_color(status);
     let source = if is_cache_hit { format!(" {CYAN}[CACHE HIT]{RESET}") } else { String::new() };
@@ -135,7 +190,7 @@ pub fn response(status: u16, ms: u128, is_cache_hit: bool) {
 }
 
 pub fn separator() {
-    if !active() { return; }
+    if !active() || json() { return; }
     let _ = writeln!(io::stdout(), "{SEPARATOR}");
     let _ = io::stdout().flush();
 }
@@ -14,6 +14,9 @@ mod server;
 mod tests;
 
 fn main() {
+    if std::env::args().any(|a| a == "--check") {
+        std::process::exit(if config::check_config() { 0 } else { 1 });
+    }
     metrics::init();
     let mut defaults = modules::collect_defaults();
     let script_defaults = script::collect_script_defaults();
@@ -23,6 +26,7 @@ fn main() {
     let c = config::load_config(&defaults);
     log::init(c.server.logging);
     log::set_level(&c.server.log_level);
+    log::set_format(&c.server.log_format);
     log::separator();
     log::info("Loading modules...");
     let mut p = modules::Pipeline::new(c.server.client_timeout);
@@ -1,7 +1,7 @@
 // Lock-free metrics using atomic counters
-use std::sync::atomic::{AtomicU64, Ordering};
-use std::sync::OnceLock;
-use std::time::Instant;
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::{Arc, Mutex, OnceLock};
+use std::time::{Duration, Instant};
 
 static START_TIME: OnceLock<Instant> = OnceLock::new();
 
@@ -15,8 +15,14 @@ static LATENCY_MAX_MS: AtomicU64 = AtomicU64::new(0);
 static CONNECTIONS_TOTAL: AtomicU64 = AtomicU64::new(0);
 static POOL_HITS: AtomicU64 = AtomicU64::new(0);
 static POOL_MISSES: AtomicU64 = AtomicU64::new(0);
+static POOL_WAITING: AtomicU64 = AtomicU64::new(0);
+static POOL_ACQUIRES: AtomicU64 = AtomicU64::new(0);
+static POOL_WAIT_US_SUM: AtomicU64 = AtomicU64::new(0);
 static CB_TRIPS: AtomicU64 = AtomicU64::new(0);
 static CB_REJECTS: AtomicU64 = AtomicU64::new(0);
+static RL_REJECTS: AtomicU64 = AtomicU64::new(0);
+static CACHE_HITS: AtomicU64 = AtomicU64::new(0);
+static CACHE_MISSES: AtomicU64 = AtomicU64::new(0);
 
 pub fn init() {
     START_TIME.get_or_init(Instant::now);
@@ -32,6 +38,120 @@ pub fn init() {
 #[inline] pub fn inc_pool_misses() { POOL_MISSES.fetch_add(1, Ordering::Relaxed); }
 #[inline] pub fn inc_cb_trips() { CB_TRIPS.fetch_add(1, Ordering::Relaxed); }
 #[inline] pub fn inc_cb_rejects() { CB_REJECTS.fetch_add(1, Ordering::Relaxed); }
+#[inline] pub fn inc_rl_rejects() { RL_REJECTS.fetch_add(1, Ordering::Relaxed); }
+#[inline] pub fn inc_cache_hits() { CACHE_HITS.fetch_add(1, Ordering::Relaxed); }
+#[inline] pub fn inc_cache_misses() { CACHE_MISSES.fetch_add(1, Ordering::Relaxed); }
+
+/// One in-flight pool acquire: counted as waiting until dropped, then its
+/// duration (lock and probing idle connections) is added to the wait sum.
+/// On a miss it's dropped before dialing, so connect time isn't counted.
+pub struct PoolWait(Instant);
+
+impl PoolWait {
+    pub fn start() -> Self {
+        POOL_WAITING.fetch_add(1, Ordering::Relaxed);
+        PoolWait(Instant::now())
+    }
+}
+
+impl Drop for PoolWait {
+    fn drop(&mut self) {
+        let us = (self.0.elapsed().as_micros() as u64).min(600_000_000);
+        POOL_WAIT_US_SUM.fetch_add(us, Ordering::Relaxed);
+        POOL_ACQUIRES.fetch_add(1, Ordering::Relaxed);
+        POOL_WAITING.fetch_sub(1, Ordering::Relaxed);
+    }
+}
+
+/// Time spent inside one pipeline module. `requests` counts the requests
+/// whose handle() ran; response hooks add to the time without counting again.
+/// The slot also carries the module's runtime pause flag, which the pipeline
+/// checks before running it.
+#[derive(Default)]
+pub struct ModuleTiming {
+    requests: AtomicU64,
+    us_sum: AtomicU64,
+    paused: AtomicBool,
+}
+
+impl ModuleTiming {
+    #[inline]
+    pub fn record_handle(&self, d: Duration) {
+        self.requests.fetch_add(1, Ordering::Relaxed);
+        self.us_sum.fetch_add((d.as_micros() as u64).min(600_000_000), Ordering::Relaxed);
+    }
+    #[inline]
+    pub fn record_response(&self, d: Duration) {
+        self.us_sum.fetch_add((d.as_micros() as u64).min(600_000_000), Ordering::Relaxed);
+    }
+    #[inline]
+    pub fn is_paused(&self) -> bool {
+        self.paused.load(Ordering::Relaxed)
+    }
+}
+
+// Keyed by module name so timings survive a pipeline rebuild.
+static MODULE_TIMINGS: Mutex<Vec<(String, Arc<ModuleTiming>)>> = Mutex::new(Vec::new());
+
+/// The shared timing slot for a module, created on first use.
+pub fn module_timing(name: &str) -> Arc<ModuleTiming> {
+    let mut all = MODULE_TIMINGS.lock().unwrap_or_else(|e| e.into_inner());
+    if let Some((_, t)) = all.iter().find(|(n, _)| n == name) {
+        return t.clone();
+    }
+    let t = Arc::new(ModuleTiming::default());
+    all.push((name.to_string(), t.clone()));
+    t
+}
+
+/// Pauses or resumes a module by name until the next restart. Returns false
+/// if no module of that name has been loaded into the pipeline.
+pub fn set_module_paused(name: &str, paused: bool) -> bool {
+    let all = MODULE_TIMINGS.lock().unwrap_or_else(|e| e.into_inner());
+    match all.iter().find(|(n, _)| n == name) {
+        Some((_, t)) => {
+            t.paused.store(paused, Ordering::Relaxed);
+            true
+        }
+        None => false,
+    }
+}
+
+/// Names of the modules currently paused.
+pub fn paused_modules() -> Vec<String> {
+    let all = MODULE_TIMINGS.lock().unwrap_or_else(|e| e.into_inner());
+    all.iter().filter(|(_, t)| t.is_paused()).map(|(n, _)| n.clone()).collect()
+}
+
+/// Per-module request counts and time, in pipeline registration order.
+pub fn module_timings_json() -> String {
+    let all = MODULE_TIMINGS.lock().unwrap_or_else(|e| e.into_inner());
+    let rows: Vec<String> = all.iter().map(|(name, t)| {
+        let n = t.requests.load(Ordering::Relaxed);
+        let us = t.us_sum.load(Ordering::Relaxed);
+        let avg = if n > 0 { us as f64 / n as f64 } else { 0.0 };
+        format!(r#"{{"name":"{}","requests":{n},"total_us":{us},"avg_us":{avg:.1}}}"#, crate::log::json_escape(name))
+    }).collect();
+    format!(r#"{{"modules":[{}]}}"#, rows.join(","))
+}
+
+/// Zeroes the cumulative counters and module timings. Gauges (active
+/// connections, in-flight pool waits) and uptime are left alone since they
+/// describe current state.
+pub fn reset() {
+    for c in [
+        &REQUESTS_TOTAL, &REQUESTS_OK, &REQUESTS_ERR, &BYTES_IN, &BYTES_OUT,
+        &LATENCY_SUM_MS, &LATENCY_MAX_MS, &CONNECTIONS_TOTAL, &POOL_HITS,
+        &POOL_MISSES, &POOL_ACQUIRES, &POOL_WAIT_US_SUM, &CB_TRIPS, &CB_REJECTS,
+        &RL_REJECTS, &CACHE_HITS, &CACHE_MISSES,
+    ] {
+        c.store(0, Ordering::Relaxed);
+    }
+    for (_, t) in MODULE_TIMINGS.lock().unwrap_or_else(|e| e.into_inner()).iter() {
+        t.requests.store(0, Ordering::Relaxed);
+        t.us_sum.store(0, Ordering::Relaxed);
+    }
+}
 
 #[inline]
 pub fn record_latency(ms: u64) {
@@ -58,8 +178,14 @@ pub struct Snapshot {
     pub active_connections: usize,
     pub pool_hits: u64,
     pub pool_misses: u64,
+    pub pool_waiting: u64,
+    pub pool_acquires: u64,
+    pub pool_wait_us_sum: u64,
     pub cb_trips: u64,
     pub cb_rejects: u64,
+    pub rl_rejects: u64,
+    pub cache_hits: u64,
+    pub cache_misses: u64,
     pub uptime_secs: u64,
 }
 
@@ -71,6 +197,14 @@ impl Snapshot {
             0
         }
     }
+
+    pub fn pool_wait_avg_ms(&self) -> f64 {
+        if self.pool_acquires > 0 {
+            self.pool_wait_us_sum as f64 / self.pool_acquires as f64 / 1000.0
+        } else {
+            0.0
+        }
+    }
 }
 
 pub fn snapshot() -> Snapshot {
@@ -86,8 +220,14 @@ pub fn snapshot() -> Snapshot {
         active_connections: crate::server::active_connections(),
         pool_hits: POOL_HITS.load(Ordering::Relaxed),
         pool_misses: POOL_MISSES.load(Ordering::Relaxed),
+        pool_waiting: POOL_WAITING.load(Ordering::Relaxed),
+        pool_acquires: POOL_ACQUIRES.load(Ordering::Relaxed),
+        pool_wait_us_sum: POOL_WAIT_US_SUM.load(Ordering::Relaxed),
         cb_trips: CB_TRIPS.load(Ordering::Relaxed),
         cb_rejects: CB_REJECTS.load(Ordering::Relaxed),
+        rl_rejects: RL_REJECTS.load(Ordering::Relaxed),
+        cache_hits: CACHE_HITS.load(Ordering::Relaxed),
+        cache_misses: CACHE_MISSES.load(Ordering::Relaxed),
         uptime_secs: START_TIME.get().map(|t| t.elapsed().as_secs()).unwrap_or(0),
     }
 }
@@ -123,26 +263,52 @@ pub fn snapshot_prometheus() -> String {
          proxycache_pool_hits {}\n\
          # TYPE proxycache_pool_misses counter\n\
          proxycache_pool_misses {}\n\
+         # HELP proxycache_pool_waiting Backend connection acquires in progress\n\
+         # TYPE proxycache_pool_waiting gauge\n\
+         proxycache_pool_waiting {}\n\
+         # TYPE proxycache_pool_acquires counter\n\
+         proxycache_pool_acquires {}\n\
+         # TYPE proxycache_pool_wait_us_sum counter\n\
+         proxycache_pool_wait_us_sum {}\n\
          # TYPE proxycache_circuit_breaker_trips counter\n\
          proxycache_circuit_breaker_trips {}\n\
          # TYPE proxycache_circuit_breaker_rejects counter\n\
-         proxycache_circuit_breaker_rejects {}\n",
+         proxycache_circuit_breaker_rejects {}\n\
+         # TYPE proxycache_rate_limiter_rejects counter\n\
+         proxycache_rate_limiter_rejects {}\n\
+         # TYPE proxycache_cache_hits counter\n\
+         proxycache_cache_hits {}\n\
+         # TYPE proxycache_cache_misses counter\n\
+         proxycache_cache_misses {}\n",
         s.uptime_secs, s.requests_total, s.requests_ok, s.requests_err,
         s.active_connections, s.connections_total, s.bytes_in, s.bytes_out,
         s.latency_sum_ms, s.latency_max_ms, s.pool_hits, s.pool_misses,
-        s.cb_trips, s.cb_rejects,
+        s.pool_waiting, s.pool_acquires, s.pool_wait_us_sum,
+        s.cb_trips, s.cb_rejects, s.rl_rejects, s.cache_hits, s.cache_misses,
     )
 }
 
+/// JSON fields recorded on behalf of a module, with the module's name. The
+/// rest are proxy-wide. Served as "sources" so clients can group by module.
+pub const METRIC_SOURCES: &[(&str, &str)] = &[
+    ("circuit_breaker_trips", "circuit_breaker"),
+    ("circuit_breaker_rejects", "circuit_breaker"),
+    ("rate_limiter_rejects", "rate_limiter"),
+    ("cache_hits", "cache"),
+    ("cache_misses", "cache"),
+];
+
 pub fn snapshot_json() -> String {
     let s = snapshot();
     let avg_lat = if s.requests_total > 0 { s.latency_sum_ms / s.requests_total } else { 0 };
+    let sources: Vec<String> = METRIC_SOURCES.iter().map(|(k, m)| format!(r#""{k}":"{m}""#)).collect();
 
     format!(
-        r#"{{"uptime_seconds":{},"requests_total":{},"requests_ok":{},"requests_err":{},"active_connections":{},"connections_total":{},"bytes_in":{},"bytes_out":{},"latency_avg_ms":{},"latency_max_ms":{},"pool_hits":{},"pool_misses":{},"circuit_breaker_trips":{},"circuit_breaker_rejects":{}}}"#,
+        r#"{{"uptime_seconds":{},"requests_total":{},"requests_ok":{},"requests_err":{},"active_connections":{},"connections_total":{},"bytes_in":{},"bytes_out":{},"latency_avg_ms":{},"latency_max_ms":{},"pool_hits":{},"pool_misses":{},"pool_waiting":{},"pool_wait_avg_ms":{:.2},"circuit_breaker_trips":{},"circuit_breaker_rejects":{},"rate_limiter_rejects":{},"cache_hits":{},"cache_misses":{},"sources":{{{}}}}}"#,
         s.uptime_secs, s.requests_total, s.requests_ok, s.requests_err,
         s.active_connections, s.connections_total, s.bytes_in, s.bytes_out,
         avg_lat, s.latency_max_ms, s.pool_hits, s.pool_misses,
-        s.cb_trips, s.cb_rejects,
+        s.pool_waiting, s.pool_wait_avg_ms(),
+        s.cb_trips, s.cb_rejects, s.rl_rejects, s.cache_hits, s.cache_misses, sources.join(","),
     )
 }
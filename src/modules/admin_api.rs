@@ -1,6 +1,8 @@
 // Admin API for proxy management
 use super::helpers as h;
+use crate::log::json_escape;
 use crate::server;
+use std::collections::HashMap;
 use std::io::{Read, Write};
 use std::net::{TcpListener, TcpStream};
 use std::sync::atomic::{AtomicUsize, Ordering};
@@ -15,13 +17,36 @@ pub fn default_config() -> toml::Table {
     t.insert("enabled".into(), toml::Value::Boolean(true));
     t.insert("listen_addr".into(), toml::Value::String("127.0.0.1:9090".into()));
     t.insert("api_key".into(), toml::Value::String("".into()));
+    t.insert("allow_ips".into(), toml::Value::Array(vec![]));
     t
 }
 
 pub fn register(ctx: &mut super::ModuleContext) {
     if !h::is_enabled(ctx.config, "admin_api") { return; }
     let addr = h::config_str(ctx.config, "admin_api", "listen_addr", "127.0.0.1:9090");
-    let api_key = h::config_str(ctx.config, "admin_api", "api_key", "");
+    let mut api_key = h::config_str(ctx.config, "admin_api", "api_key", "");
+    let key_file = h::config_str(ctx.config, "admin_api", "api_key_file", "");
+    if !key_file.is_empty() {
+        // Refuse to come up unprotected when the secret file is configured but unusable
+        match h::read_secret_file(&key_file) {
+            Ok(k) => api_key = k,
+            Err(e) => {
+                crate::log::error(&format!("admin_api: api_key_file {e}"));
+                return;
+            }
+        }
+    }
+    let mut allow = Vec::new();
+    for entry in h::config_vec_str(ctx.config, "admin_api", "allow_ips") {
+        // Same as api_key_file: a typo must not leave the API open to everyone
+        match h::IpNet::parse(&entry) {
+            Some(net) => allow.push(net),
+            None => {
+                crate::log::error(&format!("admin_api: invalid allow_ips entry '{entry}', expected an IP or CIDR"));
+                return;
+            }
+        }
+    }
     let listener = match TcpListener::bind(&addr) {
         Ok(l) => l,
         Err(e) => {
@@ -39,9 +64,11 @@ pub fn register(ctx: &mut super::ModuleContext) {
         backend: ctx.server.backend_addr.clone(),
         max_conns: ctx.server.max_connections,
         api_key,
+        allow,
         tls_enabled: !ctx.server.tls_cert.is_empty() && !ctx.server.tls_key.is_empty(),
         tls_cert: ctx.server.tls_cert.clone(),
         tls_key: ctx.server.tls_key.clone(),
+        tls_ocsp: ctx.server.tls_ocsp.clone(),
         http2: ctx.server.http2,
         http3: ctx.server.http3,
         h3_port: ctx.server.h3_port,
@@ -53,7 +80,9 @@ pub fn register(ctx: &mut super::ModuleContext) {
         worker_threads: ctx.server.worker_threads,
         shutdown_timeout: ctx.server.shutdown_timeout,
         log_level: ctx.server.log_level.clone(),
+        log_format: ctx.server.log_format.clone(),
         logging: ctx.server.logging,
+        module_config: ctx.config.clone(),
     });
     let active_admin = Arc::new(AtomicUsize::new(0));
     thread::spawn(move || {
@@ -82,9 +111,11 @@ struct Info {
     backend: String,
     max_conns: usize,
     api_key: String,
+    allow: Vec<h::IpNet>,
     tls_enabled: bool,
     tls_cert: String,
     tls_key: String,
+    tls_ocsp: String,
     http2: bool,
     http3: bool,
     h3_port: u16,
@@ -96,18 +127,9 @@ struct Info {
     worker_threads: usize,
     shutdown_timeout: u64,
     log_level: String,
+    log_format: String,
     logging: bool,
-}
-
-fn extract_header<'a>(raw: &'a str, name: &str) -> Option<&'a str> {
-    for line in raw.lines().skip(1) {
-        if let Some((k, v)) = line.split_once(':') {
-            if k.trim().eq_ignore_ascii_case(name) {
-                return Some(v.trim());
-            }
-        }
-    }
-    None
+    module_config: HashMap<String, toml::Value>,
 }
 
 const MAX_ADMIN_REQUEST: usize = 65_536;
@@ -182,8 +204,17 @@ fn handle(mut s: TcpStream, info: &Info) {
     let peer = s.peer_addr().map(|a| a.to_string()).unwrap_or_else(|_| "?".into());
     crate::log::info(&format!("admin_api: {method} {path} from {peer}"));
 
+    if !info.allow.is_empty() {
+        let permitted = s.peer_addr().map(|a| info.allow.iter().any(|n| n.contains(a.ip()))).unwrap_or(false);
+        if !permitted {
+            crate::log::warn(&format!("admin_api: {peer} is not in allow_ips"));
+            respond(&mut s, 403, r#"{"error":"forbidden"}"#);
+            return;
+        }
+    }
+
     if !info.api_key.is_empty() && path != "/ping" {
-        let provided = extract_header(&raw, "X-API-Key").unwrap_or("");
+        let provided = h::provided_key(&raw);
         if !constant_time_eq(provided.as_bytes(), info.api_key.as_bytes()) {
             crate::log::warn(&format!("admin_api: unauthorized access from {peer}"));
             respond(&mut s, 403, r#"{"error":"unauthorized"}"#);
@@ -193,7 +224,7 @@ fn handle(mut s: TcpStream, info: &Info) {
 
     match (method, path) {
         ("GET", "/") => {
-            respond(&mut s, 200, r#"{"endpoints":["/ping","/status","/config","/server","/stop","/reload","/connections","/metrics","/mods","/protocols","/tls","/config/verify","/config/repair"]}"#);
+            respond(&mut s, 200, r#"{"endpoints":["/ping","/status","/config","/server","/stop","/reload","/config/reload","/config/warnings","/config/hash","/connections","/metrics","/metrics/reset","/modules/timing","/modules/paused","/modules/<name>/pause","/modules/<name>/resume","/mods","/protocols","/tls","/config/verify","/config/repair"]}"#);
         }
         ("GET", "/ping") => {
             respond(&mut s, 200, r#"{"ping":"pong"}"#);
@@ -208,8 +239,13 @@ fn handle(mut s: TcpStream, info: &Info) {
             let mut protocols = vec!["HTTP/1.1"];
             if info.tls_enabled && info.http2 { protocols.push("HTTP/2"); }
             if info.tls_enabled && info.http3 { protocols.push("HTTP/3"); }
+            // Lets clients on other machines measure clock skew
+            let now_ms = std::time::SystemTime::now()
+                .duration_since(std::time::UNIX_EPOCH)
+                .map(|d| d.as_millis())
+                .unwrap_or(0);
             let body = format!(
-                r#"{{"status":"running","uptime_seconds":{up},"uptime":"{d}d {h}h {m}m {sec}s","listen":"{l}","backend":"{b}","scheme":"{scheme}","protocols":"{protos}","pid":{pid},"active_connections":{active},"max_connections":{mc},"requests_total":{rt},"requests_ok":{ro},"requests_err":{re},"bytes_in":{bi},"bytes_out":{bo},"avg_latency_ms":{lat}}}"#,
+                r#"{{"status":"running","uptime_seconds":{up},"uptime":"{d}d {h}h {m}m {sec}s","server_time_ms":{now_ms},"listen":"{l}","backend":"{b}","scheme":"{scheme}","protocols":"{protos}","pid":{pid},"active_connections":{active},"max_connections":{mc},"requests_total":{rt},"requests_ok":{ro},"requests_err":{re},"bytes_in":{bi},"bytes_out":{bo},"avg_latency_ms":{lat}}}"#,
                 l = info.listen, b = info.backend, mc = info.max_conns,
                 protos = protocols.join(", "),
                 rt = snap.requests_total, ro = snap.requests_ok, re = snap.requests_err,
@@ -228,6 +264,24 @@ fn handle(mut s: TcpStream, info: &Info) {
         ("GET", "/metrics") => {
             respond(&mut s, 200, &crate::metrics::snapshot_json());
         }
+        ("POST", "/metrics/reset") => {
+            let before = crate::metrics::snapshot().requests_total;
+            crate::metrics::reset();
+            crate::log::info("Metrics counters reset via admin API");
+            respond(&mut s, 200, &format!(r#"{{"action":"metrics_reset","discarded_requests":{}}}"#, before));
+        }
+        ("GET", "/modules/timing") => {
+            respond(&mut s, 200, &crate::metrics::module_timings_json());
+        }
+        ("GET", "/modules/paused") => {
+            let names: Vec<String> = crate::metrics::paused_modules().iter().map(|n| format!("\"{}\"", json_escape(n))).collect();
+            respond(&mut s, 200, &format!(r#"{{"paused":[{}]}}"#, names.join(",")));
+        }
+        ("POST", p) if pause_target(p).is_some() => {
+            let (name, pause) = pause_target(p).unwrap_or_default();
+            let (code, body) = h::pause_module(name, pause);
+            respond(&mut s, code, &body);
+        }
         ("GET", "/config") => {
             respond(&mut s, 200, &full_config_json(info));
         }
@@ -246,6 +300,10 @@ fn handle(mut s: TcpStream, info: &Info) {
         ("GET", "/config/verify") => {
             respond(&mut s, 200, &config_verify());
         }
+        ("GET", "/config/warnings") => {
+            let keys: Vec<String> = crate::config::unknown_keys().iter().map(|k| format!("\"{}\"", json_escape(k))).collect();
+            respond(&mut s, 200, &format!(r#"{{"unknown_keys":[{}]}}"#, keys.join(",")));
+        }
         ("POST", "/config/repair") => {
             respond(&mut s, 200, &config_repair());
         }
@@ -254,6 +312,27 @@ fn handle(mut s: TcpStream, info: &Info) {
             let _ = s.flush();
             server::request_shutdown();
         }
+        ("GET", "/config/hash") => {
+            respond(&mut s, 200, &format!(
+                r#"{{"hash":"{}","applied":"{}","algorithm":"fnv1a64"}}"#,
+                crate::config::loaded_hash(),
+                crate::config::applied_hash()
+            ));
+        }
+        ("POST", "/config/reload") => {
+            // Hot path: only settings that are read per use can change without a restart
+            match crate::config::reread() {
+                Some(c) => {
+                    crate::log::init(c.server.logging);
+                    crate::log::set_level(&c.server.log_level);
+                    crate::log::set_format(&c.server.log_format);
+                    crate::config::mark_applied(&c);
+                    crate::log::info("Config reloaded (server.logging, server.log_level, server.log_format applied)");
+                    respond(&mut s, 200, r#"{"action":"config_reloaded","applied":["server.logging","server.log_level","server.log_format"]}"#);
+                }
+                None => respond(&mut s, 400, r#"{"error":"config.toml is invalid, see the proxy log"}"#),
+            }
+        }
         ("POST", "/reload") => {
             respond(&mut s, 200, r#"{"action":"reloading"}"#);
             let _ = s.flush();
@@ -266,6 +345,21 @@ fn handle(mut s: TcpStream, info: &Info) {
     }
 }
 
+/// Splits `/modules/<name>/pause` or `/modules/<name>/resume` into the
+/// module name and whether to pause it.
+fn pause_target(path: &str) -> Option<(&str, bool)> {
+    let rest = path.strip_prefix("/modules/")?;
+    let (name, action) = rest.split_once('/')?;
+    if name.is_empty() {
+        return None;
+    }
+    match action {
+        "pause" => Some((name, true)),
+        "resume" => Some((name, false)),
+        _ => None,
+    }
+}
+
 fn constant_time_eq(a: &[u8], b: &[u8]) -> bool {
     if a.len() != b.len() {
         let mut _acc: u8 = 1;
@@ -302,13 +396,13 @@ fn respond(s: &mut TcpStream, code: u16, body: &str) {
 
 fn server_config_json(info: &Info) -> String {
     format!(
-        r#"{{"listen_addr":"{la}","backend_addr":"{ba}","buffer_size":{bs},"client_timeout":{ct},"backend_timeout":{bt},"max_header_size":{mh},"max_body_size":{mb},"max_connections":{mc},"worker_threads":{wt},"shutdown_timeout":{st},"log_level":"{ll}","logging":{lo},"tls_cert":"{tc}","tls_key":"{tk}","http2":{h2},"http3":{h3},"h3_port":{hp}}}"#,
+        r#"{{"listen_addr":"{la}","backend_addr":"{ba}","buffer_size":{bs},"client_timeout":{ct},"backend_timeout":{bt},"max_header_size":{mh},"max_body_size":{mb},"max_connections":{mc},"worker_threads":{wt},"shutdown_timeout":{st},"log_level":"{ll}","log_format":"{lf}","logging":{lo},"tls_cert":"{tc}","tls_key":"{tk}","http2":{h2},"http3":{h3},"h3_port":{hp}}}"#,
         la = info.listen, ba = info.backend, bs = info.buffer_size,
         ct = info.client_timeout, bt = info.backend_timeout,
         mh = info.max_header_size, mb = info.max_body_size,
         mc = info.max_conns, wt = info.worker_threads,
-        st = info.shutdown_timeout, ll = info.log_level, lo = info.logging,
-        tc = info.tls_cert, tk = info.tls_key,
+        st = info.shutdown_timeout, ll = info.log_level, lf = info.log_format, lo = info.logging,
+        tc = json_escape(&info.tls_cert), tk = json_escape(&info.tls_key),
         h2 = info.http2, h3 = info.http3, hp = info.h3_port,
     )
 }
@@ -316,7 +410,32 @@ fn server_config_json(info: &Info) -> String {
 fn full_config_json(info: &Info) -> String {
     let server = server_config_json(info);
     let mods = mods_list();
-    format!(r#"{{"server":{server},"modules":{mods}}}"#)
+    let mut names: Vec<&String> = info.module_config.keys().collect();
+    names.sort();
+    let module_config: Vec<String> = names.iter()
+        .map(|n| format!("\"{}\":{}", json_escape(n), toml_json(&info.module_config[*n])))
+        .collect();
+    format!(r#"{{"server":{server},"modules":{mods},"module_config":{{{}}}}}"#, module_config.join(","))
+}
+
+/// Module settings as loaded at startup, as JSON. api_key is left out: the
+/// config endpoint is for comparing settings, not reading secrets.
+fn toml_json(v: &toml::Value) -> String {
+    match v {
+        toml::Value::String(s) => format!("\"{}\"", json_escape(s)),
+        toml::Value::Integer(i) => i.to_string(),
+        toml::Value::Float(f) if f.is_finite() => f.to_string(),
+        toml::Value::Float(_) => "null".into(),
+        toml::Value::Boolean(b) => b.to_string(),
+        toml::Value::Datetime(d) => format!("\"{d}\""),
+        toml::Value::Array(a) => format!("[{}]", a.iter().map(toml_json).collect::<Vec<_>>().join(",")),
+        toml::Value::Table(t) => {
+            let mut keys: Vec<&String> = t.keys().filter(|k| k.as_str() != "api_key").collect();
+            keys.sort();
+            let fields: Vec<String> = keys.iter().map(|k| format!("\"{}\":{}", json_escape(k), toml_json(&t[*k]))).collect();
+            format!("{{{}}}", fields.join(","))
+        }
+    }
 }
 
 fn protocols_json(info: &Info) -> String {
@@ -342,9 +461,12 @@ fn tls_json(info: &Info) -> String {
     if info.http2 { alpn.push("h2"); }
     alpn.push("http/1.1");
     let alpn_str = alpn.join(", ");
+    // Sessions resume from the server-side cache (session IDs, and stateful
+    // TLS 1.3 tickets); stateless tickets need a ticketer, which isn't set.
+    let ocsp_exists = !info.tls_ocsp.is_empty() && std::path::Path::new(&info.tls_ocsp).exists();
     format!(
-        r#"{{"enabled":true,"cert_path":"{}","key_path":"{}","cert_exists":{},"key_exists":{},"alpn_protocols":"{}","session_cache_size":2048}}"#,
-        info.tls_cert, info.tls_key, cert_exists, key_exists, alpn_str,
+        r#"{{"enabled":true,"cert_path":"{}","key_path":"{}","cert_exists":{},"key_exists":{},"alpn_protocols":"{}","session_cache_size":2048,"stateless_tickets":false,"ocsp_path":"{}","ocsp_exists":{}}}"#,
+        json_escape(&info.tls_cert), json_escape(&info.tls_key), cert_exists, key_exists, alpn_str, json_escape(&info.tls_ocsp), ocsp_exists,
     )
 }
 
@@ -470,9 +592,10 @@ fn config_repair() -> String {
                         if let Ok(def) = crate::script::parser::parse(&src) {
                             if let Some(modules) = table.get_mut("modules").and_then(|v| v.as_table_mut()) {
                                 if !modules.contains_key(&def.name) {
-                                    let defaults = crate::script::parser::default_config_table(&def);
+                                    let mut defaults = crate::script::parser::default_config_table(&def);
+                                    defaults.insert("enabled".into(), toml::Value::Boolean(false));
                                     modules.insert(def.name.clone(), toml::Value::Table(defaults));
-                                    fixes.push(format!("added defaults for module '{}'", def.name));
+                                    fixes.push(format!("added module '{}' (disabled)", def.name));
                                 }
                             }
                         }
@@ -482,6 +605,20 @@ fn config_repair() -> String {
         }
     }
 
+    // Add built-in modules missing from config, disabled until toggled on
+    if let Some(modules) = table.get_mut("modules").and_then(|v| v.as_table_mut()) {
+        let mut builtin: Vec<_> = crate::modules::collect_defaults().into_iter().collect();
+        builtin.sort_by(|a, b| a.0.cmp(&b.0));
+        for (name, defaults) in builtin {
+            if !modules.contains_key(&name) {
+                let mut t = defaults.as_table().cloned().unwrap_or_default();
+                t.insert("enabled".into(), toml::Value::Boolean(false));
+                modules.insert(name.clone(), toml::Value::Table(t));
+                fixes.push(format!("added module '{name}' (disabled)"));
+            }
+        }
+    }
+
     // Write back
     if !fixes.is_empty() {
         match toml::to_string_pretty(&table) {
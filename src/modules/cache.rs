@@ -126,6 +126,7 @@ impl Module for Cache {
                 if let Some(tag) = r.get_header("If-None-Match") {
                     if let Some(etag) = e.resp.get_header("ETag") {
                         if tag == etag {
+                            crate::metrics::inc_cache_hits();
                             let resp = HttpResponse {
                                 version: "HTTP/1.1".to_string(),
                                 status_code: 304,
@@ -139,12 +140,14 @@ impl Module for Cache {
                 }
                 let mut cached = e.resp.clone();
                 cached.headers.push(("X-Cache".to_string(), "HIT".to_string()));
+                crate::metrics::inc_cache_hits();
                 return Some(cached);
             } else {
                 m.remove(&k);
             }
         }
 
+        crate::metrics::inc_cache_misses();
         None
     }
 
@@ -0,0 +1,65 @@
+// Header rules: set or remove request and response headers
+use super::{helpers as h, Module};
+use crate::context::Context;
+use crate::http::{HttpRequest, HttpResponse};
+
+pub fn default_config() -> toml::Table {
+    let mut t = toml::Table::new();
+    t.insert("enabled".into(), toml::Value::Boolean(false));
+    t.insert("request_set".into(), toml::Value::Array(vec![]));
+    t.insert("request_remove".into(), toml::Value::Array(vec![]));
+    t.insert("response_set".into(), toml::Value::Array(vec![]));
+    t.insert("response_remove".into(), toml::Value::Array(vec![]));
+    t
+}
+
+pub fn register(ctx: &mut super::ModuleContext) {
+    if !h::is_enabled(ctx.config, "headers") { return; }
+    let rules = Headers {
+        req_set: set_rules(h::config_vec_str(ctx.config, "headers", "request_set")),
+        req_remove: h::config_vec_str(ctx.config, "headers", "request_remove"),
+        resp_set: set_rules(h::config_vec_str(ctx.config, "headers", "response_set")),
+        resp_remove: h::config_vec_str(ctx.config, "headers", "response_remove"),
+    };
+    if rules.req_set.is_empty() && rules.req_remove.is_empty() && rules.resp_set.is_empty() && rules.resp_remove.is_empty() {
+        return;
+    }
+    ctx.pipeline.add(Box::new(rules));
+}
+
+/// Parses "Name: value" entries; malformed ones are logged and skipped.
+fn set_rules(entries: Vec<String>) -> Vec<(String, String)> {
+    entries.iter().filter_map(|e| {
+        match e.split_once(':') {
+            Some((name, value)) if !name.trim().is_empty() => Some((name.trim().to_string(), value.trim().to_string())),
+            _ => {
+                crate::log::warn(&format!("headers: ignoring rule '{e}', expected 'Name: value'"));
+                None
+            }
+        }
+    }).collect()
+}
+
+struct Headers {
+    req_set: Vec<(String, String)>,
+    req_remove: Vec<String>,
+    resp_set: Vec<(String, String)>,
+    resp_remove: Vec<String>,
+}
+
+impl Module for Headers {
+    fn name(&self) -> &str { "headers" }
+    fn handle(&self, r: &mut HttpRequest, _: &mut Context) -> Option<HttpResponse> {
+        r.headers.retain(|(k, _)| !self.req_remove.iter().any(|n| n.eq_ignore_ascii_case(k)));
+        for (n, v) in &self.req_set {
+            r.set_header(n, v);
+        }
+        None
+    }
+    fn on_response(&self, _req: &HttpRequest, resp: &mut HttpResponse, _ctx: &mut Context) {
+        resp.headers.retain(|(k, _)| !self.resp_remove.iter().any(|n| n.eq_ignore_ascii_case(k)));
+        for (n, v) in &self.resp_set {
+            resp.set_header(n, v);
+        }
+    }
+}
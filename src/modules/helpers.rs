@@ -2,6 +2,7 @@
 use crate::context::Context;
 use crate::http::HttpResponse;
 use std::collections::HashMap;
+use std::net::IpAddr;
 
 pub fn is_enabled(c: &HashMap<String, toml::Value>, m: &str) -> bool {
     config_bool(c, m, "enabled", true)
@@ -33,6 +34,99 @@ pub fn config_vec_str(c: &HashMap<String, toml::Value>, m: &str, k: &str) -> Vec
     }).unwrap_or_default()
 }
 
+/// Reads a secret such as an API key from a file named in the config,
+/// relative to config.toml. A blank file is an error like an unreadable
+/// one, since an empty secret would switch the protection off.
+pub fn read_secret_file(file: &str) -> Result<String, String> {
+    let path = crate::config::resolve_path(file);
+    let secret = std::fs::read_to_string(&path).map_err(|e| format!("can't read {}: {e}", path.display()))?;
+    let secret = secret.trim();
+    if secret.is_empty() {
+        return Err(format!("{} is empty", path.display()));
+    }
+    Ok(secret.to_string())
+}
+
+/// The value of header `name` (case-insensitive) in a raw HTTP request.
+pub fn extract_header<'a>(raw: &'a str, name: &str) -> Option<&'a str> {
+    for line in raw.lines().skip(1) {
+        if let Some((k, v)) = line.split_once(':') {
+            if k.trim().eq_ignore_ascii_case(name) {
+                return Some(v.trim());
+            }
+        }
+    }
+    None
+}
+
+/// The key a client sent: X-API-Key, or a standard `Authorization: Bearer`
+/// token for clients behind auth middleware that only speaks that.
+pub fn provided_key(raw: &str) -> &str {
+    if let Some(k) = extract_header(raw, "X-API-Key") {
+        return k;
+    }
+    match extract_header(raw, "Authorization").and_then(|v| v.split_once(' ')) {
+        Some((scheme, token)) if scheme.eq_ignore_ascii_case("bearer") => token.trim(),
+        _ => "",
+    }
+}
+
+/// Pauses or resumes a loaded module for the admin API, returning the status
+/// and JSON body. proxy_core is refused since every request goes through it.
+pub fn pause_module(name: &str, pause: bool) -> (u16, String) {
+    if name == "proxy_core" {
+        return (400, r#"{"error":"proxy_core forwards every request and can't be paused"}"#.into());
+    }
+    let quoted = crate::log::json_escape(name);
+    if !crate::metrics::set_module_paused(name, pause) {
+        return (404, format!(r#"{{"error":"module '{quoted}' is not loaded"}}"#));
+    }
+    let action = if pause { "paused" } else { "resumed" };
+    crate::log::info(&format!("Module {name} {action} via admin API"));
+    (200, format!(r#"{{"module":"{quoted}","paused":{pause}}}"#))
+}
+
+/// One allowlist entry: a single address or a CIDR network.
+pub struct IpNet {
+    addr: IpAddr,
+    prefix: u8,
+}
+
+impl IpNet {
+    /// Parses "10.0.0.5", "10.0.0.0/8" or "fd00::/8".
+    pub fn parse(s: &str) -> Option<IpNet> {
+        let s = s.trim();
+        let (ip, prefix) = match s.split_once('/') {
+            Some((ip, p)) => (ip, Some(p.parse::<u8>().ok()?)),
+            None => (s, None),
+        };
+        let addr: IpAddr = ip.parse().ok()?;
+        let max = if addr.is_ipv4() { 32 } else { 128 };
+        let prefix = prefix.unwrap_or(max);
+        if prefix > max { return None; }
+        Some(IpNet { addr, prefix })
+    }
+
+    /// IPv4-mapped IPv6 peers (::ffff:a.b.c.d) match IPv4 entries.
+    pub fn contains(&self, ip: IpAddr) -> bool {
+        let ip = match ip {
+            IpAddr::V6(v6) => v6.to_ipv4_mapped().map(IpAddr::V4).unwrap_or(ip),
+            v4 => v4,
+        };
+        match (self.addr, ip) {
+            (IpAddr::V4(net), IpAddr::V4(ip)) => {
+                let mask = u32::MAX.checked_shl(32 - self.prefix as u32).unwrap_or(0);
+                u32::from(net) & mask == u32::from(ip) & mask
+            }
+            (IpAddr::V6(net), IpAddr::V6(ip)) => {
+                let mask = u128::MAX.checked_shl(128 - self.prefix as u32).unwrap_or(0);
+                u128::from(net) & mask == u128::from(ip) & mask
+            }
+            _ => false,
+        }
+    }
+}
+
 pub fn client_ip(c: &Context) -> String {
     c.get("_client_ip").unwrap_or("?").to_string()
 }
@@ -4,6 +4,7 @@ mod admin_api;
 mod cache;
 mod circuit_breaker;
 mod compression;
+mod headers;
 mod health_check;
 mod load_balancer;
 mod metrics_exporter;
@@ -18,7 +19,10 @@ pub mod helpers;
 use crate::config::Srv;
 use crate::context::Context;
 use crate::http::{HttpRequest, HttpResponse};
+use crate::metrics::ModuleTiming;
 use std::collections::{HashMap, HashSet};
+use std::sync::Arc;
+use std::time::Instant;
 
 pub trait Module: Send + Sync {
     fn name(&self) -> &str;
@@ -51,15 +55,24 @@ fn default_priority(name: &str) -> i32 {
 }
 
 pub struct Pipeline {
-    mods: Vec<(i32, Box<dyn Module>)>,
+    mods: Vec<(i32, Box<dyn Module>, Arc<ModuleTiming>)>,
     raw: Option<Box<dyn RawHandler>>,
     overridden: HashSet<String>,
+    priorities: HashMap<String, i32>,
     to: u64,
 }
 
 impl Pipeline {
     pub fn new(t: u64) -> Self {
-        Pipeline { mods: Vec::new(), raw: None, overridden: HashSet::new(), to: t }
+        Pipeline { mods: Vec::new(), raw: None, overridden: HashSet::new(), priorities: HashMap::new(), to: t }
+    }
+    /// Apply `priority = N` from [modules.<name>] over built-in/script priorities
+    pub fn load_priority_overrides(&mut self, mc: &HashMap<String, toml::Value>) {
+        for (name, v) in mc {
+            if let Some(p) = v.get("priority").and_then(|p| p.as_integer()).and_then(|p| i32::try_from(p).ok()) {
+                self.priorities.insert(name.clone(), p);
+            }
+        }
     }
     pub fn add(&mut self, m: Box<dyn Module>) {
         let p = default_priority(m.name());
@@ -67,6 +80,7 @@ impl Pipeline {
     }
     pub fn add_with_priority(&mut self, m: Box<dyn Module>, priority: i32) {
         let name = m.name().to_string();
+        let priority = self.priorities.get(&name).copied().unwrap_or(priority);
         if self.overridden.contains(&name) {
             crate::log::module_skipped(&name);
             return;
@@ -75,11 +89,12 @@ impl Pipeline {
             self.override_module(o);
         }
         crate::log::module_loaded(&name);
-        self.mods.push((priority, m));
+        let timing = crate::metrics::module_timing(&name);
+        self.mods.push((priority, m, timing));
     }
     pub fn override_module(&mut self, name: &str) {
         self.overridden.insert(name.to_string());
-        self.mods.retain(|(_, m)| m.name() != name);
+        self.mods.retain(|(_, m, _)| m.name() != name);
     }
     pub fn set_raw_handler(&mut self, h: Box<dyn RawHandler>) {
         crate::log::module_loaded("raw connection handler");
@@ -90,30 +105,41 @@ impl Pipeline {
     }
     /// Sort modules by priority (call after all registration is done)
     pub fn sort(&mut self) {
-        self.mods.sort_by_key(|(p, _)| *p);
+        self.mods.sort_by_key(|(p, _, _)| *p);
     }
     /// Check if a module with the given name is already loaded
     pub fn has_module(&self, name: &str) -> bool {
-        self.mods.iter().any(|(_, m)| m.name() == name)
+        self.mods.iter().any(|(_, m, _)| m.name() == name)
     }
     /// Get names of all loaded modules
     #[allow(dead_code)]
     pub fn module_names(&self) -> Vec<String> {
-        self.mods.iter().map(|(_, m)| m.name().to_string()).collect()
+        self.mods.iter().map(|(_, m, _)| m.name().to_string()).collect()
     }
     pub fn handle(&self, r: &mut HttpRequest, c: &mut Context) -> HttpResponse {
         let mut resp_idx = None;
         let mut resp = HttpResponse::error(500, "No handler");
-        for (i, (_, m)) in self.mods.iter().enumerate() {
-            if let Some(r) = m.handle(r, c) {
+        for (i, (_, m, t)) in self.mods.iter().enumerate() {
+            if t.is_paused() {
+                continue;
+            }
+            let start = Instant::now();
+            let out = m.handle(r, c);
+            t.record_handle(start.elapsed());
+            if let Some(r) = out {
                 resp = r;
                 resp_idx = Some(i);
                 break;
             }
         }
         let limit = resp_idx.map(|i| i + 1).unwrap_or(self.mods.len());
-        for (_, m) in self.mods[..limit].iter().rev() {
+        for (_, m, t) in self.mods[..limit].iter().rev() {
+            if t.is_paused() {
+                continue;
+            }
+            let start = Instant::now();
             m.on_response(r, &mut resp, c);
+            t.record_response(start.elapsed());
         }
         resp
     }
@@ -127,6 +153,7 @@ pub struct ModuleContext<'a> {
 }
 
 pub fn register_all(p: &mut Pipeline, mc: &HashMap<String, toml::Value>, sc: &Srv) {
+    p.load_priority_overrides(mc);
     let mut ctx = ModuleContext { pipeline: p, config: mc, server: sc };
     active_health::register(&mut ctx);
     request_id::register(&mut ctx);
@@ -141,6 +168,7 @@ pub fn register_all(p: &mut Pipeline, mc: &HashMap<String, toml::Value>, sc: &Sr
     load_balancer::register(&mut ctx);
     proxy_core::register(&mut ctx);
     raw_tcp::register(&mut ctx);
+    headers::register(&mut ctx);
 }
 
 pub fn collect_defaults() -> HashMap<String, toml::Value> {
@@ -150,6 +178,7 @@ pub fn collect_defaults() -> HashMap<String, toml::Value> {
     d.insert("cache".into(), toml::Value::Table(cache::default_config()));
     d.insert("circuit_breaker".into(), toml::Value::Table(circuit_breaker::default_config()));
     d.insert("compression".into(), toml::Value::Table(compression::default_config()));
+    d.insert("headers".into(), toml::Value::Table(headers::default_config()));
     d.insert("health_check".into(), toml::Value::Table(health_check::default_config()));
     d.insert("load_balancer".into(), toml::Value::Table(load_balancer::default_config()));
     d.insert("metrics_exporter".into(), toml::Value::Table(metrics_exporter::default_config()));
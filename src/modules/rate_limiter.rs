@@ -74,6 +74,7 @@ impl Module for RateLimit {
             b.tokens -= 1.0;
             None
         } else {
+            crate::metrics::inc_rl_rejects();
             Some(HttpResponse::error(429, "Rate limit"))
         }
     }
@@ -31,6 +31,7 @@ impl ConnPool {
     }
 
     pub fn get(&self, addr: &SocketAddr, timeout: Duration) -> std::io::Result<TcpStream> {
+        let wait = crate::metrics::PoolWait::start();
         let mut map = match self.idle.lock() {
             Ok(g) => g,
             Err(poisoned) => {
@@ -76,6 +77,8 @@ impl ConnPool {
             }
         }
         drop(map);
+        // Dialing is connect time, not time spent waiting on the pool
+        drop(wait);
 
         crate::metrics::inc_pool_misses();
         let stream = TcpStream::connect_timeout(addr, timeout)?;
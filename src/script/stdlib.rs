@@ -141,6 +141,7 @@ fn std_rate_limit(args: &[String], ctx: &Context) -> Option<HttpResponse> {
         b.tokens -= 1.0;
         None
     } else {
+        crate::metrics::inc_rl_rejects();
         Some(HttpResponse::error(429, "Rate limit"))
     }
 }
@@ -160,6 +161,7 @@ fn std_cache_check(args: &[String], req: &HttpRequest) -> Option<HttpResponse> {
             if let Some(tag) = req.get_header("If-None-Match") {
                 if let Some(etag) = e.resp.get_header("ETag") {
                     if tag == etag {
+                        crate::metrics::inc_cache_hits();
                         return Some(HttpResponse {
                             version: "HTTP/1.1".to_string(),
                             status_code: 304,
@@ -172,11 +174,13 @@ fn std_cache_check(args: &[String], req: &HttpRequest) -> Option<HttpResponse> {
             }
             let mut cached = e.resp.clone();
             cached.headers.push(("X-Cache".to_string(), "HIT".to_string()));
+            crate::metrics::inc_cache_hits();
             return Some(cached);
         } else {
             m.remove(&key);
         }
     }
+    crate::metrics::inc_cache_misses();
     None
 }
 
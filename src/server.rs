@@ -551,9 +551,10 @@ fn build_tls_assets(cfg: &Srv) -> Option<TlsAssets> {
     let certs = load_certs(&cfg.tls_cert)?;
     let key = load_private_key(&cfg.tls_key)?;
 
+    let ocsp = load_ocsp(&cfg.tls_ocsp);
     let mut config = match rustls::ServerConfig::builder()
         .with_no_client_auth()
-        .with_single_cert(certs.clone(), key.clone_key())
+        .with_single_cert_with_ocsp(certs.clone(), key.clone_key(), ocsp)
     {
         Ok(c) => c,
         Err(e) => {
@@ -578,6 +579,21 @@ fn build_tls_assets(cfg: &Srv) -> Option<TlsAssets> {
     })
 }
 
+/// Reads the OCSP response to staple. Empty means no stapling; the file is
+/// read once per TLS setup, so a refreshed response needs a restart.
+fn load_ocsp(path: &str) -> Vec<u8> {
+    if path.is_empty() {
+        return Vec::new();
+    }
+    match std::fs::read(path) {
+        Ok(der) => der,
+        Err(e) => {
+            crate::log::warn(&format!("Cannot read OCSP response {path}: {e}"));
+            Vec::new()
+        }
+    }
+}
+
 fn load_certs(path: &str) -> Option<Vec<rustls::pki_types::CertificateDer<'static>>> {
     let file = match std::fs::File::open(path) {
         Ok(f) => f,
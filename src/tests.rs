@@ -679,6 +679,91 @@ mod pipeline_tests {
         let pipe = Pipeline::new(42);
         assert_eq!(pipe.timeout(), 42);
     }
+
+    #[test]
+    fn pipeline_records_module_timing() {
+        let mut pipe = Pipeline::new(30);
+        pipe.add_with_priority(Box::new(PassthroughModule { name: "timed_probe".into() }), 10);
+        pipe.add_with_priority(Box::new(EchoModule), 20);
+        pipe.sort();
+        let mut req = super::make_req("GET", "/");
+        let mut ctx = super::make_ctx();
+        pipe.handle(&mut req, &mut ctx);
+        let json = crate::metrics::module_timings_json();
+        assert!(json.contains(r#"{"name":"timed_probe","requests":1,"#), "{json}");
+    }
+
+    #[test]
+    fn module_timings_escape_script_names() {
+        crate::metrics::module_timing("t\u{e9}mps\\probe");
+        let json = crate::metrics::module_timings_json();
+        assert!(json.contains(r#"{"name":"témps\\probe","requests":0,"#), "{json}");
+    }
+
+    #[test]
+    fn paused_module_is_skipped_until_resumed() {
+        let mut pipe = Pipeline::new(30);
+        pipe.add_with_priority(Box::new(PassthroughModule { name: "pause_probe".into() }), 10);
+        pipe.add_with_priority(Box::new(EchoModule), 20);
+        pipe.sort();
+
+        assert!(crate::metrics::set_module_paused("pause_probe", true));
+        assert!(crate::metrics::paused_modules().contains(&"pause_probe".to_string()));
+        let mut req = super::make_req("GET", "/");
+        let mut ctx = super::make_ctx();
+        let resp = pipe.handle(&mut req, &mut ctx);
+        assert_eq!(resp.status_code, 200);
+        assert!(ctx.get("_visited_pause_probe").is_none());
+
+        assert!(crate::metrics::set_module_paused("pause_probe", false));
+        assert!(!crate::metrics::paused_modules().contains(&"pause_probe".to_string()));
+        let mut ctx = super::make_ctx();
+        pipe.handle(&mut req, &mut ctx);
+        assert_eq!(ctx.get("_visited_pause_probe"), Some("1"));
+    }
+
+    #[test]
+    fn config_priority_overrides_registration_priority() {
+        let mut mc = std::collections::HashMap::new();
+        let table = |p: toml::Value| {
+            let mut t = toml::Table::new();
+            t.insert("priority".into(), p);
+            toml::Value::Table(t)
+        };
+        mc.insert("echo".to_string(), table(toml::Value::Integer(5)));
+        mc.insert("second".to_string(), table(toml::Value::String("1".into())));
+        mc.insert("third".to_string(), table(toml::Value::Integer(i64::MAX)));
+
+        let mut pipe = Pipeline::new(30);
+        pipe.load_priority_overrides(&mc);
+        pipe.add_with_priority(Box::new(PassthroughModule { name: "first".into() }), 10);
+        pipe.add_with_priority(Box::new(PassthroughModule { name: "second".into() }), 12);
+        pipe.add_with_priority(Box::new(PassthroughModule { name: "third".into() }), 14);
+        pipe.add_with_priority(Box::new(EchoModule), 20);
+        pipe.sort();
+        // only a valid integer overrides; a string or out-of-range value is ignored
+        assert_eq!(pipe.module_names(), vec!["echo", "first", "second", "third"]);
+    }
+
+    #[test]
+    fn pausing_unloaded_module_fails() {
+        assert!(!crate::metrics::set_module_paused("never_loaded_probe", true));
+    }
+
+    #[test]
+    fn pause_responses_escape_the_module_name() {
+        let name = "caf\u{e9}\"q\\";
+        let (code, body) = crate::modules::helpers::pause_module(name, true);
+        assert_eq!(code, 404);
+        assert_eq!(body, r#"{"error":"module 'café\"q\\' is not loaded"}"#);
+
+        crate::metrics::module_timing(name);
+        let (code, body) = crate::modules::helpers::pause_module(name, true);
+        assert_eq!(code, 200);
+        assert_eq!(body, r#"{"module":"café\"q\\","paused":true}"#);
+        assert_eq!(crate::modules::helpers::pause_module(name, false).0, 200);
+        assert_eq!(crate::modules::helpers::pause_module("proxy_core", true).0, 400);
+    }
 }
 
 // ═══════════════════════════════════════════════════════════════════════════
@@ -775,6 +860,13 @@ mod config_tests {
         assert!(!cfg.validate());
     }
 
+    #[test]
+    fn validate_missing_ocsp_only_warns() {
+        let mut cfg = Srv::default();
+        cfg.tls_ocsp = "/nonexistent/ocsp.der".to_string();
+        assert!(cfg.validate());
+    }
+
     #[test]
     fn config_clone() {
         let cfg = Srv::default();
@@ -782,6 +874,119 @@ mod config_tests {
         assert_eq!(clone.listen_addr, cfg.listen_addr);
         assert_eq!(clone.buffer_size, cfg.buffer_size);
     }
+
+    #[test]
+    fn unknown_keys_found() {
+        let mut cache = toml::Table::new();
+        cache.insert("enabled".into(), toml::Value::Boolean(false));
+        cache.insert("ttl_seconds".into(), toml::Value::Integer(300));
+        let mut known = std::collections::HashMap::new();
+        known.insert("cache".to_string(), toml::Value::Table(cache));
+        known.insert("admin_api".to_string(), toml::Value::Table(toml::Table::new()));
+
+        let txt = "include = []\ntypo = 1\n[server]\nlisten_addr = \"127.0.0.1:3000\"\nlisten_adr = \"x\"\n\
+                   [modules.cache]\nenabled = true\nttl_second = 5\n[modules.admin_api]\napi_key_file = \"k\"\n\
+                   [modules.nope]\nenabled = true\n";
+        assert_eq!(
+            crate::config::find_unknown_keys(txt, Some(&known)),
+            vec!["modules.cache.ttl_second", "modules.nope", "server.listen_adr", "typo"]
+        );
+        assert_eq!(crate::config::find_unknown_keys(txt, None), vec!["server.listen_adr", "typo"]);
+    }
+
+    #[test]
+    fn priority_is_known_for_every_module() {
+        let mut known = std::collections::HashMap::new();
+        known.insert("cache".to_string(), toml::Value::Table(toml::Table::new()));
+        known.insert("compression".to_string(), toml::Value::Table(toml::Table::new()));
+        let txt = "[modules.cache]\npriority = 5\n[modules.compression]\nenabled = true\npriority = 95\nprio = 1\n";
+        assert_eq!(crate::config::find_unknown_keys(txt, Some(&known)), vec!["modules.compression.prio"]);
+    }
+
+    #[test]
+    fn content_hash_is_fnv1a64() {
+        // the CLI's configHash must agree on these
+        assert_eq!(crate::config::content_hash(b""), "cbf29ce484222325");
+        assert_eq!(crate::config::content_hash(b"a"), "af63dc4c8601ec8c");
+    }
+
+    #[test]
+    fn sources_hash_covers_includes_in_order() {
+        let dir = std::env::temp_dir().join(format!("proxycache-hash-{}", std::process::id()));
+        std::fs::create_dir_all(&dir).unwrap();
+        let (cfg, a, b) = (dir.join("config.toml"), dir.join("a.toml"), dir.join("b.toml"));
+        std::fs::write(&cfg, "include = [\"*.toml\"]\n").unwrap();
+        std::fs::write(&a, "[modules.a]\n").unwrap();
+        std::fs::write(&b, "[modules.b]\n").unwrap();
+        let cfg = cfg.to_str().unwrap();
+
+        let hash = crate::config::sources_hash(cfg, &[a.clone(), b.clone()]);
+        assert_eq!(hash, crate::config::content_hash(b"include = [\"*.toml\"]\n[modules.a]\n[modules.b]\n"));
+        assert_ne!(hash, crate::config::sources_hash(cfg, &[b.clone(), a.clone()]));
+        assert_ne!(hash, crate::config::sources_hash(cfg, &[]));
+        std::fs::write(&b, "[modules.b]\nenabled = false\n").unwrap();
+        assert_ne!(hash, crate::config::sources_hash(cfg, &[a.clone(), b.clone()]));
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn wildcard_match_star_and_question_mark() {
+        use crate::config::wildcard_match;
+        assert!(wildcard_match("*.toml", "cache.toml"));
+        assert!(wildcard_match("*.toml", ".toml"));
+        assert!(!wildcard_match("*.toml", "cache.tom"));
+        assert!(wildcard_match("mod?.toml", "mod1.toml"));
+        assert!(!wildcard_match("mod?.toml", "mod12.toml"));
+        assert!(wildcard_match("a*b*c", "aXbYbZc"));
+        assert!(!wildcard_match("a*b*c", "aXbY"));
+        assert!(wildcard_match("exact.toml", "exact.toml"));
+    }
+
+    #[test]
+    fn expand_glob_sorts_matches_and_skips_dirs() {
+        let dir = std::env::temp_dir().join(format!("proxycache-glob-{}", std::process::id()));
+        std::fs::create_dir_all(dir.join("d").join("sub.toml")).unwrap();
+        for f in ["b.toml", "a.toml", "notes.txt"] {
+            std::fs::write(dir.join("d").join(f), "").unwrap();
+        }
+        let names = |pattern: &str| -> Vec<String> {
+            crate::config::expand_glob(&dir, pattern)
+                .iter()
+                .map(|p| p.file_name().unwrap().to_string_lossy().into_owned())
+                .collect()
+        };
+        assert_eq!(names("d/*.toml"), vec!["a.toml", "b.toml"]);
+        assert_eq!(names("d/?.toml"), vec!["a.toml", "b.toml"]);
+        assert_eq!(names("d/b.toml"), vec!["b.toml"]);
+        assert!(names("d/missing.toml").is_empty());
+        assert!(names("nowhere/*.toml").is_empty());
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn merge_includes_config_wins_then_first_file() {
+        let dir = std::env::temp_dir().join(format!("proxycache-include-{}", std::process::id()));
+        std::fs::create_dir_all(dir.join("modules.d")).unwrap();
+        let cfg_path = dir.join("config.toml");
+        std::fs::write(dir.join("modules.d").join("a.toml"), "[modules.cache]\nttl_seconds = 2\n[modules.extra]\nsource = \"a\"\n").unwrap();
+        std::fs::write(dir.join("modules.d").join("b.toml"), "[modules.extra]\nsource = \"b\"\n[modules.more]\nenabled = true\n").unwrap();
+
+        let mut cfg: crate::config::Config = toml::from_str(
+            "include = [\"modules.d/*.toml\", \"missing.toml\"]\n[modules.cache]\nttl_seconds = 1\n",
+        )
+        .unwrap();
+        crate::config::merge_includes(&mut cfg, cfg_path.to_str().unwrap());
+
+        let get = |m: &str, k: &str| cfg.modules.get(m).and_then(|v| v.get(k)).cloned();
+        assert_eq!(get("cache", "ttl_seconds"), Some(toml::Value::Integer(1)));
+        assert_eq!(get("extra", "source"), Some(toml::Value::String("a".into())));
+        assert_eq!(get("more", "enabled"), Some(toml::Value::Boolean(true)));
+        let mut included: Vec<_> = cfg.included.iter().cloned().collect();
+        included.sort();
+        assert_eq!(included, vec!["extra", "more"]);
+        assert_eq!(cfg.include_files, vec![dir.join("modules.d").join("a.toml"), dir.join("modules.d").join("b.toml")]);
+        let _ = std::fs::remove_dir_all(&dir);
+    }
 }
 
 // ═══════════════════════════════════════════════════════════════════════════
@@ -864,7 +1069,8 @@ mod metrics_tests {
             requests_total: 0, requests_ok: 0, requests_err: 0,
             bytes_in: 0, bytes_out: 0, latency_sum_ms: 0, latency_max_ms: 0,
             connections_total: 0, active_connections: 0,
-            pool_hits: 0, pool_misses: 0, cb_trips: 0, cb_rejects: 0, uptime_secs: 0,
+            pool_hits: 0, pool_misses: 0, pool_waiting: 0, pool_acquires: 0, pool_wait_us_sum: 0, cb_trips: 0, cb_rejects: 0,
+            rl_rejects: 0, cache_hits: 0, cache_misses: 0, uptime_secs: 0,
         };
         assert_eq!(snap.avg_latency_ms(), 0);
     }
@@ -875,7 +1081,8 @@ mod metrics_tests {
             requests_total: 10, requests_ok: 10, requests_err: 0,
             bytes_in: 0, bytes_out: 0, latency_sum_ms: 500, latency_max_ms: 100,
             connections_total: 10, active_connections: 0,
-            pool_hits: 0, pool_misses: 0, cb_trips: 0, cb_rejects: 0, uptime_secs: 0,
+            pool_hits: 0, pool_misses: 0, pool_waiting: 0, pool_acquires: 0, pool_wait_us_sum: 0, cb_trips: 0, cb_rejects: 0,
+            rl_rejects: 0, cache_hits: 0, cache_misses: 0, uptime_secs: 0,
         };
         assert_eq!(snap.avg_latency_ms(), 50);
     }
@@ -889,7 +1096,10 @@ mod metrics_tests {
         assert!(output.contains("proxycache_bytes_out"));
         assert!(output.contains("proxycache_latency_max_ms"));
         assert!(output.contains("proxycache_pool_hits"));
+        assert!(output.contains("proxycache_pool_waiting"));
         assert!(output.contains("proxycache_circuit_breaker_trips"));
+        assert!(output.contains("proxycache_rate_limiter_rejects"));
+        assert!(output.contains("proxycache_cache_hits"));
     }
 
     #[test]
@@ -899,6 +1109,7 @@ mod metrics_tests {
         assert!(output.ends_with('}'));
         assert!(output.contains("\"requests_total\""));
         assert!(output.contains("\"latency_avg_ms\""));
+        assert!(output.contains(r#""sources":{"circuit_breaker_trips":"circuit_breaker","#));
     }
 }
 
@@ -1005,6 +1216,7 @@ mod module_rate_limiter_tests {
     #[test]
     fn rate_limiter_blocks_over_burst() {
         let pipe = build_rate_limiter_pipeline(1, 3);
+        let before = crate::metrics::snapshot().rl_rejects;
         // Drain burst
         for _ in 0..3 {
             let mut req = super::make_req("GET", "/health");
@@ -1016,6 +1228,7 @@ mod module_rate_limiter_tests {
         let mut ctx = super::make_ctx();
         let resp = pipe.handle(&mut req, &mut ctx);
         assert_eq!(resp.status_code, 429);
+        assert!(crate::metrics::snapshot().rl_rejects > before);
     }
 
     #[test]
@@ -1107,6 +1320,7 @@ mod module_cache_tests {
     #[test]
     fn cache_miss_then_hit() {
         let (pipe, counter) = build_cache_pipeline(300, 100, "hello");
+        let before = crate::metrics::snapshot();
         // First request: miss
         let mut req = super::make_req("GET", "/page");
         let mut ctx = super::make_ctx();
@@ -1121,6 +1335,9 @@ mod module_cache_tests {
         assert_eq!(resp.status_code, 200);
         assert_eq!(resp.get_header("X-Cache"), Some("HIT"));
         assert_eq!(counter.load(std::sync::atomic::Ordering::Relaxed), 1);
+        let after = crate::metrics::snapshot();
+        assert!(after.cache_misses > before.cache_misses);
+        assert!(after.cache_hits > before.cache_hits);
     }
 
     #[test]
@@ -1648,6 +1865,68 @@ mod module_url_rewriter_tests {
     }
 }
 
+#[cfg(test)]
+mod module_headers_tests {
+    use crate::modules::Pipeline;
+
+    fn build_headers_pipeline(rules: &[(&str, &[&str])]) -> Pipeline {
+        let mut mc = std::collections::HashMap::new();
+        let mut ht = toml::Table::new();
+        ht.insert("enabled".into(), toml::Value::Boolean(true));
+        for (key, list) in rules {
+            let arr = list.iter().map(|s| toml::Value::String(s.to_string())).collect();
+            ht.insert(key.to_string(), toml::Value::Array(arr));
+        }
+        mc.insert("headers".into(), toml::Value::Table(ht));
+        for name in &["active_health","admin_api","cache","circuit_breaker","compression",
+                       "health_check","load_balancer","metrics_exporter","proxy_core",
+                       "rate_limiter","raw_tcp","request_id","url_rewriter"] {
+            let mut t = toml::Table::new();
+            t.insert("enabled".into(), toml::Value::Boolean(false));
+            mc.insert(name.to_string(), toml::Value::Table(t));
+        }
+        let srv = crate::config::Srv::default();
+        let mut pipe = Pipeline::new(30);
+        crate::modules::register_all(&mut pipe, &mc, &srv);
+        pipe.sort();
+        pipe
+    }
+
+    #[test]
+    fn sets_and_removes_request_headers() {
+        let pipe = build_headers_pipeline(&[
+            ("request_set", &["X-Foo: bar", "X-Forwarded-Proto:https", "bogus"]),
+            ("request_remove", &["cookie"]),
+        ]);
+        let mut req = super::make_req_with_headers("GET", "/", &[("X-Foo", "old"), ("Cookie", "a=1")]);
+        let mut ctx = super::make_ctx();
+        pipe.handle(&mut req, &mut ctx);
+        assert_eq!(req.get_header("X-Foo"), Some("bar"));
+        assert_eq!(req.get_header("X-Forwarded-Proto"), Some("https"));
+        assert_eq!(req.get_header("Cookie"), None);
+        assert_eq!(req.get_header("bogus"), None);
+    }
+
+    #[test]
+    fn sets_and_removes_response_headers() {
+        let pipe = build_headers_pipeline(&[
+            ("response_set", &["Strict-Transport-Security: max-age=31536000"]),
+            ("response_remove", &["Content-Type"]),
+        ]);
+        let mut req = super::make_req("GET", "/");
+        let mut ctx = super::make_ctx();
+        let resp = pipe.handle(&mut req, &mut ctx);
+        assert_eq!(resp.get_header("Strict-Transport-Security"), Some("max-age=31536000"));
+        assert_eq!(resp.get_header("Content-Type"), None);
+    }
+
+    #[test]
+    fn no_rules_adds_no_module() {
+        let pipe = build_headers_pipeline(&[]);
+        assert!(!pipe.has_module("headers"));
+    }
+}
+
 #[cfg(test)]
 mod module_metrics_exporter_tests {
     use crate::modules::Pipeline;
@@ -2330,6 +2609,72 @@ mod helpers_tests {
         let ctx = crate::context::Context::new();
         assert_eq!(helpers::client_ip(&ctx), "?");
     }
+
+    #[test]
+    fn ip_net_matches_cidr_and_single_address() {
+        let net = helpers::IpNet::parse("10.0.0.0/8").unwrap();
+        assert!(net.contains("10.1.2.3".parse().unwrap()));
+        assert!(net.contains("::ffff:10.0.0.1".parse().unwrap()));
+        assert!(!net.contains("11.0.0.1".parse().unwrap()));
+        let one = helpers::IpNet::parse("127.0.0.1").unwrap();
+        assert!(one.contains("127.0.0.1".parse().unwrap()));
+        assert!(!one.contains("127.0.0.2".parse().unwrap()));
+        let v6 = helpers::IpNet::parse("fd00::/8").unwrap();
+        assert!(v6.contains("fd12::1".parse().unwrap()));
+        assert!(!v6.contains("10.1.2.3".parse().unwrap()));
+    }
+
+    #[test]
+    fn ip_net_rejects_malformed_entries() {
+        for bad in &["", "nope", "10.0.0.0/33", "::1/129", "10.0.0.0/x"] {
+            assert!(helpers::IpNet::parse(bad).is_none(), "{bad} should not parse");
+        }
+    }
+
+    #[test]
+    fn secret_file_is_trimmed_and_must_not_be_blank() {
+        let dir = std::env::temp_dir().join(format!("proxycache-secret-{}", std::process::id()));
+        std::fs::create_dir_all(&dir).unwrap();
+        let key = dir.join("key");
+        let blank = dir.join("blank");
+        std::fs::write(&key, "s3cret\n").unwrap();
+        std::fs::write(&blank, " \n\t\n").unwrap();
+        assert_eq!(helpers::read_secret_file(key.to_str().unwrap()), Ok("s3cret".to_string()));
+        assert!(helpers::read_secret_file(blank.to_str().unwrap()).unwrap_err().contains("is empty"));
+        assert!(helpers::read_secret_file(dir.join("missing").to_str().unwrap()).is_err());
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn config_paths_resolve_against_the_config_dir() {
+        // Tests run without --config, so config.toml sits in the working directory
+        assert_eq!(crate::config::resolve_path("admin.key"), std::path::PathBuf::from("admin.key"));
+        let abs = std::env::temp_dir().join("admin.key");
+        assert_eq!(crate::config::resolve_path(abs.to_str().unwrap()), abs);
+    }
+
+    #[test]
+    fn json_escape_yields_valid_json_strings() {
+        use crate::log::json_escape;
+        assert_eq!(json_escape("plain"), "plain");
+        assert_eq!(json_escape("a\"b\\c"), "a\\\"b\\\\c");
+        assert_eq!(json_escape("x\ny\tz\r"), "x\\ny\\tz\\r");
+        // Debug formatting would give \u{1} and \0 here, which JSON rejects
+        assert_eq!(json_escape("\u{1}\0"), "\\u0001\\u0000");
+        assert_eq!(json_escape("é\u{200b}"), "é\u{200b}");
+    }
+
+    #[test]
+    fn provided_key_reads_api_key_or_bearer() {
+        let req = |headers: &str| format!("GET /status HTTP/1.1\r\nHost: x\r\n{headers}\r\n");
+        assert_eq!(helpers::provided_key(&req("X-API-Key: k1\r\n")), "k1");
+        assert_eq!(helpers::provided_key(&req("Authorization: Bearer k2\r\n")), "k2");
+        assert_eq!(helpers::provided_key(&req("authorization: bEaReR  k3 \r\n")), "k3");
+        assert_eq!(helpers::provided_key(&req("Authorization: Basic dTpw\r\n")), "");
+        assert_eq!(helpers::provided_key(&req("Authorization: k4\r\n")), "");
+        assert_eq!(helpers::provided_key(&req("Authorization: Bearer k5\r\nX-API-Key: k6\r\n")), "k6");
+        assert_eq!(helpers::provided_key(&req("")), "");
+    }
 }
 
 // ═══════════════════════════════════════════════════════════════════════════